@@ -0,0 +1,78 @@
+// Package blobstore stores files, such as archived media, in a configurable
+// store.
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Store keeps files under slash-separated keys. Put reads the file from body,
+// which must be size bytes long unless size is negative, and replaces any file
+// stored under the same key. Files are streamed, never held in memory whole.
+type Store interface {
+	Put(ctx context.Context, key, contentType string, body io.Reader, size int64) error
+}
+
+// Store types for MEDIA_ARCHIVE
+const (
+	StoreFS   = "fs"
+	StoreS3   = "s3"
+	StoreNone = "none"
+)
+
+const (
+	defaultDir      = "media"
+	defaultS3Region = "us-east-1"
+)
+
+// NewFromEnv builds the store selected by MEDIA_ARCHIVE:
+//   - none (default): nil, media are not archived
+//   - fs: files under MEDIA_ARCHIVE_DIR
+//   - s3: objects in MEDIA_ARCHIVE_S3_BUCKET at MEDIA_ARCHIVE_S3_ENDPOINT, an
+//     S3-compatible service such as MinIO, signed with MEDIA_ARCHIVE_S3_ACCESS_KEY
+//     and MEDIA_ARCHIVE_S3_SECRET_KEY for MEDIA_ARCHIVE_S3_REGION
+func NewFromEnv() (Store, error) {
+	switch kind := os.Getenv("MEDIA_ARCHIVE"); kind {
+	case "", StoreNone:
+		return nil, nil
+	case StoreFS:
+		return NewFSStore(envOr("MEDIA_ARCHIVE_DIR", defaultDir))
+	case StoreS3:
+		endpoint, bucket := os.Getenv("MEDIA_ARCHIVE_S3_ENDPOINT"), os.Getenv("MEDIA_ARCHIVE_S3_BUCKET")
+		if endpoint == "" || bucket == "" {
+			return nil, fmt.Errorf("MEDIA_ARCHIVE_S3_ENDPOINT and MEDIA_ARCHIVE_S3_BUCKET are required for the %s store", StoreS3)
+		}
+		return NewS3Store(S3Config{
+			Endpoint:  endpoint,
+			Bucket:    bucket,
+			Region:    envOr("MEDIA_ARCHIVE_S3_REGION", defaultS3Region),
+			AccessKey: os.Getenv("MEDIA_ARCHIVE_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("MEDIA_ARCHIVE_S3_SECRET_KEY"),
+		}, nil)
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_ARCHIVE %q", kind)
+	}
+}
+
+// copyBody copies body to w and returns how many bytes it had, failing if
+// size is not negative and the body was not that long
+func copyBody(w io.Writer, body io.Reader, size int64) (int64, error) {
+	n, err := io.Copy(w, body)
+	if err != nil {
+		return n, err
+	}
+	if size >= 0 && n != size {
+		return n, fmt.Errorf("body was %d bytes, want %d", n, size)
+	}
+	return n, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,49 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FSStore keeps files in a directory, each key a path below it
+type FSStore struct {
+	dir string
+}
+
+// NewFSStore creates a store in dir, creating the directory if needed
+func NewFSStore(dir string) (*FSStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSStore{dir: dir}, nil
+}
+
+// Put writes the file. It is written to a temporary file first, so a file
+// under key is always complete.
+func (s *FSStore) Put(ctx context.Context, key, contentType string, body io.Reader, size int64) error {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	path := filepath.Join(s.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := copyBody(tmp, body, size); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
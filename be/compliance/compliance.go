@@ -0,0 +1,94 @@
+// Package compliance checks sponsored posts against disclosure rules.
+package compliance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// Post is what disclosure is judged on: the text as published and the
+// platform's paid-partnership label
+type Post struct {
+	Text            string
+	PaidPartnership bool
+}
+
+// PostFromContent uses the platform text when there is one and the description otherwise
+func PostFromContent(content models.Content) Post {
+	post := Post{PaidPartnership: content.PaidPartnership}
+	if content.OriginalText != nil && *content.OriginalText != "" {
+		post.Text = *content.OriginalText
+	} else if content.Description != nil {
+		post.Text = *content.Description
+	}
+	return post
+}
+
+// Evaluate checks a post against every rule that applies to it. It fails with
+// one reason per unsatisfied rule; a post no rule applies to passes.
+func Evaluate(post Post, rules []models.DisclosureRule) (string, []string) {
+	reasons := []string{}
+	for _, rule := range rules {
+		if reason := check(post, rule); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) > 0 {
+		return models.ComplianceStatusFail, reasons
+	}
+	return models.ComplianceStatusPass, reasons
+}
+
+// check returns why a post does not satisfy a rule, or "" if it does
+func check(post Post, rule models.DisclosureRule) string {
+	if rule.AcceptPaidPartnership && post.PaidPartnership {
+		return ""
+	}
+
+	if len(rule.RequiredHashtags) == 0 {
+		return fmt.Sprintf("%s: missing paid-partnership label", rule.Name)
+	}
+
+	hashtags := make(map[string]bool)
+	for _, hashtag := range entities.Values(entities.Extract(post.Text), entities.KindHashtag) {
+		hashtags[hashtag] = true
+	}
+
+	position := -1
+	for _, required := range rule.RequiredHashtags {
+		if !hashtags[required] {
+			continue
+		}
+		if p := hashtagPosition(post.Text, required); p >= 0 && (position < 0 || p < position) {
+			position = p
+		}
+	}
+
+	if position < 0 {
+		want := "#" + strings.Join(rule.RequiredHashtags, ", #")
+		if rule.AcceptPaidPartnership {
+			return fmt.Sprintf("%s: missing disclosure (one of %s or the paid-partnership label)", rule.Name, want)
+		}
+		return fmt.Sprintf("%s: missing disclosure (one of %s)", rule.Name, want)
+	}
+	if rule.MaxPosition != nil && position >= *rule.MaxPosition {
+		return fmt.Sprintf("%s: disclosure must appear within the first %d characters", rule.Name, *rule.MaxPosition)
+	}
+	return ""
+}
+
+// hashtagPosition returns the character offset of the first occurrence of a
+// normalized hashtag in text, or -1
+func hashtagPosition(text, hashtag string) int {
+	pattern := regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])([#＃]` + regexp.QuoteMeta(hashtag) + `)(?:[^\p{L}\p{N}_]|$)`)
+	loc := pattern.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return -1
+	}
+	return utf8.RuneCountInString(text[:loc[2]])
+}
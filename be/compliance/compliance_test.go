@@ -0,0 +1,95 @@
+package compliance
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestEvaluate(t *testing.T) {
+	anywhere := models.DisclosureRule{Name: "Anywhere", RequiredHashtags: []string{"ad", "sponsored"}}
+	upFront := models.DisclosureRule{Name: "Up front", RequiredHashtags: []string{"ad"}, MaxPosition: ptr(10)}
+	labelOnly := models.DisclosureRule{Name: "Label", AcceptPaidPartnership: true}
+	labelOrHashtag := models.DisclosureRule{Name: "Label or hashtag", RequiredHashtags: []string{"ad"}, AcceptPaidPartnership: true}
+
+	// Rule sets as the stores pick them for campaigns in different jurisdictions
+	us := []models.DisclosureRule{
+		{Name: "FTC", Jurisdiction: ptr("US"), RequiredHashtags: []string{"ad", "sponsored"}, AcceptPaidPartnership: true, MaxPosition: ptr(20)},
+	}
+	uk := []models.DisclosureRule{
+		{Name: "ASA", Jurisdiction: ptr("UK"), RequiredHashtags: []string{"ad"}, MaxPosition: ptr(5)},
+	}
+	usAndUK := append(slices.Clone(us), uk...)
+
+	late := "Loving my new kit from Acme #ad"
+	labelled := Post{Text: "Loving my new kit", PaidPartnership: true}
+
+	for _, tc := range []struct {
+		name    string
+		post    Post
+		rules   []models.DisclosureRule
+		status  string
+		reasons []string
+	}{
+		{"unsponsored content has no rules", Post{Text: "Just a post"}, nil, models.ComplianceStatusPass, []string{}},
+		{"hashtag anywhere", Post{Text: late}, []models.DisclosureRule{anywhere}, models.ComplianceStatusPass, []string{}},
+		{"any of the hashtags", Post{Text: "Kit #Sponsored"}, []models.DisclosureRule{anywhere}, models.ComplianceStatusPass, []string{}},
+		{"hashtag missing", Post{Text: "Loving my #adventure"}, []models.DisclosureRule{anywhere},
+			models.ComplianceStatusFail, []string{"Anywhere: missing disclosure (one of #ad, #sponsored)"}},
+		{"hashtag within the first characters", Post{Text: "#ad Loving my new kit"}, []models.DisclosureRule{upFront}, models.ComplianceStatusPass, []string{}},
+		{"hashtag ending past the limit", Post{Text: "123456 #ad"}, []models.DisclosureRule{upFront}, models.ComplianceStatusPass, []string{}},
+		{"hashtag starting at the limit", Post{Text: "123456789 #ad"}, []models.DisclosureRule{upFront},
+			models.ComplianceStatusFail, []string{"Up front: disclosure must appear within the first 10 characters"}},
+		{"position counts characters", Post{Text: "żółć ąę #ad"}, []models.DisclosureRule{upFront}, models.ComplianceStatusPass, []string{}},
+		{"earliest hashtag counts", Post{Text: "#ad at the start and #ad again at the end"}, []models.DisclosureRule{upFront}, models.ComplianceStatusPass, []string{}},
+		{"label", labelled, []models.DisclosureRule{labelOnly}, models.ComplianceStatusPass, []string{}},
+		{"label missing", Post{Text: late}, []models.DisclosureRule{labelOnly},
+			models.ComplianceStatusFail, []string{"Label: missing paid-partnership label"}},
+		{"label instead of hashtag", labelled, []models.DisclosureRule{labelOrHashtag}, models.ComplianceStatusPass, []string{}},
+		{"neither label nor hashtag", Post{Text: "Loving my new kit"}, []models.DisclosureRule{labelOrHashtag},
+			models.ComplianceStatusFail, []string{"Label or hashtag: missing disclosure (one of #ad or the paid-partnership label)"}},
+		{"label not accepted", labelled, []models.DisclosureRule{anywhere},
+			models.ComplianceStatusFail, []string{"Anywhere: missing disclosure (one of #ad, #sponsored)"}},
+		{"US post", Post{Text: late}, us, models.ComplianceStatusFail, []string{"FTC: disclosure must appear within the first 20 characters"}},
+		{"US post with label", Post{Text: late, PaidPartnership: true}, us, models.ComplianceStatusPass, []string{}},
+		{"UK post", Post{Text: "#ad Loving my new kit"}, uk, models.ComplianceStatusPass, []string{}},
+		{"UK post with label", Post{Text: late, PaidPartnership: true}, uk,
+			models.ComplianceStatusFail, []string{"ASA: disclosure must appear within the first 5 characters"}},
+		{"post in both jurisdictions", Post{Text: "My kit #sponsored", PaidPartnership: true}, usAndUK,
+			models.ComplianceStatusFail, []string{"ASA: missing disclosure (one of #ad)"}},
+		{"one reason per failing rule", Post{Text: late}, usAndUK, models.ComplianceStatusFail, []string{
+			"FTC: disclosure must appear within the first 20 characters",
+			"ASA: disclosure must appear within the first 5 characters",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, reasons := Evaluate(tc.post, tc.rules)
+			if status != tc.status || !slices.Equal(reasons, tc.reasons) {
+				t.Errorf("Evaluate = %s %q, want %s %q", status, reasons, tc.status, tc.reasons)
+			}
+		})
+	}
+}
+
+func TestPostFromContent(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content models.Content
+		want    string
+	}{
+		{"platform text", models.Content{OriginalText: ptr("tweet #ad"), Description: ptr("note"), PaidPartnership: true}, "tweet #ad"},
+		{"empty platform text", models.Content{OriginalText: ptr(""), Description: ptr("note #ad")}, "note #ad"},
+		{"description only", models.Content{Description: ptr("note #ad")}, "note #ad"},
+		{"no text", models.Content{}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			post := PostFromContent(tc.content)
+			if post.Text != tc.want || post.PaidPartnership != tc.content.PaidPartnership {
+				t.Errorf("PostFromContent = %+v, want text %q and paid partnership %v", post, tc.want, tc.content.PaidPartnership)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Package entities extracts hashtags, mentions, cashtags and outbound URLs from
+// post text and normalizes them so they can be stored and filtered on.
+package entities
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Entity kinds
+const (
+	KindHashtag = "hashtag"
+	KindMention = "mention"
+	KindCashtag = "cashtag"
+	KindURL     = "url"
+)
+
+// Entity is a normalized hashtag, mention, cashtag or URL found in a post
+type Entity struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+var (
+	// A hashtag needs at least one non-digit and must not be glued to a preceding word, URL path or '&'
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])[#＃]([\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)
+	// Mentions follow X username rules; a preceding word character or '.' means it's an email address
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])[@＠]([A-Za-z0-9_]{1,15})\b`)
+	cashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_$])\$([A-Za-z]{1,6}(?:[._][A-Za-z]{1,2})?)\b`)
+	urlPattern     = regexp.MustCompile(`https?://[^\s<>"]+`)
+)
+
+// Extract returns the distinct entities in text in the order they first appear,
+// grouped by kind
+func Extract(text string) []Entity {
+	var found []Entity
+	seen := make(map[Entity]bool)
+	add := func(kind, raw string) {
+		value := Normalize(kind, raw)
+		if value == "" {
+			return
+		}
+		entity := Entity{Kind: kind, Value: value}
+		if !seen[entity] {
+			seen[entity] = true
+			found = append(found, entity)
+		}
+	}
+
+	for _, m := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		add(KindHashtag, m[1])
+	}
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		add(KindMention, m[1])
+	}
+	for _, m := range cashtagPattern.FindAllStringSubmatch(text, -1) {
+		add(KindCashtag, m[1])
+	}
+	for _, m := range urlPattern.FindAllString(text, -1) {
+		add(KindURL, m)
+	}
+	return found
+}
+
+// Normalize puts a raw entity value in its stored form: hashtags and mentions
+// are lowercased without their sigil, cashtags uppercased without '$', and URLs
+// get a lowercase scheme and host with trailing punctuation removed. It returns
+// "" if the value is not valid for its kind.
+func Normalize(kind, raw string) string {
+	raw = strings.TrimSpace(raw)
+	switch kind {
+	case KindHashtag:
+		return strings.ToLower(strings.TrimLeft(raw, "#＃"))
+	case KindMention:
+		return strings.ToLower(strings.TrimLeft(raw, "@＠"))
+	case KindCashtag:
+		return strings.ToUpper(strings.TrimLeft(raw, "$"))
+	case KindURL:
+		raw = trimURL(raw)
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		u.Host = strings.ToLower(u.Host)
+		return u.String()
+	default:
+		return ""
+	}
+}
+
+// closingBrackets maps the brackets a URL may end with to their opening ones
+var closingBrackets = map[byte]byte{')': '(', ']': '[', '}': '{'}
+
+// trimURL removes the punctuation of the sentence around a URL from its end. A
+// closing bracket is kept when the URL opens it, as Wikipedia links do.
+func trimURL(raw string) string {
+	for {
+		raw = strings.TrimRight(raw, ".,;:!?'\"…")
+		if raw == "" {
+			return raw
+		}
+		last := raw[len(raw)-1]
+		opening, ok := closingBrackets[last]
+		if !ok || strings.Count(raw, string(last)) <= strings.Count(raw, string(opening)) {
+			return raw
+		}
+		raw = raw[:len(raw)-1]
+	}
+}
+
+// Values returns the values of the entities of one kind
+func Values(list []Entity, kind string) []string {
+	values := []string{}
+	for _, entity := range list {
+		if entity.Kind == kind {
+			values = append(values, entity.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,115 @@
+package entities
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{
+			name: "trailing punctuation",
+			text: "Loving #GoLang! Thanks @Alice, see $aapl. More at https://Example.com/post?id=1.",
+			want: []Entity{
+				{KindHashtag, "golang"},
+				{KindMention, "alice"},
+				{KindCashtag, "AAPL"},
+				{KindURL, "https://example.com/post?id=1"},
+			},
+		},
+		{
+			name: "unicode hashtags",
+			text: "#café #日本 ＃タグ #Żółw",
+			want: []Entity{
+				{KindHashtag, "café"},
+				{KindHashtag, "日本"},
+				{KindHashtag, "タグ"},
+				{KindHashtag, "żółw"},
+			},
+		},
+		{
+			name: "hashtags need a non-digit",
+			text: "#1 fan of #2024goals",
+			want: []Entity{{KindHashtag, "2024goals"}},
+		},
+		{
+			name: "hashtags glued to words or links",
+			text: "AT&T#deal C#sharp https://example.com/#anchor",
+			want: []Entity{{KindURL, "https://example.com/#anchor"}},
+		},
+		{
+			name: "cashtags vs prices",
+			text: "$TSLA was $250.50 and $5, $BRK.B too; $$ and $toolongticker",
+			want: []Entity{
+				{KindCashtag, "TSLA"},
+				{KindCashtag, "BRK.B"},
+			},
+		},
+		{
+			name: "emails vs mentions",
+			text: "mail bob@example.com or first.last@x.com, cc @bob_1 and (@carol)",
+			want: []Entity{
+				{KindMention, "bob_1"},
+				{KindMention, "carol"},
+			},
+		},
+		{
+			name: "mentions longer than a username",
+			text: "@abcdefghijklmnop",
+			want: nil,
+		},
+		{
+			name: "urls with parentheses",
+			text: "(see https://en.wikipedia.org/wiki/Go_(programming_language)) and https://example.com/a).",
+			want: []Entity{
+				{KindURL, "https://en.wikipedia.org/wiki/Go_(programming_language)"},
+				{KindURL, "https://example.com/a"},
+			},
+		},
+		{
+			name: "duplicates",
+			text: "#Go #go @Bob @bob",
+			want: []Entity{
+				{KindHashtag, "go"},
+				{KindMention, "bob"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Extract(tc.text); !slices.Equal(got, tc.want) {
+				t.Errorf("Extract(%q) = %v, want %v", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		kind string
+		raw  string
+		want string
+	}{
+		{KindHashtag, " #GoLang", "golang"},
+		{KindHashtag, "＃Café", "café"},
+		{KindMention, "@Alice", "alice"},
+		{KindMention, "＠Bob", "bob"},
+		{KindCashtag, "$aapl", "AAPL"},
+		{KindURL, "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{KindURL, "https://example.com/a?b=c!", "https://example.com/a?b=c"},
+		{KindURL, `https://example.com/a..."`, "https://example.com/a"},
+		{KindURL, "https://example.com/a)].", "https://example.com/a"},
+		{KindURL, "https://en.wikipedia.org/wiki/Go_(lang).", "https://en.wikipedia.org/wiki/Go_(lang)"},
+		{KindURL, "https://example.com/[x]}", "https://example.com/[x]"},
+		{KindURL, "ftp://example.com/file", ""},
+		{KindURL, "https://", ""},
+		{"unknown", "value", ""},
+	} {
+		if got := Normalize(tc.kind, tc.raw); got != tc.want {
+			t.Errorf("Normalize(%q, %q) = %q, want %q", tc.kind, tc.raw, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+// GetAuditEvents queries the audit log by actor, target and time range
+func (h *Handler) GetAuditEvents(c echo.Context) error {
+	if admin, err := h.requireRole(c, models.RoleAdmin); admin == nil {
+		return err
+	}
+
+	filter := models.AuditFilter{
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("target_type"),
+	}
+
+	intParams := map[string]**int{
+		"actor_id":        &filter.ActorID,
+		"impersonator_id": &filter.ImpersonatorID,
+		"target_id":       &filter.TargetID,
+	}
+	for key, dest := range intParams {
+		if value := c.QueryParam(key); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + key})
+			}
+			*dest = &id
+		}
+	}
+
+	timeParams := map[string]**time.Time{
+		"since": &filter.Since,
+		"until": &filter.Until,
+	}
+	for key, dest := range timeParams {
+		if value := c.QueryParam(key); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + key + ", expected RFC 3339 timestamp"})
+			}
+			*dest = &t
+		}
+	}
+
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.audit.GetAuditEvents(c.Request().Context(), filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
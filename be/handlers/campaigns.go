@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const campaignDateLayout = "2006-01-02"
+
+var hashtagPattern = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+
+// jurisdictionPattern matches market codes such as US, UK, EU or US-CA
+var jurisdictionPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9-]{0,8}$`)
+
+// campaignFields is a validated CampaignRequest
+type campaignFields struct {
+	name, brand        string
+	brief              *string
+	startDate, endDate time.Time
+	hashtags           []string
+	jurisdictions      []string
+}
+
+// parseCampaignRequest validates a campaign request and normalizes its hashtags
+// to lowercase without the leading '#' and its jurisdictions to uppercase
+func parseCampaignRequest(req models.CampaignRequest) (*campaignFields, error) {
+	fields := &campaignFields{
+		name:          strings.TrimSpace(req.Name),
+		brand:         strings.TrimSpace(req.Brand),
+		brief:         req.Brief,
+		hashtags:      []string{},
+		jurisdictions: []string{},
+	}
+	if fields.name == "" {
+		return nil, errors.New("name is required")
+	}
+	if fields.brand == "" {
+		return nil, errors.New("brand is required")
+	}
+
+	var err error
+	if fields.startDate, err = time.Parse(campaignDateLayout, req.StartDate); err != nil {
+		return nil, errors.New("invalid start_date, expected YYYY-MM-DD")
+	}
+	if fields.endDate, err = time.Parse(campaignDateLayout, req.EndDate); err != nil {
+		return nil, errors.New("invalid end_date, expected YYYY-MM-DD")
+	}
+	if fields.endDate.Before(fields.startDate) {
+		return nil, errors.New("end_date must not be before start_date")
+	}
+
+	seen := make(map[string]bool)
+	for _, tag := range req.Hashtags {
+		tag = entities.Normalize(entities.KindHashtag, tag)
+		if !hashtagPattern.MatchString(tag) {
+			return nil, errors.New("invalid hashtag: " + tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			fields.hashtags = append(fields.hashtags, tag)
+		}
+	}
+
+	seenCodes := make(map[string]bool)
+	for _, code := range req.Jurisdictions {
+		code, err := normalizeJurisdiction(code)
+		if err != nil {
+			return nil, err
+		}
+		if !seenCodes[code] {
+			seenCodes[code] = true
+			fields.jurisdictions = append(fields.jurisdictions, code)
+		}
+	}
+
+	return fields, nil
+}
+
+// normalizeJurisdiction uppercases a market code and checks its format
+func normalizeJurisdiction(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !jurisdictionPattern.MatchString(code) {
+		return "", errors.New("invalid jurisdiction: " + code)
+	}
+	return code, nil
+}
+
+// Campaign handlers
+
+// GetCampaigns returns all campaigns for staff and the assigned campaigns for creators
+func (h *Handler) GetCampaigns(c echo.Context) error {
+	user, err := h.getCurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var creatorID *int
+	if user.Role != models.RoleAdmin && user.Role != models.RoleManager {
+		creatorID = &user.ID
+	}
+
+	campaigns, err := h.campaigns.GetCampaigns(c.Request().Context(), creatorID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if campaigns == nil {
+		campaigns = []models.Campaign{}
+	}
+
+	return c.JSON(http.StatusOK, campaigns)
+}
+
+// CreateCampaign creates a new campaign
+func (h *Handler) CreateCampaign(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	var req models.CampaignRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	fields, err := parseCampaignRequest(req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	campaign, err := h.campaigns.CreateCampaign(c.Request().Context(), fields.name, fields.brand, fields.brief, fields.startDate, fields.endDate, fields.hashtags, fields.jurisdictions, models.Actor{UserID: &admin.ID})
+	if repository.IsUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "campaign with this name already exists"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, campaign)
+}
+
+// UpdateCampaign replaces a campaign's details
+func (h *Handler) UpdateCampaign(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid campaign id"})
+	}
+
+	var req models.CampaignRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	fields, err := parseCampaignRequest(req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	campaign, err := h.campaigns.UpdateCampaign(c.Request().Context(), campaignID, fields.name, fields.brand, fields.brief, fields.startDate, fields.endDate, fields.hashtags, fields.jurisdictions, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "campaign not found"})
+	}
+	if repository.IsUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "campaign with this name already exists"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, campaign)
+}
+
+// DeleteCampaign deletes a campaign. Attached content is kept.
+func (h *Handler) DeleteCampaign(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid campaign id"})
+	}
+
+	err = h.campaigns.DeleteCampaign(c.Request().Context(), campaignID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "campaign not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "campaign deleted"})
+}
+
+// AddCampaignCreator assigns a creator to a campaign
+func (h *Handler) AddCampaignCreator(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid campaign id"})
+	}
+
+	var req models.AddCampaignCreatorRequest
+	if err := c.Bind(&req); err != nil || req.UserID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	err = h.campaigns.AddCampaignCreator(c.Request().Context(), campaignID, req.UserID, models.Actor{UserID: &admin.ID})
+	if repository.IsForeignKeyViolation(err) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "campaign or user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "creator added"})
+}
+
+// RemoveCampaignCreator unassigns a creator from a campaign
+func (h *Handler) RemoveCampaignCreator(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid campaign id"})
+	}
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	err = h.campaigns.RemoveCampaignCreator(c.Request().Context(), campaignID, userID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "creator not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "creator removed"})
+}
+
+// AttachCampaignContent attaches content to a campaign by hand
+func (h *Handler) AttachCampaignContent(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid campaign id"})
+	}
+
+	var req models.AttachCampaignContentRequest
+	if err := c.Bind(&req); err != nil || req.ContentID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	err = h.campaigns.AttachCampaignContent(c.Request().Context(), campaignID, req.ContentID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "content not found"})
+	}
+	if repository.IsForeignKeyViolation(err) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "campaign not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "content attached"})
+}
+
+// DetachCampaignContent removes content from a campaign
+func (h *Handler) DetachCampaignContent(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid campaign id"})
+	}
+
+	contentID, err := strconv.Atoi(c.Param("content_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid content id"})
+	}
+
+	err = h.campaigns.DetachCampaignContent(c.Request().Context(), campaignID, contentID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "content not attached"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "content detached"})
+}
+
+// GetCampaignReport lists each creator's posts against the campaign window.
+// Managers only see the creators their teams cover.
+func (h *Handler) GetCampaignReport(c echo.Context) error {
+	viewer, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if viewer == nil {
+		return err
+	}
+
+	campaignID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid campaign id"})
+	}
+
+	report, err := h.campaigns.GetCampaignReport(c.Request().Context(), campaignID, viewer)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "campaign not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
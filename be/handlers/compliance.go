@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// buildDisclosureRule validates a disclosure rule request and normalizes its
+// hashtags and jurisdiction. It returns an error message if it is invalid.
+func buildDisclosureRule(req models.DisclosureRuleRequest) (*models.DisclosureRule, string) {
+	rule := &models.DisclosureRule{
+		Name:                  strings.TrimSpace(req.Name),
+		CampaignID:            req.CampaignID,
+		RequiredHashtags:      []string{},
+		AcceptPaidPartnership: req.AcceptPaidPartnership == nil || *req.AcceptPaidPartnership,
+		MaxPosition:           req.MaxPosition,
+		Enabled:               req.Enabled == nil || *req.Enabled,
+	}
+	if rule.Name == "" {
+		return nil, "name is required"
+	}
+
+	if jurisdiction := optionalString(req.Jurisdiction); jurisdiction != nil {
+		code, err := normalizeJurisdiction(*jurisdiction)
+		if err != nil {
+			return nil, err.Error()
+		}
+		rule.Jurisdiction = &code
+	}
+
+	seen := make(map[string]bool)
+	for _, tag := range req.RequiredHashtags {
+		tag = entities.Normalize(entities.KindHashtag, tag)
+		if !hashtagPattern.MatchString(tag) {
+			return nil, "invalid hashtag: " + tag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			rule.RequiredHashtags = append(rule.RequiredHashtags, tag)
+		}
+	}
+	if len(rule.RequiredHashtags) == 0 && !rule.AcceptPaidPartnership {
+		return nil, "required_hashtags is required unless accept_paid_partnership is set"
+	}
+
+	if rule.MaxPosition != nil && *rule.MaxPosition <= 0 {
+		return nil, "max_position must be positive"
+	}
+	return rule, ""
+}
+
+// Compliance handlers
+
+// GetComplianceResults lists disclosure results of sponsored content, violations
+// by default. status=all lists passing content too. Managers see their teams' creators.
+func (h *Handler) GetComplianceResults(c echo.Context) error {
+	viewer, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if viewer == nil {
+		return err
+	}
+
+	filters := make(map[string]string)
+	switch status := c.QueryParam("status"); status {
+	case "":
+		filters["status"] = models.ComplianceStatusFail
+	case "all":
+	case models.ComplianceStatusPass, models.ComplianceStatusFail:
+		filters["status"] = status
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid status"})
+	}
+
+	for _, param := range []string{"campaign_id", "user_id"} {
+		if value := c.QueryParam(param); value != "" {
+			if _, err := strconv.Atoi(value); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + param})
+			}
+			filters[param] = value
+		}
+	}
+
+	results, err := h.compliance.GetComplianceResults(c.Request().Context(), viewer, filters)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if results == nil {
+		results = []models.ComplianceResult{}
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+// EvaluateCompliance re-checks all sponsored content against the current rules
+func (h *Handler) EvaluateCompliance(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	evaluated, failed, err := h.compliance.ReevaluateCompliance(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, models.EvaluateComplianceResponse{EvaluatedCount: evaluated, FailCount: failed})
+}
+
+// Disclosure rule handlers
+
+// GetDisclosureRules lists all disclosure rules
+func (h *Handler) GetDisclosureRules(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	rules, err := h.compliance.GetDisclosureRules(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if rules == nil {
+		rules = []models.DisclosureRule{}
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
+// CreateDisclosureRule adds a rule and evaluates sponsored content against it
+func (h *Handler) CreateDisclosureRule(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	var req models.DisclosureRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	rule, msg := buildDisclosureRule(req)
+	if rule == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+
+	created, err := h.compliance.CreateDisclosureRule(c.Request().Context(), *rule, models.Actor{UserID: &admin.ID})
+	if repository.IsForeignKeyViolation(err) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "campaign not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, created)
+}
+
+// UpdateDisclosureRule replaces a rule and re-evaluates sponsored content
+func (h *Handler) UpdateDisclosureRule(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rule id"})
+	}
+
+	var req models.DisclosureRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	rule, msg := buildDisclosureRule(req)
+	if rule == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+	rule.ID = ruleID
+
+	updated, err := h.compliance.UpdateDisclosureRule(c.Request().Context(), *rule, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rule not found"})
+	}
+	if repository.IsForeignKeyViolation(err) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "campaign not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+// DeleteDisclosureRule deletes a rule and re-evaluates the content it applied to
+func (h *Handler) DeleteDisclosureRule(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rule id"})
+	}
+
+	err = h.compliance.DeleteDisclosureRule(c.Request().Context(), ruleID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rule not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "rule deleted"})
+}
@@ -1,40 +1,130 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
+	"github.com/Armatorix/SocialTracker/be/entities"
 	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/notify"
 	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/Armatorix/SocialTracker/be/stream"
+	"github.com/Armatorix/SocialTracker/be/tagrules"
 	"github.com/Armatorix/SocialTracker/be/twitter"
 	"github.com/labstack/echo/v4"
 )
 
+// TwitterSyncer fetches posts from X. *twitter.Syncer implements it.
+type TwitterSyncer interface {
+	SyncAccount(ctx context.Context, accountName string, accountID *string, cursor twitter.Cursor) (*twitter.SyncPage, error)
+	SyncAccountWithOAuth(ctx context.Context, token twitter.Token, accountName string, accountID *string, cursor twitter.Cursor, onRefresh func(twitter.Token)) (*twitter.SyncPage, error)
+	GetTwitterUserID(ctx context.Context, username string) (string, error)
+	FindRemovedTweets(ctx context.Context, ids []string) ([]string, error)
+}
+
+// TwitterOAuth connects X accounts with OAuth 2.0. *twitter.OAuthHandler implements it.
+type TwitterOAuth interface {
+	IsConfigured() bool
+	GetAuthorizationURL(userID int) (string, error)
+	ExchangeCode(code, state string) (*twitter.TokenResponse, int, error)
+	GetAuthenticatedUser(ctx context.Context, accessToken string) (*twitter.UserResponse, error)
+}
+
+// Handler serves the API. It reaches storage only through the store
+// interfaces, so tests can run it against repository/memory.
 type Handler struct {
-	repo          *repository.Repository
-	twitterSyncer *twitter.Syncer
+	users         repository.UserStore
+	accounts      repository.AccountStore
+	content       repository.ContentStore
+	jobs          repository.JobStore
+	tags          repository.TagStore
+	tagRules      repository.TagRuleStore
+	campaigns     repository.CampaignStore
+	compliance    repository.ComplianceStore
+	webhooks      repository.WebhookStore
+	teams         repository.TeamStore
+	trash         repository.TrashStore
+	tokens        repository.TokenStore
+	notifications repository.NotificationStore
+	audit         repository.AuditStore
+	impersonation repository.ImpersonationStore
+	contentEvents repository.ContentEventStore
+	twitterSyncer TwitterSyncer
+	twitterOAuth  TwitterOAuth
+	twitterLimits *twitter.Governor
+	roleMapping   RoleMapping
+	groupsClaim   string
+	contentHub    *stream.Hub
+	notifier      *notify.Notifier
 }
 
-func NewHandler(repo *repository.Repository) *Handler {
-	twitterClient := twitter.NewClient()
+func NewHandler(repo *repository.Repository, contentHub *stream.Hub, notifier *notify.Notifier) *Handler {
+	twitterOptions, err := twitter.OptionsFromEnv()
+	if err != nil {
+		log.Printf("Ignoring invalid X API settings: %v", err)
+	}
+
+	// Optional mapping of identity provider groups to roles, e.g. "st-admins=admin,st-creators=creator"
+	roleMapping, err := ParseRoleMapping(os.Getenv("ROLE_GROUP_MAPPING"))
+	if err != nil {
+		log.Printf("Ignoring ROLE_GROUP_MAPPING: %v", err)
+		roleMapping = RoleMapping{}
+	}
+
+	groupsClaim := os.Getenv("OIDC_GROUPS_CLAIM")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	twitterSyncer := twitter.NewSyncer(twitterOptions)
+
 	return &Handler{
-		repo:          repo,
-		twitterSyncer: twitter.NewSyncer(twitterClient),
+		users:         repo,
+		accounts:      repo,
+		content:       repo,
+		jobs:          repo,
+		tags:          repo,
+		tagRules:      repo,
+		campaigns:     repo,
+		compliance:    repo,
+		webhooks:      repo,
+		teams:         repo,
+		trash:         repo,
+		tokens:        repo,
+		notifications: repo,
+		audit:         repo,
+		impersonation: repo,
+		contentEvents: repo,
+		twitterSyncer: twitterSyncer,
+		twitterOAuth:  twitterSyncer.GetOAuthHandler(),
+		twitterLimits: twitterSyncer.GetGovernor(),
+		roleMapping:   roleMapping,
+		groupsClaim:   groupsClaim,
+		contentHub:    contentHub,
+		notifier:      notifier,
 	}
 }
 
-// GetCurrentUser returns the current authenticated user
+// GetCurrentUser returns the current authenticated user, or the impersonated
+// user together with the admin while an impersonation is active
 func (h *Handler) GetCurrentUser(c echo.Context) error {
+	if imp := impersonationFromContext(c); imp != nil {
+		return c.JSON(http.StatusOK, models.CurrentUserResponse{User: *imp.Target, Impersonator: imp.Admin})
+	}
+
 	user, err := h.getCurrentUser(c)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, user)
+	return c.JSON(http.StatusOK, models.CurrentUserResponse{User: *user})
 }
 
 // Social Account handlers
@@ -49,7 +139,7 @@ func (h *Handler) CreateSocialAccount(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 	}
 
-	account, err := h.repo.CreateSocialAccount(userID, req)
+	account, err := h.accounts.CreateSocialAccount(c.Request().Context(), userID, req, h.actor(c))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -63,7 +153,7 @@ func (h *Handler) GetSocialAccounts(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 	}
 
-	accounts, err := h.repo.GetSocialAccountsByUserID(userID)
+	accounts, err := h.accounts.GetSocialAccountsByUserID(c.Request().Context(), userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -86,7 +176,7 @@ func (h *Handler) DeleteSocialAccount(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid account id"})
 	}
 
-	err = h.repo.DeleteSocialAccount(accountID, userID)
+	err = h.accounts.DeleteSocialAccount(c.Request().Context(), accountID, userID, h.actor(c))
 	if err == sql.ErrNoRows {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
 	}
@@ -94,7 +184,7 @@ func (h *Handler) DeleteSocialAccount(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "account deleted"})
+	return c.JSON(http.StatusOK, map[string]string{"message": "account moved to trash"})
 }
 
 func (h *Handler) PullContentFromPlatform(c echo.Context) error {
@@ -109,7 +199,7 @@ func (h *Handler) PullContentFromPlatform(c echo.Context) error {
 	}
 
 	// Get the social account
-	account, err := h.repo.GetSocialAccountByID(accountID, userID)
+	account, err := h.accounts.GetSocialAccountByID(c.Request().Context(), accountID, userID)
 	if err == sql.ErrNoRows {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
 	}
@@ -117,85 +207,99 @@ func (h *Handler) PullContentFromPlatform(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	var response models.SyncResponse
-	response.AccountID = accountID
-	response.Platform = account.Platform
-	response.AccountName = account.AccountName
-
-	switch account.Platform {
-	case "twitter":
-		response, err = h.syncTwitterAccount(userID, account)
-		if err != nil {
-			// Check if it's a rate limit error
-			if rle, ok := twitter.IsRateLimitError(err); ok {
-				return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
-					"error":       "Rate limit exceeded. Too many requests to X/Twitter API.",
-					"retry_after": rle.RetryAfter,
-				})
-			}
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		}
-	default:
+	if !pullSupported(account.Platform) {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "auto-sync not supported for platform: " + account.Platform,
 		})
 	}
 
-	// Update last pull time
-	err = h.repo.UpdateSocialAccountLastPull(accountID, userID)
+	// The pull runs on a worker; the job reports its progress and result
+	job, err := h.jobs.EnqueuePullJob(c.Request().Context(), userID, accountID, h.actor(c))
 	if err != nil {
-		log.Printf("Failed to update last pull time: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, response)
+	c.Response().Header().Set(echo.HeaderLocation, "/api/jobs/"+strconv.Itoa(job.ID))
+	return c.JSON(http.StatusAccepted, job)
 }
 
-// syncTwitterAccount syncs content from Twitter/X for the given account
-func (h *Handler) syncTwitterAccount(userID int, account *models.SocialAccount) (models.SyncResponse, error) {
+// notifySyncFailed alerts the owner of an account that syncing it failed
+func (h *Handler) notifySyncFailed(account *models.SocialAccount, syncErr error) {
+	h.alertAccountOwner(*account, models.NotificationSyncFailed, notify.SyncFailedKey(*account, time.Now()),
+		notify.SyncFailedAlert(*account, syncErr.Error()))
+}
+
+// recordSyncRun stores the outcome of a sync for digests and webhooks. It is
+// recorded even when the worker is stopping mid-sync.
+func (h *Handler) recordSyncRun(ctx context.Context, account *models.SocialAccount, response models.SyncResponse, syncErr error) {
+	run := models.SyncRun{
+		SocialAccountID: account.ID,
+		UserID:          account.UserID,
+		Status:          models.SyncRunSucceeded,
+		SyncedCount:     response.SyncedCount,
+		SkippedCount:    response.SkippedCount,
+	}
+	if syncErr != nil {
+		msg := syncErr.Error()
+		run.Status, run.Error = models.SyncRunFailed, &msg
+	}
+	if err := h.accounts.RecordSyncRun(context.WithoutCancel(ctx), run); err != nil {
+		log.Printf("Failed to record sync run for account %d: %v", account.ID, err)
+	}
+}
+
+// syncTwitterAccount syncs content from Twitter/X for the given account,
+// reporting progress as tweets are stored
+func (h *Handler) syncTwitterAccount(ctx context.Context, userID int, account *models.SocialAccount, actor models.Actor, progress func(models.PullJobProgress)) (models.SyncResponse, error) {
 	response := models.SyncResponse{
 		AccountID:   account.ID,
 		Platform:    account.Platform,
 		AccountName: account.AccountName,
 	}
 
-	// Get the latest synced tweet ID to only fetch newer tweets
-	latestID, err := h.repo.GetLatestExternalPostID(account.ID)
+	// Continue from where the last pull got to
+	cursor, err := h.accounts.GetSyncCursor(ctx, account.ID)
 	if err != nil {
-		log.Printf("Failed to get latest external post ID: %v", err)
+		return response, err
 	}
-
-	var sinceID string
-	if latestID != nil {
-		sinceID = *latestID
+	from := twitter.Cursor{}
+	if cursor.NewestID != nil {
+		from.SinceID = *cursor.NewestID
+	}
+	if cursor.PaginationToken != nil {
+		from.PaginationToken = *cursor.PaginationToken
 	}
 
-	var tweets []twitter.SyncedTweet
+	var page *twitter.SyncPage
 
 	// Check if we have OAuth tokens - prefer OAuth over app-level token
 	if account.AccessToken != nil && *account.AccessToken != "" {
-		// Check if token needs refresh
-		if account.TokenExpiresAt != nil && time.Now().After(*account.TokenExpiresAt) {
-			if account.RefreshToken != nil && *account.RefreshToken != "" {
-				// Try to refresh the token
-				oauthHandler := h.twitterSyncer.GetOAuthHandler()
-				newTokens, err := oauthHandler.RefreshAccessToken(*account.RefreshToken)
-				if err != nil {
-					log.Printf("Failed to refresh token for account %d: %v", account.ID, err)
-					// Fall back to app-level token if refresh fails
-					goto useAppToken
-				}
-				// Update tokens in database
-				expiresAt := time.Now().Add(time.Duration(newTokens.ExpiresIn) * time.Second)
-				err = h.repo.UpdateSocialAccountTokens(account.ID, newTokens.AccessToken, newTokens.RefreshToken, expiresAt)
-				if err != nil {
-					log.Printf("Failed to update tokens: %v", err)
-				}
-				account.AccessToken = &newTokens.AccessToken
+		token := twitter.Token{AccessToken: *account.AccessToken}
+		if account.RefreshToken != nil {
+			token.RefreshToken = *account.RefreshToken
+		}
+		if account.TokenExpiresAt != nil {
+			token.ExpiresAt = *account.TokenExpiresAt
+		}
+
+		// Refreshed tokens are stored right away since refresh tokens can only be used once
+		onRefresh := func(newToken twitter.Token) {
+			err := h.accounts.UpdateSocialAccountTokens(ctx, account.ID, newToken.AccessToken, newToken.RefreshToken, newToken.ExpiresAt, actor)
+			if err != nil {
+				log.Printf("Failed to update tokens: %v", err)
 			}
+			account.AccessToken = &newToken.AccessToken
 		}
 
 		// Use OAuth to sync
-		tweets, err = h.twitterSyncer.SyncAccountWithOAuth(*account.AccessToken, account.AccountName, account.AccountID, sinceID)
+		page, err = h.twitterSyncer.SyncAccountWithOAuth(ctx, token, account.AccountName, account.AccountID, from, onRefresh)
+		if errors.Is(err, twitter.ErrTokenRefresh) {
+			log.Printf("Failed to refresh token for account %d: %v", account.ID, err)
+			h.alertAccountOwner(*account, models.NotificationTokenExpiry, notify.TokenExpiryKey(*account),
+				notify.TokenExpiryAlert(*account, time.Now()))
+			// Fall back to app-level token if refresh fails
+			goto useAppToken
+		}
 		if err != nil {
 			log.Printf("OAuth sync failed, falling back to app token: %v", err)
 			goto useAppToken
@@ -205,18 +309,18 @@ func (h *Handler) syncTwitterAccount(userID int, account *models.SocialAccount)
 
 useAppToken:
 	// Fetch tweets from Twitter using app-level token
-	tweets, err = h.twitterSyncer.SyncAccount(account.AccountName, account.AccountID, sinceID)
+	page, err = h.twitterSyncer.SyncAccount(ctx, account.AccountName, account.AccountID, from)
 	if err != nil {
 		return response, err
 	}
 
 	// If we don't have the Twitter user ID stored, fetch and save it
 	if account.AccountID == nil || *account.AccountID == "" {
-		twitterUserID, err := h.twitterSyncer.GetTwitterUserID(account.AccountName)
+		twitterUserID, err := h.twitterSyncer.GetTwitterUserID(ctx, account.AccountName)
 		if err != nil {
 			log.Printf("Failed to get Twitter user ID: %v", err)
 		} else {
-			err = h.repo.UpdateSocialAccountID(account.ID, twitterUserID)
+			err = h.accounts.UpdateSocialAccountID(ctx, account.ID, twitterUserID)
 			if err != nil {
 				log.Printf("Failed to update social account ID: %v", err)
 			}
@@ -224,39 +328,93 @@ useAppToken:
 	}
 
 processTweets:
-	// Store each tweet as content
-	for _, tweet := range tweets {
-		content, err := h.repo.CreateSyncedContent(
-			userID,
-			account.ID,
-			"twitter",
-			tweet.Link,
-			tweet.Text,
-			tweet.ExternalID,
-			tweet.PostedAt,
-		)
-		if err != nil {
-			log.Printf("Failed to create content for tweet %s: %v", tweet.ExternalID, err)
-			response.Errors = append(response.Errors, err.Error())
-			continue
+	// Tag rules run on each newly stored tweet
+	rules, err := h.content.GetTagRules(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load tag rules: %v", err)
+	}
+	matchers := tagrules.CompileAll(rules)
+
+	tweets := page.Tweets
+	progress(models.PullJobProgress{Fetched: len(tweets)})
+
+	// Store all tweets at once, recording the pull and the new cursor with them
+	posts := make([]models.SyncedPost, len(tweets))
+	for i, tweet := range tweets {
+		posts[i] = models.SyncedPost{
+			ExternalID:       tweet.ExternalID,
+			Text:             tweet.Text,
+			Link:             tweet.Link,
+			PostedAt:         tweet.PostedAt,
+			Entities:         tweet.Entities,
+			EditHistoryIDs:   tweet.EditHistoryIDs,
+			ConversationID:   tweet.ConversationID,
+			Relation:         tweet.Relation,
+			ParentExternalID: tweet.ParentID,
+		}
+		for _, media := range tweet.Media {
+			posts[i].Media = append(posts[i].Media, models.SyncedMedia(media))
 		}
-		if content == nil {
+	}
+	results, err := h.content.StoreSyncedContent(ctx, userID, account.ID, "twitter", posts, advanceSyncCursor(*cursor, page), actor)
+	if err != nil {
+		return response, err
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case models.SyncedPostSkipped:
 			// Duplicate tweet, already exists
 			response.SkippedCount++
-		} else {
+			continue
+		case models.SyncedPostUpdated:
+			// Edited tweet, its new text may match other rules
+			response.UpdatedCount++
+		default:
 			response.SyncedCount++
 		}
+		h.applyTagRules(ctx, matchers, result.Content, actor)
 	}
 
+	progress(models.PullJobProgress{Fetched: len(tweets), Processed: len(tweets)})
+
 	response.Message = "Sync completed successfully"
-	if response.SyncedCount == 0 && response.SkippedCount == 0 {
+	if response.SyncedCount == 0 && response.SkippedCount == 0 && response.UpdatedCount == 0 {
 		response.Message = "No new tweets found"
 	}
 
-	log.Printf("Twitter sync for @%s: synced=%d, skipped=%d", account.AccountName, response.SyncedCount, response.SkippedCount)
+	log.Printf("Twitter sync for @%s: synced=%d, updated=%d, skipped=%d", account.AccountName, response.SyncedCount, response.UpdatedCount, response.SkippedCount)
 	return response, nil
 }
 
+// advanceSyncCursor returns where the sync of an account has got to after it
+// fetched page
+func advanceSyncCursor(cursor models.SyncCursor, page *twitter.SyncPage) models.SyncCursor {
+	for _, tweet := range page.Tweets {
+		if cursor.HighWaterID == nil || twitter.IDLess(*cursor.HighWaterID, tweet.ExternalID) {
+			id, postedAt := tweet.ExternalID, tweet.PostedAt
+			cursor.HighWaterID, cursor.HighWaterAt = &id, &postedAt
+		}
+		if cursor.OldestID == nil || twitter.IDLess(tweet.ExternalID, *cursor.OldestID) {
+			id := tweet.ExternalID
+			cursor.OldestID = &id
+		}
+	}
+
+	if page.NextToken != "" {
+		// Pages between the cursor and the tweets fetched are left; the next
+		// pull resumes from them with the same since ID
+		token := page.NextToken
+		cursor.PaginationToken = &token
+		return cursor
+	}
+	cursor.PaginationToken = nil
+	if cursor.HighWaterID != nil {
+		cursor.NewestID = cursor.HighWaterID
+	}
+	return cursor
+}
+
 // Content handlers
 func (h *Handler) CreateContent(c echo.Context) error {
 	userID, err := h.getUserID(c)
@@ -268,8 +426,11 @@ func (h *Handler) CreateContent(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 	}
+	if msg := validateTagNames(req.Tags); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
 
-	content, err := h.repo.CreateContent(userID, req)
+	content, err := h.content.CreateContent(c.Request().Context(), userID, req, h.actor(c))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -287,7 +448,12 @@ func (h *Handler) GetContent(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 	}
 
-	content, err := h.repo.GetContentByUserID(userID)
+	filters := entityFilters(c)
+	for key, value := range relationFilters(c) {
+		filters[key] = value
+	}
+
+	content, err := h.content.GetContentByUserID(c.Request().Context(), userID, filters)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -299,6 +465,42 @@ func (h *Handler) GetContent(c echo.Context) error {
 	return c.JSON(http.StatusOK, content)
 }
 
+// UpdateContent edits the text, description, tags or paid-partnership label of content
+func (h *Handler) UpdateContent(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	contentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid content id"})
+	}
+
+	var req models.UpdateContentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if req.Tags != nil {
+		if msg := validateTagNames(*req.Tags); msg != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+		}
+	}
+
+	content, err := h.content.UpdateContent(c.Request().Context(), contentID, userID, req, h.actor(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "content not found"})
+	}
+	if err == repository.ErrSyncedContentText {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, content)
+}
+
 func (h *Handler) DeleteContent(c echo.Context) error {
 	userID, err := h.getUserID(c)
 	if err != nil {
@@ -310,7 +512,7 @@ func (h *Handler) DeleteContent(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid content id"})
 	}
 
-	err = h.repo.DeleteContent(contentID, userID)
+	err = h.content.DeleteContent(c.Request().Context(), contentID, userID, h.actor(c))
 	if err == sql.ErrNoRows {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "content not found"})
 	}
@@ -318,19 +520,38 @@ func (h *Handler) DeleteContent(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "content deleted"})
+	return c.JSON(http.StatusOK, map[string]string{"message": "content moved to trash"})
 }
 
-// Admin handlers
-func (h *Handler) GetAllContent(c echo.Context) error {
-	// Check if user is admin
-	user, err := h.getCurrentUser(c)
+// GetContentRevisions lists the earlier texts of an edited synced post, newest first
+func (h *Handler) GetContentRevisions(c echo.Context) error {
+	userID, err := h.getUserID(c)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 	}
 
-	if user.Role != "admin" {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": "admin access required"})
+	contentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid content id"})
+	}
+
+	revisions, err := h.content.GetContentRevisions(c.Request().Context(), contentID, userID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "content not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// Admin handlers
+func (h *Handler) GetAllContent(c echo.Context) error {
+	// Admins see everything, managers only the creators in their teams
+	user, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if user == nil {
+		return err
 	}
 
 	// Get query parameters for filtering
@@ -341,8 +562,14 @@ func (h *Handler) GetAllContent(c echo.Context) error {
 	if username := c.QueryParam("username"); username != "" {
 		filters["username"] = username
 	}
+	for key, value := range entityFilters(c) {
+		filters[key] = value
+	}
+	for key, value := range relationFilters(c) {
+		filters[key] = value
+	}
 
-	content, err := h.repo.GetAllContent(filters)
+	content, err := h.content.GetAllContent(c.Request().Context(), user, filters)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -363,14 +590,13 @@ func (h *Handler) GetTwitterOAuthURL(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 	}
 
-	oauthHandler := h.twitterSyncer.GetOAuthHandler()
-	if !oauthHandler.IsConfigured() {
+	if !h.twitterOAuth.IsConfigured() {
 		return c.JSON(http.StatusServiceUnavailable, map[string]string{
 			"error": "Twitter OAuth is not configured. Please set TWITTER_CLIENT_ID, TWITTER_CLIENT_SECRET, and TWITTER_REDIRECT_URI environment variables.",
 		})
 	}
 
-	authURL, err := oauthHandler.GetAuthorizationURL(userID)
+	authURL, err := h.twitterOAuth.GetAuthorizationURL(userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -396,17 +622,15 @@ func (h *Handler) HandleTwitterOAuthCallback(c echo.Context) error {
 		return c.Redirect(http.StatusTemporaryRedirect, "/?twitter_oauth_error=missing_params")
 	}
 
-	oauthHandler := h.twitterSyncer.GetOAuthHandler()
-
 	// Exchange code for tokens
-	tokens, userID, err := oauthHandler.ExchangeCode(code, state)
+	tokens, userID, err := h.twitterOAuth.ExchangeCode(code, state)
 	if err != nil {
 		log.Printf("Failed to exchange OAuth code: %v", err)
 		return c.Redirect(http.StatusTemporaryRedirect, "/?twitter_oauth_error=token_exchange_failed")
 	}
 
 	// Get the authenticated Twitter user
-	twitterUser, err := oauthHandler.GetAuthenticatedUser(tokens.AccessToken)
+	twitterUser, err := h.twitterOAuth.GetAuthenticatedUser(c.Request().Context(), tokens.AccessToken)
 	if err != nil {
 		log.Printf("Failed to get Twitter user: %v", err)
 		return c.Redirect(http.StatusTemporaryRedirect, "/?twitter_oauth_error=user_fetch_failed")
@@ -425,21 +649,21 @@ func (h *Handler) HandleTwitterOAuthCallback(c echo.Context) error {
 	}
 
 	// Check if account already exists
-	existingAccount, err := h.repo.GetSocialAccountByPlatformAndAccountID(userID, "twitter", twitterUser.Data.ID)
+	existingAccount, err := h.accounts.GetSocialAccountByPlatformAndAccountID(c.Request().Context(), userID, "twitter", twitterUser.Data.ID)
 	if err != nil && err != sql.ErrNoRows {
 		log.Printf("Failed to check existing account: %v", err)
 	}
 
 	if existingAccount != nil {
 		// Update existing account with new tokens
-		err = h.repo.UpdateSocialAccountTokens(existingAccount.ID, tokens.AccessToken, tokens.RefreshToken, expiresAt)
+		err = h.accounts.UpdateSocialAccountTokens(c.Request().Context(), existingAccount.ID, tokens.AccessToken, tokens.RefreshToken, expiresAt, h.actor(c))
 		if err != nil {
 			log.Printf("Failed to update account tokens: %v", err)
 			return c.Redirect(http.StatusTemporaryRedirect, "/?twitter_oauth_error=save_failed")
 		}
 	} else {
 		// Create new account
-		account, err := h.repo.CreateSocialAccountWithTokens(userID, req, expiresAt)
+		account, err := h.accounts.CreateSocialAccountWithTokens(c.Request().Context(), userID, req, expiresAt, h.actor(c))
 		if err != nil {
 			log.Printf("Failed to create social account: %v", err)
 			return c.Redirect(http.StatusTemporaryRedirect, "/?twitter_oauth_error=save_failed")
@@ -453,14 +677,30 @@ func (h *Handler) HandleTwitterOAuthCallback(c echo.Context) error {
 
 // GetTwitterOAuthStatus returns whether Twitter OAuth is configured
 func (h *Handler) GetTwitterOAuthStatus(c echo.Context) error {
-	oauthHandler := h.twitterSyncer.GetOAuthHandler()
 	return c.JSON(http.StatusOK, map[string]bool{
-		"configured": oauthHandler.IsConfigured(),
+		"configured": h.twitterOAuth.IsConfigured(),
 	})
 }
 
+// GetTwitterRateLimits returns the X API rate limit budgets left in the
+// current windows, per endpoint and credential
+func (h *Handler) GetTwitterRateLimits(c echo.Context) error {
+	if admin, err := h.requireRole(c, models.RoleAdmin); admin == nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, h.twitterLimits.Budgets())
+}
+
 // Helper methods
+
+// getUserID returns the ID of the user whose data the request operates on,
+// which is the impersonated user while an admin is impersonating someone
 func (h *Handler) getUserID(c echo.Context) (int, error) {
+	if imp := impersonationFromContext(c); imp != nil {
+		return imp.Target.ID, nil
+	}
+
 	user, err := h.getCurrentUser(c)
 	if err != nil {
 		return 0, err
@@ -468,7 +708,28 @@ func (h *Handler) getUserID(c echo.Context) (int, error) {
 	return user.ID, nil
 }
 
+// actor returns who performs the request for the audit log: the effective user,
+// plus the admin when they are impersonating someone
+func (h *Handler) actor(c echo.Context) models.Actor {
+	if imp := impersonationFromContext(c); imp != nil {
+		return models.Actor{UserID: &imp.Target.ID, ImpersonatorID: &imp.Admin.ID}
+	}
+
+	user, err := h.getCurrentUser(c)
+	if err != nil {
+		return models.Actor{}
+	}
+	return models.Actor{UserID: &user.ID}
+}
+
+// getCurrentUser returns the authenticated user, even while they impersonate someone
 func (h *Handler) getCurrentUser(c echo.Context) (*models.User, error) {
+	// Requests authenticated with an API token, or already resolved earlier in
+	// the request, carry their user in the context
+	if user, ok := c.Get(contextUserKey).(*models.User); ok {
+		return user, nil
+	}
+
 	// oauth2-proxy with PASS_USER_HEADERS=true sends X-Forwarded-* headers
 	userID := c.Request().Header.Get("X-Forwarded-User")
 	email := c.Request().Header.Get("X-Forwarded-Email")
@@ -505,5 +766,74 @@ func (h *Handler) getCurrentUser(c echo.Context) (*models.User, error) {
 		}
 	}
 
-	return h.repo.GetOrCreateUser(userID, email, username)
+	user, err := h.users.GetOrCreateUser(c.Request().Context(), userID, email, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the role in sync with the identity provider groups when a mapping is configured
+	if len(h.roleMapping) > 0 {
+		if role, ok := h.roleMapping.Resolve(h.requestGroups(c)); ok && role != user.Role {
+			updated, err := h.users.UpdateUserRole(c.Request().Context(), user.ID, role, models.Actor{}, "groups")
+			if err != nil {
+				log.Printf("Failed to apply group role %s to user %d: %v", role, user.ID, err)
+			} else {
+				log.Printf("Changed role of user %d from %s to %s based on groups", user.ID, user.Role, role)
+				user = updated
+			}
+		}
+	}
+
+	c.Set(contextUserKey, user)
+	return user, nil
+}
+
+// entityFilters reads the hashtag, mention and cashtag query parameters used to filter content listings
+func entityFilters(c echo.Context) map[string]string {
+	filters := make(map[string]string)
+	for _, kind := range []string{entities.KindHashtag, entities.KindMention, entities.KindCashtag} {
+		if value := c.QueryParam(kind); value != "" {
+			filters[kind] = value
+		}
+	}
+	return filters
+}
+
+// relationFilters reads the collapse_threads, exclude_replies and
+// exclude_retweets query parameters used to filter content listings
+func relationFilters(c echo.Context) map[string]string {
+	filters := make(map[string]string)
+	for _, key := range []string{models.FilterCollapseThreads, models.FilterExcludeReplies, models.FilterExcludeRetweets} {
+		if enabled, _ := strconv.ParseBool(c.QueryParam(key)); enabled {
+			filters[key] = "true"
+		}
+	}
+	return filters
+}
+
+// hasIdentity reports whether the request carries an API token user or oauth2-proxy user headers
+func hasIdentity(c echo.Context) bool {
+	if _, ok := c.Get(contextUserKey).(*models.User); ok {
+		return true
+	}
+	header := c.Request().Header
+	return header.Get("X-Forwarded-User") != "" || header.Get("X-Auth-Request-User") != ""
+}
+
+// requireRole returns the current user if they hold one of the given roles.
+// Otherwise it writes an error response and returns a nil user, so callers
+// should return the error as-is when the user is nil.
+func (h *Handler) requireRole(c echo.Context, roles ...string) (*models.User, error) {
+	user, err := h.getCurrentUser(c)
+	if err != nil {
+		return nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	for _, role := range roles {
+		if user.Role == role {
+			return user, nil
+		}
+	}
+
+	return nil, c.JSON(http.StatusForbidden, map[string]string{"error": "insufficient permissions"})
 }
@@ -0,0 +1,1323 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/notify"
+	"github.com/Armatorix/SocialTracker/be/repository/memory"
+	"github.com/Armatorix/SocialTracker/be/stream"
+	"github.com/Armatorix/SocialTracker/be/twitter"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeSyncer returns canned tweets and remembers how it was called
+type fakeSyncer struct {
+	tweets []twitter.SyncedTweet
+	// nextToken is returned as if pages were left after tweets
+	nextToken   string
+	err         error
+	oauthErr    error
+	userID      string
+	cursors     []twitter.Cursor
+	oauthTokens []string
+	// refreshed is passed to onRefresh as if the OAuth token had been refreshed
+	refreshed *twitter.Token
+}
+
+func (f *fakeSyncer) SyncAccount(ctx context.Context, accountName string, accountID *string, cursor twitter.Cursor) (*twitter.SyncPage, error) {
+	return f.sync(cursor)
+}
+
+func (f *fakeSyncer) SyncAccountWithOAuth(ctx context.Context, token twitter.Token, accountName string, accountID *string, cursor twitter.Cursor, onRefresh func(twitter.Token)) (*twitter.SyncPage, error) {
+	f.oauthTokens = append(f.oauthTokens, token.AccessToken)
+	if f.refreshed != nil {
+		onRefresh(*f.refreshed)
+	}
+	if f.oauthErr != nil {
+		return nil, f.oauthErr
+	}
+	return f.sync(cursor)
+}
+
+func (f *fakeSyncer) sync(cursor twitter.Cursor) (*twitter.SyncPage, error) {
+	f.cursors = append(f.cursors, cursor)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &twitter.SyncPage{Tweets: f.tweets, NextToken: f.nextToken}, nil
+}
+
+func (f *fakeSyncer) GetTwitterUserID(ctx context.Context, username string) (string, error) {
+	if f.userID == "" {
+		return "", errors.New("user not found")
+	}
+	return f.userID, nil
+}
+
+func (f *fakeSyncer) FindRemovedTweets(ctx context.Context, ids []string) ([]string, error) {
+	return nil, nil
+}
+
+// fakeOAuth accepts the code "good" for the user in state
+type fakeOAuth struct {
+	tokens  twitter.TokenResponse
+	user    twitter.UserResponse
+	userErr error
+}
+
+func (f *fakeOAuth) IsConfigured() bool { return true }
+
+func (f *fakeOAuth) GetAuthorizationURL(userID int) (string, error) {
+	return "https://x.com/i/oauth2/authorize?state=" + strconv.Itoa(userID), nil
+}
+
+func (f *fakeOAuth) ExchangeCode(code, state string) (*twitter.TokenResponse, int, error) {
+	if code != "good" {
+		return nil, 0, errors.New("invalid code")
+	}
+	userID, err := strconv.Atoi(state)
+	if err != nil {
+		return nil, 0, errors.New("invalid state")
+	}
+	tokens := f.tokens
+	return &tokens, userID, nil
+}
+
+func (f *fakeOAuth) GetAuthenticatedUser(ctx context.Context, accessToken string) (*twitter.UserResponse, error) {
+	if f.userErr != nil {
+		return nil, f.userErr
+	}
+	user := f.user
+	return &user, nil
+}
+
+type testServer struct {
+	echo    *echo.Echo
+	handler *Handler
+	store   *memory.Store
+	syncer  *fakeSyncer
+	oauth   *fakeOAuth
+}
+
+// newTestServer serves the routes under test with in-memory stores. Alerts
+// are dropped as the notifier has no transports.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	s := &testServer{
+		echo:   echo.New(),
+		store:  memory.New(),
+		syncer: &fakeSyncer{},
+		oauth:  &fakeOAuth{},
+	}
+	h := &Handler{
+		users:         s.store,
+		accounts:      s.store,
+		content:       s.store,
+		jobs:          s.store,
+		tags:          s.store,
+		tagRules:      s.store,
+		campaigns:     s.store,
+		compliance:    s.store,
+		webhooks:      s.store,
+		teams:         s.store,
+		trash:         s.store,
+		tokens:        s.store,
+		notifications: s.store,
+		audit:         s.store,
+		impersonation: s.store,
+		contentEvents: s.store,
+		contentHub:    stream.NewHub(),
+		twitterSyncer: s.syncer,
+		twitterOAuth:  s.oauth,
+		twitterLimits: twitter.NewGovernor(time.Second),
+		groupsClaim:   "groups",
+		notifier:      notify.New(nil),
+	}
+	s.handler = h
+
+	api := s.echo.Group("/api", h.Authenticate)
+	api.GET("/content", h.GetContent)
+	api.GET("/content/stream", h.StreamContent)
+	api.GET("/content/:id/revisions", h.GetContentRevisions)
+	api.POST("/content", h.CreateContent)
+	api.POST("/social-accounts/:id/pull", h.PullContentFromPlatform)
+	api.GET("/jobs/:id", h.GetJob)
+	api.GET("/auth/twitter/callback", h.HandleTwitterOAuthCallback)
+	api.GET("/admin/content", h.GetAllContent)
+	api.GET("/admin/users", h.ListUsers)
+	api.PUT("/admin/users/:id/role", h.UpdateUserRole)
+	api.GET("/admin/twitter/rate-limits", h.GetTwitterRateLimits)
+	api.GET("/admin/social-accounts/:id/sync-cursor", h.GetSyncCursor)
+	api.PUT("/admin/social-accounts/:id/sync-cursor", h.RewindSyncCursor)
+	api.GET("/admin/audit", h.GetAuditEvents)
+	api.POST("/admin/campaigns", h.CreateCampaign)
+	api.POST("/admin/campaigns/:id/creators", h.AddCampaignCreator)
+	api.GET("/admin/compliance", h.GetComplianceResults)
+	api.POST("/admin/disclosure-rules", h.CreateDisclosureRule)
+	return s
+}
+
+// user creates a user with a role. Requests made as them carry the
+// oauth2-proxy headers. The user ID is the email, as getCurrentUser would
+// base64 decode IDs that happen to be valid base64.
+func (s *testServer) user(t *testing.T, name, role string) *models.User {
+	t.Helper()
+
+	email := name + "@example.com"
+	user, err := s.store.GetOrCreateUser(t.Context(), email, email, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != models.RoleCreator {
+		if user, err = s.store.UpdateUserRole(t.Context(), user.ID, role, models.Actor{}, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return user
+}
+
+func (s *testServer) account(t *testing.T, user *models.User, req models.CreateSocialAccountRequest) *models.SocialAccount {
+	t.Helper()
+
+	account, err := s.store.CreateSocialAccount(t.Context(), user.ID, req, models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+// pull queues a pull of an account as user, runs the due jobs as a worker
+// would and returns the job as reported afterwards
+func (s *testServer) pull(t *testing.T, user *models.User, accountID int) models.PullJob {
+	t.Helper()
+
+	rec := s.do(t, http.MethodPost, "/api/social-accounts/"+strconv.Itoa(accountID)+"/pull", user, "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("pull returned %d: %s", rec.Code, rec.Body)
+	}
+	queued := decode[models.PullJob](t, rec)
+	location := "/api/jobs/" + strconv.Itoa(queued.ID)
+	if got := rec.Header().Get(echo.HeaderLocation); got != location {
+		t.Errorf("location = %q, want %q", got, location)
+	}
+
+	s.runJobs(t)
+
+	rec = s.do(t, http.MethodGet, location, user, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("job returned %d: %s", rec.Code, rec.Body)
+	}
+	return decode[models.PullJob](t, rec)
+}
+
+// runJobs runs the due pull jobs
+func (s *testServer) runJobs(t *testing.T) {
+	t.Helper()
+
+	jobs, err := s.store.ClaimPullJobs(t.Context(), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range jobs {
+		s.handler.RunPullJob(t.Context(), job)
+	}
+}
+
+// do sends a request as user, or anonymously when user is nil
+func (s *testServer) do(t *testing.T, method, target string, user *models.User, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if user != nil {
+		req.Header.Set("X-Forwarded-User", user.UserID)
+		req.Header.Set("X-Forwarded-Email", user.Email)
+	}
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+// sseEvent is an event read from a Server-Sent Events stream
+type sseEvent struct {
+	id, event, data string
+}
+
+// stream connects to an event stream as user over HTTP, resuming after
+// lastEventID unless it is empty, and returns the events read from it. The
+// connection is closed when the test ends.
+func (s *testServer) stream(t *testing.T, target string, user *models.User, lastEventID string) <-chan sseEvent {
+	t.Helper()
+
+	srv := httptest.NewServer(s.echo)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Forwarded-User", user.UserID)
+	req.Header.Set("X-Forwarded-Email", user.Email)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		t.Fatalf("stream returned %d", res.StatusCode)
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		defer res.Body.Close()
+
+		var event sseEvent
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				event.id = value
+			case "event":
+				event.event = value
+			case "data":
+				event.data = value
+			case "":
+				// A blank line ends an event; comments and retry hints have none
+				if event.event != "" {
+					events <- event
+				}
+				event = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+// next returns the next event of a stream
+func next(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return sseEvent{}
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func tweet(id, text string) twitter.SyncedTweet {
+	return twitter.SyncedTweet{
+		ExternalID: id,
+		Text:       text,
+		Link:       "https://x.com/alice/status/" + id,
+		PostedAt:   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestPullContentStoresNewTweets(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+	s.store.AddTagRule(models.TagRule{Name: "launches", Enabled: true, Keyword: ptr("launch"), Tags: []string{"launch"}})
+
+	s.syncer.userID = "42"
+	s.syncer.tweets = []twitter.SyncedTweet{
+		tweet("101", "Our launch is live #spring"),
+		tweet("102", "Thanks @bob"),
+	}
+
+	job := s.pull(t, alice, account.ID)
+	if job.Status != models.PullJobSucceeded || job.Result == nil {
+		t.Fatalf("job = %+v, want it to have succeeded", job)
+	}
+	if job.Result.SyncedCount != 2 || job.Result.SkippedCount != 0 {
+		t.Errorf("synced %d and skipped %d, want 2 and 0", job.Result.SyncedCount, job.Result.SkippedCount)
+	}
+	if job.Progress != (models.PullJobProgress{Fetched: 2, Processed: 2}) {
+		t.Errorf("progress = %+v, want both tweets processed", job.Progress)
+	}
+
+	contents := decode[[]models.Content](t, s.do(t, http.MethodGet, "/api/content?hashtag=spring", alice, ""))
+	if len(contents) != 1 || *contents[0].ExternalPostID != "101" {
+		t.Fatalf("content tagged #spring = %+v, want tweet 101", contents)
+	}
+	if len(contents[0].Tags) != 1 || contents[0].Tags[0] != "launch" {
+		t.Errorf("tags = %v, want the tag rule to add launch", contents[0].Tags)
+	}
+
+	stored, err := s.store.GetSocialAccountByID(t.Context(), account.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AccountID == nil || *stored.AccountID != "42" {
+		t.Errorf("account id = %v, want the looked up X user id", stored.AccountID)
+	}
+	if stored.LastPullAt == nil {
+		t.Error("last pull time was not set")
+	}
+
+	runs := s.store.SyncRuns()
+	if len(runs) != 1 || runs[0].Status != models.SyncRunSucceeded || runs[0].SyncedCount != 2 {
+		t.Errorf("sync runs = %+v, want one successful run of 2 posts", runs)
+	}
+}
+
+func TestPullContentSkipsKnownTweets(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice", AccountID: ptr("42")})
+
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "first")}
+	if job := s.pull(t, alice, account.ID); job.Status != models.PullJobSucceeded {
+		t.Fatalf("first pull = %+v, want it to have succeeded", job)
+	}
+
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "first"), tweet("102", "second")}
+	job := s.pull(t, alice, account.ID)
+	if job.Result == nil || job.Result.SyncedCount != 1 || job.Result.SkippedCount != 1 {
+		t.Errorf("second pull = %+v, want 1 synced and 1 skipped", job)
+	}
+	if got := s.syncer.cursors; len(got) != 2 || got[0].SinceID != "" || got[1].SinceID != "101" {
+		t.Errorf("cursors = %+v, want the second pull to start after 101", got)
+	}
+}
+
+func TestPullContentSkipsRepeatedTweets(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "first"), tweet("102", "second"), tweet("101", "first")}
+	job := s.pull(t, alice, account.ID)
+	if job.Result == nil || job.Result.SyncedCount != 2 || job.Result.SkippedCount != 1 {
+		t.Errorf("pull = %+v, want 2 synced and the repeat skipped", job)
+	}
+
+	rec := s.do(t, http.MethodGet, "/api/content", alice, "")
+	if content := decode[[]models.Content](t, rec); len(content) != 2 {
+		t.Errorf("stored %d posts, want 2", len(content))
+	}
+}
+
+func TestPullContentUpdatesEditedTweets(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	original := tweet("101", "frist post")
+	original.EditHistoryIDs = []string{"101"}
+	s.syncer.tweets = []twitter.SyncedTweet{original}
+	s.pull(t, alice, account.ID)
+
+	// The edit is a new tweet carrying the ID of the original in its history
+	edited := tweet("105", "first post")
+	edited.EditHistoryIDs = []string{"101", "105"}
+	s.syncer.tweets = []twitter.SyncedTweet{edited}
+	job := s.pull(t, alice, account.ID)
+	if job.Result == nil || job.Result.UpdatedCount != 1 || job.Result.SyncedCount != 0 {
+		t.Fatalf("pull = %+v, want the post updated", job)
+	}
+
+	rec := s.do(t, http.MethodGet, "/api/content", alice, "")
+	content := decode[[]models.Content](t, rec)
+	if len(content) != 1 {
+		t.Fatalf("stored %d posts, want 1", len(content))
+	}
+	if got := content[0]; *got.OriginalText != "first post" || got.EditedAt == nil || len(got.EditHistoryIDs) != 2 {
+		t.Errorf("content = %+v, want the edited text and history", got)
+	}
+
+	rec = s.do(t, http.MethodGet, "/api/content/"+strconv.Itoa(content[0].ID)+"/revisions", alice, "")
+	revisions := decode[[]models.ContentRevision](t, rec)
+	if len(revisions) != 1 || *revisions[0].OriginalText != "frist post" || *revisions[0].ExternalPostID != "101" {
+		t.Errorf("revisions = %+v, want the original text of 101", revisions)
+	}
+
+	// Pulling the same version again changes nothing
+	job = s.pull(t, alice, account.ID)
+	if job.Result == nil || job.Result.UpdatedCount != 0 || job.Result.SkippedCount != 1 {
+		t.Errorf("repeated pull = %+v, want the post skipped", job)
+	}
+}
+
+func TestPullContentStoresMedia(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	withMedia := tweet("101", "look")
+	withMedia.Media = []twitter.SyncedMedia{
+		{Key: "3_1", Type: "photo", URL: "https://pbs.twimg.com/media/1.jpg", Width: 1200, Height: 800, AltText: "a cat"},
+		{Key: "7_2", Type: "video", URL: "https://video.twimg.com/2.mp4", DurationMs: 15000},
+	}
+	s.syncer.tweets = []twitter.SyncedTweet{withMedia}
+	s.pull(t, alice, account.ID)
+
+	content := decode[[]models.Content](t, s.do(t, http.MethodGet, "/api/content", alice, ""))
+	if len(content) != 1 || len(content[0].Media) != 2 {
+		t.Fatalf("content = %+v, want the post with its photo and video", content)
+	}
+	photo, video := content[0].Media[0], content[0].Media[1]
+	if photo.MediaKey != "3_1" || *photo.URL != "https://pbs.twimg.com/media/1.jpg" || *photo.Width != 1200 || *photo.AltText != "a cat" {
+		t.Errorf("photo = %+v", photo)
+	}
+	if video.Type != "video" || *video.DurationMs != 15000 || video.PreviewURL != nil {
+		t.Errorf("video = %+v", video)
+	}
+}
+
+func TestContentRelationFilters(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	related := func(id, conversationID, relation, parentID string) twitter.SyncedTweet {
+		post := tweet(id, "post "+id)
+		n, _ := strconv.Atoi(id)
+		post.PostedAt = post.PostedAt.Add(time.Duration(n) * time.Minute)
+		post.ConversationID, post.Relation, post.ParentID = conversationID, relation, parentID
+		return post
+	}
+	// Newest first, as pulled: the thread continuation is stored before its start
+	s.syncer.tweets = []twitter.SyncedTweet{
+		related("105", "105", twitter.RelationRetweet, "90"),
+		related("104", "80", twitter.RelationReply, "80"),
+		related("103", "101", twitter.RelationThread, "102"),
+		related("102", "101", twitter.RelationThread, "101"),
+		related("101", "101", "", ""),
+	}
+	s.pull(t, alice, account.ID)
+
+	list := func(query string) []string {
+		var ids []string
+		for _, content := range decode[[]models.Content](t, s.do(t, http.MethodGet, "/api/content"+query, alice, "")) {
+			ids = append(ids, *content.ExternalPostID)
+		}
+		return ids
+	}
+	for query, want := range map[string]string{
+		"":                      "105,104,103,102,101",
+		"?exclude_replies=true": "105,103,102,101",
+		"?exclude_retweets=true&exclude_replies=1":   "103,102,101",
+		"?collapse_threads=true":                     "105,104,101",
+		"?collapse_threads=true&exclude_retweets=no": "105,104,101",
+	} {
+		if got := strings.Join(list(query), ","); got != want {
+			t.Errorf("GET /api/content%s = %s, want %s", query, got, want)
+		}
+	}
+
+	content := decode[[]models.Content](t, s.do(t, http.MethodGet, "/api/content", alice, ""))
+	byID := make(map[string]models.Content)
+	for _, c := range content {
+		byID[*c.ExternalPostID] = c
+	}
+	if parent := byID["103"].ParentContentID; parent == nil || *parent != byID["102"].ID {
+		t.Errorf("parent of 103 = %v, want 102 stored after it", parent)
+	}
+	if parent := byID["104"].ParentContentID; parent != nil {
+		t.Errorf("parent of 104 = %v, want none since the post replied to is not stored", *parent)
+	}
+}
+
+func TestCollapsedThreadsFollowFilters(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	related := func(id, text, conversationID, relation, parentID string) twitter.SyncedTweet {
+		post := tweet(id, text)
+		n, _ := strconv.Atoi(id)
+		post.PostedAt = post.PostedAt.Add(time.Duration(n) * time.Minute)
+		post.ConversationID, post.Relation, post.ParentID = conversationID, relation, parentID
+		return post
+	}
+	// A thread where only the continuation has the hashtag, and a thread
+	// continuing a reply
+	s.syncer.tweets = []twitter.SyncedTweet{
+		related("204", "more", "80", twitter.RelationThread, "203"),
+		related("203", "@bob agreed", "80", twitter.RelationReply, "80"),
+		related("202", "and #launch today", "201", twitter.RelationThread, "201"),
+		related("201", "news", "201", "", ""),
+	}
+	s.pull(t, alice, account.ID)
+
+	for query, want := range map[string]string{
+		"?collapse_threads=true":                      "203,201",
+		"?collapse_threads=true&hashtag=launch":       "202",
+		"?collapse_threads=true&exclude_replies=true": "204,201",
+		"?collapse_threads=true&mention=bob":          "203",
+	} {
+		var ids []string
+		for _, content := range decode[[]models.Content](t, s.do(t, http.MethodGet, "/api/content"+query, alice, "")) {
+			ids = append(ids, *content.ExternalPostID)
+		}
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("GET /api/content%s = %s, want %s", query, got, want)
+		}
+	}
+}
+
+func TestCreateContentTagLength(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+
+	for i, tc := range []struct {
+		tag  string
+		want int
+	}{
+		{strings.Repeat("ż", 100), http.StatusCreated},
+		{strings.Repeat("a", 101), http.StatusBadRequest},
+	} {
+		body := fmt.Sprintf(`{"platform": "twitter", "link": "https://x.com/alice/status/%d", "tags": [%q]}`, i, tc.tag)
+		if rec := s.do(t, http.MethodPost, "/api/content", alice, body); rec.Code != tc.want {
+			t.Errorf("tag of %d characters: status = %d, want %d: %s", len([]rune(tc.tag)), rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestGetContentRevisionsOfOtherUser(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	bob := s.user(t, "bob", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "first")}
+	s.pull(t, alice, account.ID)
+	content := decode[[]models.Content](t, s.do(t, http.MethodGet, "/api/content", alice, ""))
+
+	rec := s.do(t, http.MethodGet, "/api/content/"+strconv.Itoa(content[0].ID)+"/revisions", bob, "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestPullContentResumesPages(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice", AccountID: ptr("42")})
+
+	// The first pull stops with pages left
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("103", "third"), tweet("102", "second")}
+	s.syncer.nextToken = "next_102"
+	s.pull(t, alice, account.ID)
+
+	cursor, err := s.store.GetSyncCursor(t.Context(), account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.NewestID != nil || cursor.PaginationToken == nil || *cursor.PaginationToken != "next_102" ||
+		cursor.HighWaterID == nil || *cursor.HighWaterID != "103" || cursor.OldestID == nil || *cursor.OldestID != "102" {
+		t.Errorf("cursor = %+v, want the pages left kept and 103 as the high-water mark", cursor)
+	}
+
+	// The second resumes from them and catches up to the high-water mark
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "first")}
+	s.syncer.nextToken = ""
+	s.pull(t, alice, account.ID)
+
+	s.syncer.tweets = nil
+	s.pull(t, alice, account.ID)
+
+	want := []twitter.Cursor{{}, {PaginationToken: "next_102"}, {SinceID: "103"}}
+	if got := s.syncer.cursors; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("cursors = %+v, want %+v", got, want)
+	}
+	if cursor, _ := s.store.GetSyncCursor(t.Context(), account.ID); cursor.OldestID == nil || *cursor.OldestID != "101" {
+		t.Errorf("cursor = %+v, want 101 as the oldest tweet", cursor)
+	}
+}
+
+func TestRewindSyncCursor(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.user(t, "admin", models.RoleAdmin)
+	manager := s.user(t, "manager", models.RoleManager)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice", AccountID: ptr("42")})
+	path := "/api/admin/social-accounts/" + strconv.Itoa(account.ID) + "/sync-cursor"
+
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("102", "second"), tweet("101", "first")}
+	s.pull(t, alice, account.ID)
+
+	if rec := s.do(t, http.MethodPut, path, manager, `{"newest_id": "101"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("manager got %d, want 403", rec.Code)
+	}
+	if rec := s.do(t, http.MethodPut, path, admin, `{"newest_id": "latest"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("rewinding to a non-ID returned %d, want 400", rec.Code)
+	}
+	if rec := s.do(t, http.MethodGet, "/api/admin/social-accounts/999/sync-cursor", admin, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("cursor of a missing account returned %d, want 404", rec.Code)
+	}
+
+	rec := s.do(t, http.MethodPut, path, admin, `{"newest_id": "101"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rewind returned %d: %s", rec.Code, rec.Body)
+	}
+	if cursor := decode[models.SyncCursor](t, rec); cursor.NewestID == nil || *cursor.NewestID != "101" || cursor.HighWaterID == nil {
+		t.Errorf("cursor = %+v, want it rewound to 101 with the high-water mark kept", cursor)
+	}
+	s.pull(t, alice, account.ID)
+
+	if rec := s.do(t, http.MethodPut, path, admin, `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("reset returned %d: %s", rec.Code, rec.Body)
+	}
+	rec = s.do(t, http.MethodGet, path, admin, "")
+	if cursor := decode[models.SyncCursor](t, rec); cursor.NewestID != nil || cursor.HighWaterID != nil {
+		t.Errorf("cursor = %+v, want it reset", cursor)
+	}
+	s.pull(t, alice, account.ID)
+
+	if got := s.syncer.cursors; len(got) != 3 || got[1].SinceID != "101" || got[2].SinceID != "" {
+		t.Errorf("cursors = %+v, want pulls from the newest, after 101 and from the newest again", got)
+	}
+}
+
+func TestPullContentPrefersOAuthToken(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{
+		Platform: "twitter", AccountName: "alice", AccountID: ptr("42"), AccessToken: ptr("user-token"),
+	})
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "hello")}
+
+	if job := s.pull(t, alice, account.ID); job.Status != models.PullJobSucceeded {
+		t.Fatalf("job = %+v, want it to have succeeded", job)
+	}
+	if got := s.syncer.oauthTokens; len(got) != 1 || got[0] != "user-token" {
+		t.Errorf("oauth tokens used = %q, want the account's token", got)
+	}
+}
+
+func TestPullContentStoresRefreshedToken(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{
+		Platform: "twitter", AccountName: "alice", AccountID: ptr("42"), AccessToken: ptr("old-token"), RefreshToken: ptr("old-refresh"),
+	})
+	expiresAt := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	s.syncer.refreshed = &twitter.Token{AccessToken: "new-token", RefreshToken: "new-refresh", ExpiresAt: expiresAt}
+
+	if job := s.pull(t, alice, account.ID); job.Status != models.PullJobSucceeded {
+		t.Fatalf("job = %+v, want it to have succeeded", job)
+	}
+
+	stored, err := s.store.GetSocialAccountByID(t.Context(), account.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored.AccessToken != "new-token" || *stored.RefreshToken != "new-refresh" || !stored.TokenExpiresAt.Equal(expiresAt) {
+		t.Errorf("stored tokens = %q, %q expiring %v, want the refreshed ones", *stored.AccessToken, *stored.RefreshToken, stored.TokenExpiresAt)
+	}
+}
+
+func TestPullContentFallsBackWhenRefreshFails(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{
+		Platform: "twitter", AccountName: "alice", AccountID: ptr("42"), AccessToken: ptr("revoked-token"),
+	})
+	s.syncer.oauthErr = fmt.Errorf("failed to fetch tweets: %w", twitter.ErrTokenRefresh)
+	s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "hello")}
+
+	if job := s.pull(t, alice, account.ID); job.Result == nil || job.Result.SyncedCount != 1 {
+		t.Errorf("job = %+v, want 1 tweet synced with the app token", job)
+	}
+}
+
+func TestPullContentFailures(t *testing.T) {
+	t.Run("api error", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+		s.syncer.err = errors.New("twitter API error (status 503)")
+
+		job := s.pull(t, alice, account.ID)
+		if job.Status != models.PullJobFailed || job.Error == nil || *job.Error != s.syncer.err.Error() || job.FinishedAt == nil {
+			t.Errorf("job = %+v, want it to have failed with the error", job)
+		}
+
+		runs := s.store.SyncRuns()
+		if len(runs) != 1 || runs[0].Status != models.SyncRunFailed || runs[0].Error == nil || *runs[0].Error != s.syncer.err.Error() {
+			t.Errorf("sync runs = %+v, want one failed run with the error", runs)
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+		s.syncer.err = &twitter.RateLimitError{RetryAfter: 60}
+
+		job := s.pull(t, alice, account.ID)
+		if job.Status != models.PullJobQueued || job.Error == nil {
+			t.Fatalf("job = %+v, want it queued again with the error", job)
+		}
+		if wait := time.Until(job.RunAt); wait < 50*time.Second || wait > 70*time.Second {
+			t.Errorf("job runs again in %v, want it to wait for the rate limit", wait)
+		}
+
+		// The job is not due until the limit resets
+		s.runJobs(t)
+		if runs := s.store.SyncRuns(); len(runs) != 0 {
+			t.Errorf("sync runs = %+v, want none for a deferred pull", runs)
+		}
+	})
+
+	t.Run("deferred repeatedly", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+		s.syncer.err = &twitter.RateLimitError{RetryAfter: 60}
+
+		job := s.pull(t, alice, account.ID)
+		location := "/api/jobs/" + strconv.Itoa(job.ID)
+		for i := 0; i < maxPullAttempts+2; i++ {
+			s.store.Advance(time.Minute)
+			s.runJobs(t)
+			if job = decode[models.PullJob](t, s.do(t, http.MethodGet, location, alice, "")); job.Status != models.PullJobQueued {
+				t.Fatalf("after %d more deferrals job = %+v, want it still queued", i+1, job)
+			}
+		}
+
+		// Once the limit allows it, the pull goes through
+		s.syncer.err = nil
+		s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "first")}
+		s.store.Advance(time.Minute)
+		s.runJobs(t)
+		job = decode[models.PullJob](t, s.do(t, http.MethodGet, location, alice, ""))
+		if job.Status != models.PullJobSucceeded || job.Result == nil || job.Result.SyncedCount != 1 {
+			t.Errorf("job = %+v, want it to have succeeded", job)
+		}
+	})
+
+	t.Run("reclaimed", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+		rec := s.do(t, http.MethodPost, "/api/social-accounts/"+strconv.Itoa(account.ID)+"/pull", alice, "")
+		location := "/api/jobs/" + strconv.Itoa(decode[models.PullJob](t, rec).ID)
+
+		// The first worker stalls past its lease and a second one claims the job
+		stale, err := s.store.ClaimPullJobs(t.Context(), 10, time.Minute)
+		if err != nil || len(stale) != 1 {
+			t.Fatalf("claimed %+v, %v, want one job", stale, err)
+		}
+		s.store.Advance(2 * time.Minute)
+		current, err := s.store.ClaimPullJobs(t.Context(), 10, time.Minute)
+		if err != nil || len(current) != 1 {
+			t.Fatalf("claimed %+v, %v, want the job again", current, err)
+		}
+
+		// The stale worker's failure is dropped
+		s.syncer.err = errors.New("connection reset")
+		s.handler.RunPullJob(t.Context(), stale[0])
+		job := decode[models.PullJob](t, s.do(t, http.MethodGet, location, alice, ""))
+		if job.Status != models.PullJobRunning || job.Error != nil {
+			t.Fatalf("job = %+v, want it still running for the second worker", job)
+		}
+
+		s.syncer.err = nil
+		s.syncer.tweets = []twitter.SyncedTweet{tweet("101", "first")}
+		s.handler.RunPullJob(t.Context(), current[0])
+		job = decode[models.PullJob](t, s.do(t, http.MethodGet, location, alice, ""))
+		if job.Status != models.PullJobSucceeded || job.Result == nil || job.Result.SyncedCount != 1 {
+			t.Errorf("job = %+v, want the second worker's result", job)
+		}
+
+		// Nor can the stale worker overwrite the result afterwards
+		s.handler.RunPullJob(t.Context(), stale[0])
+		if again := decode[models.PullJob](t, s.do(t, http.MethodGet, location, alice, "")); again.Status != job.Status || again.Result == nil || again.Result.SyncedCount != 1 || !again.FinishedAt.Equal(*job.FinishedAt) {
+			t.Errorf("job = %+v, want it unchanged by the stale worker", again)
+		}
+	})
+}
+
+func TestPullContentQueuesOneJobPerAccount(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+	path := "/api/social-accounts/" + strconv.Itoa(account.ID) + "/pull"
+
+	first := decode[models.PullJob](t, s.do(t, http.MethodPost, path, alice, ""))
+	second := decode[models.PullJob](t, s.do(t, http.MethodPost, path, alice, ""))
+	if first.ID == 0 || first.ID != second.ID {
+		t.Errorf("queued jobs %d and %d, want the pending job reused", first.ID, second.ID)
+	}
+
+	s.runJobs(t)
+	third := decode[models.PullJob](t, s.do(t, http.MethodPost, path, alice, ""))
+	if third.ID == first.ID || third.Status != models.PullJobQueued {
+		t.Errorf("job after the pull finished = %+v, want a new queued job", third)
+	}
+}
+
+func TestGetJobOfOtherUser(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	mallory := s.user(t, "mallory", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	job := decode[models.PullJob](t, s.do(t, http.MethodPost, "/api/social-accounts/"+strconv.Itoa(account.ID)+"/pull", alice, ""))
+	if rec := s.do(t, http.MethodGet, "/api/jobs/"+strconv.Itoa(job.ID), mallory, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("reading another user's job returned %d, want 404", rec.Code)
+	}
+}
+
+func TestPullContentOfOtherUsersAccount(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	bob := s.user(t, "bob", models.RoleCreator)
+	account := s.account(t, alice, models.CreateSocialAccountRequest{Platform: "twitter", AccountName: "alice"})
+
+	rec := s.do(t, http.MethodPost, "/api/social-accounts/"+strconv.Itoa(account.ID)+"/pull", bob, "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("pull of another user's account returned %d, want 404", rec.Code)
+	}
+	if runs := s.store.SyncRuns(); len(runs) != 0 {
+		t.Errorf("sync runs = %+v, want none", runs)
+	}
+}
+
+func TestTwitterOAuthCallback(t *testing.T) {
+	callback := func(params url.Values) string {
+		return "/api/auth/twitter/callback?" + params.Encode()
+	}
+
+	t.Run("missing params", func(t *testing.T) {
+		s := newTestServer(t)
+		rec := s.do(t, http.MethodGet, callback(url.Values{"code": {"good"}}), nil, "")
+		assertRedirect(t, rec, "/?twitter_oauth_error=missing_params")
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		s := newTestServer(t)
+		rec := s.do(t, http.MethodGet, callback(url.Values{"error": {"access_denied"}}), nil, "")
+		assertRedirect(t, rec, "/?twitter_oauth_error=access_denied")
+	})
+
+	t.Run("exchange fails", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		rec := s.do(t, http.MethodGet, callback(url.Values{"code": {"bad"}, "state": {strconv.Itoa(alice.ID)}}), nil, "")
+		assertRedirect(t, rec, "/?twitter_oauth_error=token_exchange_failed")
+	})
+
+	t.Run("user lookup fails", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		s.oauth.userErr = errors.New("unauthorized")
+		rec := s.do(t, http.MethodGet, callback(url.Values{"code": {"good"}, "state": {strconv.Itoa(alice.ID)}}), nil, "")
+		assertRedirect(t, rec, "/?twitter_oauth_error=user_fetch_failed")
+	})
+
+	t.Run("connects new account", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		s.oauth.tokens = twitter.TokenResponse{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 7200}
+		s.oauth.user.Data.ID, s.oauth.user.Data.Username = "42", "alice_x"
+
+		rec := s.do(t, http.MethodGet, callback(url.Values{"code": {"good"}, "state": {strconv.Itoa(alice.ID)}}), nil, "")
+		assertRedirect(t, rec, "/?twitter_oauth_success=true")
+
+		account, err := s.store.GetSocialAccountByPlatformAndAccountID(t.Context(), alice.ID, "twitter", "42")
+		if err != nil {
+			t.Fatalf("account was not created: %v", err)
+		}
+		if account.AccountName != "alice_x" || *account.AccessToken != "access" || *account.RefreshToken != "refresh" {
+			t.Errorf("account = %+v, want alice_x with the exchanged tokens", account)
+		}
+		if account.TokenExpiresAt == nil || time.Until(*account.TokenExpiresAt) < time.Hour {
+			t.Errorf("token expires at %v, want about two hours from now", account.TokenExpiresAt)
+		}
+	})
+
+	t.Run("reconnects existing account", func(t *testing.T) {
+		s := newTestServer(t)
+		alice := s.user(t, "alice", models.RoleCreator)
+		existing := s.account(t, alice, models.CreateSocialAccountRequest{
+			Platform: "twitter", AccountName: "alice_x", AccountID: ptr("42"), AccessToken: ptr("old"),
+		})
+		s.oauth.tokens = twitter.TokenResponse{AccessToken: "new", RefreshToken: "refresh", ExpiresIn: 7200}
+		s.oauth.user.Data.ID, s.oauth.user.Data.Username = "42", "alice_x"
+
+		rec := s.do(t, http.MethodGet, callback(url.Values{"code": {"good"}, "state": {strconv.Itoa(alice.ID)}}), nil, "")
+		assertRedirect(t, rec, "/?twitter_oauth_success=true")
+
+		accounts, err := s.store.GetSocialAccountsByUserID(t.Context(), alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(accounts) != 1 {
+			t.Fatalf("user has %d accounts, want the existing one only", len(accounts))
+		}
+		account, err := s.store.GetSocialAccountByID(t.Context(), existing.ID, alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *account.AccessToken != "new" {
+			t.Errorf("access token = %q, want it replaced", *account.AccessToken)
+		}
+	})
+}
+
+func assertRedirect(t *testing.T, rec *httptest.ResponseRecorder, location string) {
+	t.Helper()
+
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("status = %d, want a redirect: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get(echo.HeaderLocation); got != location {
+		t.Errorf("redirected to %q, want %q", got, location)
+	}
+}
+
+func TestTwitterRateLimitsRequireAdmin(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.user(t, "admin", models.RoleAdmin)
+	manager := s.user(t, "manager", models.RoleManager)
+
+	if rec := s.do(t, http.MethodGet, "/api/admin/twitter/rate-limits", manager, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("manager got %d, want 403", rec.Code)
+	}
+	rec := s.do(t, http.MethodGet, "/api/admin/twitter/rate-limits", admin, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin got %d: %s", rec.Code, rec.Body)
+	}
+	if budgets := decode[[]twitter.Budget](t, rec); len(budgets) != 0 {
+		t.Errorf("budgets = %+v, want none before any call", budgets)
+	}
+}
+
+func TestAdminRoutesRequireRole(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.user(t, "admin", models.RoleAdmin)
+	manager := s.user(t, "manager", models.RoleManager)
+	alice := s.user(t, "alice", models.RoleCreator)
+	bob := s.user(t, "bob", models.RoleCreator)
+	team, err := s.store.CreateTeam(t.Context(), models.CreateTeamRequest{Name: "roster"}, models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range []*models.User{manager, alice} {
+		if err := s.store.AddTeamMember(t.Context(), team.ID, member.ID, models.Actor{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, user := range []*models.User{alice, bob} {
+		body := `{"platform":"twitter","link":"https://x.com/` + user.Username + `/status/1"}`
+		if rec := s.do(t, http.MethodPost, "/api/content", user, body); rec.Code != http.StatusCreated {
+			t.Fatalf("creating content returned %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		user   *models.User
+		status int
+		count  int
+	}{
+		{"creator", alice, http.StatusForbidden, 0},
+		{"manager sees team", manager, http.StatusOK, 1},
+		{"admin sees all", admin, http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(t, http.MethodGet, "/api/admin/content", tt.user, "")
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if rec.Code == http.StatusOK {
+				if contents := decode[[]models.ContentWithUser](t, rec); len(contents) != tt.count {
+					t.Errorf("got %d posts, want %d", len(contents), tt.count)
+				}
+			}
+		})
+	}
+
+	t.Run("only admins change roles", func(t *testing.T) {
+		path := "/api/admin/users/" + strconv.Itoa(bob.ID) + "/role"
+		if rec := s.do(t, http.MethodPut, path, manager, `{"role":"manager"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("manager changing a role got %d, want 403", rec.Code)
+		}
+		rec := s.do(t, http.MethodPut, path, admin, `{"role":"manager"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("admin changing a role got %d: %s", rec.Code, rec.Body)
+		}
+		if user := decode[models.User](t, rec); user.Role != models.RoleManager {
+			t.Errorf("role = %s, want manager", user.Role)
+		}
+	})
+
+	t.Run("admins cannot change their own role", func(t *testing.T) {
+		path := "/api/admin/users/" + strconv.Itoa(admin.ID) + "/role"
+		if rec := s.do(t, http.MethodPut, path, admin, `{"role":"creator"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("got %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		// Without oauth2-proxy headers the user resolves to the empty identity,
+		// which is a creator
+		if rec := s.do(t, http.MethodGet, "/api/admin/users", nil, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("got %d, want 403", rec.Code)
+		}
+	})
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.user(t, "admin", models.RoleAdmin)
+
+	tokens := map[string]string{}
+	for _, scope := range []string{models.TokenScopeReadOnly, models.TokenScopeReadWrite, models.TokenScopeAdmin} {
+		raw := apiTokenPrefix + scope
+		s.store.AddAPIToken(admin.ID, hashAPIToken(raw), scope, time.Now().Add(time.Hour))
+		tokens[scope] = raw
+	}
+	expired := apiTokenPrefix + "expired"
+	s.store.AddAPIToken(admin.ID, hashAPIToken(expired), models.TokenScopeAdmin, time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		status int
+	}{
+		{"read-only reads", tokens[models.TokenScopeReadOnly], http.MethodGet, "/api/content", http.StatusOK},
+		{"read-only writes", tokens[models.TokenScopeReadOnly], http.MethodPost, "/api/content", http.StatusForbidden},
+		{"read-write writes", tokens[models.TokenScopeReadWrite], http.MethodPost, "/api/content", http.StatusCreated},
+		{"read-write on admin route", tokens[models.TokenScopeReadWrite], http.MethodGet, "/api/admin/users", http.StatusForbidden},
+		{"admin on admin route", tokens[models.TokenScopeAdmin], http.MethodGet, "/api/admin/users", http.StatusOK},
+		{"expired", expired, http.MethodGet, "/api/content", http.StatusUnauthorized},
+		{"unknown", apiTokenPrefix + "unknown", http.MethodGet, "/api/content", http.StatusUnauthorized},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"platform":"twitter","link":"https://x.com/admin/status/` + strconv.Itoa(i) + `"}`
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			s.echo.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSponsoredContentIsCheckedForDisclosure(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.user(t, "admin", models.RoleAdmin)
+	alice := s.user(t, "alice", models.RoleCreator)
+
+	body := `{"name":"Launch","brand":"Acme","start_date":"2000-01-01","end_date":"2100-12-31","hashtags":["acmelaunch"],"jurisdictions":["US"]}`
+	rec := s.do(t, http.MethodPost, "/api/admin/campaigns", admin, body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating campaign returned %d: %s", rec.Code, rec.Body)
+	}
+	campaign := decode[models.Campaign](t, rec)
+	target := "/api/admin/campaigns/" + strconv.Itoa(campaign.ID) + "/creators"
+	if rec := s.do(t, http.MethodPost, target, admin, fmt.Sprintf(`{"user_id":%d}`, alice.ID)); rec.Code != http.StatusOK {
+		t.Fatalf("assigning creator returned %d: %s", rec.Code, rec.Body)
+	}
+	// Only the rule of the campaign's jurisdiction applies
+	for _, body := range []string{
+		`{"name":"FTC","jurisdiction":"US","required_hashtags":["ad"],"enabled":true}`,
+		`{"name":"ASA","jurisdiction":"UK","required_hashtags":["advert"],"enabled":true}`,
+	} {
+		if rec := s.do(t, http.MethodPost, "/api/admin/disclosure-rules", admin, body); rec.Code != http.StatusCreated {
+			t.Fatalf("creating rule returned %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	posts := map[string]string{
+		"1": "Loving my new kit #acmelaunch",
+		"2": "#ad Loving my new kit #acmelaunch",
+		"3": "Unrelated post #ad",
+	}
+	for id, text := range posts {
+		body := fmt.Sprintf(`{"platform":"twitter","link":"https://x.com/alice/status/%s","original_text":%q}`, id, text)
+		if rec := s.do(t, http.MethodPost, "/api/content", alice, body); rec.Code != http.StatusCreated {
+			t.Fatalf("creating content returned %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	rec = s.do(t, http.MethodGet, "/api/admin/compliance?status=all", admin, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("compliance returned %d: %s", rec.Code, rec.Body)
+	}
+	statuses := make(map[string]string)
+	for _, result := range decode[[]models.ComplianceResult](t, rec) {
+		statuses[*result.Content.OriginalText] = result.Status
+	}
+	want := map[string]string{
+		posts["1"]: models.ComplianceStatusFail,
+		posts["2"]: models.ComplianceStatusPass,
+	}
+	if fmt.Sprint(statuses) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v: only posts carrying the campaign hashtag are sponsored", statuses, want)
+	}
+
+	rec = s.do(t, http.MethodGet, "/api/admin/audit?target_type=campaign", admin, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("audit returned %d: %s", rec.Code, rec.Body)
+	}
+	var actions []string
+	for _, event := range decode[[]models.AuditEvent](t, rec) {
+		actions = append(actions, event.Action)
+	}
+	if got, want := strings.Join(actions, ","), "campaign.creator_added,campaign.created"; got != want {
+		t.Errorf("audit actions = %s, want %s", got, want)
+	}
+}
+
+// createContent adds a post as user through the API
+func (s *testServer) createContent(t *testing.T, user *models.User, link string) models.Content {
+	t.Helper()
+
+	rec := s.do(t, http.MethodPost, "/api/content", user, fmt.Sprintf(`{"platform": "twitter", "link": %q}`, link))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create content returned %d: %s", rec.Code, rec.Body)
+	}
+	return decode[models.Content](t, rec)
+}
+
+// wantContentEvent checks that a stream event is of a type and about content
+func wantContentEvent(t *testing.T, event sseEvent, eventType string, contentID int) {
+	t.Helper()
+
+	var data models.ContentEvent
+	if err := json.Unmarshal([]byte(event.data), &data); err != nil {
+		t.Fatalf("decoding %q: %v", event.data, err)
+	}
+	if event.event != eventType || data.ContentID != contentID || event.id != strconv.FormatInt(data.ID, 10) {
+		t.Errorf("event %s %s about content %d, want %s about content %d", event.id, event.event, data.ContentID, eventType, contentID)
+	}
+}
+
+func TestContentStreamResumesFromLastEventID(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+	bob := s.user(t, "bob", models.RoleCreator)
+
+	live := s.stream(t, "/api/content/stream", alice, "")
+	first := s.createContent(t, alice, "https://x.com/alice/status/1")
+	s.createContent(t, bob, "https://x.com/bob/status/1")
+	second := s.createContent(t, alice, "https://x.com/alice/status/2")
+	s.handler.contentHub.Broadcast()
+
+	firstEvent := next(t, live)
+	wantContentEvent(t, firstEvent, "content.created", first.ID)
+	secondEvent := next(t, live)
+	wantContentEvent(t, secondEvent, "content.created", second.ID)
+
+	t.Run("after an event", func(t *testing.T) {
+		resumed := s.stream(t, "/api/content/stream", alice, firstEvent.id)
+		if event := next(t, resumed); event != secondEvent {
+			t.Errorf("resumed with %+v, want %+v", event, secondEvent)
+		}
+	})
+
+	t.Run("after the last event", func(t *testing.T) {
+		resumed := s.stream(t, "/api/content/stream", alice, secondEvent.id)
+		third := s.createContent(t, alice, "https://x.com/alice/status/3")
+		s.handler.contentHub.Broadcast()
+		wantContentEvent(t, next(t, resumed), "content.created", third.ID)
+	})
+
+	t.Run("after an event no longer kept", func(t *testing.T) {
+		resumed := s.stream(t, "/api/content/stream", alice, "999999")
+		if event := next(t, resumed); event.event != "reset" {
+			t.Errorf("resumed with %+v, want a reset", event)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/content/stream?last_event_id=abc", nil)
+		req.Header.Set("X-Forwarded-User", alice.UserID)
+		req.Header.Set("X-Forwarded-Email", alice.Email)
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+// An event recorded by a transaction that commits after a later one must not
+// be skipped: the later event is held back until it is served first
+func TestContentStreamWaitsForEarlierTransaction(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user(t, "alice", models.RoleCreator)
+
+	first := s.createContent(t, alice, "https://x.com/alice/status/1")
+	live := s.stream(t, "/api/content/stream", alice, "")
+
+	commit := s.store.OpenContentTransaction(first, models.ContentEventUpdated)
+	second := s.createContent(t, alice, "https://x.com/alice/status/2")
+	s.handler.contentHub.Broadcast()
+
+	select {
+	case event := <-live:
+		t.Fatalf("served %+v while an earlier transaction was open", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	commit()
+	s.handler.contentHub.Broadcast()
+
+	late := next(t, live)
+	wantContentEvent(t, late, "content.updated", first.ID)
+	later := next(t, live)
+	wantContentEvent(t, later, "content.created", second.ID)
+
+	resumed := s.stream(t, "/api/content/stream", alice, late.id)
+	if event := next(t, resumed); event != later {
+		t.Errorf("resumed after the late event with %+v, want %+v", event, later)
+	}
+
+	resumed = s.stream(t, "/api/content/stream", alice, later.id)
+	third := s.createContent(t, alice, "https://x.com/alice/status/3")
+	s.handler.contentHub.Broadcast()
+	wantContentEvent(t, next(t, resumed), "content.created", third.ID)
+}
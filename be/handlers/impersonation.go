@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// actAsHeader lets an admin act as another user for a single request
+	actAsHeader = "X-Act-As-User"
+
+	contextImpersonationKey = "impersonation"
+
+	defaultImpersonationMinutes = 30
+	maxImpersonationMinutes     = 240
+)
+
+// impersonation describes an admin acting as another user for the current request
+type impersonation struct {
+	Admin     *models.User
+	Target    *models.User
+	SessionID *int
+}
+
+// impersonationFromContext returns the active impersonation of the request, if any
+func impersonationFromContext(c echo.Context) *impersonation {
+	imp, _ := c.Get(contextImpersonationKey).(*impersonation)
+	return imp
+}
+
+// Impersonate makes getUserID resolve to another user when an admin sends the
+// act-as header or has an open impersonation session. Responses are marked with
+// X-Impersonating-User and X-Impersonator headers and write requests are logged.
+func (h *Handler) Impersonate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		actAs := c.Request().Header.Get(actAsHeader)
+
+		var user *models.User
+		var err error
+		if hasIdentity(c) {
+			user, err = h.getCurrentUser(c)
+		}
+		if user == nil || err != nil || user.Role != models.RoleAdmin {
+			if actAs != "" {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "only admins can act as another user"})
+			}
+			return next(c)
+		}
+
+		// Managing the session itself always happens as the admin
+		if strings.HasPrefix(c.Path(), "/api/admin/impersonation") {
+			return next(c)
+		}
+
+		var targetID int
+		var sessionID *int
+		if actAs != "" {
+			targetID, err = strconv.Atoi(actAs)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + actAsHeader + " header"})
+			}
+		} else {
+			session, err := h.impersonation.GetActiveImpersonationSession(c.Request().Context(), user.ID)
+			if err == sql.ErrNoRows {
+				return next(c)
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			targetID = session.TargetUserID
+			sessionID = &session.ID
+		}
+
+		target, err := h.users.GetUserByID(c.Request().Context(), targetID)
+		if err == sql.ErrNoRows {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "impersonated user not found"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if target.Role == models.RoleAdmin {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "cannot impersonate another admin"})
+		}
+
+		c.Set(contextImpersonationKey, &impersonation{Admin: user, Target: target, SessionID: sessionID})
+		c.Response().Header().Set("X-Impersonating-User", strconv.Itoa(target.ID))
+		c.Response().Header().Set("X-Impersonator", strconv.Itoa(user.ID))
+
+		err = next(c)
+
+		if method := c.Request().Method; method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions {
+			status := c.Response().Status
+			if he, ok := err.(*echo.HTTPError); ok && !c.Response().Committed {
+				status = he.Code
+			}
+			// Record the action even if the client has gone away since it was made
+			ctx := context.WithoutCancel(c.Request().Context())
+			if logErr := h.impersonation.RecordImpersonationAction(ctx, user.ID, target.ID, sessionID, method, c.Request().URL.Path, status); logErr != nil {
+				log.Printf("Failed to record impersonated %s %s by admin %d: %v", method, c.Request().URL.Path, user.ID, logErr)
+			}
+		}
+
+		return err
+	}
+}
+
+// Impersonation handlers
+
+// StartImpersonation starts a time-limited session in which the admin acts as another user
+func (h *Handler) StartImpersonation(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	var req models.StartImpersonationRequest
+	if err := c.Bind(&req); err != nil || req.UserID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	if req.DurationMinutes == 0 {
+		req.DurationMinutes = defaultImpersonationMinutes
+	}
+	if req.DurationMinutes < 1 || req.DurationMinutes > maxImpersonationMinutes {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "duration_minutes must be between 1 and " + strconv.Itoa(maxImpersonationMinutes),
+		})
+	}
+
+	target, err := h.users.GetUserByID(c.Request().Context(), req.UserID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if target.Role == models.RoleAdmin {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "cannot impersonate another admin"})
+	}
+
+	expiresAt := time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+	session, err := h.impersonation.StartImpersonationSession(c.Request().Context(), admin.ID, target.ID, req.Reason, expiresAt)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	log.Printf("Admin %d started impersonating user %d until %s", admin.ID, target.ID, expiresAt.Format(time.RFC3339))
+	return c.JSON(http.StatusCreated, session)
+}
+
+// GetImpersonation returns the admin's active impersonation session
+func (h *Handler) GetImpersonation(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	session, err := h.impersonation.GetActiveImpersonationSession(c.Request().Context(), admin.ID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no active impersonation session"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, session)
+}
+
+// StopImpersonation ends the admin's active impersonation session
+func (h *Handler) StopImpersonation(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	err = h.impersonation.EndImpersonationSession(c.Request().Context(), admin.ID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no active impersonation session"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "impersonation ended"})
+}
+
+// GetImpersonationActions lists write requests made while impersonating
+func (h *Handler) GetImpersonationActions(c echo.Context) error {
+	if admin, err := h.requireRole(c, models.RoleAdmin); admin == nil {
+		return err
+	}
+
+	filters := make(map[string]int)
+	for _, key := range []string{"admin_id", "target_user_id"} {
+		if value := c.QueryParam(key); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid " + key})
+			}
+			filters[key] = id
+		}
+	}
+
+	actions, err := h.impersonation.GetImpersonationActions(c.Request().Context(), filters)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if actions == nil {
+		actions = []models.ImpersonationAction{}
+	}
+
+	return c.JSON(http.StatusOK, actions)
+}
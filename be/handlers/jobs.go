@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/Armatorix/SocialTracker/be/twitter"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPullWorkers = 2
+	// maxPullAttempts bounds how often a job is claimed again after its
+	// worker died while running it. Pulls deferred by the rate limit do not
+	// count.
+	maxPullAttempts = 3
+)
+
+// PullWorkers returns how many pull jobs run at once in a process, from
+// PULL_WORKERS. The API server runs none when it is 0, leaving the jobs to
+// separate worker processes.
+func PullWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("PULL_WORKERS"))
+	if err != nil || workers < 0 {
+		return defaultPullWorkers
+	}
+	return workers
+}
+
+// pullSupported reports whether content can be pulled from accounts on platform
+func pullSupported(platform string) bool {
+	return platform == "twitter"
+}
+
+// LookupRemovedPosts returns which of the given posts on platform the
+// platform no longer has
+func (h *Handler) LookupRemovedPosts(ctx context.Context, platform string, ids []string) ([]string, error) {
+	switch platform {
+	case "twitter":
+		return h.twitterSyncer.FindRemovedTweets(ctx, ids)
+	default:
+		return nil, fmt.Errorf("looking up posts on %s is not supported", platform)
+	}
+}
+
+// GetJob reports the status, progress and result of one of the user's pull jobs
+func (h *Handler) GetJob(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	jobID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid job id"})
+	}
+
+	job, err := h.jobs.GetPullJob(c.Request().Context(), jobID, userID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "job not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// RunPullJob pulls the account of a claimed job and records the outcome on
+// the job. A pull deferred by the X API rate limit is queued again for when
+// the limit resets. If the claim ran out and another worker claimed the job
+// meanwhile, the outcome is dropped and left to that worker.
+func (h *Handler) RunPullJob(ctx context.Context, job models.PullJob) {
+	fail := func(err error, retryAfter time.Duration) {
+		err = h.jobs.FailPullJob(context.WithoutCancel(ctx), job, err.Error(), retryAfter)
+		if errors.Is(err, repository.ErrPullJobLost) {
+			log.Printf("Pull job %d was claimed by another worker, dropping its failure", job.ID)
+		} else if err != nil {
+			log.Printf("Failed to record failure of pull job %d: %v", job.ID, err)
+		}
+	}
+
+	if job.Attempts > maxPullAttempts {
+		fail(fmt.Errorf("gave up after %d attempts", maxPullAttempts), 0)
+		return
+	}
+
+	account, err := h.accounts.GetSocialAccountByID(ctx, job.SocialAccountID, job.UserID)
+	if err == sql.ErrNoRows {
+		fail(fmt.Errorf("account not found"), 0)
+		return
+	}
+	if err != nil {
+		fail(err, 0)
+		return
+	}
+
+	progress := func(p models.PullJobProgress) {
+		if err := h.jobs.UpdatePullJobProgress(ctx, job, p); err != nil {
+			log.Printf("Failed to update progress of pull job %d: %v", job.ID, err)
+		}
+	}
+
+	var response models.SyncResponse
+	switch account.Platform {
+	case "twitter":
+		response, err = h.syncTwitterAccount(ctx, job.UserID, account, job.Actor, progress)
+	default:
+		err = fmt.Errorf("auto-sync not supported for platform: %s", account.Platform)
+	}
+
+	if rle, ok := twitter.IsRateLimitError(err); ok {
+		log.Printf("Pull job %d deferred by the rate limit for %d seconds", job.ID, rle.RetryAfter)
+		fail(err, time.Duration(rle.RetryAfter)*time.Second)
+		return
+	}
+
+	h.recordSyncRun(ctx, account, response, err)
+	if err != nil {
+		h.notifySyncFailed(account, err)
+		fail(err, 0)
+		return
+	}
+
+	err = h.jobs.CompletePullJob(context.WithoutCancel(ctx), job, response)
+	if errors.Is(err, repository.ErrPullJobLost) {
+		log.Printf("Pull job %d was claimed by another worker, dropping its result", job.ID)
+	} else if err != nil {
+		log.Printf("Failed to complete pull job %d: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/notify"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	notificationHistoryLimit = 50
+	alertTimeout             = 30 * time.Second
+)
+
+// alertAccountOwner alerts an account's owner in the background, unless they
+// turned alerts off or were already sent the same alert
+func (h *Handler) alertAccountOwner(account models.SocialAccount, kind, key string, msg notify.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+		defer cancel()
+
+		recipient, err := h.users.GetNotificationRecipient(ctx, account.UserID)
+		if err != nil {
+			log.Printf("Failed to load notification preferences of user %d: %v", account.UserID, err)
+			return
+		}
+		if _, err := h.notifier.Alert(ctx, *recipient, kind, key, msg); err != nil {
+			log.Printf("Failed to send %s alert for account %d: %v", kind, account.ID, err)
+		}
+	}()
+}
+
+// Notification handlers
+
+// GetNotificationPreferences returns the current user's notification preferences
+func (h *Handler) GetNotificationPreferences(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	recipient, err := h.users.GetNotificationRecipient(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, recipient.Preferences)
+}
+
+// UpdateNotificationPreferences changes the current user's notification preferences
+func (h *Handler) UpdateNotificationPreferences(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req models.UpdateNotificationPreferencesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	if req.DigestFrequency != nil && !models.IsValidDigestFrequency(*req.DigestFrequency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "digest_frequency must be off, daily or weekly"})
+	}
+	if req.SlackWebhookURL != nil {
+		webhookURL := strings.TrimSpace(*req.SlackWebhookURL)
+		if webhookURL != "" {
+			u, err := url.Parse(webhookURL)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "slack_webhook_url must be an absolute https URL"})
+			}
+		}
+		req.SlackWebhookURL = &webhookURL
+	}
+
+	prefs, err := h.notifications.UpdateNotificationPreferences(c.Request().Context(), userID, req, h.actor(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, prefs)
+}
+
+// SendTestNotification sends a test message over the current user's enabled channels
+func (h *Handler) SendTestNotification(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	recipient, err := h.users.GetNotificationRecipient(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	msg := notify.Message{
+		Subject: "SocialTracker test notification",
+		Text:    "Notifications are set up. Digests and alerts will be sent here.\n",
+	}
+	delivered, err := h.notifier.Send(c.Request().Context(), *recipient, msg)
+	if errors.Is(err, notify.ErrNoChannel) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if delivered == 0 {
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
+
+	response := map[string]interface{}{"delivered": delivered}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetNotifications lists the digests and alerts recently sent to the current user
+func (h *Handler) GetNotifications(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	notifications, err := h.notifications.GetNotifications(c.Request().Context(), userID, notificationHistoryLimit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if notifications == nil {
+		notifications = []models.Notification{}
+	}
+
+	return c.JSON(http.StatusOK, notifications)
+}
+
+// PreviewDigest renders the current user's most recent digest. The frequency
+// query parameter defaults to the user's own, or daily when they get none.
+func (h *Handler) PreviewDigest(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	recipient, err := h.users.GetNotificationRecipient(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	frequency := c.QueryParam("frequency")
+	if frequency == "" {
+		frequency = recipient.Preferences.DigestFrequency
+	}
+	if frequency == models.DigestOff {
+		frequency = models.DigestDaily
+	}
+	from, to, _, ok := notify.DigestPeriod(frequency, time.Now())
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "frequency must be daily or weekly"})
+	}
+
+	digest, err := h.notifications.GetDigest(c.Request().Context(), &recipient.User, frequency, from, to, notify.DigestPostsPerCreator)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": notify.RenderDigest(*digest),
+		"digest":  digest,
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+// rolePriority decides which role wins when a user is in several mapped groups
+var rolePriority = map[string]int{
+	models.RoleCreator: 1,
+	models.RoleManager: 2,
+	models.RoleAdmin:   3,
+}
+
+// RoleMapping maps identity provider group names to application roles
+type RoleMapping map[string]string
+
+// ParseRoleMapping parses a mapping of the form "group=role,other-group=role"
+func ParseRoleMapping(s string) (RoleMapping, error) {
+	mapping := RoleMapping{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		group = strings.TrimSpace(group)
+		role = strings.TrimSpace(role)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid group mapping %q, expected group=role", pair)
+		}
+		if !models.IsValidRole(role) {
+			return nil, fmt.Errorf("invalid role %q for group %q", role, group)
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
+
+// Resolve returns the highest priority role mapped from the given groups
+func (m RoleMapping) Resolve(groups []string) (string, bool) {
+	var role string
+	for _, group := range groups {
+		mapped, ok := m[group]
+		if ok && rolePriority[mapped] > rolePriority[role] {
+			role = mapped
+		}
+	}
+	return role, role != ""
+}
+
+// requestGroups returns the identity provider groups of the current request.
+// oauth2-proxy passes them in X-Forwarded-Groups (or X-Auth-Request-Groups);
+// otherwise the groups claim of the forwarded OIDC access token is used.
+func (h *Handler) requestGroups(c echo.Context) []string {
+	header := c.Request().Header.Get("X-Forwarded-Groups")
+	if header == "" {
+		header = c.Request().Header.Get("X-Auth-Request-Groups")
+	}
+	if header != "" {
+		var groups []string
+		for _, group := range strings.Split(header, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	}
+
+	token := c.Request().Header.Get("X-Forwarded-Access-Token")
+	if token == "" {
+		token = c.Request().Header.Get("X-Auth-Request-Access-Token")
+	}
+	return groupsFromJWT(token, h.groupsClaim)
+}
+
+// groupsFromJWT reads a groups claim from a JWT payload. The token is not
+// verified: it is only trusted because oauth2-proxy sits in front of us.
+func groupsFromJWT(token, claim string) []string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+
+	var groups []string
+	switch v := claims[claim].(type) {
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok && s != "" {
+				groups = append(groups, s)
+			}
+		}
+	case string:
+		if v != "" {
+			groups = append(groups, v)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	contentStreamBatch = 200
+	// Comments keep proxies from closing idle streams; each heartbeat also
+	// re-checks for events in case a notification was lost
+	contentStreamHeartbeat = 25 * time.Second
+	// How soon to re-check for events held back behind a running transaction,
+	// which may finish without a notification
+	contentStreamHeldRetry = time.Second
+)
+
+// Content stream handlers
+
+// StreamContent streams changes to the current user's content as Server-Sent Events
+func (h *Handler) StreamContent(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	return h.streamContentEvents(c, nil, &userID)
+}
+
+// StreamAllContent streams changes to all content as Server-Sent Events.
+// Managers only receive changes to their teams' creators' content.
+func (h *Handler) StreamAllContent(c echo.Context) error {
+	viewer, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if viewer == nil {
+		return err
+	}
+
+	return h.streamContentEvents(c, viewer, nil)
+}
+
+// streamContentEvents writes content events in commit order until the client
+// disconnects. Each event's id can be sent back in Last-Event-ID (or
+// last_event_id, for clients that cannot set headers) to resume after it. When
+// that event is no longer kept, a "reset" event tells the client to reload
+// instead.
+func (h *Handler) streamContentEvents(c echo.Context, viewer *models.User, userID *int) error {
+	ctx := c.Request().Context()
+	position, err := h.contentEvents.GetLatestContentEventPosition(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	reset := false
+	resumeFrom := c.Request().Header.Get("Last-Event-ID")
+	if resumeFrom == "" {
+		resumeFrom = c.QueryParam("last_event_id")
+	}
+	if resumeFrom != "" {
+		id, err := strconv.ParseInt(resumeFrom, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid last event id"})
+		}
+		resumed, err := h.contentEvents.GetContentEventPosition(ctx, id)
+		if err == sql.ErrNoRows {
+			reset = true
+		} else if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		} else {
+			position = *resumed
+		}
+	}
+
+	wake, unsubscribe := h.contentHub.Subscribe()
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	// Stop nginx-style proxies from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if reset {
+		if position.ID > 0 {
+			fmt.Fprintf(res, "id: %d\n", position.ID)
+		}
+		fmt.Fprint(res, "event: reset\ndata: {}\n\n")
+	}
+	// Tell the client how long to wait before reconnecting
+	fmt.Fprintf(res, "retry: 3000\n\n")
+	res.Flush()
+
+	heartbeat := time.NewTicker(contentStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var held bool
+		for {
+			var events []models.ContentEvent
+			events, held, err = h.contentEvents.GetContentEvents(ctx, position, viewer, userID, contentStreamBatch)
+			if err != nil {
+				c.Logger().Errorf("content stream: %v", err)
+				return nil
+			}
+			for _, event := range events {
+				data, err := json.Marshal(event)
+				if err != nil {
+					return nil
+				}
+				fmt.Fprintf(res, "id: %d\nevent: content.%s\ndata: %s\n\n", event.ID, event.Type, data)
+				position = models.ContentEventPosition{XactID: event.XactID, ID: event.ID}
+			}
+			if len(events) > 0 {
+				res.Flush()
+			}
+			if len(events) < contentStreamBatch {
+				break
+			}
+		}
+
+		var retry <-chan time.Time
+		if held {
+			retry = time.After(contentStreamHeldRetry)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-retry:
+		case <-heartbeat.C:
+			fmt.Fprint(res, ": keepalive\n\n")
+			res.Flush()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Sync cursor handlers
+
+// GetSyncCursor returns where the sync of an account has got to
+func (h *Handler) GetSyncCursor(c echo.Context) error {
+	if admin, err := h.requireRole(c, models.RoleAdmin); admin == nil {
+		return err
+	}
+
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid account id"})
+	}
+
+	cursor, err := h.accounts.GetSyncCursor(c.Request().Context(), accountID)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, cursor)
+}
+
+// RewindSyncCursor moves the sync cursor of an account back to a post ID, or
+// resets it without one, so the next pull fetches the posts after it again
+func (h *Handler) RewindSyncCursor(c echo.Context) error {
+	if admin, err := h.requireRole(c, models.RoleAdmin); admin == nil {
+		return err
+	}
+
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid account id"})
+	}
+
+	var req models.RewindSyncCursorRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if req.NewestID != nil {
+		id := strings.TrimSpace(*req.NewestID)
+		if id == "" || strings.Trim(id, "0123456789") != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "newest_id must be a post ID"})
+		}
+		req.NewestID = &id
+	}
+
+	cursor, err := h.accounts.RewindSyncCursor(c.Request().Context(), accountID, req.NewestID, h.actor(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, cursor)
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/tagrules"
+	"github.com/labstack/echo/v4"
+)
+
+const tagRulePreviewLimit = 50
+
+// tagRuleEditor is who is managing tag rules: the effective user, and whether
+// they may manage global rules, which requires an admin acting as themselves
+type tagRuleEditor struct {
+	userID int
+	admin  bool
+}
+
+func (h *Handler) tagRuleEditor(c echo.Context) (*tagRuleEditor, error) {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.getCurrentUser(c)
+	if err != nil {
+		return nil, err
+	}
+	return &tagRuleEditor{
+		userID: userID,
+		admin:  user.Role == models.RoleAdmin && impersonationFromContext(c) == nil,
+	}, nil
+}
+
+// canManage reports whether the editor may change or apply a rule
+func (e *tagRuleEditor) canManage(rule *models.TagRule) bool {
+	if rule.OwnerID == nil {
+		return e.admin
+	}
+	return *rule.OwnerID == e.userID
+}
+
+// optionalString trims s and returns nil if it is empty
+func optionalString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*s)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// buildTagRule validates a rule request for the editor. It returns the rule,
+// or an HTTP status and message describing why the request was rejected.
+func (h *Handler) buildTagRule(ctx context.Context, req models.TagRuleRequest, editor *tagRuleEditor) (*models.TagRule, int, string) {
+	rule := &models.TagRule{
+		Name:            strings.TrimSpace(req.Name),
+		Enabled:         req.Enabled == nil || *req.Enabled,
+		Keyword:         optionalString(req.Keyword),
+		Pattern:         optionalString(req.Pattern),
+		Hashtag:         optionalString(req.Hashtag),
+		Platform:        optionalString(req.Platform),
+		SocialAccountID: req.SocialAccountID,
+	}
+	if rule.Name == "" {
+		return nil, http.StatusBadRequest, "name is required"
+	}
+
+	if req.Global {
+		if !editor.admin {
+			return nil, http.StatusForbidden, "only admins can manage global rules"
+		}
+	} else {
+		rule.OwnerID = &editor.userID
+		if rule.SocialAccountID != nil {
+			if _, err := h.accounts.GetSocialAccountByID(ctx, *rule.SocialAccountID, editor.userID); err == sql.ErrNoRows {
+				return nil, http.StatusBadRequest, "social account not found"
+			} else if err != nil {
+				return nil, http.StatusInternalServerError, err.Error()
+			}
+		}
+	}
+
+	if rule.Hashtag != nil {
+		hashtag := entities.Normalize(entities.KindHashtag, *rule.Hashtag)
+		rule.Hashtag = &hashtag
+	}
+	if rule.Keyword == nil && rule.Pattern == nil && rule.Hashtag == nil && rule.Platform == nil && rule.SocialAccountID == nil {
+		return nil, http.StatusBadRequest, "at least one of keyword, pattern, hashtag, platform or social_account_id is required"
+	}
+
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			rule.Tags = append(rule.Tags, tag)
+		}
+	}
+	if len(rule.Tags) == 0 {
+		return nil, http.StatusBadRequest, "tags is required"
+	}
+	if msg := validateTagNames(rule.Tags); msg != "" {
+		return nil, http.StatusBadRequest, msg
+	}
+
+	if _, err := tagrules.Compile(*rule); err != nil {
+		return nil, http.StatusBadRequest, err.Error()
+	}
+	return rule, 0, ""
+}
+
+// applyTagRules adds the tags of matching rules to newly stored or edited content
+func (h *Handler) applyTagRules(ctx context.Context, matchers []*tagrules.Matcher, content *models.Content, actor models.Actor) {
+	tags := tagrules.Apply(matchers, *content)
+	if len(tags) == 0 {
+		return
+	}
+	if _, err := h.content.AddContentTags(ctx, content.ID, tags, actor); err != nil {
+		log.Printf("Failed to apply tag rules to content %d: %v", content.ID, err)
+	}
+}
+
+// Tag rule handlers
+
+// GetTagRules lists the current user's rules and the global rules
+func (h *Handler) GetTagRules(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	rules, err := h.content.GetTagRules(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if rules == nil {
+		rules = []models.TagRule{}
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
+// CreateTagRule creates a rule for the current user, or a global rule for admins
+func (h *Handler) CreateTagRule(c echo.Context) error {
+	editor, err := h.tagRuleEditor(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req models.TagRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	rule, status, msg := h.buildTagRule(c.Request().Context(), req, editor)
+	if rule == nil {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	created, err := h.tagRules.CreateTagRule(c.Request().Context(), *rule, h.actor(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, created)
+}
+
+// UpdateTagRule replaces a rule's name, conditions and tags
+func (h *Handler) UpdateTagRule(c echo.Context) error {
+	editor, err := h.tagRuleEditor(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rule id"})
+	}
+
+	existing, err := h.tagRules.GetTagRule(c.Request().Context(), ruleID)
+	if err == sql.ErrNoRows || (err == nil && !editor.canManage(existing)) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rule not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	var req models.TagRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	// A rule keeps its scope
+	req.Global = existing.OwnerID == nil
+
+	rule, status, msg := h.buildTagRule(c.Request().Context(), req, editor)
+	if rule == nil {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+	rule.ID = ruleID
+
+	updated, err := h.tagRules.UpdateTagRule(c.Request().Context(), *rule, h.actor(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rule not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTagRule deletes a rule. Tags it already applied are kept.
+func (h *Handler) DeleteTagRule(c echo.Context) error {
+	editor, err := h.tagRuleEditor(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rule id"})
+	}
+
+	existing, err := h.tagRules.GetTagRule(c.Request().Context(), ruleID)
+	if err == sql.ErrNoRows || (err == nil && !editor.canManage(existing)) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rule not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	err = h.tagRules.DeleteTagRule(c.Request().Context(), ruleID, h.actor(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rule not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "rule deleted"})
+}
+
+// PreviewTagRule is a dry run: it lists the existing content a rule would
+// match, without saving the rule or tagging anything
+func (h *Handler) PreviewTagRule(c echo.Context) error {
+	editor, err := h.tagRuleEditor(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req models.TagRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	rule, status, msg := h.buildTagRule(c.Request().Context(), req, editor)
+	if rule == nil {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	matcher, err := tagrules.Compile(*rule)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	contents, err := h.tagRules.GetContentForTagRules(c.Request().Context(), rule.OwnerID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	preview := models.TagRulePreview{Matches: []models.Content{}}
+	for _, content := range contents {
+		if !matcher.Match(content) {
+			continue
+		}
+		preview.MatchCount++
+		if len(preview.Matches) < tagRulePreviewLimit {
+			preview.Matches = append(preview.Matches, content)
+		}
+	}
+
+	return c.JSON(http.StatusOK, preview)
+}
+
+// ApplyTagRule re-applies a saved rule to existing content in its scope
+func (h *Handler) ApplyTagRule(c echo.Context) error {
+	editor, err := h.tagRuleEditor(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rule id"})
+	}
+
+	rule, err := h.tagRules.GetTagRule(c.Request().Context(), ruleID)
+	if err == sql.ErrNoRows || (err == nil && !editor.canManage(rule)) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rule not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	matcher, err := tagrules.Compile(*rule)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+
+	contents, err := h.tagRules.GetContentForTagRules(c.Request().Context(), rule.OwnerID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	var response models.ApplyTagRuleResponse
+	actor := h.actor(c)
+	for _, content := range contents {
+		if !matcher.Match(content) {
+			continue
+		}
+		response.MatchCount++
+
+		tags := tagrules.Apply([]*tagrules.Matcher{matcher}, content)
+		if len(tags) == 0 {
+			continue
+		}
+
+		tagged, err := h.content.AddContentTags(c.Request().Context(), content.ID, tags, actor)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if tagged {
+			response.TaggedCount++
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
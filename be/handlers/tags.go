@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/labstack/echo/v4"
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// maxTagNameLength is the length of the tag name, slug and alias columns
+const maxTagNameLength = 100
+
+// validateTagRequest trims a tag request and returns an error message if it is invalid
+func validateTagRequest(req *models.TagRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if repository.TagSlug(req.Name) == "" {
+		return "name must contain a letter or digit"
+	}
+	if utf8.RuneCountInString(req.Name) > maxTagNameLength {
+		return "name must be at most 100 characters"
+	}
+	if req.Color != nil && !tagColorPattern.MatchString(*req.Color) {
+		return "color must be a hex color like #1d9bf0"
+	}
+	return ""
+}
+
+// validateTagNames returns an error message if any of the tags given with
+// content or a tag rule is too long to be added to the vocabulary
+func validateTagNames(names []string) string {
+	for _, name := range names {
+		if utf8.RuneCountInString(strings.TrimSpace(name)) > maxTagNameLength {
+			return "tags must be at most 100 characters"
+		}
+	}
+	return ""
+}
+
+// Tag handlers
+
+// GetTags lists the tag vocabulary with usage counts, for autocomplete.
+// q filters to tags whose name or an alias starts with it.
+func (h *Handler) GetTags(c echo.Context) error {
+	if _, err := h.getCurrentUser(c); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	tags, err := h.tags.GetTags(c.Request().Context(), c.QueryParam("q"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag adds a tag to the vocabulary
+func (h *Handler) CreateTag(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	var req models.TagRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if msg := validateTagRequest(&req); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+
+	tag, err := h.tags.CreateTag(c.Request().Context(), req, models.Actor{UserID: &admin.ID})
+	if err == repository.ErrTagInUse || repository.IsUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": repository.ErrTagInUse.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames a tag and updates its color and description. Existing
+// content is rewritten to the new name.
+func (h *Handler) UpdateTag(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	var req models.TagRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if msg := validateTagRequest(&req); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+
+	tag, err := h.tags.UpdateTag(c.Request().Context(), tagID, req, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tag not found"})
+	}
+	if err == repository.ErrTagInUse || repository.IsUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": repository.ErrTagInUse.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag from the vocabulary and from all content
+func (h *Handler) DeleteTag(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	err = h.tags.DeleteTag(c.Request().Context(), tagID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tag not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "tag deleted"})
+}
+
+// AddTagAlias adds an alternative spelling to a tag
+func (h *Handler) AddTagAlias(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	var req models.AddTagAliasRequest
+	if err := c.Bind(&req); err != nil || repository.TagSlug(req.Alias) == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+	if msg := validateTagNames([]string{req.Alias}); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+
+	tag, err := h.tags.AddTagAlias(c.Request().Context(), tagID, req.Alias, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tag not found"})
+	}
+	if err == repository.ErrTagInUse {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, tag)
+}
+
+// RemoveTagAlias removes an alias from a tag
+func (h *Handler) RemoveTagAlias(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	alias, err := url.PathUnescape(c.Param("alias"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid alias"})
+	}
+
+	err = h.tags.RemoveTagAlias(c.Request().Context(), tagID, alias, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "alias not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "alias removed"})
+}
+
+// MergeTags folds the source tags into the tag in the URL, rewriting existing content
+func (h *Handler) MergeTags(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	var req models.MergeTagsRequest
+	if err := c.Bind(&req); err != nil || len(req.SourceIDs) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "source_ids is required"})
+	}
+
+	tag, err := h.tags.MergeTags(c.Request().Context(), tagID, req.SourceIDs, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tag not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, tag)
+}
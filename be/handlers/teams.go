@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// GetAllSocialAccounts returns social accounts across users, scoped to the
+// creators a manager's teams cover
+func (h *Handler) GetAllSocialAccounts(c echo.Context) error {
+	viewer, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if viewer == nil {
+		return err
+	}
+
+	filters := make(map[string]string)
+	if platform := c.QueryParam("platform"); platform != "" {
+		filters["platform"] = platform
+	}
+	if username := c.QueryParam("username"); username != "" {
+		filters["username"] = username
+	}
+
+	accounts, err := h.accounts.GetAllSocialAccounts(c.Request().Context(), viewer, filters)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if accounts == nil {
+		accounts = []models.SocialAccountWithUser{}
+	}
+
+	return c.JSON(http.StatusOK, accounts)
+}
+
+// Team handlers
+
+// GetTeams returns all teams for admins and their own teams for managers
+func (h *Handler) GetTeams(c echo.Context) error {
+	viewer, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if viewer == nil {
+		return err
+	}
+
+	var memberID *int
+	if viewer.Role != models.RoleAdmin {
+		memberID = &viewer.ID
+	}
+
+	teams, err := h.teams.GetTeams(c.Request().Context(), memberID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if teams == nil {
+		teams = []models.Team{}
+	}
+
+	return c.JSON(http.StatusOK, teams)
+}
+
+// CreateTeam creates a new team
+func (h *Handler) CreateTeam(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	var req models.CreateTeamRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	}
+
+	team, err := h.teams.CreateTeam(c.Request().Context(), req, models.Actor{UserID: &admin.ID})
+	if repository.IsUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "team with this name already exists"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, team)
+}
+
+// DeleteTeam deletes a team
+func (h *Handler) DeleteTeam(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	teamID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid team id"})
+	}
+
+	err = h.teams.DeleteTeam(c.Request().Context(), teamID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "team not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "team deleted"})
+}
+
+// GetTeamMembers lists the members of a team. Managers may only list their own teams.
+func (h *Handler) GetTeamMembers(c echo.Context) error {
+	viewer, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if viewer == nil {
+		return err
+	}
+
+	teamID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid team id"})
+	}
+
+	if viewer.Role != models.RoleAdmin {
+		member, err := h.teams.IsTeamMember(c.Request().Context(), teamID, viewer.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if !member {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "team not found"})
+		}
+	}
+
+	members, err := h.teams.GetTeamMembers(c.Request().Context(), teamID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if members == nil {
+		members = []models.User{}
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+// AddTeamMember adds a user to a team
+func (h *Handler) AddTeamMember(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	teamID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid team id"})
+	}
+
+	var req models.AddTeamMemberRequest
+	if err := c.Bind(&req); err != nil || req.UserID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	err = h.teams.AddTeamMember(c.Request().Context(), teamID, req.UserID, models.Actor{UserID: &admin.ID})
+	if repository.IsForeignKeyViolation(err) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "team or user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "member added"})
+}
+
+// RemoveTeamMember removes a user from a team
+func (h *Handler) RemoveTeamMember(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	teamID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid team id"})
+	}
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	err = h.teams.RemoveTeamMember(c.Request().Context(), teamID, userID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "member not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "member removed"})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultAPITokenExpiryDays = 90
+	maxAPITokenExpiryDays     = 365
+)
+
+// API token handlers
+
+// GetAPITokens lists the current user's API tokens
+func (h *Handler) GetAPITokens(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	tokens, err := h.tokens.GetAPITokensByUserID(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if tokens == nil {
+		tokens = []models.APIToken{}
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAPIToken mints a new API token. The plaintext token is only returned in this response.
+func (h *Handler) CreateAPIToken(c echo.Context) error {
+	user, err := h.getCurrentUser(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	// Impersonating admins must not be able to mint credentials for someone else
+	if impersonationFromContext(c) != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "API tokens cannot be created while impersonating"})
+	}
+
+	var req models.CreateAPITokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	}
+
+	switch req.Scope {
+	case models.TokenScopeReadOnly, models.TokenScopeReadWrite:
+	case models.TokenScopeAdmin:
+		if user.Role != models.RoleAdmin {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "admin scope requires the admin role"})
+		}
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid scope: " + req.Scope})
+	}
+
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultAPITokenExpiryDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxAPITokenExpiryDays {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "expires_in_days must be between 1 and " + strconv.Itoa(maxAPITokenExpiryDays),
+		})
+	}
+
+	plaintext, err := generateAPIToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+	token, err := h.tokens.CreateAPIToken(c.Request().Context(), user.ID, req.Name, hashAPIToken(plaintext), plaintext[:len(apiTokenPrefix)+6], req.Scope, expiresAt, h.actor(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, models.CreateAPITokenResponse{
+		APIToken: *token,
+		Token:    plaintext,
+	})
+}
+
+// RevokeAPIToken revokes one of the current user's API tokens
+func (h *Handler) RevokeAPIToken(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid token id"})
+	}
+
+	err = h.tokens.RevokeAPIToken(c.Request().Context(), tokenID, userID, h.actor(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "token not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "token revoked"})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultTrashRetentionDays = 30
+
+// TrashRetentionDays returns how long deleted content and accounts stay in the
+// trash before they are purged, from TRASH_RETENTION_DAYS
+func TrashRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return defaultTrashRetentionDays
+	}
+	return days
+}
+
+// Trash handlers
+
+// GetTrash lists the current user's deleted content and social accounts
+func (h *Handler) GetTrash(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	content, err := h.trash.GetTrashedContent(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	accounts, err := h.trash.GetTrashedSocialAccounts(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if content == nil {
+		content = []models.Content{}
+	}
+	if accounts == nil {
+		accounts = []models.SocialAccount{}
+	}
+
+	return c.JSON(http.StatusOK, models.TrashResponse{
+		Content:        content,
+		SocialAccounts: accounts,
+		RetentionDays:  TrashRetentionDays(),
+	})
+}
+
+// RestoreContent takes content out of the trash
+func (h *Handler) RestoreContent(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	contentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid content id"})
+	}
+
+	content, err := h.trash.RestoreContent(c.Request().Context(), contentID, userID, h.actor(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "content not found in trash"})
+	}
+	if repository.IsUniqueViolation(err) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "content with this link already exists"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, content)
+}
+
+// RestoreSocialAccount takes a social account out of the trash
+func (h *Handler) RestoreSocialAccount(c echo.Context) error {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid account id"})
+	}
+
+	account, err := h.trash.RestoreSocialAccount(c.Request().Context(), accountID, userID, h.actor(c))
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found in trash"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, account)
+}
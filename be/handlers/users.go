@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+// User management handlers
+
+// ListUsers returns all users, or only the members of their teams for managers
+func (h *Handler) ListUsers(c echo.Context) error {
+	viewer, err := h.requireRole(c, models.RoleAdmin, models.RoleManager)
+	if viewer == nil {
+		return err
+	}
+
+	users, err := h.users.ListUsers(c.Request().Context(), viewer)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if users == nil {
+		users = []models.User{}
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+// UpdateUserRole changes the role of a user
+func (h *Handler) UpdateUserRole(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	var req models.UpdateUserRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	if !models.IsValidRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid role: " + req.Role})
+	}
+
+	// Prevent admins from locking themselves out
+	if targetID == admin.ID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot change your own role"})
+	}
+
+	user, err := h.users.UpdateUserRole(c.Request().Context(), targetID, req.Role, models.Actor{UserID: &admin.ID}, "api")
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// GetUserRoleChanges returns the role change history of a user
+func (h *Handler) GetUserRoleChanges(c echo.Context) error {
+	if admin, err := h.requireRole(c, models.RoleAdmin); admin == nil {
+		return err
+	}
+
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	changes, err := h.users.GetRoleChanges(c.Request().Context(), targetID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if changes == nil {
+		changes = []models.RoleChange{}
+	}
+
+	return c.JSON(http.StatusOK, changes)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	webhookSecretPrefix    = "whsec_"
+	minWebhookSecretLength = 16
+	webhookDeliveryLimit   = 100
+)
+
+// generateWebhookSecret returns a new random signing secret
+func generateWebhookSecret() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// buildWebhook validates a webhook request. It returns an error message if it is invalid.
+func buildWebhook(req models.WebhookRequest) (*models.Webhook, string) {
+	webhook := &models.Webhook{
+		URL:     strings.TrimSpace(req.URL),
+		Enabled: req.Enabled == nil || *req.Enabled,
+	}
+
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "url must be an absolute http or https URL"
+	}
+
+	if req.Secret != nil {
+		webhook.Secret = strings.TrimSpace(*req.Secret)
+		if len(webhook.Secret) < minWebhookSecretLength {
+			return nil, "secret must be at least 16 characters"
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, event := range models.WebhookEvents {
+		known[event] = true
+	}
+	seen := make(map[string]bool)
+	for _, event := range req.Events {
+		if !known[event] {
+			return nil, "unknown event type: " + event
+		}
+		if !seen[event] {
+			seen[event] = true
+			webhook.Events = append(webhook.Events, event)
+		}
+	}
+	if len(webhook.Events) == 0 {
+		return nil, "events is required"
+	}
+	return webhook, ""
+}
+
+// Webhook handlers
+
+// GetWebhooks lists all webhook subscriptions
+func (h *Handler) GetWebhooks(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	webhooks, err := h.webhooks.GetWebhooks(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+// CreateWebhook subscribes a URL to events. The signing secret is returned
+// once; one is generated if the request does not provide it.
+func (h *Handler) CreateWebhook(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	var req models.WebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	webhook, msg := buildWebhook(req)
+	if webhook == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+	if webhook.Secret == "" {
+		if webhook.Secret, err = generateWebhookSecret(); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to generate secret"})
+		}
+	}
+
+	created, err := h.webhooks.CreateWebhook(c.Request().Context(), *webhook, models.Actor{UserID: &admin.ID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, models.CreateWebhookResponse{Webhook: *created, Secret: created.Secret})
+}
+
+// UpdateWebhook replaces a webhook's URL, events and enabled flag, and rotates
+// its secret when one is given
+func (h *Handler) UpdateWebhook(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook id"})
+	}
+
+	var req models.WebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	webhook, msg := buildWebhook(req)
+	if webhook == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+	webhook.ID = webhookID
+
+	updated, err := h.webhooks.UpdateWebhook(c.Request().Context(), *webhook, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "webhook not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+// DeleteWebhook removes a subscription and its delivery log
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook id"})
+	}
+
+	err = h.webhooks.DeleteWebhook(c.Request().Context(), webhookID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "webhook not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "webhook deleted"})
+}
+
+// GetWebhookDeliveries lists a webhook's most recent deliveries, newest first.
+// status filters to pending, delivered or failed deliveries.
+func (h *Handler) GetWebhookDeliveries(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook id"})
+	}
+
+	status := c.QueryParam("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid status"})
+	}
+
+	if _, err := h.webhooks.GetWebhook(c.Request().Context(), webhookID); err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "webhook not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	deliveries, err := h.webhooks.GetWebhookDeliveries(c.Request().Context(), webhookID, status, webhookDeliveryLimit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverWebhookDelivery queues a past delivery again. The new delivery
+// carries the same event ID so receivers can deduplicate.
+func (h *Handler) RedeliverWebhookDelivery(c echo.Context) error {
+	admin, err := h.requireRole(c, models.RoleAdmin)
+	if admin == nil {
+		return err
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid webhook id"})
+	}
+
+	deliveryID, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid delivery id"})
+	}
+
+	delivery, err := h.webhooks.RedeliverWebhookDelivery(c.Request().Context(), webhookID, deliveryID, models.Actor{UserID: &admin.ID})
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "delivery not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+// PruneContentEvents deletes content events older than retention every
+// interval until ctx is cancelled. Streams cannot resume from pruned events.
+func PruneContentEvents(ctx context.Context, repo *repository.Repository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if pruned, err := repo.PruneContentEvents(ctx, time.Now().Add(-retention)); err != nil {
+			log.Printf("Failed to prune content events: %v", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d content events", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/blobstore"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+const (
+	mediaArchiveBatchSize = 20
+	// Media that failed this often, e.g. because the post is gone, are left alone
+	mediaArchiveMaxAttempts = 5
+	// Larger files are not archived
+	mediaArchiveMaxSize = 100 << 20
+)
+
+var errMediaTooLarge = fmt.Errorf("file is larger than %d bytes", mediaArchiveMaxSize)
+
+// maxSizeReader fails once more than n bytes have been read
+type maxSizeReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	if m.n -= int64(n); m.n < 0 {
+		return n, errMediaTooLarge
+	}
+	return n, err
+}
+
+// ArchiveMedia copies the files of synced media to store every interval
+// until ctx is cancelled, so they outlive the posts. client downloads the
+// files; nil uses a 5 minute timeout.
+func ArchiveMedia(ctx context.Context, repo *repository.Repository, store blobstore.Store, client *http.Client, interval time.Duration) {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			media, err := repo.GetMediaToArchive(ctx, mediaArchiveBatchSize, mediaArchiveMaxAttempts)
+			if err != nil {
+				log.Printf("Failed to load media to archive: %v", err)
+				break
+			}
+
+			failed := 0
+			for _, m := range media {
+				key, err := archiveMedia(ctx, client, store, m)
+				if err != nil {
+					failed++
+					log.Printf("Failed to archive media %d: %v", m.ID, err)
+					err = repo.RecordMediaArchiveFailure(ctx, m.ID, err.Error())
+				} else {
+					err = repo.MarkMediaArchived(ctx, m.ID, key)
+				}
+				if err != nil {
+					log.Printf("Failed to record archiving of media %d: %v", m.ID, err)
+				}
+			}
+
+			// Keep going while there is a backlog, retrying failures next time
+			if failed > 0 || len(media) < mediaArchiveBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveMedia streams a media file into the store under
+// content/<content ID>/<media key><extension>, returning the key
+func archiveMedia(ctx context.Context, client *http.Client, store blobstore.Store, media models.ContentMedia) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *media.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download responded with status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > mediaArchiveMaxSize {
+		return "", fmt.Errorf("file of %d bytes is too large", resp.ContentLength)
+	}
+
+	var ext string
+	if u, err := url.Parse(*media.URL); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	key := fmt.Sprintf("content/%d/%s%s", media.ContentID, media.MediaKey, ext)
+	// The size is -1 when the response does not say, which the store accepts
+	body := &maxSizeReader{r: resp.Body, n: mediaArchiveMaxSize}
+	if err := store.Put(ctx, key, resp.Header.Get("Content-Type"), body, resp.ContentLength); err != nil {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/notify"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+const (
+	// Owners are alerted this long before a token that cannot be refreshed expires
+	tokenExpiryWarning = 24 * time.Hour
+	// Sync runs are only needed for digests, the longest of which covers a week
+	syncRunRetention = 30 * 24 * time.Hour
+)
+
+// SendNotifications sends the digests that are due and alerts about expiring
+// tokens every interval until ctx is cancelled, and prunes old sync runs
+func SendNotifications(ctx context.Context, repo *repository.Repository, notifier *notify.Notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		for _, frequency := range []string{models.DigestDaily, models.DigestWeekly} {
+			sendDigests(ctx, repo, notifier, frequency, now)
+		}
+		alertExpiringTokens(ctx, repo, notifier, now)
+
+		if pruned, err := repo.PruneSyncRuns(ctx, now.Add(-syncRunRetention)); err != nil {
+			log.Printf("Failed to prune sync runs: %v", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d sync runs", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDigests sends the latest digest of a frequency to everyone who has not
+// received it yet. Digests with nothing to report are not sent.
+func sendDigests(ctx context.Context, repo *repository.Repository, notifier *notify.Notifier, frequency string, now time.Time) {
+	from, to, key, _ := notify.DigestPeriod(frequency, now)
+
+	recipients, err := repo.GetDigestRecipients(ctx, frequency, key)
+	if err != nil {
+		log.Printf("Failed to load %s digest recipients: %v", frequency, err)
+		return
+	}
+
+	for _, recipient := range recipients {
+		if ctx.Err() != nil {
+			return
+		}
+		if !notifier.Enabled(recipient) {
+			continue
+		}
+
+		digest, err := repo.GetDigest(ctx, &recipient.User, frequency, from, to, notify.DigestPostsPerCreator)
+		if err != nil {
+			log.Printf("Failed to build %s digest for user %d: %v", frequency, recipient.ID, err)
+			continue
+		}
+		if len(digest.Creators) == 0 {
+			continue
+		}
+
+		if _, err := notifier.Notify(ctx, recipient, models.NotificationDigest, key, notify.RenderDigest(*digest)); err != nil {
+			log.Printf("Failed to send %s digest to user %d: %v", frequency, recipient.ID, err)
+		}
+	}
+}
+
+// alertExpiringTokens alerts the owners of accounts whose tokens expire soon
+// and cannot be refreshed
+func alertExpiringTokens(ctx context.Context, repo *repository.Repository, notifier *notify.Notifier, now time.Time) {
+	accounts, err := repo.GetAccountsWithExpiringTokens(ctx, now.Add(tokenExpiryWarning))
+	if err != nil {
+		log.Printf("Failed to load accounts with expiring tokens: %v", err)
+		return
+	}
+
+	for _, account := range accounts {
+		if ctx.Err() != nil {
+			return
+		}
+
+		recipient, err := repo.GetNotificationRecipient(ctx, account.UserID)
+		if err != nil {
+			log.Printf("Failed to load notification preferences of user %d: %v", account.UserID, err)
+			continue
+		}
+
+		_, err = notifier.Alert(ctx, *recipient, models.NotificationTokenExpiry, notify.TokenExpiryKey(account), notify.TokenExpiryAlert(account, now))
+		if err != nil {
+			log.Printf("Failed to send token expiry alert for account %d: %v", account.ID, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/outbox"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+const (
+	outboxBatchSize = 100
+	// Published events are kept this long for troubleshooting
+	outboxRetention     = 7 * 24 * time.Hour
+	outboxPruneInterval = time.Hour
+)
+
+// RelayOutbox publishes outbox events to sink every interval until ctx is
+// cancelled, and prunes old published events
+func RelayOutbox(ctx context.Context, repo *repository.Repository, sink outbox.Sink, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		if _, err := outbox.Relay(ctx, repo, sink, outboxBatchSize); err != nil {
+			log.Printf("Failed to relay outbox: %v", err)
+		}
+
+		if time.Since(lastPrune) > outboxPruneInterval {
+			if pruned, err := repo.PruneOutbox(ctx, time.Now().Add(-outboxRetention)); err != nil {
+				log.Printf("Failed to prune outbox: %v", err)
+			} else if pruned > 0 {
+				log.Printf("Pruned %d published outbox events", pruned)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
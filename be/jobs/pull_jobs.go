@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+const (
+	// pullJobLease must outlast a pull, so a job is not claimed again while
+	// it is still running
+	pullJobLease = 10 * time.Minute
+	// Finished jobs are kept this long for their status to be read
+	pullJobRetention     = 7 * 24 * time.Hour
+	pullJobPruneInterval = time.Hour
+)
+
+// RunPullJobs runs queued pull jobs on up to workers goroutines, looking for
+// due jobs every interval until ctx is cancelled. Jobs that have started are
+// not cancelled with ctx; it returns once they have finished.
+func RunPullJobs(ctx context.Context, repo *repository.Repository, run func(context.Context, models.PullJob), workers int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	idle := make(chan struct{}, workers)
+	for i := 0; i < workers; i++ {
+		idle <- struct{}{}
+	}
+
+	var lastPrune time.Time
+	for {
+		// Claim only as many jobs as there are idle workers, leaving the rest
+		// to other processes
+		for free := len(idle); free > 0; free = len(idle) {
+			claimed, err := repo.ClaimPullJobs(ctx, free, pullJobLease)
+			if err != nil {
+				log.Printf("Failed to claim pull jobs: %v", err)
+			}
+			for _, job := range claimed {
+				<-idle
+				wg.Add(1)
+				go func(job models.PullJob) {
+					defer wg.Done()
+					defer func() { idle <- struct{}{} }()
+					run(context.WithoutCancel(ctx), job)
+				}(job)
+			}
+			if err != nil || len(claimed) < free {
+				break
+			}
+		}
+
+		if time.Since(lastPrune) > pullJobPruneInterval {
+			if pruned, err := repo.PrunePullJobs(ctx, time.Now().Add(-pullJobRetention)); err != nil {
+				log.Printf("Failed to prune pull jobs: %v", err)
+			} else if pruned > 0 {
+				log.Printf("Pruned %d finished pull jobs", pruned)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+const (
+	reconcileBatchSize = 100
+	// Posts are looked up again once their last lookup is this old
+	reconcileStaleness = 24 * time.Hour
+)
+
+// ReconcileContent looks up synced posts on platform whose last lookup is
+// stale, marking those the platform no longer has as removed. lookup returns
+// the IDs of posts the platform reports as not found. It runs once
+// immediately and then every interval until ctx is cancelled.
+func ReconcileContent(ctx context.Context, repo *repository.Repository, platform string, lookup func(ctx context.Context, platform string, ids []string) ([]string, error), interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checked, removed, err := reconcileContent(ctx, repo, platform, lookup)
+		if err != nil {
+			log.Printf("Failed to reconcile %s content: %v", platform, err)
+		} else if removed > 0 {
+			log.Printf("Reconciled %s content: checked=%d, removed=%d", platform, checked, removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileContent reconciles batches of stale posts until none are left
+func reconcileContent(ctx context.Context, repo *repository.Repository, platform string, lookup func(ctx context.Context, platform string, ids []string) ([]string, error)) (checked, removed int, err error) {
+	for ctx.Err() == nil {
+		contents, err := repo.GetContentToReconcile(ctx, platform, time.Now().Add(-reconcileStaleness), reconcileBatchSize)
+		if err != nil || len(contents) == 0 {
+			return checked, removed, err
+		}
+
+		// An edited post is looked up by the ID of its latest version
+		byPostID := make(map[string]int, len(contents))
+		ids := make([]string, 0, len(contents))
+		for _, content := range contents {
+			id := currentPostID(content)
+			byPostID[id] = content.ID
+			ids = append(ids, id)
+		}
+
+		notFound, err := lookup(ctx, platform, ids)
+		if err != nil {
+			return checked, removed, err
+		}
+		var foundIDs, removedIDs []int
+		for _, id := range notFound {
+			if contentID, ok := byPostID[id]; ok {
+				removedIDs = append(removedIDs, contentID)
+				delete(byPostID, id)
+			}
+		}
+		for _, contentID := range byPostID {
+			foundIDs = append(foundIDs, contentID)
+		}
+
+		newlyRemoved, err := repo.RecordReconciliation(ctx, foundIDs, removedIDs)
+		if err != nil {
+			return checked, removed, err
+		}
+		checked += len(contents)
+		removed += newlyRemoved
+
+		if len(contents) < reconcileBatchSize {
+			break
+		}
+	}
+	return checked, removed, nil
+}
+
+func currentPostID(content models.Content) string {
+	if len(content.EditHistoryIDs) > 0 {
+		return content.EditHistoryIDs[len(content.EditHistoryIDs)-1]
+	}
+	return *content.ExternalPostID
+}
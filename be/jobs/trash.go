@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+// PurgeTrash permanently deletes content and social accounts that have been in
+// the trash for longer than retention. It runs once immediately and then every
+// interval until ctx is cancelled.
+func PurgeTrash(ctx context.Context, repo *repository.Repository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		contentPurged, accountsPurged, err := repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+		} else if contentPurged > 0 || accountsPurged > 0 {
+			log.Printf("Purged trash: content=%d, social_accounts=%d", contentPurged, accountsPurged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
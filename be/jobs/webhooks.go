@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/Armatorix/SocialTracker/be/webhooks"
+)
+
+const (
+	webhookBatchSize = 20
+	// webhookLease must outlast a batch of attempts, so a delivery is not
+	// claimed again while it is still being sent
+	webhookLease = 5 * time.Minute
+)
+
+// DeliverWebhooks sends due webhook deliveries every interval until ctx is
+// cancelled. Failed attempts are retried with exponential backoff until
+// webhooks.MaxAttempts is reached.
+func DeliverWebhooks(ctx context.Context, repo *repository.Repository, sender *webhooks.Sender, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			sent, err := deliverWebhookBatch(ctx, repo, sender)
+			if err != nil {
+				log.Printf("Failed to deliver webhooks: %v", err)
+			}
+			// Keep going while there is a backlog
+			if err != nil || sent < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverWebhookBatch claims and sends one batch, returning how many were attempted
+func deliverWebhookBatch(ctx context.Context, repo *repository.Repository, sender *webhooks.Sender) (int, error) {
+	deliveries, err := repo.ClaimWebhookDeliveries(ctx, webhookBatchSize, webhookLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			// The rest are claimed again once their lease runs out
+			break
+		}
+		result := sender.Send(ctx, delivery)
+		// Recorded even when ctx ends now, so a sent delivery is not sent again
+		recordCtx := context.WithoutCancel(ctx)
+		if result.OK() {
+			err = repo.CompleteWebhookDelivery(recordCtx, delivery.WebhookDelivery, result.StatusCode, result.Body)
+		} else {
+			err = repo.FailWebhookDelivery(recordCtx, delivery.WebhookDelivery, result.StatusCode, result.Body, result.Error(), webhookRetryAfter(delivery))
+		}
+		if errors.Is(err, repository.ErrWebhookDeliveryLost) {
+			// Its lease ran out while sending; the sender that claimed it since records the outcome
+			log.Printf("Webhook delivery %d was claimed by another sender, dropping its outcome", delivery.ID)
+		} else if err != nil {
+			log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// webhookRetryAfter is when to retry a delivery whose attempt just failed, or 0 to give up
+func webhookRetryAfter(delivery models.PendingWebhookDelivery) time.Duration {
+	attempts := delivery.Attempts + 1
+	if attempts >= webhooks.MaxAttempts {
+		return 0
+	}
+	return webhooks.Backoff(attempts)
+}
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/Armatorix/SocialTracker/be/blobstore"
 	"github.com/Armatorix/SocialTracker/be/handlers"
+	"github.com/Armatorix/SocialTracker/be/jobs"
 	"github.com/Armatorix/SocialTracker/be/migrations"
+	"github.com/Armatorix/SocialTracker/be/notify"
+	"github.com/Armatorix/SocialTracker/be/outbox"
 	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/Armatorix/SocialTracker/be/stream"
+	"github.com/Armatorix/SocialTracker/be/webhooks"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	_ "github.com/lib/pq"
@@ -44,7 +54,46 @@ func main() {
 
 	// Initialize repository and handlers
 	repo := repository.NewRepository(db)
-	h := handlers.NewHandler(repo)
+	contentHub := stream.NewHub()
+	notifier, err := notify.NewFromEnv(repo)
+	if err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
+	}
+	h := handlers.NewHandler(repo, contentHub, notifier)
+
+	// "worker" runs only pull jobs, for deployments that scale them apart from the API
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		runWorker(repo, h)
+		return
+	}
+
+	// Background jobs
+	retention := time.Duration(handlers.TrashRetentionDays()) * 24 * time.Hour
+	go jobs.PurgeTrash(context.Background(), repo, retention, time.Hour)
+	go contentHub.Listen(context.Background(), dbURL, repository.ContentEventsChannel)
+	go jobs.PruneContentEvents(context.Background(), repo, 24*time.Hour, time.Hour)
+	go jobs.DeliverWebhooks(context.Background(), repo, webhooks.NewSender(nil), 5*time.Second)
+	go jobs.SendNotifications(context.Background(), repo, notifier, 15*time.Minute)
+	go jobs.ReconcileContent(context.Background(), repo, "twitter", h.LookupRemovedPosts, 5*time.Minute)
+
+	mediaArchive, err := blobstore.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure media archive: %v", err)
+	}
+	if mediaArchive != nil {
+		go jobs.ArchiveMedia(context.Background(), repo, mediaArchive, nil, time.Minute)
+	}
+	if workers := handlers.PullWorkers(); workers > 0 {
+		go jobs.RunPullJobs(context.Background(), repo, h.RunPullJob, workers, time.Second)
+	}
+
+	sink, err := outbox.NewSinkFromEnv(db)
+	if err != nil {
+		log.Fatalf("Failed to configure outbox sink: %v", err)
+	}
+	if sink != nil {
+		go jobs.RelayOutbox(context.Background(), repo, sink, time.Second)
+	}
 
 	e := echo.New()
 
@@ -63,6 +112,11 @@ func main() {
 				echo.HeaderContentType,
 				echo.HeaderAuthorization,
 				echo.HeaderAccessControlAllowOrigin,
+				"X-Act-As-User",
+			},
+			ExposeHeaders: []string{
+				"X-Impersonating-User",
+				"X-Impersonator",
 			},
 			AllowCredentials: true,
 		},
@@ -75,7 +129,7 @@ func main() {
 	})
 
 	// API routes
-	api := e.Group("/api")
+	api := e.Group("/api", h.Authenticate, h.Impersonate)
 
 	// User routes
 	api.GET("/user", h.GetCurrentUser)
@@ -85,19 +139,109 @@ func main() {
 	api.POST("/social-accounts", h.CreateSocialAccount)
 	api.DELETE("/social-accounts/:id", h.DeleteSocialAccount)
 	api.POST("/social-accounts/:id/pull", h.PullContentFromPlatform)
+	api.POST("/social-accounts/:id/restore", h.RestoreSocialAccount)
+
+	// Job routes
+	api.GET("/jobs/:id", h.GetJob)
+
+	// API token routes
+	api.GET("/tokens", h.GetAPITokens)
+	api.POST("/tokens", h.CreateAPIToken)
+	api.DELETE("/tokens/:id", h.RevokeAPIToken)
+
+	// Notification routes
+	api.GET("/notifications", h.GetNotifications)
+	api.GET("/notifications/preferences", h.GetNotificationPreferences)
+	api.PUT("/notifications/preferences", h.UpdateNotificationPreferences)
+	api.POST("/notifications/test", h.SendTestNotification)
+	api.GET("/notifications/digest/preview", h.PreviewDigest)
 
 	// Twitter OAuth routes
 	api.GET("/auth/twitter/status", h.GetTwitterOAuthStatus)
 	api.GET("/auth/twitter", h.GetTwitterOAuthURL)
 	api.GET("/auth/twitter/callback", h.HandleTwitterOAuthCallback)
+	api.GET("/admin/twitter/rate-limits", h.GetTwitterRateLimits)
 
 	// Content routes
 	api.GET("/content", h.GetContent)
+	api.GET("/content/stream", h.StreamContent)
 	api.POST("/content", h.CreateContent)
+	api.PATCH("/content/:id", h.UpdateContent)
 	api.DELETE("/content/:id", h.DeleteContent)
+	api.POST("/content/:id/restore", h.RestoreContent)
+	api.GET("/content/:id/revisions", h.GetContentRevisions)
+
+	// Trash routes
+	api.GET("/trash", h.GetTrash)
 
 	// Admin routes
 	api.GET("/admin/content", h.GetAllContent)
+	api.GET("/admin/content/stream", h.StreamAllContent)
+	api.GET("/admin/users", h.ListUsers)
+	api.PUT("/admin/users/:id/role", h.UpdateUserRole)
+	api.GET("/admin/users/:id/role-changes", h.GetUserRoleChanges)
+	api.GET("/admin/social-accounts", h.GetAllSocialAccounts)
+	api.GET("/admin/social-accounts/:id/sync-cursor", h.GetSyncCursor)
+	api.PUT("/admin/social-accounts/:id/sync-cursor", h.RewindSyncCursor)
+	api.GET("/admin/audit", h.GetAuditEvents)
+
+	// Impersonation routes
+	api.GET("/admin/impersonation", h.GetImpersonation)
+	api.POST("/admin/impersonation", h.StartImpersonation)
+	api.DELETE("/admin/impersonation", h.StopImpersonation)
+	api.GET("/admin/impersonation/actions", h.GetImpersonationActions)
+
+	// Team routes
+	api.GET("/admin/teams", h.GetTeams)
+	api.POST("/admin/teams", h.CreateTeam)
+	api.DELETE("/admin/teams/:id", h.DeleteTeam)
+	api.GET("/admin/teams/:id/members", h.GetTeamMembers)
+	api.POST("/admin/teams/:id/members", h.AddTeamMember)
+	api.DELETE("/admin/teams/:id/members/:user_id", h.RemoveTeamMember)
+
+	// Campaign routes
+	api.GET("/campaigns", h.GetCampaigns)
+	api.POST("/admin/campaigns", h.CreateCampaign)
+	api.PUT("/admin/campaigns/:id", h.UpdateCampaign)
+	api.DELETE("/admin/campaigns/:id", h.DeleteCampaign)
+	api.GET("/admin/campaigns/:id/report", h.GetCampaignReport)
+	api.POST("/admin/campaigns/:id/creators", h.AddCampaignCreator)
+	api.DELETE("/admin/campaigns/:id/creators/:user_id", h.RemoveCampaignCreator)
+	api.POST("/admin/campaigns/:id/content", h.AttachCampaignContent)
+	api.DELETE("/admin/campaigns/:id/content/:content_id", h.DetachCampaignContent)
+
+	// Tag routes
+	api.GET("/tags", h.GetTags)
+	api.POST("/admin/tags", h.CreateTag)
+	api.PUT("/admin/tags/:id", h.UpdateTag)
+	api.DELETE("/admin/tags/:id", h.DeleteTag)
+	api.POST("/admin/tags/:id/aliases", h.AddTagAlias)
+	api.DELETE("/admin/tags/:id/aliases/:alias", h.RemoveTagAlias)
+	api.POST("/admin/tags/:id/merge", h.MergeTags)
+
+	// Tag rule routes
+	api.GET("/tag-rules", h.GetTagRules)
+	api.POST("/tag-rules", h.CreateTagRule)
+	api.POST("/tag-rules/preview", h.PreviewTagRule)
+	api.PUT("/tag-rules/:id", h.UpdateTagRule)
+	api.DELETE("/tag-rules/:id", h.DeleteTagRule)
+	api.POST("/tag-rules/:id/apply", h.ApplyTagRule)
+
+	// Compliance routes
+	api.GET("/admin/compliance", h.GetComplianceResults)
+	api.POST("/admin/compliance/evaluate", h.EvaluateCompliance)
+	api.GET("/admin/disclosure-rules", h.GetDisclosureRules)
+	api.POST("/admin/disclosure-rules", h.CreateDisclosureRule)
+	api.PUT("/admin/disclosure-rules/:id", h.UpdateDisclosureRule)
+	api.DELETE("/admin/disclosure-rules/:id", h.DeleteDisclosureRule)
+
+	// Webhook routes
+	api.GET("/admin/webhooks", h.GetWebhooks)
+	api.POST("/admin/webhooks", h.CreateWebhook)
+	api.PUT("/admin/webhooks/:id", h.UpdateWebhook)
+	api.DELETE("/admin/webhooks/:id", h.DeleteWebhook)
+	api.GET("/admin/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	api.POST("/admin/webhooks/:id/deliveries/:delivery_id/redeliver", h.RedeliverWebhookDelivery)
 
 	fe := e.Group("", func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -128,3 +272,19 @@ func main() {
 	// Start server
 	e.Logger.Fatal(e.Start(":8080"))
 }
+
+// runWorker runs pull jobs until the process is interrupted, letting the jobs
+// in progress finish
+func runWorker(repo *repository.Repository, h *handlers.Handler) {
+	workers := handlers.PullWorkers()
+	if workers == 0 {
+		log.Fatal("PULL_WORKERS must be positive for the worker")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Running %d pull workers", workers)
+	jobs.RunPullJobs(ctx, repo, h.RunPullJob, workers, time.Second)
+	log.Println("Pull workers stopped")
+}
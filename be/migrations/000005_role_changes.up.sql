@@ -0,0 +1,13 @@
+-- Record every role change, whether made by an admin or by identity provider group mapping
+CREATE TABLE IF NOT EXISTS role_changes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_role VARCHAR(50) NOT NULL,
+    new_role VARCHAR(50) NOT NULL,
+    changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    source VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_role_change_source CHECK (source IN ('api', 'groups'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_changes_user_id ON role_changes(user_id);
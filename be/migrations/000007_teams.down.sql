@@ -0,0 +1,9 @@
+-- Drop teams
+DROP INDEX IF EXISTS idx_team_members_user_id;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
+
+-- Demote managers and restore the original role constraint
+UPDATE users SET role = 'creator' WHERE role = 'manager';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_role;
+ALTER TABLE users ADD CONSTRAINT chk_role CHECK (role IN ('admin', 'creator'));
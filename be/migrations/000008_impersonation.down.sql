@@ -0,0 +1,6 @@
+-- Drop impersonation tables
+DROP INDEX IF EXISTS idx_impersonation_actions_target_user_id;
+DROP INDEX IF EXISTS idx_impersonation_actions_admin_id;
+DROP TABLE IF EXISTS impersonation_actions;
+DROP INDEX IF EXISTS idx_impersonation_sessions_active;
+DROP TABLE IF EXISTS impersonation_sessions;
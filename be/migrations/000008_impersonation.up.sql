@@ -0,0 +1,29 @@
+-- Time-limited sessions in which an admin acts as another user
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    id SERIAL PRIMARY KEY,
+    admin_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_active
+ON impersonation_sessions(admin_id)
+WHERE ended_at IS NULL;
+
+-- Write requests performed while impersonating, via a session or the act-as header
+CREATE TABLE IF NOT EXISTS impersonation_actions (
+    id SERIAL PRIMARY KEY,
+    admin_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id INTEGER REFERENCES impersonation_sessions(id) ON DELETE SET NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_actions_admin_id ON impersonation_actions(admin_id);
+CREATE INDEX IF NOT EXISTS idx_impersonation_actions_target_user_id ON impersonation_actions(target_user_id);
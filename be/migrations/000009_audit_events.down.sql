@@ -0,0 +1,6 @@
+-- Drop audit trail
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+DROP INDEX IF EXISTS idx_audit_events_target;
+DROP INDEX IF EXISTS idx_audit_events_impersonator_id;
+DROP INDEX IF EXISTS idx_audit_events_actor_id;
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,18 @@
+-- Trashed rows cannot be represented without soft delete, so remove them
+DELETE FROM content WHERE deleted_at IS NOT NULL;
+DELETE FROM social_accounts WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_social_accounts_deleted_at;
+DROP INDEX IF EXISTS idx_content_deleted_at;
+
+DROP INDEX IF EXISTS idx_content_external_post_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_external_post_unique
+ON content(social_account_id, external_post_id)
+WHERE external_post_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_content_user_link_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_user_link_unique
+ON content(user_id, link);
+
+ALTER TABLE social_accounts DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE content DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,18 @@
+-- Soft delete for content and social accounts
+ALTER TABLE content ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE social_accounts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Uniqueness only applies to live rows, so a trashed link can be added again
+DROP INDEX IF EXISTS idx_content_user_link_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_user_link_unique
+ON content(user_id, link)
+WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_content_external_post_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_external_post_unique
+ON content(social_account_id, external_post_id)
+WHERE external_post_id IS NOT NULL AND deleted_at IS NULL;
+
+-- Support trash listing and the purge job
+CREATE INDEX IF NOT EXISTS idx_content_deleted_at ON content(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_social_accounts_deleted_at ON social_accounts(deleted_at) WHERE deleted_at IS NOT NULL;
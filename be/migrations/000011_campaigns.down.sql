@@ -0,0 +1,6 @@
+-- Drop campaigns
+DROP INDEX IF EXISTS idx_campaign_content_content_id;
+DROP INDEX IF EXISTS idx_campaign_creators_user_id;
+DROP TABLE IF EXISTS campaign_content;
+DROP TABLE IF EXISTS campaign_creators;
+DROP TABLE IF EXISTS campaigns;
@@ -0,0 +1,35 @@
+-- Campaigns group content under a brand brief with a date range and required hashtags
+CREATE TABLE IF NOT EXISTS campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    brief TEXT,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    -- Normalized hashtags (lowercase, without '#') that content must carry to be auto-attached
+    hashtags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_campaign_dates CHECK (end_date >= start_date)
+);
+
+-- Creators assigned to a campaign
+CREATE TABLE IF NOT EXISTS campaign_creators (
+    campaign_id INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (campaign_id, user_id)
+);
+
+-- Content attached to a campaign, either by hand or by hashtag match
+CREATE TABLE IF NOT EXISTS campaign_content (
+    campaign_id INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    content_id INTEGER NOT NULL REFERENCES content(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (campaign_id, content_id),
+    CONSTRAINT chk_campaign_content_source CHECK (source IN ('manual', 'auto'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaign_creators_user_id ON campaign_creators(user_id);
+CREATE INDEX IF NOT EXISTS idx_campaign_content_content_id ON campaign_content(content_id);
@@ -0,0 +1,3 @@
+-- Drop extracted entities
+DROP INDEX IF EXISTS idx_content_entities_kind_value;
+DROP TABLE IF EXISTS content_entities;
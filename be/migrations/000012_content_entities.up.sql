@@ -0,0 +1,34 @@
+-- Hashtags, mentions, cashtags and outbound URLs extracted from content text
+CREATE TABLE IF NOT EXISTS content_entities (
+    content_id INTEGER NOT NULL REFERENCES content(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    -- Normalized value: hashtags and mentions lowercase without sigil, cashtags uppercase without '$'
+    value TEXT NOT NULL,
+    PRIMARY KEY (content_id, kind, value),
+    CONSTRAINT chk_content_entity_kind CHECK (kind IN ('hashtag', 'mention', 'cashtag', 'url'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_entities_kind_value ON content_entities(kind, value);
+
+-- Backfill existing content. New rows are extracted by the application.
+INSERT INTO content_entities (content_id, kind, value)
+SELECT DISTINCT c.id, e.kind, e.value
+FROM content c
+CROSS JOIN LATERAL (
+    SELECT 'hashtag' AS kind, lower(m[1]) AS value
+    FROM regexp_matches(COALESCE(c.original_text, '') || ' ' || COALESCE(c.description, ''),
+                        '(?:^|[^[:alnum:]_&/#])#([[:alnum:]_]*[[:alpha:]_][[:alnum:]_]*)', 'g') AS m
+    UNION
+    SELECT 'mention', lower(m[1])
+    FROM regexp_matches(COALESCE(c.original_text, '') || ' ' || COALESCE(c.description, ''),
+                        '(?:^|[^[:alnum:]_@.])@([A-Za-z0-9_]{1,15})\M', 'g') AS m
+    UNION
+    SELECT 'cashtag', upper(m[1])
+    FROM regexp_matches(COALESCE(c.original_text, '') || ' ' || COALESCE(c.description, ''),
+                        '(?:^|[^[:alnum:]_$])\$([A-Za-z]{1,6}(?:[._][A-Za-z]{1,2})?)\M', 'g') AS m
+    UNION
+    SELECT 'url', rtrim(m[1], '.,;:!?)]}''"')
+    FROM regexp_matches(COALESCE(c.original_text, '') || ' ' || COALESCE(c.description, ''),
+                        '(https?://[^[:space:]<>"]+)', 'g') AS m
+) e
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+-- Drop the tag vocabulary. Content keeps its canonical tag names.
+DROP INDEX IF EXISTS idx_content_tags;
+DROP INDEX IF EXISTS idx_tag_aliases_tag_id;
+DROP TABLE IF EXISTS tag_aliases;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,45 @@
+-- Managed tag vocabulary. slug is the lowercase alphanumeric form of a name
+-- that spellings are matched on, so "NikeQ3" and "nike-q3" resolve to one tag.
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    color VARCHAR(7),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_tag_color CHECK (color IS NULL OR color ~ '^#[0-9a-fA-F]{6}$')
+);
+
+-- Alternative spellings that resolve to a tag
+CREATE TABLE IF NOT EXISTS tag_aliases (
+    slug VARCHAR(100) PRIMARY KEY,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    alias VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_aliases_tag_id ON tag_aliases(tag_id);
+CREATE INDEX IF NOT EXISTS idx_content_tags ON content USING GIN (tags);
+
+-- Build the vocabulary from existing content, using the most common spelling as the name
+INSERT INTO tags (name, slug)
+SELECT DISTINCT ON (slug) name, slug
+FROM (
+    SELECT btrim(t) AS name, regexp_replace(lower(t), '[^[:alnum:]]', '', 'g') AS slug, COUNT(*) AS uses
+    FROM content, unnest(tags) AS t
+    GROUP BY 1, 2
+) spellings
+WHERE slug <> ''
+ORDER BY slug, uses DESC, name
+ON CONFLICT DO NOTHING;
+
+-- Rewrite existing content to canonical names
+UPDATE content c SET tags = ARRAY(
+    SELECT tg.name
+    FROM unnest(c.tags) WITH ORDINALITY AS u(t, ord)
+    JOIN tags tg ON tg.slug = regexp_replace(lower(u.t), '[^[:alnum:]]', '', 'g')
+    GROUP BY tg.name
+    ORDER BY MIN(u.ord)
+)
+WHERE cardinality(c.tags) > 0;
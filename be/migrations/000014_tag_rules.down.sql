@@ -0,0 +1,3 @@
+-- Drop tag rules
+DROP INDEX IF EXISTS idx_tag_rules_owner_id;
+DROP TABLE IF EXISTS tag_rules;
@@ -0,0 +1,26 @@
+-- Rules that tag synced content automatically. Every condition that is set
+-- must match. Rules without an owner are global and apply to all creators.
+CREATE TABLE IF NOT EXISTS tag_rules (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Case-insensitive substring of the post text
+    keyword TEXT,
+    -- Regular expression (Go RE2 syntax) matched against the post text
+    pattern TEXT,
+    -- Normalized hashtag the post must carry
+    hashtag VARCHAR(255),
+    platform VARCHAR(50),
+    social_account_id INTEGER REFERENCES social_accounts(id) ON DELETE CASCADE,
+    tags TEXT[] NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_tag_rule_condition CHECK (
+        keyword IS NOT NULL OR pattern IS NOT NULL OR hashtag IS NOT NULL
+        OR platform IS NOT NULL OR social_account_id IS NOT NULL
+    ),
+    CONSTRAINT chk_tag_rule_tags CHECK (cardinality(tags) > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_rules_owner_id ON tag_rules(owner_id);
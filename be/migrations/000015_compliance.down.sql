@@ -0,0 +1,6 @@
+-- Drop compliance checks
+DROP INDEX IF EXISTS idx_content_compliance_status;
+DROP TABLE IF EXISTS content_compliance;
+DROP TABLE IF EXISTS disclosure_rules;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS jurisdictions;
+ALTER TABLE content DROP COLUMN IF EXISTS paid_partnership;
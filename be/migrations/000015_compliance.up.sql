@@ -0,0 +1,36 @@
+-- Platform paid-partnership label, which satisfies disclosure rules that accept it
+ALTER TABLE content ADD COLUMN IF NOT EXISTS paid_partnership BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Markets a campaign runs in, e.g. US or UK, used to pick disclosure rules
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS jurisdictions TEXT[] NOT NULL DEFAULT '{}';
+
+-- Disclosure rules that sponsored content (content attached to a campaign) must satisfy.
+-- A rule applies to every campaign unless campaign_id is set, and to every
+-- jurisdiction unless jurisdiction is set.
+CREATE TABLE IF NOT EXISTS disclosure_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    jurisdiction VARCHAR(10),
+    campaign_id INTEGER REFERENCES campaigns(id) ON DELETE CASCADE,
+    -- Normalized hashtags, any one of which discloses the post
+    required_hashtags TEXT[] NOT NULL DEFAULT '{}',
+    accept_paid_partnership BOOLEAN NOT NULL DEFAULT TRUE,
+    -- The disclosure hashtag must start within this many characters of the post text
+    max_position INTEGER,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_disclosure_rule_method CHECK (cardinality(required_hashtags) > 0 OR accept_paid_partnership),
+    CONSTRAINT chk_disclosure_rule_position CHECK (max_position IS NULL OR max_position > 0)
+);
+
+-- Latest compliance result per sponsored content row
+CREATE TABLE IF NOT EXISTS content_compliance (
+    content_id INTEGER PRIMARY KEY REFERENCES content(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL,
+    reasons TEXT[] NOT NULL DEFAULT '{}',
+    evaluated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_compliance_status CHECK (status IN ('pass', 'fail'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_compliance_status ON content_compliance(status);
@@ -0,0 +1,6 @@
+-- Drop content change feed
+DROP TRIGGER IF EXISTS trg_content_events ON content;
+DROP FUNCTION IF EXISTS record_content_event();
+DROP INDEX IF EXISTS idx_content_events_created_at;
+DROP INDEX IF EXISTS idx_content_events_user_id;
+DROP TABLE IF EXISTS content_events;
@@ -0,0 +1,55 @@
+-- Content change feed for real-time streams. A trigger on content records each
+-- change and notifies the content_events channel, so every replica hears about
+-- changes made by any other. Ids are used as SSE event ids for resuming.
+CREATE TABLE IF NOT EXISTS content_events (
+    id BIGSERIAL PRIMARY KEY,
+    -- No foreign key: events outlive purged content
+    content_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    event_type VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_content_event_type CHECK (event_type IN ('created', 'updated', 'deleted'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_events_user_id ON content_events(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_content_events_created_at ON content_events(created_at);
+
+-- Moving content to the trash is a delete and restoring it is a create; edits
+-- to content in the trash are not reported
+CREATE OR REPLACE FUNCTION record_content_event() RETURNS TRIGGER AS $$
+DECLARE
+    kind VARCHAR(20);
+    changed content%ROWTYPE;
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        changed := NEW;
+        kind := CASE WHEN NEW.deleted_at IS NULL THEN 'created' END;
+    ELSIF TG_OP = 'DELETE' THEN
+        changed := OLD;
+        kind := CASE WHEN OLD.deleted_at IS NULL THEN 'deleted' END;
+    ELSE
+        changed := NEW;
+        kind := CASE
+            WHEN OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN 'deleted'
+            WHEN OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN 'created'
+            WHEN NEW.deleted_at IS NULL AND NEW IS DISTINCT FROM OLD THEN 'updated'
+        END;
+    END IF;
+
+    IF kind IS NOT NULL THEN
+        INSERT INTO content_events (content_id, user_id, event_type)
+        VALUES (changed.id, changed.user_id, kind)
+        RETURNING id INTO event_id;
+        PERFORM pg_notify('content_events', json_build_object(
+            'id', event_id, 'content_id', changed.id, 'user_id', changed.user_id, 'type', kind
+        )::TEXT);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_content_events ON content;
+CREATE TRIGGER trg_content_events
+    AFTER INSERT OR UPDATE OR DELETE ON content
+    FOR EACH ROW EXECUTE FUNCTION record_content_event();
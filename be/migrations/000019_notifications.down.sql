@@ -0,0 +1,5 @@
+-- Drop notifications
+DROP TABLE IF EXISTS notifications;
+DROP INDEX IF EXISTS idx_sync_runs_created_at;
+DROP TABLE IF EXISTS sync_runs;
+DROP TABLE IF EXISTS notification_preferences;
@@ -0,0 +1,47 @@
+-- Per-user notification settings. Users without a row get the defaults:
+-- email on, Slack off, alerts on, and a daily digest for admins and managers
+-- or a weekly one for creators.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Slack incoming webhook URL; Slack notifications are off when NULL
+    slack_webhook_url TEXT,
+    digest_frequency VARCHAR(10) NOT NULL,
+    -- Immediate alerts for failed syncs and expiring tokens
+    alerts_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_digest_frequency CHECK (digest_frequency IN ('off', 'daily', 'weekly'))
+);
+
+-- Outcome of every account sync, summarised in digests
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id SERIAL PRIMARY KEY,
+    social_account_id INTEGER NOT NULL REFERENCES social_accounts(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    synced_count INTEGER NOT NULL DEFAULT 0,
+    skipped_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_sync_run_status CHECK (status IN ('succeeded', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_runs_created_at ON sync_runs(created_at);
+
+-- Digests and alerts sent to users. The key identifies what a notification is
+-- about, e.g. the digest period or the account and day of a failed sync, so
+-- each is sent at most once; failed sends may be retried.
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    dedupe_key VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    CONSTRAINT uq_notifications_key UNIQUE (user_id, kind, dedupe_key),
+    CONSTRAINT chk_notification_status CHECK (status IN ('pending', 'sent', 'failed'))
+);
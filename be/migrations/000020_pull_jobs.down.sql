@@ -0,0 +1,5 @@
+-- Drop pull jobs
+DROP INDEX IF EXISTS idx_pull_jobs_finished_at;
+DROP INDEX IF EXISTS idx_pull_jobs_due;
+DROP INDEX IF EXISTS uq_pull_jobs_active_account;
+DROP TABLE IF EXISTS pull_jobs;
@@ -0,0 +1,31 @@
+-- Queue of content pulls from social accounts. Workers claim queued jobs with
+-- SELECT ... FOR UPDATE SKIP LOCKED; a running job whose lease has expired
+-- belongs to a worker that died and is claimed again.
+CREATE TABLE IF NOT EXISTS pull_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    social_account_id INTEGER NOT NULL REFERENCES social_accounts(id) ON DELETE CASCADE,
+    -- Who requested the pull, recorded on the audit events of synced content
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    impersonator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- When a queued job is due, e.g. after the X API rate limit resets
+    run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    lease_expires_at TIMESTAMP,
+    fetched_count INTEGER NOT NULL DEFAULT 0,
+    processed_count INTEGER NOT NULL DEFAULT 0,
+    -- The sync response of a finished job
+    result JSONB,
+    last_error TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_pull_job_status CHECK (status IN ('queued', 'running', 'succeeded', 'failed'))
+);
+
+-- At most one pull of an account is waiting or running at a time
+CREATE UNIQUE INDEX IF NOT EXISTS uq_pull_jobs_active_account ON pull_jobs(social_account_id) WHERE status IN ('queued', 'running');
+CREATE INDEX IF NOT EXISTS idx_pull_jobs_due ON pull_jobs(run_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_pull_jobs_finished_at ON pull_jobs(finished_at) WHERE finished_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Drop sync cursors
+DROP TABLE IF EXISTS sync_cursors;
@@ -0,0 +1,31 @@
+-- Where the sync of each account has got to. Tweet IDs grow over time, so
+-- pulls ask X for tweets after newest_id. A pull that stops with more pages
+-- to fetch keeps the pagination token and resumes from it; newest_id moves
+-- up to the high-water mark, the newest tweet fetched, once the pages run out.
+CREATE TABLE IF NOT EXISTS sync_cursors (
+    social_account_id INTEGER PRIMARY KEY REFERENCES social_accounts(id) ON DELETE CASCADE,
+    newest_id TEXT,
+    -- The oldest tweet fetched, how far back the account's history reaches
+    oldest_id TEXT,
+    pagination_token TEXT,
+    high_water_id TEXT,
+    high_water_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Start from the synced content, trashed posts included. IDs are compared as
+-- numbers since they are decimal strings.
+INSERT INTO sync_cursors (social_account_id, newest_id, oldest_id, high_water_id, high_water_at)
+SELECT DISTINCT ON (newest.social_account_id)
+    newest.social_account_id, newest.external_post_id, oldest.external_post_id,
+    newest.external_post_id, newest.posted_at
+FROM content newest
+JOIN LATERAL (
+    SELECT external_post_id FROM content
+    WHERE social_account_id = newest.social_account_id AND external_post_id ~ '^[0-9]+$'
+    ORDER BY external_post_id::NUMERIC
+    LIMIT 1
+) oldest ON TRUE
+WHERE newest.social_account_id IS NOT NULL AND newest.external_post_id ~ '^[0-9]+$'
+ORDER BY newest.social_account_id, newest.external_post_id::NUMERIC DESC
+ON CONFLICT (social_account_id) DO NOTHING;
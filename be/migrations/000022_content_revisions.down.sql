@@ -0,0 +1,7 @@
+-- Drop content revisions and platform state
+DROP TABLE IF EXISTS content_revisions;
+DROP INDEX IF EXISTS idx_content_reconciled_at;
+ALTER TABLE content DROP COLUMN IF EXISTS reconciled_at;
+ALTER TABLE content DROP COLUMN IF EXISTS removed_on_platform_at;
+ALTER TABLE content DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE content DROP COLUMN IF EXISTS edit_history_ids;
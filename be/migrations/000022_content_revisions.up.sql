@@ -0,0 +1,24 @@
+-- Synced posts follow edits and deletions on the platform. An edited tweet is
+-- a new tweet listing all versions in its edit history; the content row keeps
+-- its original ID and link and takes the latest text.
+ALTER TABLE content ADD COLUMN IF NOT EXISTS edit_history_ids TEXT[];
+ALTER TABLE content ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+-- Set when the platform no longer returns the post
+ALTER TABLE content ADD COLUMN IF NOT EXISTS removed_on_platform_at TIMESTAMP;
+-- When the post was last looked up on the platform to find removed posts
+ALTER TABLE content ADD COLUMN IF NOT EXISTS reconciled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_content_reconciled_at ON content(reconciled_at NULLS FIRST)
+    WHERE deleted_at IS NULL AND external_post_id IS NOT NULL;
+
+-- The text of synced posts before each edit
+CREATE TABLE IF NOT EXISTS content_revisions (
+    id SERIAL PRIMARY KEY,
+    content_id INTEGER NOT NULL REFERENCES content(id) ON DELETE CASCADE,
+    -- The ID of the version the text belonged to
+    external_post_id TEXT,
+    original_text TEXT,
+    revised_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_revisions_content_id ON content_revisions(content_id);
@@ -0,0 +1,2 @@
+-- Drop content media
+DROP TABLE IF EXISTS content_media;
@@ -0,0 +1,26 @@
+-- Photos, videos and GIFs attached to synced posts, in the order they were
+-- attached. url is the file itself: the photo, or the best MP4 of a video.
+CREATE TABLE IF NOT EXISTS content_media (
+    id SERIAL PRIMARY KEY,
+    content_id INTEGER NOT NULL REFERENCES content(id) ON DELETE CASCADE,
+    media_key TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    type TEXT NOT NULL,
+    url TEXT,
+    preview_url TEXT,
+    width INTEGER,
+    height INTEGER,
+    duration_ms INTEGER,
+    alt_text TEXT,
+    -- Where the file is kept in the media archive once it has been copied
+    -- there, so it outlives the post
+    archive_key TEXT,
+    archived_at TIMESTAMP,
+    archive_attempts INTEGER NOT NULL DEFAULT 0,
+    archive_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (content_id, media_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_media_unarchived ON content_media(id)
+    WHERE archived_at IS NULL AND url IS NOT NULL;
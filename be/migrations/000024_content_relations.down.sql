@@ -0,0 +1,7 @@
+-- Drop content relations
+DROP INDEX IF EXISTS idx_content_parent_content_id;
+DROP INDEX IF EXISTS idx_content_conversation;
+ALTER TABLE content DROP COLUMN IF EXISTS parent_content_id;
+ALTER TABLE content DROP COLUMN IF EXISTS parent_external_id;
+ALTER TABLE content DROP COLUMN IF EXISTS relation;
+ALTER TABLE content DROP COLUMN IF EXISTS conversation_id;
@@ -0,0 +1,12 @@
+-- How synced posts relate to each other. conversation_id is the post that
+-- started the thread a post is in. relation is how a post relates to its
+-- parent: thread (continues the account's own post), reply, quote or
+-- retweet. parent_content_id is set once the parent is stored too.
+ALTER TABLE content ADD COLUMN IF NOT EXISTS conversation_id TEXT;
+ALTER TABLE content ADD COLUMN IF NOT EXISTS relation TEXT;
+ALTER TABLE content ADD COLUMN IF NOT EXISTS parent_external_id TEXT;
+ALTER TABLE content ADD COLUMN IF NOT EXISTS parent_content_id INTEGER REFERENCES content(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_content_conversation ON content(user_id, conversation_id)
+    WHERE conversation_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_content_parent_content_id ON content(parent_content_id);
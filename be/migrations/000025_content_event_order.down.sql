@@ -0,0 +1,5 @@
+-- Read content events in id order again
+DROP INDEX IF EXISTS idx_content_events_user_position;
+DROP INDEX IF EXISTS idx_content_events_position;
+CREATE INDEX IF NOT EXISTS idx_content_events_user_id ON content_events(user_id, id);
+ALTER TABLE content_events DROP COLUMN IF EXISTS xact_id;
@@ -0,0 +1,10 @@
+-- Content event ids are allocated when a change is made but become visible
+-- when its transaction commits, so a reader following ids would skip an event
+-- that commits after a later one. Each event records its transaction, and
+-- streams read events in (xact_id, id) order, only once every transaction
+-- before them has finished.
+ALTER TABLE content_events ADD COLUMN IF NOT EXISTS xact_id XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+DROP INDEX IF EXISTS idx_content_events_user_id;
+CREATE INDEX IF NOT EXISTS idx_content_events_position ON content_events(xact_id, id);
+CREATE INDEX IF NOT EXISTS idx_content_events_user_position ON content_events(user_id, xact_id, id);
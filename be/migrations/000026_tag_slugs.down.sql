@@ -0,0 +1,3 @@
+-- Slugs stay as recomputed and merged tags stay merged; the old slugs did not
+-- match the application's and cannot be restored
+SELECT 1;
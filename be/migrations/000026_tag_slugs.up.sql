@@ -0,0 +1,68 @@
+-- 000013 built tag slugs with the database locale, which may only count ASCII
+-- as alphanumeric, so "café" got the slug "caf" while the application's
+-- TagSlug keeps every Unicode letter and digit. Recompute slugs to match and
+-- merge tags that now share one.
+
+-- Slug of a tag name as computed by the application. [:alnum:] and lower()
+-- follow the collation, so use ICU or the builtin Unicode collation where the
+-- server has one, and the database locale otherwise.
+DO $$
+DECLARE
+    coll TEXT := 'default';
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_collation WHERE collname = 'und-x-icu') THEN
+        coll := 'und-x-icu';
+    ELSIF EXISTS (SELECT 1 FROM pg_collation WHERE collname = 'pg_c_utf8') THEN
+        coll := 'pg_c_utf8';
+    END IF;
+    EXECUTE format($f$
+        CREATE FUNCTION pg_temp.tag_slug(name TEXT) RETURNS TEXT AS $s$
+            SELECT regexp_replace(lower(name COLLATE %I), '[^[:alnum:]]', '', 'g')
+        $s$ LANGUAGE SQL IMMUTABLE
+    $f$, coll);
+END
+$$;
+
+-- Each tag's new slug, and the tag it is kept as: the tag already holding the
+-- slug, or the oldest of those sharing it
+CREATE TEMP TABLE tag_reslugs AS
+SELECT id, name, slug, FIRST_VALUE(id) OVER (PARTITION BY slug ORDER BY old_slug = slug DESC, id) AS keep_id
+FROM (SELECT id, name, slug AS old_slug, pg_temp.tag_slug(name) AS slug FROM tags) t;
+
+-- Content carries tag names, so rename merged tags to the tag they are kept as
+UPDATE content c SET tags = ARRAY(
+    SELECT COALESCE(k.name, u.t)
+    FROM unnest(c.tags) WITH ORDINALITY AS u(t, ord)
+    LEFT JOIN tag_reslugs r ON r.name = u.t AND r.id <> r.keep_id
+    LEFT JOIN tags k ON k.id = r.keep_id
+    GROUP BY 1
+    ORDER BY MIN(u.ord)
+), updated_at = CURRENT_TIMESTAMP
+WHERE c.tags && ARRAY(SELECT name::TEXT FROM tag_reslugs WHERE id <> keep_id);
+
+-- Re-slug aliases onto the kept tags, dropping those that now match a tag's
+-- own slug and keeping the oldest where several share a slug
+CREATE TEMP TABLE tag_alias_reslugs AS
+SELECT DISTINCT ON (slug) slug, tag_id, alias, created_at
+FROM (
+    SELECT pg_temp.tag_slug(a.alias) AS slug, r.keep_id AS tag_id, a.alias, a.created_at
+    FROM tag_aliases a
+    JOIN tag_reslugs r ON r.id = a.tag_id
+) a
+WHERE slug <> '' AND NOT EXISTS (SELECT 1 FROM tag_reslugs r WHERE r.slug = a.slug)
+ORDER BY slug, created_at, alias;
+
+DELETE FROM tag_aliases;
+DELETE FROM tags t USING tag_reslugs r WHERE r.id = t.id AND r.id <> r.keep_id;
+
+-- Slugs are unique and checked row by row, so clear them before setting the
+-- new ones; '#' never appears in a slug
+UPDATE tags SET slug = '#' || id;
+UPDATE tags t SET slug = r.slug FROM tag_reslugs r WHERE r.id = t.id;
+
+INSERT INTO tag_aliases (slug, tag_id, alias, created_at)
+SELECT slug, tag_id, alias, created_at FROM tag_alias_reslugs;
+
+DROP TABLE tag_alias_reslugs;
+DROP TABLE tag_reslugs;
+DROP FUNCTION pg_temp.tag_slug(TEXT);
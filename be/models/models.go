@@ -1,9 +1,28 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+)
+
+// Roles a user can hold
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleCreator = "creator"
 )
 
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleManager, RoleCreator:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	ID        int       `json:"id" db:"id"`
 	UserID    string    `json:"user_id" db:"user_id"`
@@ -26,6 +45,7 @@ type SocialAccount struct {
 	LastPullAt     *time.Time `json:"last_pull_at,omitempty" db:"last_pull_at"`
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type Content struct {
@@ -39,8 +59,29 @@ type Content struct {
 	Tags            []string   `json:"tags,omitempty" db:"tags"`
 	ExternalPostID  *string    `json:"external_post_id,omitempty" db:"external_post_id"`
 	PostedAt        *time.Time `json:"posted_at,omitempty" db:"posted_at"`
+	PaidPartnership bool       `json:"paid_partnership" db:"paid_partnership"`
 	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// EditHistoryIDs are the IDs of all versions of an edited synced post, oldest first
+	EditHistoryIDs []string   `json:"edit_history_ids,omitempty" db:"edit_history_ids"`
+	EditedAt       *time.Time `json:"edited_at,omitempty" db:"edited_at"`
+	// RemovedOnPlatformAt is when the platform was found to no longer have the post
+	RemovedOnPlatformAt *time.Time `json:"removed_on_platform_at,omitempty" db:"removed_on_platform_at"`
+	// ConversationID is the ID of the post that started the thread a synced post is in
+	ConversationID *string `json:"conversation_id,omitempty" db:"conversation_id"`
+	// Relation is how a synced post relates to its parent, one of the Relation constants
+	Relation         *string `json:"relation,omitempty" db:"relation"`
+	ParentExternalID *string `json:"parent_external_id,omitempty" db:"parent_external_id"`
+	// ParentContentID is the parent, once it is stored too
+	ParentContentID *int `json:"parent_content_id,omitempty" db:"parent_content_id"`
+	// Entities extracted from the text, filled in by listings and on create
+	Hashtags []string `json:"hashtags,omitempty"`
+	Mentions []string `json:"mentions,omitempty"`
+	Cashtags []string `json:"cashtags,omitempty"`
+	URLs     []string `json:"urls,omitempty"`
+	// Media attached to synced posts, filled in by listings and on sync
+	Media []ContentMedia `json:"media,omitempty"`
 }
 
 type CreateSocialAccountRequest struct {
@@ -58,6 +99,16 @@ type CreateContentRequest struct {
 	OriginalText    *string  `json:"original_text"`
 	Description     *string  `json:"description"`
 	Tags            []string `json:"tags"`
+	PaidPartnership bool     `json:"paid_partnership"`
+}
+
+// UpdateContentRequest edits content. Fields left out are unchanged. The
+// original text can only be edited on manually added content.
+type UpdateContentRequest struct {
+	OriginalText    *string   `json:"original_text"`
+	Description     *string   `json:"description"`
+	Tags            *[]string `json:"tags"`
+	PaidPartnership *bool     `json:"paid_partnership"`
 }
 
 type ContentWithUser struct {
@@ -78,6 +129,739 @@ type SyncResponse struct {
 	AccountName  string   `json:"account_name"`
 	SyncedCount  int      `json:"synced_count"`
 	SkippedCount int      `json:"skipped_count"`
+	UpdatedCount int      `json:"updated_count"`
 	Errors       []string `json:"errors,omitempty"`
 	Message      string   `json:"message"`
 }
+
+// SyncedPost is a post fetched from a platform to be stored as content
+type SyncedPost struct {
+	ExternalID string
+	Text       string
+	Link       string
+	PostedAt   time.Time
+	// Entities the platform reported, or nil to extract them from the text
+	Entities []entities.Entity
+	// EditHistoryIDs are the IDs of all versions of the post, oldest first.
+	// A stored post with any of them is updated to this version.
+	EditHistoryIDs []string
+	// Media attached to the post, in order
+	Media []SyncedMedia
+	// ConversationID, Relation and ParentExternalID place the post in its
+	// thread; empty if unknown or standalone
+	ConversationID   string
+	Relation         string
+	ParentExternalID string
+}
+
+// Relations of a synced post to its parent. A thread post continues the
+// account's own post, while a reply answers someone else's.
+const (
+	RelationThread  = "thread"
+	RelationReply   = "reply"
+	RelationQuote   = "quote"
+	RelationRetweet = "retweet"
+)
+
+// Filters of content listings by relation, set to "true" to apply
+const (
+	// FilterCollapseThreads lists only the first stored post of each thread
+	FilterCollapseThreads = "collapse_threads"
+	FilterExcludeReplies  = "exclude_replies"
+	FilterExcludeRetweets = "exclude_retweets"
+)
+
+// SyncedMedia is a photo, video or GIF attached to a synced post. Empty
+// fields are unknown.
+type SyncedMedia struct {
+	Key        string
+	Type       string
+	URL        string
+	PreviewURL string
+	Width      int
+	Height     int
+	DurationMs int
+	AltText    string
+}
+
+// ContentMedia is a photo, video or GIF attached to synced content. URL is
+// the file itself: the photo, or the best MP4 of a video. ArchiveKey is set
+// once the file has been copied to the media archive.
+type ContentMedia struct {
+	ID         int        `json:"id" db:"id"`
+	ContentID  int        `json:"content_id" db:"content_id"`
+	MediaKey   string     `json:"media_key" db:"media_key"`
+	Type       string     `json:"type" db:"type"`
+	URL        *string    `json:"url,omitempty" db:"url"`
+	PreviewURL *string    `json:"preview_url,omitempty" db:"preview_url"`
+	Width      *int       `json:"width,omitempty" db:"width"`
+	Height     *int       `json:"height,omitempty" db:"height"`
+	DurationMs *int       `json:"duration_ms,omitempty" db:"duration_ms"`
+	AltText    *string    `json:"alt_text,omitempty" db:"alt_text"`
+	ArchiveKey *string    `json:"archive_key,omitempty" db:"archive_key"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty" db:"archived_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Outcomes of storing a synced post
+const (
+	SyncedPostCreated = "created"
+	SyncedPostUpdated = "updated"
+	SyncedPostSkipped = "skipped"
+)
+
+// SyncedPostResult is what became of one post of a synced batch. Content is
+// set for created posts and posts whose text was updated by an edit; skipped
+// posts were already stored as they are, or trashed.
+type SyncedPostResult struct {
+	ExternalID string
+	Status     string
+	Content    *Content
+}
+
+// ContentRevision is the text a synced post had before it was edited
+type ContentRevision struct {
+	ID             int       `json:"id" db:"id"`
+	ContentID      int       `json:"content_id" db:"content_id"`
+	ExternalPostID *string   `json:"external_post_id,omitempty" db:"external_post_id"`
+	OriginalText   *string   `json:"original_text,omitempty" db:"original_text"`
+	RevisedAt      time.Time `json:"revised_at" db:"revised_at"`
+}
+
+// SyncCursor is where the sync of an account has got to. Pulls fetch posts
+// after NewestID, resuming from PaginationToken if the last pull stopped with
+// pages left. The high-water mark is the newest post fetched; NewestID moves
+// up to it once no pages are left.
+type SyncCursor struct {
+	SocialAccountID int        `json:"social_account_id" db:"social_account_id"`
+	NewestID        *string    `json:"newest_id,omitempty" db:"newest_id"`
+	OldestID        *string    `json:"oldest_id,omitempty" db:"oldest_id"`
+	PaginationToken *string    `json:"pagination_token,omitempty" db:"pagination_token"`
+	HighWaterID     *string    `json:"high_water_id,omitempty" db:"high_water_id"`
+	HighWaterAt     *time.Time `json:"high_water_at,omitempty" db:"high_water_at"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// RewindSyncCursorRequest moves an account's sync cursor back, so the next
+// pull fetches posts after NewestID again. Without NewestID the cursor is
+// reset and the next pull starts from the newest posts.
+type RewindSyncCursorRequest struct {
+	NewestID *string `json:"newest_id"`
+}
+
+// RoleChange is an audit record of a user's role being changed
+type RoleChange struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	OldRole   string    `json:"old_role" db:"old_role"`
+	NewRole   string    `json:"new_role" db:"new_role"`
+	ChangedBy *int      `json:"changed_by,omitempty" db:"changed_by"`
+	Source    string    `json:"source" db:"source"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// UpdateUserRoleRequest is used by admins to change a user's role
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// API token scopes
+const (
+	TokenScopeReadOnly  = "read-only"
+	TokenScopeReadWrite = "read-write"
+	TokenScopeAdmin     = "admin"
+)
+
+// APIToken is a personal access token for scripted API access.
+// The token itself is only returned once, on creation.
+type APIToken struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	TokenPrefix string     `json:"token_prefix" db:"token_prefix"`
+	Scope       string     `json:"scope" db:"scope"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateAPITokenRequest struct {
+	Name          string `json:"name" binding:"required"`
+	Scope         string `json:"scope" binding:"required"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+// CreateAPITokenResponse includes the plaintext token, which cannot be retrieved again
+type CreateAPITokenResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// Team groups a manager with the creators on their roster
+type Team struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	MemberCount int       `json:"member_count" db:"member_count"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateTeamRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Description *string `json:"description"`
+}
+
+type AddTeamMemberRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
+
+// SocialAccountWithUser is a social account joined with its owner, for staff views
+type SocialAccountWithUser struct {
+	SocialAccount
+	Username string `json:"username" db:"username"`
+	Email    string `json:"email" db:"email"`
+}
+
+// CurrentUserResponse is the effective user of a request. When an admin is
+// impersonating someone, the admin is returned as the impersonator.
+type CurrentUserResponse struct {
+	User
+	Impersonator *User `json:"impersonator,omitempty"`
+}
+
+// ImpersonationSession lets an admin act as another user until it expires or is ended
+type ImpersonationSession struct {
+	ID           int        `json:"id" db:"id"`
+	AdminID      int        `json:"admin_id" db:"admin_id"`
+	TargetUserID int        `json:"target_user_id" db:"target_user_id"`
+	Reason       *string    `json:"reason,omitempty" db:"reason"`
+	StartedAt    time.Time  `json:"started_at" db:"started_at"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty" db:"ended_at"`
+}
+
+type StartImpersonationRequest struct {
+	UserID          int     `json:"user_id" binding:"required"`
+	DurationMinutes int     `json:"duration_minutes"`
+	Reason          *string `json:"reason"`
+}
+
+// ImpersonationAction records a write request made while impersonating
+type ImpersonationAction struct {
+	ID           int       `json:"id" db:"id"`
+	AdminID      int       `json:"admin_id" db:"admin_id"`
+	TargetUserID int       `json:"target_user_id" db:"target_user_id"`
+	SessionID    *int      `json:"session_id,omitempty" db:"session_id"`
+	Method       string    `json:"method" db:"method"`
+	Path         string    `json:"path" db:"path"`
+	Status       int       `json:"status" db:"status"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// Actor identifies who performed a mutation, for the audit log. A nil UserID
+// means the change was made by the system, e.g. identity provider group mapping.
+type Actor struct {
+	UserID         *int
+	ImpersonatorID *int
+}
+
+// AuditEvent records a single mutation with the state before and after it
+type AuditEvent struct {
+	ID             int64           `json:"id" db:"id"`
+	ActorID        *int            `json:"actor_id,omitempty" db:"actor_id"`
+	ImpersonatorID *int            `json:"impersonator_id,omitempty" db:"impersonator_id"`
+	Action         string          `json:"action" db:"action"`
+	TargetType     string          `json:"target_type" db:"target_type"`
+	TargetID       *int            `json:"target_id,omitempty" db:"target_id"`
+	Before         json.RawMessage `json:"before,omitempty" db:"before"`
+	After          json.RawMessage `json:"after,omitempty" db:"after"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter narrows down audit log queries; zero values are ignored
+type AuditFilter struct {
+	ActorID        *int
+	ImpersonatorID *int
+	Action         string
+	TargetType     string
+	TargetID       *int
+	Since          *time.Time
+	Until          *time.Time
+	Limit          int
+}
+
+// TrashResponse lists a user's soft-deleted content and social accounts
+type TrashResponse struct {
+	Content        []Content       `json:"content"`
+	SocialAccounts []SocialAccount `json:"social_accounts"`
+	RetentionDays  int             `json:"retention_days"`
+}
+
+// Campaign sources for attached content
+const (
+	CampaignSourceManual = "manual"
+	CampaignSourceAuto   = "auto"
+)
+
+// Campaign groups content for a brand over a date range. Content from assigned
+// creators that carries all of the campaign's hashtags is attached automatically.
+type Campaign struct {
+	ID            int       `json:"id" db:"id"`
+	Name          string    `json:"name" db:"name"`
+	Brand         string    `json:"brand" db:"brand"`
+	Brief         *string   `json:"brief,omitempty" db:"brief"`
+	StartDate     time.Time `json:"start_date" db:"start_date"`
+	EndDate       time.Time `json:"end_date" db:"end_date"`
+	Hashtags      []string  `json:"hashtags" db:"hashtags"`
+	Jurisdictions []string  `json:"jurisdictions" db:"jurisdictions"`
+	CreatorCount  int       `json:"creator_count" db:"creator_count"`
+	ContentCount  int       `json:"content_count" db:"content_count"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CampaignRequest creates or replaces a campaign. Dates are YYYY-MM-DD.
+type CampaignRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Brand         string   `json:"brand" binding:"required"`
+	Brief         *string  `json:"brief"`
+	StartDate     string   `json:"start_date" binding:"required"`
+	EndDate       string   `json:"end_date" binding:"required"`
+	Hashtags      []string `json:"hashtags"`
+	Jurisdictions []string `json:"jurisdictions"`
+}
+
+type AddCampaignCreatorRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
+
+type AttachCampaignContentRequest struct {
+	ContentID int `json:"content_id" binding:"required"`
+}
+
+// CampaignContent is a post attached to a campaign
+type CampaignContent struct {
+	Content
+	Source   string `json:"source" db:"source"`
+	InWindow bool   `json:"in_window" db:"in_window"`
+}
+
+// CampaignCreatorReport lists one creator's posts for a campaign
+type CampaignCreatorReport struct {
+	UserID        int               `json:"user_id" db:"user_id"`
+	Username      string            `json:"username" db:"username"`
+	Email         string            `json:"email" db:"email"`
+	Assigned      bool              `json:"assigned" db:"assigned"`
+	PostCount     int               `json:"post_count"`
+	InWindowCount int               `json:"in_window_count"`
+	Posts         []CampaignContent `json:"posts"`
+}
+
+// CampaignReport lists each creator's posts against the campaign window
+type CampaignReport struct {
+	Campaign Campaign                `json:"campaign"`
+	Creators []CampaignCreatorReport `json:"creators"`
+}
+
+// Tag is an entry in the managed tag vocabulary. Content tags always use the
+// canonical name; aliases are alternative spellings that resolve to it.
+type Tag struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Color       *string   `json:"color,omitempty" db:"color"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	Aliases     []string  `json:"aliases" db:"aliases"`
+	UsageCount  int       `json:"usage_count" db:"usage_count"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TagRequest creates a tag or renames and updates one. Aliases are only used on create.
+type TagRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Color       *string  `json:"color"`
+	Description *string  `json:"description"`
+	Aliases     []string `json:"aliases"`
+}
+
+type AddTagAliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+// MergeTagsRequest merges the source tags into the tag in the URL
+type MergeTagsRequest struct {
+	SourceIDs []int `json:"source_ids" binding:"required"`
+}
+
+// TagRule applies tags to content that matches all of its set conditions.
+// Rules without an owner are global and apply to every creator's content.
+type TagRule struct {
+	ID              int       `json:"id" db:"id"`
+	OwnerID         *int      `json:"owner_id,omitempty" db:"owner_id"`
+	Name            string    `json:"name" db:"name"`
+	Enabled         bool      `json:"enabled" db:"enabled"`
+	Keyword         *string   `json:"keyword,omitempty" db:"keyword"`
+	Pattern         *string   `json:"pattern,omitempty" db:"pattern"`
+	Hashtag         *string   `json:"hashtag,omitempty" db:"hashtag"`
+	Platform        *string   `json:"platform,omitempty" db:"platform"`
+	SocialAccountID *int      `json:"social_account_id,omitempty" db:"social_account_id"`
+	Tags            []string  `json:"tags" db:"tags"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TagRuleRequest creates, replaces or previews a tag rule. Only admins may create global rules.
+type TagRuleRequest struct {
+	Name            string   `json:"name" binding:"required"`
+	Enabled         *bool    `json:"enabled"`
+	Keyword         *string  `json:"keyword"`
+	Pattern         *string  `json:"pattern"`
+	Hashtag         *string  `json:"hashtag"`
+	Platform        *string  `json:"platform"`
+	SocialAccountID *int     `json:"social_account_id"`
+	Tags            []string `json:"tags" binding:"required"`
+	Global          bool     `json:"global"`
+}
+
+// TagRulePreview lists the existing content a rule would match, without tagging it
+type TagRulePreview struct {
+	MatchCount int       `json:"match_count"`
+	Matches    []Content `json:"matches"`
+}
+
+// ApplyTagRuleResponse reports how much existing content a rule matched and newly tagged
+type ApplyTagRuleResponse struct {
+	MatchCount  int `json:"match_count"`
+	TaggedCount int `json:"tagged_count"`
+}
+
+// Compliance statuses
+const (
+	ComplianceStatusPass = "pass"
+	ComplianceStatusFail = "fail"
+)
+
+// DisclosureRule is a disclosure requirement for sponsored content. It applies
+// to all campaigns unless CampaignID is set and to all jurisdictions unless
+// Jurisdiction is set. A post satisfies it with one of the required hashtags
+// or, if accepted, the platform's paid-partnership label.
+type DisclosureRule struct {
+	ID                    int       `json:"id" db:"id"`
+	Name                  string    `json:"name" db:"name"`
+	Jurisdiction          *string   `json:"jurisdiction,omitempty" db:"jurisdiction"`
+	CampaignID            *int      `json:"campaign_id,omitempty" db:"campaign_id"`
+	RequiredHashtags      []string  `json:"required_hashtags" db:"required_hashtags"`
+	AcceptPaidPartnership bool      `json:"accept_paid_partnership" db:"accept_paid_partnership"`
+	MaxPosition           *int      `json:"max_position,omitempty" db:"max_position"`
+	Enabled               bool      `json:"enabled" db:"enabled"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type DisclosureRuleRequest struct {
+	Name                  string   `json:"name" binding:"required"`
+	Jurisdiction          *string  `json:"jurisdiction"`
+	CampaignID            *int     `json:"campaign_id"`
+	RequiredHashtags      []string `json:"required_hashtags"`
+	AcceptPaidPartnership *bool    `json:"accept_paid_partnership"`
+	MaxPosition           *int     `json:"max_position"`
+	Enabled               *bool    `json:"enabled"`
+}
+
+// ComplianceResult is the latest disclosure check of a sponsored post
+type ComplianceResult struct {
+	Content     ContentWithUser `json:"content"`
+	Status      string          `json:"status" db:"status"`
+	Reasons     []string        `json:"reasons" db:"reasons"`
+	EvaluatedAt time.Time       `json:"evaluated_at" db:"evaluated_at"`
+}
+
+// EvaluateComplianceResponse reports a full re-evaluation of sponsored content
+type EvaluateComplianceResponse struct {
+	EvaluatedCount int `json:"evaluated_count"`
+	FailCount      int `json:"fail_count"`
+}
+
+// Webhook event types
+const (
+	WebhookEventContentCreated      = "content.created"
+	WebhookEventContentUpdated      = "content.updated"
+	WebhookEventContentDeleted      = "content.deleted"
+	WebhookEventContentPurged       = "content.purged"
+	WebhookEventAccountConnected    = "account.connected"
+	WebhookEventAccountDisconnected = "account.disconnected"
+	WebhookEventAccountPurged       = "account.purged"
+	WebhookEventSyncFailed          = "sync.failed"
+)
+
+// WebhookEvents lists the event types a webhook can subscribe to
+var WebhookEvents = []string{
+	WebhookEventContentCreated,
+	WebhookEventContentUpdated,
+	WebhookEventContentDeleted,
+	WebhookEventContentPurged,
+	WebhookEventAccountConnected,
+	WebhookEventAccountDisconnected,
+	WebhookEventAccountPurged,
+	WebhookEventSyncFailed,
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is an outbound subscription. The secret is only returned once, on creation.
+type Webhook struct {
+	ID        int       `json:"id" db:"id"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"-" db:"secret"`
+	Events    []string  `json:"events" db:"events"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookRequest creates or replaces a webhook. A secret is generated when
+// creating without one; when updating, leaving it out keeps the current one.
+type WebhookRequest struct {
+	URL     string   `json:"url" binding:"required"`
+	Secret  *string  `json:"secret"`
+	Events  []string `json:"events" binding:"required"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// CreateWebhookResponse includes the signing secret, which cannot be retrieved again
+type CreateWebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery is one queued event for one webhook and the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             int             `json:"id" db:"id"`
+	WebhookID      int             `json:"webhook_id" db:"webhook_id"`
+	EventID        string          `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty" db:"last_attempt_at"`
+	ResponseStatus *int            `json:"response_status,omitempty" db:"response_status"`
+	ResponseBody   *string         `json:"response_body,omitempty" db:"response_body"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+// PendingWebhookDelivery is a delivery claimed for sending, with where and how to sign it
+type PendingWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// SyncFailedEvent is the payload of a sync.failed webhook event
+type SyncFailedEvent struct {
+	AccountID   int    `json:"account_id"`
+	UserID      int    `json:"user_id"`
+	Platform    string `json:"platform"`
+	AccountName string `json:"account_name"`
+	Error       string `json:"error"`
+}
+
+// OutboxEvent is a content or account change waiting to be published
+type OutboxEvent struct {
+	ID            int64           `json:"id" db:"id"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   int             `json:"aggregate_id" db:"aggregate_id"`
+	EventType     string          `json:"type" db:"event_type"`
+	Payload       json.RawMessage `json:"data" db:"payload"`
+	Attempts      int             `json:"-" db:"attempts"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Content event types in the real-time feed
+const (
+	ContentEventCreated = "created"
+	ContentEventUpdated = "updated"
+	ContentEventDeleted = "deleted"
+)
+
+// ContentEvent is a change to content. Content is its current state, which is
+// nil once the content has been purged.
+type ContentEvent struct {
+	ID        int64            `json:"id" db:"id"`
+	Type      string           `json:"type" db:"event_type"`
+	ContentID int              `json:"content_id" db:"content_id"`
+	UserID    int              `json:"user_id" db:"user_id"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	Content   *ContentWithUser `json:"content,omitempty"`
+	// XactID is the transaction that recorded the event
+	XactID int64 `json:"-" db:"xact_id"`
+}
+
+// ContentEventPosition is a place in the content event feed, which is ordered
+// by the transaction that recorded an event, then by event ID
+type ContentEventPosition struct {
+	XactID int64
+	ID     int64
+}
+
+// Digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// IsValidDigestFrequency reports whether frequency is one of the known digest frequencies
+func IsValidDigestFrequency(frequency string) bool {
+	switch frequency {
+	case DigestOff, DigestDaily, DigestWeekly:
+		return true
+	}
+	return false
+}
+
+// Notification kinds
+const (
+	NotificationDigest      = "digest"
+	NotificationSyncFailed  = "sync_failed"
+	NotificationTokenExpiry = "token_expiry"
+)
+
+// NotificationPreferences are a user's notification settings. The Slack
+// webhook URL lets anyone post to the channel, so it is never returned.
+type NotificationPreferences struct {
+	UserID          int        `json:"user_id" db:"user_id"`
+	EmailEnabled    bool       `json:"email_enabled" db:"email_enabled"`
+	SlackWebhookURL *string    `json:"-" db:"slack_webhook_url"`
+	SlackEnabled    bool       `json:"slack_enabled"`
+	DigestFrequency string     `json:"digest_frequency" db:"digest_frequency"`
+	AlertsEnabled   bool       `json:"alerts_enabled" db:"alerts_enabled"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// UpdateNotificationPreferencesRequest changes notification settings. Fields
+// left out are unchanged; an empty Slack webhook URL turns Slack off.
+type UpdateNotificationPreferencesRequest struct {
+	EmailEnabled    *bool   `json:"email_enabled"`
+	SlackWebhookURL *string `json:"slack_webhook_url"`
+	DigestFrequency *string `json:"digest_frequency"`
+	AlertsEnabled   *bool   `json:"alerts_enabled"`
+}
+
+// NotificationRecipient is a user with their notification settings
+type NotificationRecipient struct {
+	User
+	Preferences NotificationPreferences `json:"preferences"`
+}
+
+// Sync run statuses
+const (
+	SyncRunSucceeded = "succeeded"
+	SyncRunFailed    = "failed"
+)
+
+// SyncRun is the outcome of one account sync
+type SyncRun struct {
+	ID              int       `json:"id" db:"id"`
+	SocialAccountID int       `json:"social_account_id" db:"social_account_id"`
+	UserID          int       `json:"user_id" db:"user_id"`
+	Status          string    `json:"status" db:"status"`
+	SyncedCount     int       `json:"synced_count" db:"synced_count"`
+	SkippedCount    int       `json:"skipped_count" db:"skipped_count"`
+	Error           *string   `json:"error,omitempty" db:"error"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// Pull job statuses
+const (
+	PullJobQueued    = "queued"
+	PullJobRunning   = "running"
+	PullJobSucceeded = "succeeded"
+	PullJobFailed    = "failed"
+)
+
+// PullJob is a queued pull of new content from a social account
+type PullJob struct {
+	ID              int    `json:"id" db:"id"`
+	UserID          int    `json:"user_id" db:"user_id"`
+	SocialAccountID int    `json:"social_account_id" db:"social_account_id"`
+	Status          string `json:"status" db:"status"`
+	Attempts        int    `json:"attempts" db:"attempts"`
+	// RunAt is when a queued job is due
+	RunAt    time.Time       `json:"run_at" db:"run_at"`
+	Progress PullJobProgress `json:"progress"`
+	// Result is the sync response of a finished job
+	Result     *SyncResponse `json:"result,omitempty" db:"result"`
+	Error      *string       `json:"error,omitempty" db:"last_error"`
+	StartedAt  *time.Time    `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty" db:"finished_at"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at" db:"updated_at"`
+	// LeaseExpiresAt is when the claim on a running job runs out. It also
+	// identifies the claim, so a worker whose job was claimed again cannot
+	// record an outcome for it.
+	LeaseExpiresAt *time.Time `json:"-" db:"lease_expires_at"`
+	// Actor requested the pull
+	Actor Actor `json:"-"`
+}
+
+// PullJobProgress is how far a running pull has come
+type PullJobProgress struct {
+	Fetched   int `json:"fetched" db:"fetched_count"`
+	Processed int `json:"processed" db:"processed_count"`
+}
+
+// Finished reports whether the job has succeeded or failed
+func (j PullJob) Finished() bool {
+	return j.Status == PullJobSucceeded || j.Status == PullJobFailed
+}
+
+// Digest summarises what happened over one digest period
+type Digest struct {
+	Frequency string          `json:"frequency"`
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Creators  []DigestCreator `json:"creators"`
+}
+
+// DigestCreator is one creator's activity in a digest. Posts holds only the
+// most recent of PostCount new posts.
+type DigestCreator struct {
+	UserID        int       `json:"user_id"`
+	Username      string    `json:"username"`
+	PostCount     int       `json:"post_count"`
+	Posts         []Content `json:"posts"`
+	SyncCount     int       `json:"sync_count"`
+	FailedSyncs   int       `json:"failed_syncs"`
+	LastSyncError *string   `json:"last_sync_error,omitempty"`
+}
+
+// Notification statuses
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// Notification is a digest or alert sent to a user. Key identifies what it is
+// about, such as the digest period, so that it is only sent once.
+type Notification struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	Kind      string     `json:"kind" db:"kind"`
+	Key       string     `json:"key" db:"dedupe_key"`
+	Subject   string     `json:"subject" db:"subject"`
+	Status    string     `json:"status" db:"status"`
+	Error     *string    `json:"error,omitempty" db:"error"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+}
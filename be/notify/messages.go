@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+const (
+	// DigestPostsPerCreator is how many of each creator's newest posts a digest lists
+	DigestPostsPerCreator = 5
+
+	snippetLength = 80
+)
+
+// DigestPeriod returns the most recent complete period for a digest frequency,
+// and a key identifying it: the previous UTC day for daily digests and the
+// previous ISO week, Monday to Monday, for weekly ones. ok is false when the
+// frequency has no digests.
+func DigestPeriod(frequency string, now time.Time) (from, to time.Time, key string, ok bool) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch frequency {
+	case models.DigestDaily:
+		from = today.AddDate(0, 0, -1)
+		return from, today, "daily:" + from.Format("2006-01-02"), true
+	case models.DigestWeekly:
+		sinceMonday := (int(today.Weekday()) + 6) % 7
+		to = today.AddDate(0, 0, -sinceMonday)
+		from = to.AddDate(0, 0, -7)
+		year, week := from.ISOWeek()
+		return from, to, fmt.Sprintf("weekly:%d-W%02d", year, week), true
+	}
+	return time.Time{}, time.Time{}, "", false
+}
+
+// RenderDigest renders a digest as a message
+func RenderDigest(digest models.Digest) Message {
+	var subject string
+	switch digest.Frequency {
+	case models.DigestWeekly:
+		last := digest.To.AddDate(0, 0, -1)
+		subject = fmt.Sprintf("Your weekly SocialTracker digest for %s – %s", digest.From.Format("Jan 2"), last.Format("Jan 2, 2006"))
+	default:
+		subject = fmt.Sprintf("Your daily SocialTracker digest for %s", digest.From.Format("Jan 2, 2006"))
+	}
+
+	var posts, syncs, failed int
+	for _, creator := range digest.Creators {
+		posts += creator.PostCount
+		syncs += creator.SyncCount
+		failed += creator.FailedSyncs
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s from %s. %s",
+		plural(posts, "new post", "new posts"), plural(len(digest.Creators), "creator", "creators"), plural(syncs, "sync", "syncs"))
+	if failed > 0 {
+		fmt.Fprintf(&b, ", %d failed", failed)
+	}
+	b.WriteString(".\n")
+
+	for _, creator := range digest.Creators {
+		fmt.Fprintf(&b, "\n%s: %s, %s", creator.Username,
+			plural(creator.PostCount, "new post", "new posts"), plural(creator.SyncCount, "sync", "syncs"))
+		if creator.FailedSyncs > 0 {
+			fmt.Fprintf(&b, " (%d failed)", creator.FailedSyncs)
+		}
+		b.WriteString("\n")
+
+		for _, post := range creator.Posts {
+			fmt.Fprintf(&b, "  - [%s] ", post.Platform)
+			if snippet := postSnippet(post); snippet != "" {
+				fmt.Fprintf(&b, "%s ", snippet)
+			}
+			fmt.Fprintf(&b, "%s\n", post.Link)
+		}
+		if more := creator.PostCount - len(creator.Posts); more > 0 {
+			fmt.Fprintf(&b, "  ...and %d more\n", more)
+		}
+		if creator.LastSyncError != nil {
+			fmt.Fprintf(&b, "  Last sync error: %s\n", *creator.LastSyncError)
+		}
+	}
+
+	return Message{Subject: subject, Text: b.String()}
+}
+
+// SyncFailedAlert tells an account's owner that syncing it failed
+func SyncFailedAlert(account models.SocialAccount, syncErr string) Message {
+	return Message{
+		Subject: fmt.Sprintf("Sync failed for @%s on %s", account.AccountName, account.Platform),
+		Text: fmt.Sprintf("SocialTracker could not sync new posts from @%s on %s:\n\n  %s\n\n"+
+			"New posts will not show up until a sync succeeds. If this keeps happening, reconnect the account.\n",
+			account.AccountName, account.Platform, syncErr),
+	}
+}
+
+// SyncFailedKey identifies sync failure alerts, so an account's owner is
+// alerted at most once a day
+func SyncFailedKey(account models.SocialAccount, now time.Time) string {
+	return fmt.Sprintf("account:%d:%s", account.ID, now.UTC().Format("2006-01-02"))
+}
+
+// TokenExpiryAlert tells an account's owner that its access token has expired
+// or is about to, and cannot be refreshed
+func TokenExpiryAlert(account models.SocialAccount, now time.Time) Message {
+	if account.TokenExpiresAt == nil || !account.TokenExpiresAt.After(now) {
+		return Message{
+			Subject: fmt.Sprintf("Your %s connection for @%s has expired", account.Platform, account.AccountName),
+			Text: fmt.Sprintf("SocialTracker can no longer access @%s on %s with your authorization. "+
+				"Reconnect the account to keep syncing new posts.\n", account.AccountName, account.Platform),
+		}
+	}
+	return Message{
+		Subject: fmt.Sprintf("Your %s connection for @%s expires soon", account.Platform, account.AccountName),
+		Text: fmt.Sprintf("SocialTracker's access to @%s on %s expires on %s UTC and cannot be renewed automatically. "+
+			"Reconnect the account to keep syncing new posts.\n",
+			account.AccountName, account.Platform, account.TokenExpiresAt.UTC().Format("Jan 2, 2006 15:04")),
+	}
+}
+
+// TokenExpiryKey identifies token expiry alerts, so an account's owner is
+// alerted once per token
+func TokenExpiryKey(account models.SocialAccount) string {
+	var expiresAt int64
+	if account.TokenExpiresAt != nil {
+		expiresAt = account.TokenExpiresAt.Unix()
+	}
+	return fmt.Sprintf("account:%d:%d", account.ID, expiresAt)
+}
+
+// postSnippet returns the start of a post's text on a single line
+func postSnippet(post models.Content) string {
+	text := ""
+	if post.OriginalText != nil {
+		text = *post.OriginalText
+	} else if post.Description != nil {
+		text = *post.Description
+	}
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if len(runes) > snippetLength {
+		return strings.TrimSpace(string(runes[:snippetLength-1])) + "…"
+	}
+	return text
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
@@ -0,0 +1,137 @@
+// Package notify renders digests and alerts and sends them to users by email
+// and Slack.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+const defaultSMTPFrom = "SocialTracker <noreply@socialtracker.local>"
+
+// ErrNoChannel is returned when a recipient has no notification channel enabled
+var ErrNoChannel = errors.New("no notification channel is enabled")
+
+// Message is a notification ready to be sent
+type Message struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+// Transport sends messages over one channel
+type Transport interface {
+	// Name identifies the transport in errors
+	Name() string
+	// Accepts reports whether the recipient gets messages over this transport
+	Accepts(recipient models.NotificationRecipient) bool
+	Send(ctx context.Context, recipient models.NotificationRecipient, msg Message) error
+}
+
+// Store keeps track of sent notifications so each is sent only once
+type Store interface {
+	ClaimNotification(ctx context.Context, userID int, kind, key, subject string) (int, bool, error)
+	FinishNotification(ctx context.Context, id int, sent bool, sendErr error) error
+}
+
+// Notifier sends messages over every transport a recipient has enabled
+type Notifier struct {
+	store      Store
+	transports []Transport
+}
+
+// New creates a notifier
+func New(store Store, transports ...Transport) *Notifier {
+	return &Notifier{store: store, transports: transports}
+}
+
+// NewFromEnv creates a notifier that sends to Slack, and by email when
+// SMTP_HOST is set. SMTP_PORT defaults to 25; SMTP_USERNAME and SMTP_PASSWORD
+// enable authentication and SMTP_FROM sets the sender.
+func NewFromEnv(store Store) (*Notifier, error) {
+	transports := []Transport{NewSlackTransport(nil)}
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "25"
+		}
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = defaultSMTPFrom
+		}
+		smtp, err := NewSMTPTransport(net.JoinHostPort(host, port), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+		if err != nil {
+			return nil, err
+		}
+		transports = append(transports, smtp)
+	}
+
+	return New(store, transports...), nil
+}
+
+// Enabled reports whether the recipient gets messages over any transport
+func (n *Notifier) Enabled(recipient models.NotificationRecipient) bool {
+	for _, t := range n.transports {
+		if t.Accepts(recipient) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send sends a message over every transport the recipient has enabled. It
+// returns how many transports delivered it and what went wrong with the rest.
+func (n *Notifier) Send(ctx context.Context, recipient models.NotificationRecipient, msg Message) (int, error) {
+	attempted, delivered := 0, 0
+	var errs []error
+	for _, t := range n.transports {
+		if !t.Accepts(recipient) {
+			continue
+		}
+		attempted++
+		if err := t.Send(ctx, recipient, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.Name(), err))
+			continue
+		}
+		delivered++
+	}
+	if attempted == 0 {
+		return 0, ErrNoChannel
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// Notify sends a message unless the notification identified by kind and key
+// was already sent to the recipient. It reports whether the message was
+// delivered; one that could not be delivered at all is retried on the next
+// call.
+func (n *Notifier) Notify(ctx context.Context, recipient models.NotificationRecipient, kind, key string, msg Message) (bool, error) {
+	if !n.Enabled(recipient) {
+		return false, nil
+	}
+
+	id, claimed, err := n.store.ClaimNotification(ctx, recipient.ID, kind, key, msg.Subject)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	delivered, sendErr := n.Send(ctx, recipient, msg)
+	// Recorded even when ctx ends now, so a sent message is not sent again
+	if err := n.store.FinishNotification(context.WithoutCancel(ctx), id, delivered > 0, sendErr); err != nil {
+		return delivered > 0, err
+	}
+	return delivered > 0, sendErr
+}
+
+// Alert sends an immediate alert once, unless the recipient turned alerts off
+func (n *Notifier) Alert(ctx context.Context, recipient models.NotificationRecipient, kind, key string, msg Message) (bool, error) {
+	if !recipient.Preferences.AlertsEnabled {
+		return false, nil
+	}
+	return n.Notify(ctx, recipient, kind, key, msg)
+}
@@ -0,0 +1,456 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// smtpMessage is what the local SMTP server received
+type smtpMessage struct {
+	auth string
+	from string
+	to   []string
+	data []byte
+}
+
+// newSMTPServer starts a local MailHog-style SMTP server that accepts any
+// message and reports each one it receives
+func newSMTPServer(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan smtpMessage, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func serveSMTP(conn net.Conn, received chan<- smtpMessage) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	var msg smtpMessage
+
+	tp.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250-AUTH PLAIN")
+			tp.PrintfLine("250 8BITMIME")
+		case "AUTH":
+			msg.auth = arg
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			msg.from = arg
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, arg)
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			msg.data, err = tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			tp.PrintfLine("250 OK: queued")
+			received <- msg
+			msg = smtpMessage{}
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// newSlackServer starts a local Slack incoming webhook that records the
+// payloads it receives and answers with status
+func newSlackServer(t *testing.T, status int) (*httptest.Server, <-chan map[string]string) {
+	t.Helper()
+	received := make(chan map[string]string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			io.WriteString(w, "ok")
+		} else {
+			io.WriteString(w, "invalid_token")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func testRecipient(slackURL string) models.NotificationRecipient {
+	recipient := models.NotificationRecipient{
+		User: models.User{ID: 3, Email: "ada@example.com", Username: "Ada Lovelace", Role: models.RoleCreator},
+		Preferences: models.NotificationPreferences{
+			UserID:          3,
+			EmailEnabled:    true,
+			DigestFrequency: models.DigestDaily,
+			AlertsEnabled:   true,
+		},
+	}
+	if slackURL != "" {
+		recipient.Preferences.SlackWebhookURL = &slackURL
+	}
+	return recipient
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received")
+		panic("unreachable")
+	}
+}
+
+func TestSMTPTransportSendsPlainTextEmail(t *testing.T) {
+	addr, received := newSMTPServer(t)
+	transport, err := NewSMTPTransport(addr, "", "", "SocialTracker <noreply@socialtracker.local>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := Message{Subject: "Sync failed for @ada — twitter", Text: "Line one\nLine two with a long tail " + strings.Repeat("x", 100) + "\n"}
+	if err := transport.Send(context.Background(), testRecipient(""), msg); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	got := receive(t, received)
+	if got.auth != "" {
+		t.Errorf("expected no authentication, got %q", got.auth)
+	}
+	if !strings.HasPrefix(got.from, "FROM:<noreply@socialtracker.local>") {
+		t.Errorf("unexpected sender %q", got.from)
+	}
+	if len(got.to) != 1 || got.to[0] != "TO:<ada@example.com>" {
+		t.Errorf("unexpected recipients %q", got.to)
+	}
+
+	email, err := mail.ReadMessage(strings.NewReader(string(got.data)))
+	if err != nil {
+		t.Fatalf("received invalid email: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(email.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("expected subject %q, got %q (%v)", msg.Subject, subject, err)
+	}
+	if to := email.Header.Get("To"); to != `"Ada Lovelace" <ada@example.com>` {
+		t.Errorf("unexpected To header %q", to)
+	}
+	if email.Header.Get("Message-Id") == "" {
+		t.Error("expected a Message-ID")
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(email.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := strings.ReplaceAll(string(body), "\r\n", "\n"); text != msg.Text {
+		t.Errorf("expected body %q, got %q", msg.Text, text)
+	}
+}
+
+func TestSMTPTransportAuthenticates(t *testing.T) {
+	addr, received := newSMTPServer(t)
+	transport, err := NewSMTPTransport(addr, "mailer", "s3cret", "noreply@socialtracker.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := transport.Send(context.Background(), testRecipient(""), Message{Subject: "Hi", Text: "Hello\n"}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	got := receive(t, received)
+	mechanism, credentials, _ := strings.Cut(got.auth, " ")
+	decoded, _ := base64.StdEncoding.DecodeString(credentials)
+	if mechanism != "PLAIN" || string(decoded) != "\x00mailer\x00s3cret" {
+		t.Errorf("unexpected authentication %q", got.auth)
+	}
+}
+
+func TestSMTPTransportAcceptsOnlyEnabledEmail(t *testing.T) {
+	transport, err := NewSMTPTransport("localhost:1025", "", "", "noreply@socialtracker.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipient := testRecipient("")
+	if !transport.Accepts(recipient) {
+		t.Error("expected email to be accepted")
+	}
+	recipient.Preferences.EmailEnabled = false
+	if transport.Accepts(recipient) {
+		t.Error("expected disabled email to be skipped")
+	}
+}
+
+func TestSlackTransportPostsEscapedText(t *testing.T) {
+	server, received := newSlackServer(t, http.StatusOK)
+
+	msg := Message{Subject: "Digest <daily>", Text: "Tom & Jerry\n"}
+	if err := NewSlackTransport(nil).Send(context.Background(), testRecipient(server.URL), msg); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	got := receive(t, received)
+	if want := "*Digest &lt;daily&gt;*\nTom &amp; Jerry\n"; got["text"] != want {
+		t.Errorf("expected text %q, got %q", want, got["text"])
+	}
+}
+
+func TestSlackTransportReportsRejection(t *testing.T) {
+	server, _ := newSlackServer(t, http.StatusForbidden)
+
+	err := NewSlackTransport(nil).Send(context.Background(), testRecipient(server.URL), Message{Subject: "Hi"})
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "invalid_token") {
+		t.Fatalf("expected the rejection to be reported, got %v", err)
+	}
+}
+
+// memoryStore keeps claimed notifications in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	nextID int
+	ids    map[string]int
+	sent   map[int]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{ids: make(map[string]int), sent: make(map[int]bool)}
+}
+
+func (s *memoryStore) ClaimNotification(ctx context.Context, userID int, kind, key, subject string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := kind + "|" + key
+	if id, ok := s.ids[k]; ok {
+		sent, finished := s.sent[id]
+		if !finished || sent {
+			return 0, false, nil
+		}
+	}
+	s.nextID++
+	s.ids[k] = s.nextID
+	return s.nextID, true, nil
+}
+
+func (s *memoryStore) FinishNotification(ctx context.Context, id int, sent bool, sendErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent[id] = sent
+	return nil
+}
+
+func TestNotifySendsOnce(t *testing.T) {
+	server, received := newSlackServer(t, http.StatusOK)
+	notifier := New(newMemoryStore(), NewSlackTransport(nil))
+	recipient := testRecipient(server.URL)
+	msg := Message{Subject: "Hi", Text: "Hello\n"}
+
+	delivered, err := notifier.Notify(context.Background(), recipient, models.NotificationSyncFailed, "account:1:2026-10-18", msg)
+	if err != nil || !delivered {
+		t.Fatalf("expected delivery, got %v, %v", delivered, err)
+	}
+	receive(t, received)
+
+	delivered, err = notifier.Notify(context.Background(), recipient, models.NotificationSyncFailed, "account:1:2026-10-18", msg)
+	if err != nil || delivered {
+		t.Fatalf("expected the repeat to be skipped, got %v, %v", delivered, err)
+	}
+	select {
+	case <-received:
+		t.Fatal("repeat was sent")
+	default:
+	}
+}
+
+func TestNotifyRetriesFailedDelivery(t *testing.T) {
+	failing, _ := newSlackServer(t, http.StatusInternalServerError)
+	working, received := newSlackServer(t, http.StatusOK)
+	notifier := New(newMemoryStore(), NewSlackTransport(nil))
+	msg := Message{Subject: "Hi", Text: "Hello\n"}
+
+	delivered, err := notifier.Notify(context.Background(), testRecipient(failing.URL), models.NotificationDigest, "daily:2026-10-17", msg)
+	if err == nil || delivered {
+		t.Fatalf("expected the delivery to fail, got %v, %v", delivered, err)
+	}
+
+	delivered, err = notifier.Notify(context.Background(), testRecipient(working.URL), models.NotificationDigest, "daily:2026-10-17", msg)
+	if err != nil || !delivered {
+		t.Fatalf("expected the retry to be delivered, got %v, %v", delivered, err)
+	}
+	receive(t, received)
+}
+
+func TestSendReportsPartialFailure(t *testing.T) {
+	slack, _ := newSlackServer(t, http.StatusInternalServerError)
+	addr, received := newSMTPServer(t)
+	smtp, err := NewSMTPTransport(addr, "", "", "noreply@socialtracker.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := New(newMemoryStore(), NewSlackTransport(nil), smtp)
+
+	delivered, err := notifier.Send(context.Background(), testRecipient(slack.URL), Message{Subject: "Hi", Text: "Hello\n"})
+	if delivered != 1 {
+		t.Errorf("expected email to be delivered, got %d deliveries", delivered)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "slack: ") {
+		t.Errorf("expected the Slack failure to be reported, got %v", err)
+	}
+	receive(t, received)
+}
+
+func TestSendWithoutChannels(t *testing.T) {
+	recipient := testRecipient("")
+	recipient.Preferences.EmailEnabled = false
+	notifier := New(newMemoryStore(), NewSlackTransport(nil))
+
+	if _, err := notifier.Send(context.Background(), recipient, Message{Subject: "Hi"}); !errors.Is(err, ErrNoChannel) {
+		t.Fatalf("expected ErrNoChannel, got %v", err)
+	}
+	if notifier.Enabled(recipient) {
+		t.Error("expected notifier to be disabled for the recipient")
+	}
+}
+
+func TestAlertRespectsPreference(t *testing.T) {
+	server, received := newSlackServer(t, http.StatusOK)
+	notifier := New(newMemoryStore(), NewSlackTransport(nil))
+	recipient := testRecipient(server.URL)
+	recipient.Preferences.AlertsEnabled = false
+
+	delivered, err := notifier.Alert(context.Background(), recipient, models.NotificationTokenExpiry, "account:1:0", Message{Subject: "Hi"})
+	if err != nil || delivered {
+		t.Fatalf("expected no alert, got %v, %v", delivered, err)
+	}
+	select {
+	case <-received:
+		t.Fatal("alert was sent")
+	default:
+	}
+}
+
+func TestDigestPeriod(t *testing.T) {
+	// A Sunday evening in New York, already Monday in UTC
+	now := time.Date(2026, 10, 18, 22, 30, 0, 0, time.FixedZone("EDT", -4*3600))
+
+	tests := []struct {
+		frequency string
+		from, to  time.Time
+		key       string
+	}{
+		{models.DigestDaily, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), "daily:2026-10-18"},
+		{models.DigestWeekly, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), "weekly:2026-W42"},
+	}
+	for _, tt := range tests {
+		from, to, key, ok := DigestPeriod(tt.frequency, now)
+		if !ok || !from.Equal(tt.from) || !to.Equal(tt.to) || key != tt.key {
+			t.Errorf("%s: got %s – %s %q %v, want %s – %s %q", tt.frequency, from, to, key, ok, tt.from, tt.to, tt.key)
+		}
+	}
+
+	if _, _, _, ok := DigestPeriod(models.DigestOff, now); ok {
+		t.Error("expected no period when digests are off")
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	text := "Launch day!\n\nCheck out   the new #collab"
+	syncErr := "rate limit exceeded"
+	digest := models.Digest{
+		Frequency: models.DigestWeekly,
+		From:      time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		Creators: []models.DigestCreator{
+			{
+				Username:  "ada",
+				PostCount: 3,
+				Posts: []models.Content{
+					{Platform: "twitter", Link: "https://x.com/ada/status/2", OriginalText: &text},
+					{Platform: "instagram", Link: "https://instagram.com/p/abc"},
+				},
+				SyncCount:     4,
+				FailedSyncs:   1,
+				LastSyncError: &syncErr,
+			},
+			{Username: "grace", SyncCount: 1},
+		},
+	}
+
+	msg := RenderDigest(digest)
+	if want := "Your weekly SocialTracker digest for Oct 12 – Oct 18, 2026"; msg.Subject != want {
+		t.Errorf("expected subject %q, got %q", want, msg.Subject)
+	}
+	for _, want := range []string{
+		"3 new posts from 2 creators. 5 syncs, 1 failed.\n",
+		"\nada: 3 new posts, 4 syncs (1 failed)\n",
+		"  - [twitter] Launch day! Check out the new #collab https://x.com/ada/status/2\n",
+		"  - [instagram] https://instagram.com/p/abc\n",
+		"  ...and 1 more\n",
+		"  Last sync error: rate limit exceeded\n",
+		"\ngrace: 0 new posts, 1 sync\n",
+	} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("expected digest to contain %q, got:\n%s", want, msg.Text)
+		}
+	}
+}
+
+func TestTokenExpiryAlert(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(6 * time.Hour)
+	account := models.SocialAccount{ID: 9, Platform: "twitter", AccountName: "ada", TokenExpiresAt: &expiresAt}
+
+	if msg := TokenExpiryAlert(account, now); !strings.Contains(msg.Subject, "expires soon") || !strings.Contains(msg.Text, "Oct 18, 2026 18:00") {
+		t.Errorf("unexpected upcoming expiry alert %+v", msg)
+	}
+	if msg := TokenExpiryAlert(account, expiresAt.Add(time.Minute)); !strings.Contains(msg.Subject, "has expired") {
+		t.Errorf("unexpected expired alert %+v", msg)
+	}
+	if key := TokenExpiryKey(account); key != "account:9:"+strconv.FormatInt(expiresAt.Unix(), 10) {
+		t.Errorf("unexpected key %q", key)
+	}
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// slackEscaper escapes the characters Slack treats as markup
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackTransport posts messages to each recipient's Slack incoming webhook
+type SlackTransport struct {
+	client *http.Client
+}
+
+// NewSlackTransport creates a Slack transport. A nil client uses one with a 10 second timeout.
+func NewSlackTransport(client *http.Client) *SlackTransport {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &SlackTransport{client: client}
+}
+
+// Name identifies the transport
+func (t *SlackTransport) Name() string {
+	return "slack"
+}
+
+// Accepts reports whether the recipient has set up a Slack webhook
+func (t *SlackTransport) Accepts(recipient models.NotificationRecipient) bool {
+	return recipient.Preferences.SlackWebhookURL != nil && *recipient.Preferences.SlackWebhookURL != ""
+}
+
+// Send posts the message with its subject in bold
+func (t *SlackTransport) Send(ctx context.Context, recipient models.NotificationRecipient, msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"text": "*" + slackEscaper.Replace(msg.Subject) + "*\n" + slackEscaper.Replace(msg.Text),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *recipient.Preferences.SlackWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Slack explains what is wrong in a short plain text body, e.g. "invalid_token"
+		reason, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("slack responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(reason)))
+	}
+	return nil
+}
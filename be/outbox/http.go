@@ -0,0 +1,63 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/webhooks"
+)
+
+// HTTPSink posts each event as JSON to a URL. Receivers should deduplicate on
+// the event ID header, since an event can be delivered more than once.
+type HTTPSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewHTTPSink creates an HTTP sink. Requests are signed like webhook
+// deliveries when secret is not empty. A nil client uses a 10 second timeout.
+func NewHTTPSink(url, secret string, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSink{url: url, secret: secret, client: client}
+}
+
+// Publish posts the event and expects a 2xx response
+func (s *HTTPSink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.HeaderEvent, event.EventType)
+	req.Header.Set(webhooks.HeaderEventID, strconv.FormatInt(event.ID, 10))
+	if s.secret != "" {
+		req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(s.secret, time.Now(), body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sink responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/nats-io/nats.go"
+)
+
+const natsTimeout = 10 * time.Second
+
+// NATSSink publishes events to NATS core subjects named
+// "<prefix>.<event type>", e.g. socialtracker.content.created. Each message
+// carries the event ID in the Nats-Msg-Id header, which JetStream streams
+// deduplicate on. Every publish is flushed, so an event only counts as
+// published once the server has processed it.
+type NATSSink struct {
+	url           string
+	subjectPrefix string
+
+	mu   sync.Mutex
+	conn *nats.Conn
+}
+
+// NewNATSSink creates a sink for a NATS server URL, which may carry
+// credentials and use tls://. The connection is made on the first publish.
+func NewNATSSink(url, subjectPrefix string) *NATSSink {
+	return &NATSSink{url: url, subjectPrefix: strings.TrimSuffix(subjectPrefix, ".")}
+}
+
+// Publish sends the event and waits for the server to acknowledge it
+func (s *NATSSink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(s.subjectPrefix + "." + event.EventType)
+	msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(event.ID, 10))
+	msg.Data = data
+	if err := conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, natsTimeout)
+	defer cancel()
+	return conn.FlushWithContext(ctx)
+}
+
+// connect returns the connection, making it if needed. The client reconnects
+// by itself once connected.
+func (s *NATSSink) connect() (*nats.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil && !s.conn.IsClosed() {
+		return s.conn, nil
+	}
+	conn, err := nats.Connect(s.url,
+		nats.Name("socialtracker-outbox"),
+		nats.Timeout(natsTimeout),
+		nats.MaxReconnects(-1),
+		// Fail publishes while reconnecting, the relay retries them
+		nats.ReconnectBufSize(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// Close closes the connection, if any
+func (s *NATSSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
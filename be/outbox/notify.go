@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// maxNotifyPayload stays under Postgres' 8000 byte NOTIFY payload limit
+const maxNotifyPayload = 7900
+
+// NotifySink publishes events on a Postgres LISTEN/NOTIFY channel
+type NotifySink struct {
+	db      *sql.DB
+	channel string
+}
+
+// NewNotifySink creates a sink that notifies channel
+func NewNotifySink(db *sql.DB, channel string) *NotifySink {
+	return &NotifySink{db: db, channel: channel}
+}
+
+// notifyMessage is an event whose data may have been left out for size
+type notifyMessage struct {
+	models.OutboxEvent
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Publish sends the event as JSON. Events too large for a notification are
+// sent without their data and flagged as truncated.
+func (s *NotifySink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	message, err := json.Marshal(notifyMessage{OutboxEvent: event})
+	if err != nil {
+		return err
+	}
+	if len(message) > maxNotifyPayload {
+		event.Payload = json.RawMessage("null")
+		if message, err = json.Marshal(notifyMessage{OutboxEvent: event, Truncated: true}); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, s.channel, string(message))
+	return err
+}
@@ -0,0 +1,62 @@
+// Package outbox publishes outbox events to a configurable sink.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// Sink publishes events. Publish returns only once the sink has accepted the
+// event; an error means the event is retried later.
+type Sink interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+// Sink types for OUTBOX_SINK
+const (
+	SinkNotify = "notify"
+	SinkHTTP   = "http"
+	SinkNATS   = "nats"
+	SinkNone   = "none"
+)
+
+const (
+	defaultNotifyChannel = "socialtracker_events"
+	defaultNATSURL       = "nats://localhost:4222"
+	defaultNATSSubject   = "socialtracker"
+)
+
+// NewSinkFromEnv builds the sink selected by OUTBOX_SINK:
+//   - notify (default): pg_notify on OUTBOX_NOTIFY_CHANNEL
+//   - http: POST to OUTBOX_HTTP_URL, signed with OUTBOX_HTTP_SECRET when set
+//   - nats: publish to OUTBOX_NATS_URL under the OUTBOX_NATS_SUBJECT prefix
+//   - none: nil, events are kept in the outbox unpublished
+func NewSinkFromEnv(db *sql.DB) (Sink, error) {
+	switch kind := os.Getenv("OUTBOX_SINK"); kind {
+	case "", SinkNotify:
+		return NewNotifySink(db, envOr("OUTBOX_NOTIFY_CHANNEL", defaultNotifyChannel)), nil
+	case SinkHTTP:
+		url := os.Getenv("OUTBOX_HTTP_URL")
+		if url == "" {
+			return nil, fmt.Errorf("OUTBOX_HTTP_URL is required for the %s sink", SinkHTTP)
+		}
+		return NewHTTPSink(url, os.Getenv("OUTBOX_HTTP_SECRET"), nil), nil
+	case SinkNATS:
+		return NewNATSSink(envOr("OUTBOX_NATS_URL", defaultNATSURL), envOr("OUTBOX_NATS_SUBJECT", defaultNATSSubject)), nil
+	case SinkNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown OUTBOX_SINK %q", kind)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
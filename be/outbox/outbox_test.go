@@ -0,0 +1,301 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository/memory"
+	"github.com/Armatorix/SocialTracker/be/webhooks"
+)
+
+const testSecret = "whsec_test-secret-value"
+
+func testEvent() models.OutboxEvent {
+	return models.OutboxEvent{
+		ID:            42,
+		AggregateType: "content",
+		AggregateID:   7,
+		EventType:     models.WebhookEventContentCreated,
+		Payload:       json.RawMessage(`{"id":7,"link":"https://x.com/creator/status/1"}`),
+		CreatedAt:     time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// receivedRequest is what the local receiver saw
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver starts a local HTTP receiver that records requests and answers with status
+func newReceiver(t *testing.T, status int) (*httptest.Server, <-chan receivedRequest) {
+	t.Helper()
+	received := make(chan receivedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestHTTPSinkPostsSignedEvent(t *testing.T) {
+	server, received := newReceiver(t, http.StatusNoContent)
+
+	if err := NewHTTPSink(server.URL, testSecret, nil).Publish(context.Background(), testEvent()); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	req := <-received
+	if got := req.header.Get(webhooks.HeaderEvent); got != models.WebhookEventContentCreated {
+		t.Errorf("event header = %q", got)
+	}
+	if got := req.header.Get(webhooks.HeaderEventID); got != "42" {
+		t.Errorf("event id header = %q", got)
+	}
+	if err := webhooks.Verify(testSecret, req.header.Get(webhooks.HeaderSignature), req.body, time.Minute); err != nil {
+		t.Errorf("signature did not verify: %v", err)
+	}
+
+	var event models.OutboxEvent
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("body is not an event: %v", err)
+	}
+	if event.ID != 42 || event.AggregateID != 7 || string(event.Payload) != `{"id":7,"link":"https://x.com/creator/status/1"}` {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestHTTPSinkUnsigned(t *testing.T) {
+	server, received := newReceiver(t, http.StatusOK)
+
+	if err := NewHTTPSink(server.URL, "", nil).Publish(context.Background(), testEvent()); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if got := (<-received).header.Get(webhooks.HeaderSignature); got != "" {
+		t.Errorf("signature header = %q, want none without a secret", got)
+	}
+}
+
+func TestHTTPSinkReportsReceiverErrors(t *testing.T) {
+	server, received := newReceiver(t, http.StatusServiceUnavailable)
+
+	err := NewHTTPSink(server.URL, testSecret, nil).Publish(context.Background(), testEvent())
+	<-received
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("error = %v, want the 503 reported", err)
+	}
+}
+
+// natsMessage is a message published to the local NATS server
+type natsMessage struct {
+	subject string
+	header  string
+	data    []byte
+}
+
+// newNATSServer starts a local server speaking enough of the NATS client
+// protocol to accept publishes, and returns its URL
+func newNATSServer(t *testing.T) (string, <-chan natsMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make(chan natsMessage, 10)
+	var conns sync.WaitGroup
+	t.Cleanup(func() {
+		listener.Close()
+		conns.Wait()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				defer conns.Done()
+				serveNATS(conn, messages)
+			}()
+		}
+	}()
+	return "nats://" + listener.Addr().String(), messages
+}
+
+func serveNATS(conn net.Conn, messages chan<- natsMessage) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO %s\r\n", `{"server_id":"test","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}`)
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "PUB", "HPUB":
+			// PUB <subject> [reply] <size>, HPUB <subject> [reply] <header size> <total size>
+			total, _ := strconv.Atoi(fields[len(fields)-1])
+			headerSize := 0
+			if strings.EqualFold(fields[0], "HPUB") {
+				headerSize, _ = strconv.Atoi(fields[len(fields)-2])
+			}
+			body := make([]byte, total+2)
+			if _, err := io.ReadFull(reader, body); err != nil {
+				return
+			}
+			messages <- natsMessage{subject: fields[1], header: string(body[:headerSize]), data: body[headerSize:total]}
+		}
+	}
+}
+
+func TestNATSSinkPublishes(t *testing.T) {
+	url, messages := newNATSServer(t)
+	sink := NewNATSSink(url, "socialtracker.")
+	defer sink.Close()
+
+	for _, id := range []int64{1, 2} {
+		event := testEvent()
+		event.ID = id
+		if err := sink.Publish(context.Background(), event); err != nil {
+			t.Fatalf("publish %d failed: %v", id, err)
+		}
+
+		msg := <-messages
+		if msg.subject != "socialtracker."+models.WebhookEventContentCreated {
+			t.Errorf("subject = %q", msg.subject)
+		}
+		if want := fmt.Sprintf("Nats-Msg-Id: %d\r\n", id); !strings.Contains(msg.header, want) {
+			t.Errorf("header = %q, want %q", msg.header, want)
+		}
+		var published models.OutboxEvent
+		if err := json.Unmarshal(msg.data, &published); err != nil || published.ID != id {
+			t.Errorf("data = %s (%v), want event %d", msg.data, err, id)
+		}
+	}
+}
+
+func TestNATSSinkReportsConnectionErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "nats://" + listener.Addr().String()
+	listener.Close()
+
+	if err := NewNATSSink(url, "socialtracker").Publish(context.Background(), testEvent()); err == nil {
+		t.Error("expected publishing without a server to fail")
+	}
+}
+
+// flakySink records published event IDs and fails events listed in failures
+// as many times as given
+type flakySink struct {
+	published []int64
+	failures  map[int64]int
+}
+
+func (s *flakySink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	if s.failures[event.ID] > 0 {
+		s.failures[event.ID]--
+		return errors.New("sink unavailable")
+	}
+	s.published = append(s.published, event.ID)
+	return nil
+}
+
+func TestRelayPublishesInOrderAndRetries(t *testing.T) {
+	store := memory.New()
+	add := func(aggregateID int, eventType string) int64 {
+		return store.AddOutboxEvent("content", aggregateID, eventType, json.RawMessage(`{}`)).ID
+	}
+	a1 := add(1, models.WebhookEventContentCreated)
+	b1 := add(2, models.WebhookEventContentCreated)
+	a2 := add(1, models.WebhookEventContentUpdated)
+	b2 := add(2, models.WebhookEventContentUpdated)
+	a3 := add(1, models.WebhookEventContentDeleted)
+
+	sink := &flakySink{failures: map[int64]int{a1: 2}}
+	// Batches of two still drain everything publishable
+	published, err := Relay(context.Background(), store, sink, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 2 || fmt.Sprint(sink.published) != fmt.Sprint([]int64{b1, b2}) {
+		t.Fatalf("published %d: %v, want only aggregate 2 while aggregate 1 fails", published, sink.published)
+	}
+
+	// Not due before the backoff has passed
+	if published, _ := Relay(context.Background(), store, sink, 10); published != 0 {
+		t.Errorf("published %d before the retry was due", published)
+	}
+
+	store.Advance(5 * time.Second)
+	if published, _ := Relay(context.Background(), store, sink, 10); published != 0 {
+		t.Errorf("published %d although the event failed again", published)
+	}
+
+	// The second failure backs off twice as long
+	store.Advance(5 * time.Second)
+	if published, _ := Relay(context.Background(), store, sink, 10); published != 0 {
+		t.Errorf("published %d before the longer backoff was due", published)
+	}
+	store.Advance(5 * time.Second)
+	published, err = Relay(context.Background(), store, sink, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{b1, b2, a1, a2, a3}; published != 3 || fmt.Sprint(sink.published) != fmt.Sprint(want) {
+		t.Errorf("published %d: %v, want %v", published, sink.published, want)
+	}
+}
+
+func TestRelayPrunesOnlyPublishedEvents(t *testing.T) {
+	store := memory.New()
+	published := store.AddOutboxEvent("content", 1, models.WebhookEventContentCreated, json.RawMessage(`{}`))
+	failing := store.AddOutboxEvent("content", 2, models.WebhookEventContentCreated, json.RawMessage(`{}`))
+
+	sink := &flakySink{failures: map[int64]int{failing.ID: 1}}
+	if _, err := Relay(context.Background(), store, sink, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Advance(time.Minute)
+	pruned, err := store.PruneOutbox(context.Background(), time.Now().Add(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned %d events, want only event %d", pruned, published.ID)
+	}
+
+	if _, err := Relay(context.Background(), store, sink, 10); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{published.ID, failing.ID}; fmt.Sprint(sink.published) != fmt.Sprint(want) {
+		t.Errorf("published %v, want %v with the unpublished event kept", sink.published, want)
+	}
+}
@@ -0,0 +1,28 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+// Relay publishes the due events of store to sink, batchSize at a time, until
+// no more can be published, and returns how many were. Each batch holds at
+// most the next event of every aggregate, so an aggregate's events are
+// published in order across batches, and one whose event failed waits for the
+// retry while the others go ahead.
+func Relay(ctx context.Context, store repository.OutboxStore, sink Sink, batchSize int) (int, error) {
+	publish := func(event models.OutboxEvent) error {
+		return sink.Publish(ctx, event)
+	}
+
+	total := 0
+	for {
+		published, err := store.RelayOutbox(ctx, batchSize, publish)
+		total += published
+		if err != nil || published == 0 {
+			return total, err
+		}
+	}
+}
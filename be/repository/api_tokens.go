@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// API token operations
+
+// CreateAPIToken stores a new API token. Only the hash of the token is persisted.
+func (r *Repository) CreateAPIToken(ctx context.Context, userID int, name, tokenHash, tokenPrefix, scope string, expiresAt time.Time, actor models.Actor) (*models.APIToken, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var token models.APIToken
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scope, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, user_id, name, token_prefix, scope, expires_at, last_used_at, revoked_at, created_at
+	`, userID, name, tokenHash, tokenPrefix, scope, expiresAt).
+		Scan(&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scope, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditAPITokenCreated, "api_token", token.ID, nil, token); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetAPITokensByUserID lists a user's API tokens, including revoked and expired ones
+func (r *Repository) GetAPITokensByUserID(ctx context.Context, userID int) ([]models.APIToken, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, name, token_prefix, scope, expires_at, last_used_at, revoked_at, created_at
+		FROM api_tokens WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		var token models.APIToken
+		err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scope, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken revokes one of a user's tokens. Returns sql.ErrNoRows if the
+// token doesn't exist or was already revoked.
+func (r *Repository) RevokeAPIToken(ctx context.Context, tokenID, userID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var token models.APIToken
+	err = tx.QueryRowContext(ctx, `
+		UPDATE api_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING id, user_id, name, token_prefix, scope, expires_at, last_used_at, revoked_at, created_at
+	`, tokenID, userID).Scan(&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scope, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditAPITokenRevoked, "api_token", token.ID, nil, token); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AuthenticateAPIToken looks up an active (not revoked, not expired) token by hash,
+// records its use and returns it together with its owner. Returns sql.ErrNoRows
+// if no active token matches.
+func (r *Repository) AuthenticateAPIToken(ctx context.Context, tokenHash string) (*models.User, *models.APIToken, error) {
+	var user models.User
+	var token models.APIToken
+	err := r.db.QueryRowContext(ctx, `
+		WITH used AS (
+			UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP
+			WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+			RETURNING id, user_id, name, token_prefix, scope, expires_at, last_used_at, revoked_at, created_at
+		)
+		SELECT t.id, t.user_id, t.name, t.token_prefix, t.scope, t.expires_at, t.last_used_at, t.revoked_at, t.created_at,
+		       u.id, u.user_id, u.email, u.username, u.role, u.created_at, u.updated_at
+		FROM used t
+		JOIN users u ON t.user_id = u.id
+	`, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scope, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt,
+		&user.ID, &user.UserID, &user.Email, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, &token, nil
+}
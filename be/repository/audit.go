@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// Audit actions
+const (
+	AuditContentCreated                 = "content.created"
+	AuditContentDeleted                 = "content.deleted"
+	AuditContentRestored                = "content.restored"
+	AuditContentPurged                  = "content.purged"
+	AuditContentTagsUpdated             = "content.tags_updated"
+	AuditContentUpdated                 = "content.updated"
+	AuditContentRemovedOnPlatform       = "content.removed_on_platform"
+	AuditAccountConnected               = "account.connected"
+	AuditAccountDisconnected            = "account.disconnected"
+	AuditAccountRestored                = "account.restored"
+	AuditAccountPurged                  = "account.purged"
+	AuditAccountTokensUpdated           = "account.tokens_updated"
+	AuditAccountSyncCursorRewound       = "account.sync_cursor_rewound"
+	AuditUserRoleChanged                = "user.role_changed"
+	AuditAPITokenCreated                = "api_token.created"
+	AuditAPITokenRevoked                = "api_token.revoked"
+	AuditTeamCreated                    = "team.created"
+	AuditTeamDeleted                    = "team.deleted"
+	AuditTeamMemberAdded                = "team.member_added"
+	AuditTeamMemberRemoved              = "team.member_removed"
+	AuditImpersonationStarted           = "impersonation.started"
+	AuditImpersonationEnded             = "impersonation.ended"
+	AuditCampaignCreated                = "campaign.created"
+	AuditCampaignUpdated                = "campaign.updated"
+	AuditCampaignDeleted                = "campaign.deleted"
+	AuditCampaignCreatorAdded           = "campaign.creator_added"
+	AuditCampaignCreatorRemoved         = "campaign.creator_removed"
+	AuditCampaignContentAttached        = "campaign.content_attached"
+	AuditCampaignContentDetached        = "campaign.content_detached"
+	AuditTagCreated                     = "tag.created"
+	AuditTagUpdated                     = "tag.updated"
+	AuditTagDeleted                     = "tag.deleted"
+	AuditTagMerged                      = "tag.merged"
+	AuditTagAliasAdded                  = "tag.alias_added"
+	AuditTagAliasRemoved                = "tag.alias_removed"
+	AuditTagRuleCreated                 = "tag_rule.created"
+	AuditTagRuleUpdated                 = "tag_rule.updated"
+	AuditTagRuleDeleted                 = "tag_rule.deleted"
+	AuditDisclosureRuleCreated          = "disclosure_rule.created"
+	AuditDisclosureRuleUpdated          = "disclosure_rule.updated"
+	AuditDisclosureRuleDeleted          = "disclosure_rule.deleted"
+	AuditWebhookCreated                 = "webhook.created"
+	AuditWebhookUpdated                 = "webhook.updated"
+	AuditWebhookDeleted                 = "webhook.deleted"
+	AuditWebhookRedelivered             = "webhook.redelivered"
+	AuditNotificationPreferencesUpdated = "notification_preferences.updated"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertAuditEvent records a mutation. It is meant to run in the same transaction
+// as the mutation itself. before and after are marshalled to JSON; nil is stored as NULL.
+func insertAuditEvent(ctx context.Context, db execer, actor models.Actor, action, targetType string, targetID int, before, after interface{}) error {
+	beforeJSON, err := marshalAuditState(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalAuditState(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO audit_events (actor_id, impersonator_id, action, target_type, target_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, actor.UserID, actor.ImpersonatorID, action, targetType, targetID, beforeJSON, afterJSON)
+	if err != nil {
+		return fmt.Errorf("failed to write audit event %s: %w", action, err)
+	}
+	return nil
+}
+
+func marshalAuditState(state interface{}) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// GetAuditEvents queries the audit log, newest first
+func (r *Repository) GetAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	query := `
+		SELECT id, actor_id, impersonator_id, action, target_type, target_id, before, after, created_at
+		FROM audit_events
+		WHERE 1=1
+	`
+
+	var args []interface{}
+	argCount := 1
+
+	if filter.ActorID != nil {
+		query += fmt.Sprintf(" AND actor_id = $%d", argCount)
+		args = append(args, *filter.ActorID)
+		argCount++
+	}
+
+	if filter.ImpersonatorID != nil {
+		query += fmt.Sprintf(" AND impersonator_id = $%d", argCount)
+		args = append(args, *filter.ImpersonatorID)
+		argCount++
+	}
+
+	if filter.Action != "" {
+		query += fmt.Sprintf(" AND action = $%d", argCount)
+		args = append(args, filter.Action)
+		argCount++
+	}
+
+	if filter.TargetType != "" {
+		query += fmt.Sprintf(" AND target_type = $%d", argCount)
+		args = append(args, filter.TargetType)
+		argCount++
+	}
+
+	if filter.TargetID != nil {
+		query += fmt.Sprintf(" AND target_id = $%d", argCount)
+		args = append(args, *filter.TargetID)
+		argCount++
+	}
+
+	if filter.Since != nil {
+		query += fmt.Sprintf(" AND created_at >= $%d", argCount)
+		args = append(args, *filter.Since)
+		argCount++
+	}
+
+	if filter.Until != nil {
+		query += fmt.Sprintf(" AND created_at < $%d", argCount)
+		args = append(args, *filter.Until)
+		argCount++
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 200
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", argCount)
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var before, after []byte
+		err := rows.Scan(&event.ID, &event.ActorID, &event.ImpersonatorID, &event.Action, &event.TargetType, &event.TargetID, &before, &after, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		event.Before = before
+		event.After = after
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// Campaign operations
+
+const campaignColumns = `
+	cp.id, cp.name, cp.brand, cp.brief, cp.start_date, cp.end_date, cp.hashtags, cp.jurisdictions,
+	(SELECT COUNT(*) FROM campaign_creators cc WHERE cc.campaign_id = cp.id),
+	(SELECT COUNT(*) FROM campaign_content cx JOIN content ct ON ct.id = cx.content_id
+	 WHERE cx.campaign_id = cp.id AND ct.deleted_at IS NULL),
+	cp.created_at, cp.updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCampaign(row scanner) (*models.Campaign, error) {
+	var campaign models.Campaign
+	err := row.Scan(&campaign.ID, &campaign.Name, &campaign.Brand, &campaign.Brief, &campaign.StartDate, &campaign.EndDate,
+		pq.Array(&campaign.Hashtags), pq.Array(&campaign.Jurisdictions), &campaign.CreatorCount, &campaign.ContentCount, &campaign.CreatedAt, &campaign.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// autoAttachCampaigns attaches content from assigned creators that was posted in
+// a campaign's window and carries all of its hashtags. campaignID and contentID
+// narrow the match when not nil. Existing attachments are left untouched.
+func autoAttachCampaigns(ctx context.Context, db execer, campaignID, contentID *int) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO campaign_content (campaign_id, content_id, source)
+		SELECT cp.id, ct.id, 'auto'
+		FROM campaigns cp
+		JOIN campaign_creators cc ON cc.campaign_id = cp.id
+		JOIN content ct ON ct.user_id = cc.user_id AND ct.deleted_at IS NULL
+		WHERE cardinality(cp.hashtags) > 0
+		  AND COALESCE(ct.posted_at, ct.created_at)::DATE BETWEEN cp.start_date AND cp.end_date
+		  AND cp.hashtags <@ ARRAY(SELECT value FROM content_entities WHERE content_id = ct.id AND kind = 'hashtag')
+		  AND ($1::INTEGER IS NULL OR cp.id = $1)
+		  AND ($2::INTEGER IS NULL OR ct.id = $2)
+		ON CONFLICT DO NOTHING
+	`, campaignID, contentID)
+	return err
+}
+
+// CreateCampaign creates a campaign with no creators assigned
+func (r *Repository) CreateCampaign(ctx context.Context, name, brand string, brief *string, startDate, endDate time.Time, hashtags, jurisdictions []string, actor models.Actor) (*models.Campaign, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO campaigns (name, brand, brief, start_date, end_date, hashtags, jurisdictions)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, name, brand, brief, startDate, endDate, pq.Array(hashtags), pq.Array(jurisdictions)).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign, err := scanCampaign(tx.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns cp WHERE cp.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditCampaignCreated, "campaign", campaign.ID, nil, campaign); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// UpdateCampaign replaces a campaign's details, attaches any content that now
+// matches and re-checks the disclosure of its content. Returns sql.ErrNoRows if the campaign does not exist.
+func (r *Repository) UpdateCampaign(ctx context.Context, id int, name, brand string, brief *string, startDate, endDate time.Time, hashtags, jurisdictions []string, actor models.Actor) (*models.Campaign, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanCampaign(tx.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns cp WHERE cp.id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE campaigns
+		SET name = $2, brand = $3, brief = $4, start_date = $5, end_date = $6, hashtags = $7,
+		    jurisdictions = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id, name, brand, brief, startDate, endDate, pq.Array(hashtags), pq.Array(jurisdictions))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := autoAttachCampaigns(ctx, tx, &id, nil); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := reevaluateCompliance(ctx, tx, &id); err != nil {
+		return nil, err
+	}
+
+	after, err := scanCampaign(tx.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns cp WHERE cp.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditCampaignUpdated, "campaign", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// GetCampaign returns a campaign or sql.ErrNoRows
+func (r *Repository) GetCampaign(ctx context.Context, id int) (*models.Campaign, error) {
+	return scanCampaign(r.db.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns cp WHERE cp.id = $1`, id))
+}
+
+// GetCampaigns returns all campaigns, or only those creatorID is assigned to when it is not nil
+func (r *Repository) GetCampaigns(ctx context.Context, creatorID *int) ([]models.Campaign, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+campaignColumns+`
+		FROM campaigns cp
+		WHERE $1::INTEGER IS NULL OR cp.id IN (SELECT campaign_id FROM campaign_creators WHERE user_id = $1)
+		ORDER BY cp.start_date DESC, cp.name
+	`, creatorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campaigns []models.Campaign
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, *campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+// DeleteCampaign deletes a campaign along with its assignments and attachments.
+// The attached content itself is kept.
+func (r *Repository) DeleteCampaign(ctx context.Context, id int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	campaign, err := scanCampaign(tx.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns cp WHERE cp.id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return err
+	}
+
+	contentIDs, err := sponsoredContentIDs(ctx, tx, &id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM campaigns WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	if _, _, err := evaluateComplianceFor(ctx, tx, contentIDs); err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditCampaignDeleted, "campaign", id, campaign, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddCampaignCreator assigns a creator to a campaign and attaches their matching
+// content; assigning an existing creator is a no-op
+func (r *Repository) AddCampaignCreator(ctx context.Context, campaignID, userID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO campaign_creators (campaign_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, campaignID, userID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil
+	}
+
+	if err := autoAttachCampaigns(ctx, tx, &campaignID, nil); err != nil {
+		return err
+	}
+
+	if _, _, err := reevaluateCompliance(ctx, tx, &campaignID); err != nil {
+		return err
+	}
+
+	after := map[string]int{"campaign_id": campaignID, "user_id": userID}
+	if err := insertAuditEvent(ctx, tx, actor, AuditCampaignCreatorAdded, "campaign", campaignID, nil, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveCampaignCreator unassigns a creator. Content already attached stays
+// attached. Returns sql.ErrNoRows if the creator was not assigned.
+func (r *Repository) RemoveCampaignCreator(ctx context.Context, campaignID, userID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var removed int
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM campaign_creators WHERE campaign_id = $1 AND user_id = $2
+		RETURNING user_id
+	`, campaignID, userID).Scan(&removed)
+	if err != nil {
+		return err
+	}
+
+	before := map[string]int{"campaign_id": campaignID, "user_id": userID}
+	if err := insertAuditEvent(ctx, tx, actor, AuditCampaignCreatorRemoved, "campaign", campaignID, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AttachCampaignContent attaches content to a campaign by hand. An automatic
+// attachment of the same content becomes manual. Returns sql.ErrNoRows if the
+// content does not exist, or a foreign key violation if the campaign does not.
+func (r *Repository) AttachCampaignContent(ctx context.Context, campaignID, contentID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var attached int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO campaign_content (campaign_id, content_id, source)
+		SELECT $1, id, 'manual' FROM content WHERE id = $2 AND deleted_at IS NULL
+		ON CONFLICT (campaign_id, content_id) DO UPDATE SET source = 'manual'
+		RETURNING content_id
+	`, campaignID, contentID).Scan(&attached)
+	if err != nil {
+		return err
+	}
+
+	if _, err := evaluateCompliance(ctx, tx, contentID); err != nil {
+		return err
+	}
+
+	after := map[string]interface{}{"campaign_id": campaignID, "content_id": contentID, "source": models.CampaignSourceManual}
+	if err := insertAuditEvent(ctx, tx, actor, AuditCampaignContentAttached, "campaign", campaignID, nil, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DetachCampaignContent removes content from a campaign. Returns sql.ErrNoRows if it was not attached.
+func (r *Repository) DetachCampaignContent(ctx context.Context, campaignID, contentID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var source string
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM campaign_content WHERE campaign_id = $1 AND content_id = $2
+		RETURNING source
+	`, campaignID, contentID).Scan(&source)
+	if err != nil {
+		return err
+	}
+
+	if _, err := evaluateCompliance(ctx, tx, contentID); err != nil {
+		return err
+	}
+
+	before := map[string]interface{}{"campaign_id": campaignID, "content_id": contentID, "source": source}
+	if err := insertAuditEvent(ctx, tx, actor, AuditCampaignContentDetached, "campaign", campaignID, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetCampaignReport lists the campaign's assigned creators and every creator
+// with attached content, each with their posts and whether they fall in the
+// campaign window. Creators are limited to what viewer may see.
+func (r *Repository) GetCampaignReport(ctx context.Context, campaignID int, viewer *models.User) (*models.CampaignReport, error) {
+	campaign, err := r.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, scopeArgs := visibleUsersCondition(viewer, "u.id", 2)
+	args := append([]interface{}{campaignID}, scopeArgs...)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, u.username, u.email,
+		       EXISTS (SELECT 1 FROM campaign_creators cc WHERE cc.campaign_id = $1 AND cc.user_id = u.id)
+		FROM users u
+		WHERE (u.id IN (SELECT user_id FROM campaign_creators WHERE campaign_id = $1)
+		    OR u.id IN (SELECT ct.user_id FROM campaign_content cx JOIN content ct ON ct.id = cx.content_id
+		                WHERE cx.campaign_id = $1 AND ct.deleted_at IS NULL))`+scope+`
+		ORDER BY u.username
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &models.CampaignReport{Campaign: *campaign, Creators: []models.CampaignCreatorReport{}}
+	byUser := make(map[int]int)
+	for rows.Next() {
+		creator := models.CampaignCreatorReport{Posts: []models.CampaignContent{}}
+		if err := rows.Scan(&creator.UserID, &creator.Username, &creator.Email, &creator.Assigned); err != nil {
+			return nil, err
+		}
+		byUser[creator.UserID] = len(report.Creators)
+		report.Creators = append(report.Creators, creator)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts, err := r.db.QueryContext(ctx, `
+		SELECT ct.id, ct.user_id, ct.social_account_id, ct.platform, ct.link, ct.original_text, ct.description, ct.tags,
+		       ct.external_post_id, ct.posted_at, ct.paid_partnership, ct.created_at, ct.updated_at, cx.source,
+		       COALESCE(ct.posted_at, ct.created_at)::DATE BETWEEN cp.start_date AND cp.end_date
+		FROM campaign_content cx
+		JOIN campaigns cp ON cp.id = cx.campaign_id
+		JOIN content ct ON ct.id = cx.content_id
+		WHERE cx.campaign_id = $1 AND ct.deleted_at IS NULL
+		ORDER BY COALESCE(ct.posted_at, ct.created_at)
+	`, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer posts.Close()
+
+	for posts.Next() {
+		var post models.CampaignContent
+		err := posts.Scan(&post.ID, &post.UserID, &post.SocialAccountID, &post.Platform, &post.Link,
+			&post.OriginalText, &post.Description, pq.Array(&post.Tags), &post.ExternalPostID, &post.PostedAt, &post.PaidPartnership, &post.CreatedAt, &post.UpdatedAt,
+			&post.Source, &post.InWindow)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := byUser[post.UserID]
+		if !ok {
+			// Not visible to the viewer
+			continue
+		}
+		creator := &report.Creators[i]
+		creator.Posts = append(creator.Posts, post)
+		creator.PostCount++
+		if post.InWindow {
+			creator.InWindowCount++
+		}
+	}
+	return report, posts.Err()
+}
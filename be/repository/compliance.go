@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Armatorix/SocialTracker/be/compliance"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// Disclosure rule operations
+
+const disclosureRuleColumns = `id, name, jurisdiction, campaign_id, required_hashtags, accept_paid_partnership, max_position, enabled, created_at, updated_at`
+
+func scanDisclosureRule(row scanner) (*models.DisclosureRule, error) {
+	var rule models.DisclosureRule
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Jurisdiction, &rule.CampaignID, pq.Array(&rule.RequiredHashtags),
+		&rule.AcceptPaidPartnership, &rule.MaxPosition, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetDisclosureRules lists all disclosure rules
+func (r *Repository) GetDisclosureRules(ctx context.Context) ([]models.DisclosureRule, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+disclosureRuleColumns+` FROM disclosure_rules ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.DisclosureRule
+	for rows.Next() {
+		rule, err := scanDisclosureRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// CreateDisclosureRule stores a rule and re-evaluates sponsored content against it
+func (r *Repository) CreateDisclosureRule(ctx context.Context, rule models.DisclosureRule, actor models.Actor) (*models.DisclosureRule, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanDisclosureRule(tx.QueryRowContext(ctx, `
+		INSERT INTO disclosure_rules (name, jurisdiction, campaign_id, required_hashtags, accept_paid_partnership, max_position, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+disclosureRuleColumns,
+		rule.Name, rule.Jurisdiction, rule.CampaignID, pq.Array(rule.RequiredHashtags), rule.AcceptPaidPartnership, rule.MaxPosition, rule.Enabled))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := reevaluateCompliance(ctx, tx, created.CampaignID); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditDisclosureRuleCreated, "disclosure_rule", created.ID, nil, created); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateDisclosureRule replaces a rule and re-evaluates sponsored content.
+// Returns sql.ErrNoRows if the rule does not exist.
+func (r *Repository) UpdateDisclosureRule(ctx context.Context, rule models.DisclosureRule, actor models.Actor) (*models.DisclosureRule, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanDisclosureRule(tx.QueryRowContext(ctx, `SELECT `+disclosureRuleColumns+` FROM disclosure_rules WHERE id = $1 FOR UPDATE`, rule.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := scanDisclosureRule(tx.QueryRowContext(ctx, `
+		UPDATE disclosure_rules
+		SET name = $2, jurisdiction = $3, campaign_id = $4, required_hashtags = $5, accept_paid_partnership = $6,
+		    max_position = $7, enabled = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+disclosureRuleColumns,
+		rule.ID, rule.Name, rule.Jurisdiction, rule.CampaignID, pq.Array(rule.RequiredHashtags), rule.AcceptPaidPartnership, rule.MaxPosition, rule.Enabled))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := reevaluateCompliance(ctx, tx, nil); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditDisclosureRuleUpdated, "disclosure_rule", rule.ID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// DeleteDisclosureRule deletes a rule and re-evaluates the content it applied to
+func (r *Repository) DeleteDisclosureRule(ctx context.Context, id int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rule, err := scanDisclosureRule(tx.QueryRowContext(ctx, `DELETE FROM disclosure_rules WHERE id = $1 RETURNING `+disclosureRuleColumns, id))
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := reevaluateCompliance(ctx, tx, rule.CampaignID); err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditDisclosureRuleDeleted, "disclosure_rule", id, rule, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Compliance evaluation
+
+// evaluateCompliance checks one piece of content against the disclosure rules
+// of the campaigns it is attached to and stores the result. Content that is not
+// attached to any campaign is not sponsored and has its result removed.
+// It returns the status, or "" when the content is not sponsored.
+func evaluateCompliance(ctx context.Context, tx *sql.Tx, contentID int) (string, error) {
+	var content models.Content
+	var sponsored bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT original_text, description, paid_partnership,
+		       EXISTS (SELECT 1 FROM campaign_content WHERE content_id = $1)
+		FROM content WHERE id = $1
+	`, contentID).Scan(&content.OriginalText, &content.Description, &content.PaidPartnership, &sponsored)
+	if err != nil {
+		return "", err
+	}
+
+	if !sponsored {
+		_, err := tx.ExecContext(ctx, `DELETE FROM content_compliance WHERE content_id = $1`, contentID)
+		return "", err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+disclosureRuleColumns+`
+		FROM disclosure_rules r
+		WHERE r.enabled AND EXISTS (
+			SELECT 1 FROM campaign_content cx
+			JOIN campaigns cp ON cp.id = cx.campaign_id
+			WHERE cx.content_id = $1
+			  AND (r.campaign_id IS NULL OR r.campaign_id = cp.id)
+			  AND (r.jurisdiction IS NULL OR r.jurisdiction = ANY(cp.jurisdictions))
+		)
+		ORDER BY r.id
+	`, contentID)
+	if err != nil {
+		return "", err
+	}
+	var rules []models.DisclosureRule
+	for rows.Next() {
+		rule, err := scanDisclosureRule(rows)
+		if err != nil {
+			rows.Close()
+			return "", err
+		}
+		rules = append(rules, *rule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	status, reasons := compliance.Evaluate(compliance.PostFromContent(content), rules)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO content_compliance (content_id, status, reasons, evaluated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (content_id) DO UPDATE
+		SET status = EXCLUDED.status, reasons = EXCLUDED.reasons, evaluated_at = EXCLUDED.evaluated_at
+	`, contentID, status, pq.Array(reasons))
+	return status, err
+}
+
+// sponsoredContentIDs returns the content attached to a campaign, or to any
+// campaign when campaignID is nil. The latter includes content that has a
+// result but may no longer be sponsored.
+func sponsoredContentIDs(ctx context.Context, tx *sql.Tx, campaignID *int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT content_id FROM campaign_content WHERE $1::INTEGER IS NULL OR campaign_id = $1
+		UNION
+		SELECT content_id FROM content_compliance WHERE $1::INTEGER IS NULL
+	`, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// evaluateComplianceFor evaluates each piece of content and counts the results
+func evaluateComplianceFor(ctx context.Context, tx *sql.Tx, contentIDs []int) (evaluated, failed int, err error) {
+	for _, id := range contentIDs {
+		status, err := evaluateCompliance(ctx, tx, id)
+		if err != nil {
+			return 0, 0, fmt.Errorf("evaluate content %d: %w", id, err)
+		}
+		if status != "" {
+			evaluated++
+		}
+		if status == models.ComplianceStatusFail {
+			failed++
+		}
+	}
+	return evaluated, failed, nil
+}
+
+// reevaluateCompliance evaluates the content of one campaign, or all sponsored content when campaignID is nil
+func reevaluateCompliance(ctx context.Context, tx *sql.Tx, campaignID *int) (evaluated, failed int, err error) {
+	ids, err := sponsoredContentIDs(ctx, tx, campaignID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return evaluateComplianceFor(ctx, tx, ids)
+}
+
+// ReevaluateCompliance re-checks all sponsored content against the current rules
+func (r *Repository) ReevaluateCompliance(ctx context.Context) (evaluated, failed int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	evaluated, failed, err = reevaluateCompliance(ctx, tx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return evaluated, failed, nil
+}
+
+// GetComplianceResults lists compliance results of live content, limited to
+// what viewer may see. Supported filters are status, campaign_id and user_id.
+func (r *Repository) GetComplianceResults(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.ComplianceResult, error) {
+	query := `
+		SELECT c.id, c.user_id, c.social_account_id, c.platform, c.link, c.original_text, c.description, c.tags,
+		       c.external_post_id, c.posted_at, c.paid_partnership, c.created_at, c.updated_at, u.username, u.email,
+		       cc.status, cc.reasons, cc.evaluated_at
+		FROM content_compliance cc
+		JOIN content c ON c.id = cc.content_id
+		JOIN users u ON u.id = c.user_id
+		WHERE c.deleted_at IS NULL
+	`
+
+	scope, args := visibleUsersCondition(viewer, "c.user_id", 1)
+	query += scope
+	argCount := len(args) + 1
+
+	if status, ok := filters["status"]; ok && status != "" {
+		query += fmt.Sprintf(" AND cc.status = $%d", argCount)
+		args = append(args, status)
+		argCount++
+	}
+
+	if campaignID, ok := filters["campaign_id"]; ok && campaignID != "" {
+		query += fmt.Sprintf(" AND c.id IN (SELECT content_id FROM campaign_content WHERE campaign_id = $%d)", argCount)
+		args = append(args, campaignID)
+		argCount++
+	}
+
+	if userID, ok := filters["user_id"]; ok && userID != "" {
+		query += fmt.Sprintf(" AND c.user_id = $%d", argCount)
+		args = append(args, userID)
+	}
+
+	query += " ORDER BY COALESCE(c.posted_at, c.created_at) DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.ComplianceResult
+	for rows.Next() {
+		var result models.ComplianceResult
+		content := &result.Content
+		err := rows.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt,
+			&content.Username, &content.Email, &result.Status, pq.Array(&result.Reasons), &result.EvaluatedAt)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// Content event operations
+
+// ContentEventsChannel is the LISTEN/NOTIFY channel the content trigger notifies
+const ContentEventsChannel = "content_events"
+
+// stableEvents limits content events to those of transactions older than any
+// still running. No event can appear before or among them any more, so they
+// can be served in feed order without skipping any.
+const stableEvents = `e.xact_id < pg_snapshot_xmin(pg_current_snapshot())`
+
+// GetContentEvents returns up to limit content events after a position, in
+// feed order, with the current state of their content. Events are limited to
+// what viewer may see when viewer is not nil, and to userID's content when
+// userID is not nil. held reports committed events that are held back until
+// an older transaction finishes.
+func (r *Repository) GetContentEvents(ctx context.Context, after models.ContentEventPosition, viewer *models.User, userID *int, limit int) (events []models.ContentEvent, held bool, err error) {
+	query := `
+		SELECT e.id, e.xact_id::TEXT::BIGINT, e.event_type, e.content_id, e.user_id, e.created_at
+		FROM content_events e
+		WHERE (e.xact_id, e.id) > ($1::XID8, $2) AND ` + stableEvents + `
+		  AND ($3::INTEGER IS NULL OR e.user_id = $3)
+	`
+	args := []interface{}{after.XactID, after.ID, userID}
+	if viewer != nil {
+		scope, scopeArgs := visibleUsersCondition(viewer, "e.user_id", len(args)+1)
+		query += scope
+		args = append(args, scopeArgs...)
+	}
+	query += fmt.Sprintf(" ORDER BY e.xact_id, e.id LIMIT $%d", len(args)+1)
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var contentIDs []int64
+	for rows.Next() {
+		var event models.ContentEvent
+		if err := rows.Scan(&event.ID, &event.XactID, &event.Type, &event.ContentID, &event.UserID, &event.CreatedAt); err != nil {
+			return nil, false, err
+		}
+		events = append(events, event)
+		contentIDs = append(contentIDs, int64(event.ContentID))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(events) < limit {
+		err := r.db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM content_events WHERE xact_id >= pg_snapshot_xmin(pg_current_snapshot()))
+		`).Scan(&held)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if len(events) == 0 {
+		return nil, held, nil
+	}
+
+	contents, err := r.getContentWithUserByIDs(ctx, contentIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range events {
+		if content, ok := contents[events[i].ContentID]; ok {
+			events[i].Content = content
+		}
+	}
+	return events, held, nil
+}
+
+// getContentWithUserByIDs loads content by id, including content in the trash
+func (r *Repository) getContentWithUserByIDs(ctx context.Context, ids []int64) (map[int]*models.ContentWithUser, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.id, c.user_id, c.social_account_id, c.platform, c.link, c.original_text, c.description, c.tags,
+		       c.external_post_id, c.posted_at, c.paid_partnership, c.created_at, c.updated_at, c.deleted_at, u.username, u.email,`+contentEntityColumns+`
+		FROM content c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contents := make(map[int]*models.ContentWithUser)
+	for rows.Next() {
+		var content models.ContentWithUser
+		err := rows.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt, &content.DeletedAt,
+			&content.Username, &content.Email,
+			pq.Array(&content.Hashtags), pq.Array(&content.Mentions), pq.Array(&content.Cashtags), pq.Array(&content.URLs))
+		if err != nil {
+			return nil, err
+		}
+		contents[content.ID] = &content
+	}
+	return contents, rows.Err()
+}
+
+// GetContentEventPosition returns the position of a content event in the
+// feed, or sql.ErrNoRows if it is not kept
+func (r *Repository) GetContentEventPosition(ctx context.Context, id int64) (*models.ContentEventPosition, error) {
+	position := models.ContentEventPosition{ID: id}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT xact_id::TEXT::BIGINT FROM content_events WHERE id = $1
+	`, id).Scan(&position.XactID)
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// GetLatestContentEventPosition returns the position after the last event
+// that can be served, or the start of the feed when there is none
+func (r *Repository) GetLatestContentEventPosition(ctx context.Context) (models.ContentEventPosition, error) {
+	var position models.ContentEventPosition
+	err := r.db.QueryRowContext(ctx, `
+		SELECT e.xact_id::TEXT::BIGINT, e.id
+		FROM content_events e
+		WHERE `+stableEvents+`
+		ORDER BY e.xact_id DESC, e.id DESC
+		LIMIT 1
+	`).Scan(&position.XactID, &position.ID)
+	if err == sql.ErrNoRows {
+		return position, nil
+	}
+	return position, err
+}
+
+// PruneContentEvents deletes content events recorded before the cutoff
+func (r *Repository) PruneContentEvents(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM content_events WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+const contentMediaColumns = `id, content_id, media_key, type, url, preview_url, width, height, duration_ms, alt_text,
+	archive_key, archived_at, created_at`
+
+func scanContentMedia(row scanner) (models.ContentMedia, error) {
+	var media models.ContentMedia
+	err := row.Scan(&media.ID, &media.ContentID, &media.MediaKey, &media.Type, &media.URL, &media.PreviewURL,
+		&media.Width, &media.Height, &media.DurationMs, &media.AltText, &media.ArchiveKey, &media.ArchivedAt, &media.CreatedAt)
+	return media, err
+}
+
+// replaceContentMedia stores the media attached to synced content, replacing
+// any it had. Media still attached keep their archived file.
+func replaceContentMedia(ctx context.Context, tx *sql.Tx, contentID int, list []models.SyncedMedia) ([]models.ContentMedia, error) {
+	keys := make([]string, len(list))
+	for i, media := range list {
+		keys[i] = media.Key
+	}
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM content_media WHERE content_id = $1 AND NOT media_key = ANY($2)
+	`, contentID, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]models.ContentMedia, 0, len(list))
+	for i, media := range list {
+		row := tx.QueryRowContext(ctx, `
+			INSERT INTO content_media (content_id, media_key, position, type, url, preview_url, width, height, duration_ms, alt_text)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, 0), NULLIF($9, 0), NULLIF($10, ''))
+			ON CONFLICT (content_id, media_key) DO UPDATE
+			SET position = EXCLUDED.position, type = EXCLUDED.type, url = EXCLUDED.url, preview_url = EXCLUDED.preview_url,
+			    width = EXCLUDED.width, height = EXCLUDED.height, duration_ms = EXCLUDED.duration_ms, alt_text = EXCLUDED.alt_text
+			RETURNING `+contentMediaColumns,
+			contentID, media.Key, i, media.Type, media.URL, media.PreviewURL, media.Width, media.Height, media.DurationMs, media.AltText)
+		m, err := scanContentMedia(row)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, m)
+	}
+	return stored, nil
+}
+
+// loadContentMedia returns the media attached to content, by content ID
+func (r *Repository) loadContentMedia(ctx context.Context, contentIDs []int) (map[int][]models.ContentMedia, error) {
+	byContent := make(map[int][]models.ContentMedia)
+	if len(contentIDs) == 0 {
+		return byContent, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+contentMediaColumns+`
+		FROM content_media
+		WHERE content_id = ANY($1)
+		ORDER BY content_id, position
+	`, pq.Array(contentIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		media, err := scanContentMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		byContent[media.ContentID] = append(byContent[media.ContentID], media)
+	}
+	return byContent, rows.Err()
+}
+
+// GetMediaToArchive returns up to limit media files that have not been
+// archived yet and have failed fewer than maxAttempts times, oldest first
+func (r *Repository) GetMediaToArchive(ctx context.Context, limit, maxAttempts int) ([]models.ContentMedia, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+contentMediaColumns+`
+		FROM content_media
+		WHERE archived_at IS NULL AND url IS NOT NULL AND archive_attempts < $2
+		ORDER BY id
+		LIMIT $1
+	`, limit, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.ContentMedia
+	for rows.Next() {
+		media, err := scanContentMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, media)
+	}
+	return list, rows.Err()
+}
+
+// MarkMediaArchived records that a media file was copied to the archive under key
+func (r *Repository) MarkMediaArchived(ctx context.Context, mediaID int, key string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE content_media
+		SET archive_key = $2, archived_at = CURRENT_TIMESTAMP, archive_attempts = archive_attempts + 1, archive_error = NULL
+		WHERE id = $1
+	`, mediaID, key)
+	return err
+}
+
+// RecordMediaArchiveFailure records a failed attempt to archive a media file
+func (r *Repository) RecordMediaArchiveFailure(ctx context.Context, mediaID int, errMsg string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE content_media
+		SET archive_attempts = archive_attempts + 1, archive_error = $2
+		WHERE id = $1
+	`, mediaID, errMsg)
+	return err
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// linkContentParents points synced posts of a user at their parents where
+// both are stored: the posts with contentIDs at their parents, and stored
+// posts at parents among externalIDs. Posts of a page are stored newest
+// first, so a reply can be stored before the post it replies to. It returns
+// the parent IDs set, by post.
+func linkContentParents(ctx context.Context, tx *sql.Tx, userID int, contentIDs []int, externalIDs []string) (map[int]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE content c SET parent_content_id = p.id
+		FROM content p
+		WHERE c.user_id = $1 AND c.parent_content_id IS NULL AND c.parent_external_id IS NOT NULL
+		  AND (c.id = ANY($2) OR c.parent_external_id = ANY($3))
+		  AND p.user_id = c.user_id AND p.external_post_id = c.parent_external_id AND p.deleted_at IS NULL
+		RETURNING c.id, p.id
+	`, userID, pq.Array(contentIDs), pq.Array(externalIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := make(map[int]int)
+	for rows.Next() {
+		var id, parentID int
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, err
+		}
+		parents[id] = parentID
+	}
+	return parents, rows.Err()
+}
+
+// relationFilterConditions builds the conditions for the relation filters of
+// content listings, for content aliased as c. entityArgN is the number of the
+// first placeholder of the entity filter conditions of the listing, which
+// collapsed threads reuse so that only listed posts hide later thread posts.
+func relationFilterConditions(filters map[string]string, entityArgN int) string {
+	query := relationExclusions(filters, "c")
+	if filters[models.FilterCollapseThreads] == "true" {
+		// A thread post is left out when an earlier post of its thread is listed.
+		// Posts of a thread share the user, so only the other filters are repeated.
+		threadConditions, _ := entityFilterConditions(filters, "t.id", entityArgN)
+		query += ` AND NOT (c.relation = '` + models.RelationThread + `' AND EXISTS (
+			SELECT 1 FROM content t
+			WHERE t.user_id = c.user_id AND t.conversation_id = c.conversation_id AND t.deleted_at IS NULL
+			  AND t.platform = c.platform
+			  AND COALESCE(t.posted_at, t.created_at) < COALESCE(c.posted_at, c.created_at)` +
+			threadConditions + relationExclusions(filters, "t") + `
+		))`
+	}
+	return query
+}
+
+// relationExclusions returns the conditions of the exclude_replies and
+// exclude_retweets filters for content aliased as alias
+func relationExclusions(filters map[string]string, alias string) string {
+	var query string
+	if filters[models.FilterExcludeReplies] == "true" {
+		query += " AND " + alias + ".relation IS DISTINCT FROM '" + models.RelationReply + "'"
+	}
+	if filters[models.FilterExcludeRetweets] == "true" {
+		query += " AND " + alias + ".relation IS DISTINCT FROM '" + models.RelationRetweet + "'"
+	}
+	return query
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// contentEntityColumns selects the extracted entities of content aliased as c,
+// in the order Content.Hashtags, Mentions, Cashtags, URLs
+const contentEntityColumns = `
+	ARRAY(SELECT value FROM content_entities e WHERE e.content_id = c.id AND e.kind = 'hashtag' ORDER BY value),
+	ARRAY(SELECT value FROM content_entities e WHERE e.content_id = c.id AND e.kind = 'mention' ORDER BY value),
+	ARRAY(SELECT value FROM content_entities e WHERE e.content_id = c.id AND e.kind = 'cashtag' ORDER BY value),
+	ARRAY(SELECT value FROM content_entities e WHERE e.content_id = c.id AND e.kind = 'url' ORDER BY value)`
+
+// contentText is the text entities are extracted from
+func contentText(originalText, description *string) string {
+	var parts []string
+	if originalText != nil {
+		parts = append(parts, *originalText)
+	}
+	if description != nil {
+		parts = append(parts, *description)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// replaceContentEntities stores the entities of a piece of content, replacing any it had
+func replaceContentEntities(ctx context.Context, db execer, contentID int, list []entities.Entity) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM content_entities WHERE content_id = $1`, contentID); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	kinds := make([]string, len(list))
+	values := make([]string, len(list))
+	for i, entity := range list {
+		kinds[i] = entity.Kind
+		values[i] = entity.Value
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO content_entities (content_id, kind, value)
+		SELECT $1, kind, value FROM unnest($2::TEXT[], $3::TEXT[]) AS e(kind, value)
+		ON CONFLICT DO NOTHING
+	`, contentID, pq.Array(kinds), pq.Array(values))
+	return err
+}
+
+// setContentEntities fills in the entity fields of content
+func setContentEntities(content *models.Content, list []entities.Entity) {
+	content.Hashtags = entities.Values(list, entities.KindHashtag)
+	content.Mentions = entities.Values(list, entities.KindMention)
+	content.Cashtags = entities.Values(list, entities.KindCashtag)
+	content.URLs = entities.Values(list, entities.KindURL)
+}
+
+// entityFilterConditions returns SQL conditions (each starting with AND)
+// restricting column to content that has the hashtag, mention or cashtag given
+// in filters. argN is the number of the first placeholder the conditions may use.
+func entityFilterConditions(filters map[string]string, column string, argN int) (string, []interface{}) {
+	var query string
+	var args []interface{}
+	for _, kind := range []string{entities.KindHashtag, entities.KindMention, entities.KindCashtag} {
+		raw, ok := filters[kind]
+		if !ok || raw == "" {
+			continue
+		}
+		query += fmt.Sprintf(" AND %s IN (SELECT content_id FROM content_entities WHERE kind = '%s' AND value = $%d)", column, kind, argN)
+		args = append(args, entities.Normalize(kind, raw))
+		argN++
+	}
+	return query, args
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// Impersonation operations
+
+// StartImpersonationSession starts a session for an admin, ending any session they already have open
+func (r *Repository) StartImpersonationSession(ctx context.Context, adminID, targetUserID int, reason *string, expiresAt time.Time) (*models.ImpersonationSession, error) {
+	actor := models.Actor{UserID: &adminID}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE impersonation_sessions SET ended_at = CURRENT_TIMESTAMP
+		WHERE admin_id = $1 AND ended_at IS NULL
+	`, adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	var session models.ImpersonationSession
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO impersonation_sessions (admin_id, target_user_id, reason, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, admin_id, target_user_id, reason, started_at, expires_at, ended_at
+	`, adminID, targetUserID, reason, expiresAt).
+		Scan(&session.ID, &session.AdminID, &session.TargetUserID, &session.Reason, &session.StartedAt, &session.ExpiresAt, &session.EndedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditImpersonationStarted, "user", targetUserID, nil, session); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetActiveImpersonationSession returns the admin's open, unexpired session or sql.ErrNoRows
+func (r *Repository) GetActiveImpersonationSession(ctx context.Context, adminID int) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, admin_id, target_user_id, reason, started_at, expires_at, ended_at
+		FROM impersonation_sessions
+		WHERE admin_id = $1 AND ended_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, adminID).Scan(&session.ID, &session.AdminID, &session.TargetUserID, &session.Reason, &session.StartedAt, &session.ExpiresAt, &session.EndedAt)
+
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// EndImpersonationSession ends the admin's open session. Returns sql.ErrNoRows if there is none.
+func (r *Repository) EndImpersonationSession(ctx context.Context, adminID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var session models.ImpersonationSession
+	err = tx.QueryRowContext(ctx, `
+		UPDATE impersonation_sessions SET ended_at = CURRENT_TIMESTAMP
+		WHERE admin_id = $1 AND ended_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING id, admin_id, target_user_id, reason, started_at, expires_at, ended_at
+	`, adminID).Scan(&session.ID, &session.AdminID, &session.TargetUserID, &session.Reason, &session.StartedAt, &session.ExpiresAt, &session.EndedAt)
+	if err != nil {
+		return err
+	}
+
+	actor := models.Actor{UserID: &adminID}
+	if err := insertAuditEvent(ctx, tx, actor, AuditImpersonationEnded, "user", session.TargetUserID, nil, session); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordImpersonationAction logs a write request made while impersonating
+func (r *Repository) RecordImpersonationAction(ctx context.Context, adminID, targetUserID int, sessionID *int, method, path string, status int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO impersonation_actions (admin_id, target_user_id, session_id, method, path, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, adminID, targetUserID, sessionID, method, path, status)
+	return err
+}
+
+// GetImpersonationActions lists impersonated write requests, newest first.
+// Supported filters are admin_id and target_user_id.
+func (r *Repository) GetImpersonationActions(ctx context.Context, filters map[string]int) ([]models.ImpersonationAction, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, admin_id, target_user_id, session_id, method, path, status, created_at
+		FROM impersonation_actions
+		WHERE ($1::INTEGER IS NULL OR admin_id = $1)
+		  AND ($2::INTEGER IS NULL OR target_user_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT 500
+	`, nullableInt(filters, "admin_id"), nullableInt(filters, "target_user_id"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []models.ImpersonationAction
+	for rows.Next() {
+		var action models.ImpersonationAction
+		err := rows.Scan(&action.ID, &action.AdminID, &action.TargetUserID, &action.SessionID, &action.Method, &action.Path, &action.Status, &action.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+// nullableInt returns filters[key] or nil if it is not set
+func nullableInt(filters map[string]int, key string) *int {
+	if v, ok := filters[key]; ok {
+		return &v
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+func (s *Store) CreateAPIToken(ctx context.Context, userID int, name, tokenHash, tokenPrefix, scope string, expiresAt time.Time, actor models.Actor) (*models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return nil, foreignKeyViolation
+	}
+	token := &apiToken{
+		APIToken: models.APIToken{
+			ID:          s.id(),
+			UserID:      userID,
+			Name:        name,
+			TokenPrefix: tokenPrefix,
+			Scope:       scope,
+			ExpiresAt:   expiresAt,
+			CreatedAt:   s.now(),
+		},
+		hash: tokenHash,
+	}
+	s.apiTokens = append(s.apiTokens, token)
+
+	created := token.APIToken
+	s.audit(actor, repository.AuditAPITokenCreated, "api_token", created.ID, nil, created)
+	return &created, nil
+}
+
+func (s *Store) GetAPITokensByUserID(ctx context.Context, userID int) ([]models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []models.APIToken
+	for _, token := range s.apiTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token.APIToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if !tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+		}
+		return tokens[i].ID > tokens[j].ID
+	})
+	return tokens, nil
+}
+
+func (s *Store) RevokeAPIToken(ctx context.Context, tokenID, userID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.apiTokens {
+		if token.ID != tokenID || token.UserID != userID || token.RevokedAt != nil {
+			continue
+		}
+		now := s.now()
+		token.RevokedAt = &now
+
+		s.audit(actor, repository.AuditAPITokenRevoked, "api_token", tokenID, nil, token.APIToken)
+		return nil
+	}
+	return sql.ErrNoRows
+}
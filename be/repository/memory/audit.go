@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// audit records a mutation in the audit log, like the repository's insertAuditEvent
+func (s *Store) audit(actor models.Actor, action, targetType string, targetID int, before, after interface{}) {
+	s.auditEvents = append(s.auditEvents, models.AuditEvent{
+		ID:             int64(s.id()),
+		ActorID:        actor.UserID,
+		ImpersonatorID: actor.ImpersonatorID,
+		Action:         action,
+		TargetType:     targetType,
+		TargetID:       &targetID,
+		Before:         mustMarshal(before),
+		After:          mustMarshal(after),
+		CreatedAt:      s.now(),
+	})
+}
+
+func (s *Store) GetAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.AuditEvent
+	for _, event := range s.auditEvents {
+		if filter.ActorID != nil && (event.ActorID == nil || *event.ActorID != *filter.ActorID) ||
+			filter.ImpersonatorID != nil && (event.ImpersonatorID == nil || *event.ImpersonatorID != *filter.ImpersonatorID) ||
+			filter.Action != "" && event.Action != filter.Action ||
+			filter.TargetType != "" && event.TargetType != filter.TargetType ||
+			filter.TargetID != nil && (event.TargetID == nil || *event.TargetID != *filter.TargetID) ||
+			filter.Since != nil && event.CreatedAt.Before(*filter.Since) ||
+			filter.Until != nil && !event.CreatedAt.Before(*filter.Until) {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 200
+	}
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
@@ -0,0 +1,317 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+type campaign struct {
+	models.Campaign
+	creators map[int]bool
+	// content maps attached content to how it was attached
+	content map[int]string
+}
+
+// campaignView returns a campaign with its counts, as the repository lists it
+func (s *Store) campaignView(c *campaign) models.Campaign {
+	view := c.Campaign
+	view.Hashtags = append([]string{}, c.Hashtags...)
+	view.Jurisdictions = append([]string{}, c.Jurisdictions...)
+	view.CreatorCount, view.ContentCount = len(c.creators), 0
+	for contentID := range c.content {
+		if content, ok := s.content[contentID]; ok && content.DeletedAt == nil {
+			view.ContentCount++
+		}
+	}
+	return view
+}
+
+// sortedCampaigns returns all campaigns in ID order
+func (s *Store) sortedCampaigns() []*campaign {
+	campaigns := make([]*campaign, 0, len(s.campaigns))
+	for _, c := range s.campaigns {
+		campaigns = append(campaigns, c)
+	}
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].ID < campaigns[j].ID })
+	return campaigns
+}
+
+// postDate is the day content was posted, or added when it has no post date
+func postDate(content *models.Content) time.Time {
+	at := content.CreatedAt
+	if content.PostedAt != nil {
+		at = *content.PostedAt
+	}
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// inWindow reports whether content was posted between the campaign's start and end dates
+func (c *campaign) inWindow(content *models.Content) bool {
+	day := postDate(content)
+	return !day.Before(c.StartDate) && !day.After(c.EndDate)
+}
+
+// autoAttachCampaigns attaches content from assigned creators that was posted in
+// a campaign's window and carries all of its hashtags. campaignID and contentID
+// narrow the match when not nil. Existing attachments are left untouched.
+func (s *Store) autoAttachCampaigns(campaignID, contentID *int) {
+	for _, c := range s.sortedCampaigns() {
+		if len(c.Hashtags) == 0 || campaignID != nil && c.ID != *campaignID {
+			continue
+		}
+		for _, content := range s.content {
+			if contentID != nil && content.ID != *contentID || content.DeletedAt != nil ||
+				!c.creators[content.UserID] || !c.inWindow(content) || c.content[content.ID] != "" {
+				continue
+			}
+			if containsAll(content.Hashtags, c.Hashtags) {
+				c.content[content.ID] = models.CampaignSourceAuto
+			}
+		}
+	}
+}
+
+func containsAll(values, wanted []string) bool {
+	has := make(map[string]bool)
+	for _, value := range values {
+		has[value] = true
+	}
+	for _, value := range wanted {
+		if !has[value] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) CreateCampaign(ctx context.Context, name, brand string, brief *string, startDate, endDate time.Time, hashtags, jurisdictions []string, actor models.Actor) (*models.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	c := &campaign{
+		Campaign: models.Campaign{
+			ID:            s.id(),
+			Name:          name,
+			Brand:         brand,
+			Brief:         brief,
+			StartDate:     startDate,
+			EndDate:       endDate,
+			Hashtags:      append([]string{}, hashtags...),
+			Jurisdictions: append([]string{}, jurisdictions...),
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		},
+		creators: make(map[int]bool),
+		content:  make(map[int]string),
+	}
+	s.campaigns[c.ID] = c
+
+	created := s.campaignView(c)
+	s.audit(actor, repository.AuditCampaignCreated, "campaign", c.ID, nil, created)
+	return &created, nil
+}
+
+func (s *Store) UpdateCampaign(ctx context.Context, id int, name, brand string, brief *string, startDate, endDate time.Time, hashtags, jurisdictions []string, actor models.Actor) (*models.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	before := s.campaignView(c)
+
+	c.Name, c.Brand, c.Brief, c.StartDate, c.EndDate = name, brand, brief, startDate, endDate
+	c.Hashtags = append([]string{}, hashtags...)
+	c.Jurisdictions = append([]string{}, jurisdictions...)
+	c.UpdatedAt = s.now()
+	s.autoAttachCampaigns(&id, nil)
+	s.reevaluateCompliance(&id)
+
+	after := s.campaignView(c)
+	s.audit(actor, repository.AuditCampaignUpdated, "campaign", id, before, after)
+	return &after, nil
+}
+
+func (s *Store) GetCampaigns(ctx context.Context, creatorID *int) ([]models.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var campaigns []models.Campaign
+	for _, c := range s.campaigns {
+		if creatorID == nil || c.creators[*creatorID] {
+			campaigns = append(campaigns, s.campaignView(c))
+		}
+	}
+	sort.Slice(campaigns, func(i, j int) bool {
+		if !campaigns[i].StartDate.Equal(campaigns[j].StartDate) {
+			return campaigns[i].StartDate.After(campaigns[j].StartDate)
+		}
+		return campaigns[i].Name < campaigns[j].Name
+	})
+	return campaigns, nil
+}
+
+func (s *Store) DeleteCampaign(ctx context.Context, id int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	deleted := s.campaignView(c)
+
+	contentIDs := s.sponsoredContentIDs(&id)
+	delete(s.campaigns, id)
+	s.evaluateComplianceFor(contentIDs)
+
+	s.audit(actor, repository.AuditCampaignDeleted, "campaign", id, deleted, nil)
+	return nil
+}
+
+// AddCampaignCreator assigns a creator to a campaign and attaches their
+// matching content; assigning an existing creator is a no-op. Returns a
+// foreign key violation if the campaign or user does not exist.
+func (s *Store) AddCampaignCreator(ctx context.Context, campaignID, userID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[campaignID]
+	if _, userExists := s.users[userID]; !ok || !userExists {
+		return foreignKeyViolation
+	}
+	if c.creators[userID] {
+		return nil
+	}
+	c.creators[userID] = true
+	s.autoAttachCampaigns(&campaignID, nil)
+	s.reevaluateCompliance(&campaignID)
+
+	after := map[string]int{"campaign_id": campaignID, "user_id": userID}
+	s.audit(actor, repository.AuditCampaignCreatorAdded, "campaign", campaignID, nil, after)
+	return nil
+}
+
+func (s *Store) RemoveCampaignCreator(ctx context.Context, campaignID, userID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[campaignID]
+	if !ok || !c.creators[userID] {
+		return sql.ErrNoRows
+	}
+	delete(c.creators, userID)
+
+	before := map[string]int{"campaign_id": campaignID, "user_id": userID}
+	s.audit(actor, repository.AuditCampaignCreatorRemoved, "campaign", campaignID, before, nil)
+	return nil
+}
+
+// AttachCampaignContent attaches content to a campaign by hand. An automatic
+// attachment of the same content becomes manual. Returns sql.ErrNoRows if the
+// content does not exist, or a foreign key violation if the campaign does not.
+func (s *Store) AttachCampaignContent(ctx context.Context, campaignID, contentID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.content[contentID]
+	if !ok || content.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	c, ok := s.campaigns[campaignID]
+	if !ok {
+		return foreignKeyViolation
+	}
+	c.content[contentID] = models.CampaignSourceManual
+	s.evaluateCompliance(contentID)
+
+	after := map[string]interface{}{"campaign_id": campaignID, "content_id": contentID, "source": models.CampaignSourceManual}
+	s.audit(actor, repository.AuditCampaignContentAttached, "campaign", campaignID, nil, after)
+	return nil
+}
+
+func (s *Store) DetachCampaignContent(ctx context.Context, campaignID, contentID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[campaignID]
+	if !ok || c.content[contentID] == "" {
+		return sql.ErrNoRows
+	}
+	source := c.content[contentID]
+	delete(c.content, contentID)
+	s.evaluateCompliance(contentID)
+
+	before := map[string]interface{}{"campaign_id": campaignID, "content_id": contentID, "source": source}
+	s.audit(actor, repository.AuditCampaignContentDetached, "campaign", campaignID, before, nil)
+	return nil
+}
+
+func (s *Store) GetCampaignReport(ctx context.Context, campaignID int, viewer *models.User) (*models.CampaignReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.campaigns[campaignID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	var posts []*models.Content
+	involved := make(map[int]bool)
+	for userID := range c.creators {
+		involved[userID] = true
+	}
+	for contentID := range c.content {
+		if content, ok := s.content[contentID]; ok && content.DeletedAt == nil {
+			posts = append(posts, content)
+			involved[content.UserID] = true
+		}
+	}
+
+	report := &models.CampaignReport{Campaign: s.campaignView(c), Creators: []models.CampaignCreatorReport{}}
+	for userID := range involved {
+		user, ok := s.users[userID]
+		if !ok || !s.canSee(viewer, userID) {
+			continue
+		}
+		report.Creators = append(report.Creators, models.CampaignCreatorReport{
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			Assigned: c.creators[userID],
+			Posts:    []models.CampaignContent{},
+		})
+	}
+	sort.Slice(report.Creators, func(i, j int) bool {
+		return report.Creators[i].Username < report.Creators[j].Username
+	})
+	byUser := make(map[int]int)
+	for i, creator := range report.Creators {
+		byUser[creator.UserID] = i
+	}
+
+	// Oldest first, the reverse of the feed order
+	sort.Slice(posts, func(i, j int) bool { return postedAfter(posts[j], posts[i]) })
+	for _, content := range posts {
+		i, ok := byUser[content.UserID]
+		if !ok {
+			// Not visible to the viewer
+			continue
+		}
+		creator := &report.Creators[i]
+		post := models.CampaignContent{Content: copyContent(content), Source: c.content[content.ID], InWindow: c.inWindow(content)}
+		creator.Posts = append(creator.Posts, post)
+		creator.PostCount++
+		if post.InWindow {
+			creator.InWindowCount++
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,264 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/compliance"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+type complianceResult struct {
+	status      string
+	reasons     []string
+	evaluatedAt time.Time
+}
+
+// sortedDisclosureRules returns all disclosure rules in ID order
+func (s *Store) sortedDisclosureRules() []*models.DisclosureRule {
+	rules := make([]*models.DisclosureRule, 0, len(s.disclosureRules))
+	for _, rule := range s.disclosureRules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// ruleApplies reports whether a disclosure rule covers a campaign
+func ruleApplies(rule *models.DisclosureRule, c *campaign) bool {
+	if rule.CampaignID != nil && *rule.CampaignID != c.ID {
+		return false
+	}
+	if rule.Jurisdiction == nil {
+		return true
+	}
+	for _, jurisdiction := range c.Jurisdictions {
+		if jurisdiction == *rule.Jurisdiction {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateCompliance checks one piece of content against the disclosure rules
+// of the campaigns it is attached to and stores the result. Content that is not
+// attached to any campaign is not sponsored and has its result removed.
+// It returns the status, or "" when the content is not sponsored.
+func (s *Store) evaluateCompliance(contentID int) string {
+	content, ok := s.content[contentID]
+	if !ok {
+		return ""
+	}
+
+	var attached []*campaign
+	for _, c := range s.campaigns {
+		if c.content[contentID] != "" {
+			attached = append(attached, c)
+		}
+	}
+	if len(attached) == 0 {
+		delete(s.complianceResults, contentID)
+		return ""
+	}
+
+	var rules []models.DisclosureRule
+	for _, rule := range s.sortedDisclosureRules() {
+		if !rule.Enabled {
+			continue
+		}
+		for _, c := range attached {
+			if ruleApplies(rule, c) {
+				rules = append(rules, *rule)
+				break
+			}
+		}
+	}
+
+	status, reasons := compliance.Evaluate(compliance.PostFromContent(*content), rules)
+	s.complianceResults[contentID] = complianceResult{status: status, reasons: reasons, evaluatedAt: s.now()}
+	return status
+}
+
+// sponsoredContentIDs returns the content attached to a campaign, or to any
+// campaign when campaignID is nil. The latter includes content that has a
+// result but may no longer be sponsored.
+func (s *Store) sponsoredContentIDs(campaignID *int) []int {
+	seen := make(map[int]bool)
+	for _, c := range s.campaigns {
+		if campaignID != nil && c.ID != *campaignID {
+			continue
+		}
+		for contentID := range c.content {
+			seen[contentID] = true
+		}
+	}
+	if campaignID == nil {
+		for contentID := range s.complianceResults {
+			seen[contentID] = true
+		}
+	}
+
+	ids := make([]int, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// evaluateComplianceFor evaluates each piece of content and counts the results
+func (s *Store) evaluateComplianceFor(contentIDs []int) (evaluated, failed int) {
+	for _, id := range contentIDs {
+		status := s.evaluateCompliance(id)
+		if status != "" {
+			evaluated++
+		}
+		if status == models.ComplianceStatusFail {
+			failed++
+		}
+	}
+	return evaluated, failed
+}
+
+// reevaluateCompliance evaluates the content of one campaign, or all sponsored content when campaignID is nil
+func (s *Store) reevaluateCompliance(campaignID *int) (evaluated, failed int) {
+	return s.evaluateComplianceFor(s.sponsoredContentIDs(campaignID))
+}
+
+func (s *Store) GetDisclosureRules(ctx context.Context) ([]models.DisclosureRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rules []models.DisclosureRule
+	for _, rule := range s.disclosureRules {
+		rules = append(rules, *rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Name != rules[j].Name {
+			return rules[i].Name < rules[j].Name
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+func (s *Store) CreateDisclosureRule(ctx context.Context, rule models.DisclosureRule, actor models.Actor) (*models.DisclosureRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rule.CampaignID != nil {
+		if _, ok := s.campaigns[*rule.CampaignID]; !ok {
+			return nil, foreignKeyViolation
+		}
+	}
+	rule.ID = s.id()
+	rule.RequiredHashtags = append([]string{}, rule.RequiredHashtags...)
+	rule.CreatedAt = s.now()
+	rule.UpdatedAt = rule.CreatedAt
+	created := rule
+	s.disclosureRules[rule.ID] = &created
+	s.reevaluateCompliance(rule.CampaignID)
+
+	s.audit(actor, repository.AuditDisclosureRuleCreated, "disclosure_rule", rule.ID, nil, rule)
+	return &rule, nil
+}
+
+func (s *Store) UpdateDisclosureRule(ctx context.Context, rule models.DisclosureRule, actor models.Actor) (*models.DisclosureRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.disclosureRules[rule.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if rule.CampaignID != nil {
+		if _, ok := s.campaigns[*rule.CampaignID]; !ok {
+			return nil, foreignKeyViolation
+		}
+	}
+	before := *stored
+
+	rule.RequiredHashtags = append([]string{}, rule.RequiredHashtags...)
+	rule.CreatedAt, rule.UpdatedAt = before.CreatedAt, s.now()
+	*stored = rule
+	s.reevaluateCompliance(nil)
+
+	s.audit(actor, repository.AuditDisclosureRuleUpdated, "disclosure_rule", rule.ID, before, rule)
+	return &rule, nil
+}
+
+func (s *Store) DeleteDisclosureRule(ctx context.Context, id int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.disclosureRules[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.disclosureRules, id)
+	s.reevaluateCompliance(rule.CampaignID)
+
+	s.audit(actor, repository.AuditDisclosureRuleDeleted, "disclosure_rule", id, *rule, nil)
+	return nil
+}
+
+func (s *Store) ReevaluateCompliance(ctx context.Context) (evaluated, failed int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evaluated, failed = s.reevaluateCompliance(nil)
+	return evaluated, failed, nil
+}
+
+func (s *Store) GetComplianceResults(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.ComplianceResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var campaignID, userID *int
+	for key, target := range map[string]**int{"campaign_id": &campaignID, "user_id": &userID} {
+		if value := filters[key]; value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			*target = &id
+		}
+	}
+	var filterCampaign *campaign
+	if campaignID != nil {
+		filterCampaign = s.campaigns[*campaignID]
+	}
+
+	var results []models.ComplianceResult
+	for _, content := range s.sortedContent() {
+		result, ok := s.complianceResults[content.ID]
+		if !ok || content.DeletedAt != nil || !s.canSee(viewer, content.UserID) {
+			continue
+		}
+		if status := filters["status"]; status != "" && result.status != status {
+			continue
+		}
+		if campaignID != nil && (filterCampaign == nil || filterCampaign.content[content.ID] == "") {
+			continue
+		}
+		if userID != nil && content.UserID != *userID {
+			continue
+		}
+
+		withUser := models.ContentWithUser{Content: copyContent(content)}
+		if user, ok := s.users[content.UserID]; ok {
+			withUser.Username, withUser.Email = user.Username, user.Email
+		}
+		results = append(results, models.ComplianceResult{
+			Content:     withUser,
+			Status:      result.status,
+			Reasons:     append([]string{}, result.reasons...),
+			EvaluatedAt: result.evaluatedAt,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// recordContentEvent adds a change to the content event feed, as the
+// repository's trigger does for every change to content
+func (s *Store) recordContentEvent(content *models.Content, kind string) {
+	id := int64(s.id())
+	s.contentEvents = append(s.contentEvents, models.ContentEvent{
+		ID:        id,
+		Type:      kind,
+		ContentID: content.ID,
+		UserID:    content.UserID,
+		CreatedAt: s.now(),
+		XactID:    id,
+	})
+}
+
+// OpenContentTransaction records a change to content in a transaction that
+// stays open until commit is called, like a slow transaction in Postgres. The
+// event takes its transaction id and id now, but is only in the feed once
+// committed, and events of later transactions are held back until then.
+func (s *Store) OpenContentTransaction(content models.Content, kind string) (commit func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := int64(s.id())
+	s.openContentEvents[id] = models.ContentEvent{
+		ID:        id,
+		Type:      kind,
+		ContentID: content.ID,
+		UserID:    content.UserID,
+		CreatedAt: s.now(),
+		XactID:    id,
+	}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if event, ok := s.openContentEvents[id]; ok {
+			delete(s.openContentEvents, id)
+			s.contentEvents = append(s.contentEvents, event)
+		}
+	}
+}
+
+// stableEvent reports whether event's transaction is older than any still
+// open, as the repository's stableEvents condition does
+func (s *Store) stableEvent(event models.ContentEvent) bool {
+	for xactID := range s.openContentEvents {
+		if event.XactID >= xactID {
+			return false
+		}
+	}
+	return true
+}
+
+// eventAfter reports whether event comes after position in the feed
+func eventAfter(event models.ContentEvent, position models.ContentEventPosition) bool {
+	if event.XactID != position.XactID {
+		return event.XactID > position.XactID
+	}
+	return event.ID > position.ID
+}
+
+func (s *Store) GetContentEvents(ctx context.Context, after models.ContentEventPosition, viewer *models.User, userID *int, limit int) ([]models.ContentEvent, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.ContentEvent
+	held := false
+	for _, event := range s.contentEvents {
+		if !s.stableEvent(event) {
+			held = true
+			continue
+		}
+		if !eventAfter(event, after) || userID != nil && event.UserID != *userID || viewer != nil && !s.canSee(viewer, event.UserID) {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return eventAfter(events[j], models.ContentEventPosition{XactID: events[i].XactID, ID: events[i].ID})
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	for i := range events {
+		content, ok := s.content[events[i].ContentID]
+		if !ok {
+			continue
+		}
+		withUser := models.ContentWithUser{Content: copyContent(content)}
+		if user, ok := s.users[content.UserID]; ok {
+			withUser.Username, withUser.Email = user.Username, user.Email
+		}
+		events[i].Content = &withUser
+	}
+	return events, held, nil
+}
+
+func (s *Store) GetContentEventPosition(ctx context.Context, id int64) (*models.ContentEventPosition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range s.contentEvents {
+		if event.ID == id {
+			return &models.ContentEventPosition{XactID: event.XactID, ID: event.ID}, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) GetLatestContentEventPosition(ctx context.Context) (models.ContentEventPosition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latest models.ContentEventPosition
+	for _, event := range s.contentEvents {
+		if s.stableEvent(event) && eventAfter(event, latest) {
+			latest = models.ContentEventPosition{XactID: event.XactID, ID: event.ID}
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+// activeSession returns the admin's open, unexpired session
+func (s *Store) activeSession(adminID int) *models.ImpersonationSession {
+	var active *models.ImpersonationSession
+	now := s.now()
+	for _, session := range s.sessions {
+		if session.AdminID == adminID && session.EndedAt == nil && session.ExpiresAt.After(now) &&
+			(active == nil || session.StartedAt.After(active.StartedAt)) {
+			active = session
+		}
+	}
+	return active
+}
+
+func (s *Store) StartImpersonationSession(ctx context.Context, adminID, targetUserID int, reason *string, expiresAt time.Time) (*models.ImpersonationSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, session := range s.sessions {
+		if session.AdminID == adminID && session.EndedAt == nil {
+			session.EndedAt = &now
+		}
+	}
+
+	session := &models.ImpersonationSession{
+		ID:           s.id(),
+		AdminID:      adminID,
+		TargetUserID: targetUserID,
+		Reason:       reason,
+		StartedAt:    now,
+		ExpiresAt:    expiresAt,
+	}
+	s.sessions = append(s.sessions, session)
+
+	started := *session
+	s.audit(models.Actor{UserID: &adminID}, repository.AuditImpersonationStarted, "user", targetUserID, nil, started)
+	return &started, nil
+}
+
+func (s *Store) GetActiveImpersonationSession(ctx context.Context, adminID int) (*models.ImpersonationSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.activeSession(adminID)
+	if session == nil {
+		return nil, sql.ErrNoRows
+	}
+	active := *session
+	return &active, nil
+}
+
+func (s *Store) EndImpersonationSession(ctx context.Context, adminID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.activeSession(adminID)
+	if session == nil {
+		return sql.ErrNoRows
+	}
+	now := s.now()
+	session.EndedAt = &now
+
+	s.audit(models.Actor{UserID: &adminID}, repository.AuditImpersonationEnded, "user", session.TargetUserID, nil, *session)
+	return nil
+}
+
+func (s *Store) RecordImpersonationAction(ctx context.Context, adminID, targetUserID int, sessionID *int, method, path string, status int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.impersonationActions = append(s.impersonationActions, models.ImpersonationAction{
+		ID:           s.id(),
+		AdminID:      adminID,
+		TargetUserID: targetUserID,
+		SessionID:    sessionID,
+		Method:       method,
+		Path:         path,
+		Status:       status,
+		CreatedAt:    s.now(),
+	})
+	return nil
+}
+
+func (s *Store) GetImpersonationActions(ctx context.Context, filters map[string]int) ([]models.ImpersonationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var actions []models.ImpersonationAction
+	for _, action := range s.impersonationActions {
+		if adminID, ok := filters["admin_id"]; ok && action.AdminID != adminID {
+			continue
+		}
+		if targetUserID, ok := filters["target_user_id"]; ok && action.TargetUserID != targetUserID {
+			continue
+		}
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if !actions[i].CreatedAt.Equal(actions[j].CreatedAt) {
+			return actions[i].CreatedAt.After(actions[j].CreatedAt)
+		}
+		return actions[i].ID > actions[j].ID
+	})
+	if len(actions) > 500 {
+		actions = actions[:500]
+	}
+	return actions, nil
+}
@@ -0,0 +1,1263 @@
+// Package memory implements the repository stores in memory, for tests that
+// should not need a database. It follows the behaviour of the Postgres
+// repository closely enough for handlers to be exercised against it: mutations
+// are audited, recorded in the outbox and content event feed, and queue
+// webhook deliveries, and content is attached to campaigns and checked for
+// compliance as it changes. Each call commits on its own; OpenContentTransaction
+// stands in for a transaction that commits late, holding the content event
+// feed back until it does.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+var (
+	_ repository.UserStore          = (*Store)(nil)
+	_ repository.AccountStore       = (*Store)(nil)
+	_ repository.ContentStore       = (*Store)(nil)
+	_ repository.JobStore           = (*Store)(nil)
+	_ repository.OutboxStore        = (*Store)(nil)
+	_ repository.TagStore           = (*Store)(nil)
+	_ repository.TagRuleStore       = (*Store)(nil)
+	_ repository.CampaignStore      = (*Store)(nil)
+	_ repository.ComplianceStore    = (*Store)(nil)
+	_ repository.WebhookStore       = (*Store)(nil)
+	_ repository.TeamStore          = (*Store)(nil)
+	_ repository.TrashStore         = (*Store)(nil)
+	_ repository.TokenStore         = (*Store)(nil)
+	_ repository.NotificationStore  = (*Store)(nil)
+	_ repository.AuditStore         = (*Store)(nil)
+	_ repository.ImpersonationStore = (*Store)(nil)
+	_ repository.ContentEventStore  = (*Store)(nil)
+)
+
+type apiToken struct {
+	models.APIToken
+	hash string
+}
+
+// Store holds everything the repository stores in memory. It is safe for concurrent use.
+type Store struct {
+	mu                   sync.Mutex
+	nextID               int
+	users                map[int]*models.User
+	roleChanges          []models.RoleChange
+	teams                map[int]*team
+	apiTokens            []*apiToken
+	accounts             map[int]*models.SocialAccount
+	content              map[int]*models.Content
+	tags                 map[int]*tag
+	tagRules             []models.TagRule
+	campaigns            map[int]*campaign
+	disclosureRules      map[int]*models.DisclosureRule
+	complianceResults    map[int]complianceResult
+	webhooks             map[int]*models.Webhook
+	deliveries           []*models.WebhookDelivery
+	notifications        []*models.Notification
+	preferences          map[int]models.NotificationPreferences
+	auditEvents          []models.AuditEvent
+	sessions             []*models.ImpersonationSession
+	impersonationActions []models.ImpersonationAction
+	contentEvents        []models.ContentEvent
+	syncRuns             []models.SyncRun
+	pullJobs             map[int]*models.PullJob
+	cursors              map[int]models.SyncCursor
+	revisions            []models.ContentRevision
+	outbox               []*outboxEvent
+	// openContentEvents are the events of open transactions, by transaction id
+	openContentEvents map[int64]models.ContentEvent
+	// clock is how far Advance has moved the store's time ahead of the wall clock
+	clock time.Duration
+}
+
+type outboxEvent struct {
+	models.OutboxEvent
+	nextAttemptAt time.Time
+	publishedAt   *time.Time
+}
+
+// New creates an empty store
+func New() *Store {
+	return &Store{
+		users:             make(map[int]*models.User),
+		teams:             make(map[int]*team),
+		accounts:          make(map[int]*models.SocialAccount),
+		content:           make(map[int]*models.Content),
+		tags:              make(map[int]*tag),
+		campaigns:         make(map[int]*campaign),
+		disclosureRules:   make(map[int]*models.DisclosureRule),
+		complianceResults: make(map[int]complianceResult),
+		webhooks:          make(map[int]*models.Webhook),
+		preferences:       make(map[int]models.NotificationPreferences),
+		pullJobs:          make(map[int]*models.PullJob),
+		cursors:           make(map[int]models.SyncCursor),
+		openContentEvents: make(map[int64]models.ContentEvent),
+	}
+}
+
+// now is the store's current time
+func (s *Store) now() time.Time {
+	return time.Now().Add(s.clock)
+}
+
+// Advance moves the store's time forward, so that retries and leases come due
+// without waiting for them
+func (s *Store) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clock += d
+}
+
+// id returns the next ID. IDs are unique across all entities, which makes
+// mixing them up in tests fail loudly.
+func (s *Store) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+// AddAPIToken stores an API token by the hash of its secret
+func (s *Store) AddAPIToken(userID int, tokenHash, scope string, expiresAt time.Time) models.APIToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := &apiToken{
+		APIToken: models.APIToken{
+			ID:        s.id(),
+			UserID:    userID,
+			Name:      "test",
+			Scope:     scope,
+			ExpiresAt: expiresAt,
+			CreatedAt: s.now(),
+		},
+		hash: tokenHash,
+	}
+	s.apiTokens = append(s.apiTokens, token)
+	return token.APIToken
+}
+
+// SyncRuns returns the recorded sync runs, oldest first
+func (s *Store) SyncRuns() []models.SyncRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]models.SyncRun(nil), s.syncRuns...)
+}
+
+// canSee mirrors the visibility rules of the repository: admins see everyone,
+// managers the members of their teams and everyone else only themselves
+func (s *Store) canSee(viewer *models.User, userID int) bool {
+	switch viewer.Role {
+	case models.RoleAdmin:
+		return true
+	case models.RoleManager:
+		for _, team := range s.teams {
+			if team.members[viewer.ID] && team.members[userID] {
+				return true
+			}
+		}
+		return false
+	default:
+		return viewer.ID == userID
+	}
+}
+
+// User operations
+
+func (s *Store) GetOrCreateUser(ctx context.Context, userID, email, username string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, user := range s.users {
+		if user.UserID == userID {
+			user.Email, user.Username, user.UpdatedAt = email, username, now
+			copied := *user
+			return &copied, nil
+		}
+	}
+
+	user := &models.User{
+		ID:        s.id(),
+		UserID:    userID,
+		Email:     email,
+		Username:  username,
+		Role:      models.RoleCreator,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.users[user.ID] = user
+	copied := *user
+	return &copied, nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *Store) ListUsers(ctx context.Context, viewer *models.User) ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []models.User
+	for _, user := range s.users {
+		if s.canSee(viewer, user.ID) {
+			users = append(users, *user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Username != users[j].Username {
+			return users[i].Username < users[j].Username
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+func (s *Store) UpdateUserRole(ctx context.Context, id int, role string, actor models.Actor, source string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	if user.Role != role {
+		s.audit(actor, repository.AuditUserRoleChanged, "user", id,
+			map[string]string{"role": user.Role},
+			map[string]string{"role": role, "source": source})
+		s.roleChanges = append(s.roleChanges, models.RoleChange{
+			ID:        s.id(),
+			UserID:    id,
+			OldRole:   user.Role,
+			NewRole:   role,
+			ChangedBy: actor.UserID,
+			Source:    source,
+			CreatedAt: s.now(),
+		})
+		user.Role, user.UpdatedAt = role, s.now()
+	}
+
+	copied := *user
+	return &copied, nil
+}
+
+func (s *Store) GetRoleChanges(ctx context.Context, userID int) ([]models.RoleChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []models.RoleChange
+	for i := len(s.roleChanges) - 1; i >= 0; i-- {
+		if s.roleChanges[i].UserID == userID {
+			changes = append(changes, s.roleChanges[i])
+		}
+	}
+	return changes, nil
+}
+
+func (s *Store) AuthenticateAPIToken(ctx context.Context, tokenHash string) (*models.User, *models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, token := range s.apiTokens {
+		if token.hash != tokenHash || token.RevokedAt != nil || !token.ExpiresAt.After(now) {
+			continue
+		}
+		user, ok := s.users[token.UserID]
+		if !ok {
+			break
+		}
+		token.LastUsedAt = &now
+		copiedUser, copiedToken := *user, token.APIToken
+		return &copiedUser, &copiedToken, nil
+	}
+	return nil, nil, sql.ErrNoRows
+}
+
+// GetNotificationRecipient returns a user with their notification preferences
+func (s *Store) GetNotificationRecipient(ctx context.Context, userID int) (*models.NotificationRecipient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &models.NotificationRecipient{User: *user, Preferences: s.notificationPreferences(user)}, nil
+}
+
+// Social account operations
+
+func (s *Store) GetSocialAccountsByUserID(ctx context.Context, userID int) ([]models.SocialAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var accounts []models.SocialAccount
+	for _, account := range s.sortedAccounts() {
+		if account.UserID == userID && account.DeletedAt == nil {
+			accounts = append(accounts, withoutTokens(*account))
+		}
+	}
+	return accounts, nil
+}
+
+func (s *Store) GetAllSocialAccounts(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.SocialAccountWithUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var accounts []models.SocialAccountWithUser
+	for _, account := range s.sortedAccounts() {
+		user := s.users[account.UserID]
+		if user == nil || !s.canSee(viewer, user.ID) {
+			continue
+		}
+		if platform := filters["platform"]; platform != "" && account.Platform != platform {
+			continue
+		}
+		if !usernameMatches(user, filters["username"]) {
+			continue
+		}
+		accounts = append(accounts, models.SocialAccountWithUser{
+			SocialAccount: withoutTokens(*account),
+			Username:      user.Username,
+			Email:         user.Email,
+		})
+	}
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].Username < accounts[j].Username
+	})
+	return accounts, nil
+}
+
+func (s *Store) GetSocialAccountByID(ctx context.Context, accountID, userID int) (*models.SocialAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[accountID]
+	if !ok || account.UserID != userID || account.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (s *Store) GetSocialAccountByPlatformAndAccountID(ctx context.Context, userID int, platform string, accountID string) (*models.SocialAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, account := range s.sortedAccounts() {
+		if account.UserID == userID && account.Platform == platform && account.DeletedAt == nil &&
+			account.AccountID != nil && *account.AccountID == accountID {
+			copied := *account
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) CreateSocialAccount(ctx context.Context, userID int, req models.CreateSocialAccountRequest, actor models.Actor) (*models.SocialAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account := s.insertAccount(userID, req, nil, actor)
+	return &account, nil
+}
+
+func (s *Store) CreateSocialAccountWithTokens(ctx context.Context, userID int, req models.CreateSocialAccountRequest, tokenExpiresAt time.Time, actor models.Actor) (*models.SocialAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account := s.insertAccount(userID, req, &tokenExpiresAt, actor)
+	return &account, nil
+}
+
+func (s *Store) insertAccount(userID int, req models.CreateSocialAccountRequest, tokenExpiresAt *time.Time, actor models.Actor) models.SocialAccount {
+	now := s.now()
+	account := &models.SocialAccount{
+		ID:             s.id(),
+		UserID:         userID,
+		Platform:       req.Platform,
+		AccountName:    req.AccountName,
+		AccountID:      req.AccountID,
+		AccessToken:    req.AccessToken,
+		RefreshToken:   req.RefreshToken,
+		TokenExpiresAt: tokenExpiresAt,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	s.accounts[account.ID] = account
+
+	created := withoutTokens(*account)
+	s.recordEvent("social_account", account.ID, repository.AuditAccountConnected, created)
+	s.audit(actor, repository.AuditAccountConnected, "social_account", account.ID, nil, created)
+	return created
+}
+
+func (s *Store) UpdateSocialAccountTokens(ctx context.Context, accountID int, accessToken string, refreshToken string, expiresAt time.Time, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[accountID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	previousExpiry := account.TokenExpiresAt
+	account.AccessToken, account.RefreshToken = &accessToken, &refreshToken
+	account.TokenExpiresAt, account.UpdatedAt = &expiresAt, s.now()
+
+	event := map[string]interface{}{"id": accountID, "token_expires_at": expiresAt}
+	s.recordEvent("social_account", accountID, repository.AuditAccountTokensUpdated, event)
+	s.audit(actor, repository.AuditAccountTokensUpdated, "social_account", accountID,
+		map[string]*time.Time{"token_expires_at": previousExpiry},
+		map[string]time.Time{"token_expires_at": expiresAt})
+	return nil
+}
+
+func (s *Store) UpdateSocialAccountID(ctx context.Context, accountID int, externalID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if account, ok := s.accounts[accountID]; ok {
+		account.AccountID, account.UpdatedAt = &externalID, s.now()
+	}
+	return nil
+}
+
+func (s *Store) DeleteSocialAccount(ctx context.Context, accountID, userID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[accountID]
+	if !ok || account.UserID != userID || account.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := s.now()
+	account.DeletedAt = &now
+
+	deleted := withoutTokens(*account)
+	s.recordEvent("social_account", accountID, repository.AuditAccountDisconnected, deleted)
+	s.audit(actor, repository.AuditAccountDisconnected, "social_account", accountID, deleted, nil)
+	return nil
+}
+
+func (s *Store) GetSyncCursor(ctx context.Context, accountID int) (*models.SyncCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if account, ok := s.accounts[accountID]; !ok || account.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	cursor := s.cursors[accountID]
+	cursor.SocialAccountID = accountID
+	return &cursor, nil
+}
+
+func (s *Store) RewindSyncCursor(ctx context.Context, accountID int, newestID *string, actor models.Actor) (*models.SyncCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if account, ok := s.accounts[accountID]; !ok || account.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	before := s.cursors[accountID]
+	before.SocialAccountID = accountID
+	now := s.now()
+	after := models.SyncCursor{SocialAccountID: accountID, NewestID: newestID, UpdatedAt: &now}
+	if newestID != nil {
+		after.OldestID, after.HighWaterID, after.HighWaterAt = before.OldestID, before.HighWaterID, before.HighWaterAt
+	}
+	s.cursors[accountID] = after
+	s.audit(actor, repository.AuditAccountSyncCursorRewound, "social_account", accountID, before, after)
+	return &after, nil
+}
+
+func (s *Store) RecordSyncRun(ctx context.Context, run models.SyncRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[run.SocialAccountID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	run.ID = s.id()
+	run.CreatedAt = s.now()
+	s.syncRuns = append(s.syncRuns, run)
+
+	if run.Status == models.SyncRunFailed {
+		event := models.SyncFailedEvent{AccountID: account.ID, UserID: run.UserID, Platform: account.Platform, AccountName: account.AccountName}
+		if run.Error != nil {
+			event.Error = *run.Error
+		}
+		s.enqueueWebhookEvent(models.WebhookEventSyncFailed, event)
+	}
+	return nil
+}
+
+// sortedAccounts returns all accounts, newest first
+func (s *Store) sortedAccounts() []*models.SocialAccount {
+	accounts := make([]*models.SocialAccount, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID > accounts[j].ID })
+	return accounts
+}
+
+// withoutTokens drops what the repository does not select when listing accounts
+func withoutTokens(account models.SocialAccount) models.SocialAccount {
+	account.AccessToken, account.RefreshToken = nil, nil
+	return account
+}
+
+// Content operations
+
+func (s *Store) GetContentByUserID(ctx context.Context, userID int, filters map[string]string) ([]models.Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var contents []models.Content
+	for _, content := range s.sortedContent() {
+		if content.UserID == userID && content.DeletedAt == nil && entitiesMatch(content, filters) && s.relationsMatch(content, filters) {
+			contents = append(contents, copyContent(content))
+		}
+	}
+	return contents, nil
+}
+
+func (s *Store) GetAllContent(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.ContentWithUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var contents []models.ContentWithUser
+	for _, content := range s.sortedContent() {
+		user := s.users[content.UserID]
+		if content.DeletedAt != nil || user == nil || !s.canSee(viewer, user.ID) {
+			continue
+		}
+		if platform := filters["platform"]; platform != "" && content.Platform != platform {
+			continue
+		}
+		if !usernameMatches(user, filters["username"]) || !entitiesMatch(content, filters) || !s.relationsMatch(content, filters) {
+			continue
+		}
+		contents = append(contents, models.ContentWithUser{
+			Content:  copyContent(content),
+			Username: user.Username,
+			Email:    user.Email,
+		})
+	}
+	return contents, nil
+}
+
+func (s *Store) CreateContent(ctx context.Context, userID int, req models.CreateContentRequest, actor models.Actor) (*models.Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findContent(userID, req.Link, false) != nil {
+		// Duplicate content, return nil without error
+		return nil, nil
+	}
+
+	now := s.now()
+	content := &models.Content{
+		ID:              s.id(),
+		UserID:          userID,
+		SocialAccountID: req.SocialAccountID,
+		Platform:        req.Platform,
+		Link:            req.Link,
+		OriginalText:    req.OriginalText,
+		Description:     req.Description,
+		Tags:            s.resolveTags(req.Tags),
+		PaidPartnership: req.PaidPartnership,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	setEntities(content, entities.Extract(contentText(content)))
+	s.content[content.ID] = content
+	s.autoAttachCampaigns(nil, &content.ID)
+	s.evaluateCompliance(content.ID)
+
+	created := copyContent(content)
+	s.recordEvent("content", content.ID, repository.AuditContentCreated, created)
+	s.audit(actor, repository.AuditContentCreated, "content", content.ID, nil, created)
+	s.recordContentEvent(content, models.ContentEventCreated)
+	return &created, nil
+}
+
+func (s *Store) UpdateContent(ctx context.Context, contentID, userID int, req models.UpdateContentRequest, actor models.Actor) (*models.Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.content[contentID]
+	if !ok || content.UserID != userID || content.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	if req.OriginalText != nil && content.ExternalPostID != nil {
+		return nil, repository.ErrSyncedContentText
+	}
+	before := copyContent(content)
+	if req.OriginalText != nil {
+		content.OriginalText = req.OriginalText
+	}
+	if req.Description != nil {
+		content.Description = req.Description
+	}
+	if req.Tags != nil {
+		content.Tags = s.resolveTags(*req.Tags)
+	}
+	if req.PaidPartnership != nil {
+		content.PaidPartnership = *req.PaidPartnership
+	}
+	content.UpdatedAt = s.now()
+	setEntities(content, entities.Extract(contentText(content)))
+	s.autoAttachCampaigns(nil, &content.ID)
+	s.evaluateCompliance(content.ID)
+
+	after := copyContent(content)
+	s.recordEvent("content", content.ID, repository.AuditContentUpdated, after)
+	s.audit(actor, repository.AuditContentUpdated, "content", content.ID, before, after)
+	s.recordContentEvent(content, models.ContentEventUpdated)
+	return &after, nil
+}
+
+func (s *Store) DeleteContent(ctx context.Context, contentID, userID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.content[contentID]
+	if !ok || content.UserID != userID || content.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := s.now()
+	content.DeletedAt = &now
+
+	deleted := copyContent(content)
+	s.recordEvent("content", contentID, repository.AuditContentDeleted, deleted)
+	s.audit(actor, repository.AuditContentDeleted, "content", contentID, deleted, nil)
+	s.recordContentEvent(content, models.ContentEventDeleted)
+	return nil
+}
+
+// StoreSyncedContent stores the posts of a pull and records the pull and its
+// cursor on the account. Like the repository it updates posts the user already
+// has by link or edit history, keeping edited text as a revision, and skips
+// posts in the trash.
+func (s *Store) StoreSyncedContent(ctx context.Context, userID, socialAccountID int, platform string, posts []models.SyncedPost, cursor models.SyncCursor, actor models.Actor) ([]models.SyncedPostResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	results := make([]models.SyncedPostResult, len(posts))
+	created := make(map[int]*models.Content)
+	seen := make(map[string]bool)
+	for i, post := range posts {
+		results[i] = models.SyncedPostResult{ExternalID: post.ExternalID, Status: models.SyncedPostSkipped}
+		if seen[post.Link] {
+			continue
+		}
+		seen[post.Link] = true
+
+		if content := s.findSyncedContent(userID, socialAccountID, post); content != nil {
+			if content.DeletedAt == nil && s.updateSyncedContent(content, post, now, actor) {
+				copied := copyContent(content)
+				results[i].Status, results[i].Content = models.SyncedPostUpdated, &copied
+			}
+			continue
+		}
+
+		content := &models.Content{
+			ID:              s.id(),
+			UserID:          userID,
+			SocialAccountID: &socialAccountID,
+			Platform:        platform,
+			Link:            post.Link,
+			OriginalText:    &post.Text,
+			ExternalPostID:  &post.ExternalID,
+			PostedAt:        &post.PostedAt,
+			EditHistoryIDs:  post.EditHistoryIDs,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		content.ConversationID = optional(post.ConversationID)
+		content.Relation = optional(post.Relation)
+		content.ParentExternalID = optional(post.ParentExternalID)
+		content.Media = s.contentMedia(content.ID, post.Media, now)
+		setSyncedEntities(content, post)
+		s.content[content.ID] = content
+		created[i] = content
+	}
+
+	// Posts are linked to their parents in either direction once all are stored
+	s.linkParents(userID)
+	for i := range posts {
+		content, ok := created[i]
+		if !ok {
+			continue
+		}
+		s.autoAttachCampaigns(nil, &content.ID)
+		s.evaluateCompliance(content.ID)
+
+		copied := copyContent(content)
+		s.recordEvent("content", content.ID, repository.AuditContentCreated, copied)
+		s.audit(actor, repository.AuditContentCreated, "content", content.ID, nil, copied)
+		s.recordContentEvent(content, models.ContentEventCreated)
+		results[i].Status, results[i].Content = models.SyncedPostCreated, &copied
+	}
+
+	if account, ok := s.accounts[socialAccountID]; ok && account.UserID == userID && account.DeletedAt == nil {
+		account.LastPullAt, account.UpdatedAt = &now, now
+	}
+	cursor.SocialAccountID, cursor.UpdatedAt = socialAccountID, &now
+	s.cursors[socialAccountID] = cursor
+	return results, nil
+}
+
+// linkParents points the synced posts of a user at their stored parents
+func (s *Store) linkParents(userID int) {
+	for _, content := range s.content {
+		if content.UserID != userID || content.ParentContentID != nil || content.ParentExternalID == nil {
+			continue
+		}
+		for _, parent := range s.content {
+			if parent.UserID == userID && parent.DeletedAt == nil && parent.ExternalPostID != nil &&
+				*parent.ExternalPostID == *content.ParentExternalID {
+				content.ParentContentID = &parent.ID
+				break
+			}
+		}
+	}
+}
+
+// findSyncedContent returns the post of the user that post is a version of,
+// live posts taking precedence over trashed ones
+func (s *Store) findSyncedContent(userID, socialAccountID int, post models.SyncedPost) *models.Content {
+	if content := s.findContent(userID, post.Link, false); content != nil {
+		return content
+	}
+	if content := s.findContent(userID, post.Link, true); content != nil {
+		return content
+	}
+	for i := len(post.EditHistoryIDs) - 1; i >= 0; i-- {
+		var trashed *models.Content
+		for _, content := range s.content {
+			if content.UserID != userID || content.SocialAccountID == nil || *content.SocialAccountID != socialAccountID ||
+				content.ExternalPostID == nil || *content.ExternalPostID != post.EditHistoryIDs[i] {
+				continue
+			}
+			if content.DeletedAt == nil {
+				return content
+			}
+			trashed = content
+		}
+		if trashed != nil {
+			return trashed
+		}
+	}
+	return nil
+}
+
+// updateSyncedContent brings content up to date with post and reports
+// whether its text was edited
+func (s *Store) updateSyncedContent(content *models.Content, post models.SyncedPost, now time.Time, actor models.Actor) bool {
+	before := copyContent(content)
+	edited := content.OriginalText == nil || *content.OriginalText != post.Text
+	if edited {
+		versionID := content.ExternalPostID
+		if len(content.EditHistoryIDs) > 0 {
+			versionID = &content.EditHistoryIDs[len(content.EditHistoryIDs)-1]
+		}
+		s.revisions = append(s.revisions, models.ContentRevision{
+			ID:             s.id(),
+			ContentID:      content.ID,
+			ExternalPostID: versionID,
+			OriginalText:   content.OriginalText,
+			RevisedAt:      now,
+		})
+		content.OriginalText, content.EditedAt = &post.Text, &now
+		setSyncedEntities(content, post)
+	}
+	if len(post.EditHistoryIDs) > 0 {
+		content.EditHistoryIDs = post.EditHistoryIDs
+	}
+	content.Media = s.contentMedia(content.ID, post.Media, now)
+	content.RemovedOnPlatformAt, content.UpdatedAt = nil, now
+
+	if edited {
+		s.evaluateCompliance(content.ID)
+		after := copyContent(content)
+		s.recordEvent("content", content.ID, repository.AuditContentUpdated, after)
+		s.audit(actor, repository.AuditContentUpdated, "content", content.ID, before, after)
+		s.recordContentEvent(content, models.ContentEventUpdated)
+	}
+	return edited
+}
+
+// contentMedia returns media as stored for content
+func (s *Store) contentMedia(contentID int, list []models.SyncedMedia, now time.Time) []models.ContentMedia {
+	var media []models.ContentMedia
+	for _, m := range list {
+		media = append(media, models.ContentMedia{
+			ID:         s.id(),
+			ContentID:  contentID,
+			MediaKey:   m.Key,
+			Type:       m.Type,
+			URL:        optional(m.URL),
+			PreviewURL: optional(m.PreviewURL),
+			Width:      optionalInt(m.Width),
+			Height:     optionalInt(m.Height),
+			DurationMs: optionalInt(m.DurationMs),
+			AltText:    optional(m.AltText),
+			CreatedAt:  now,
+		})
+	}
+	return media
+}
+
+// optional and optionalInt return nil for empty values, like NULLIF
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func optionalInt(value int) *int {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
+
+func setSyncedEntities(content *models.Content, post models.SyncedPost) {
+	ents := post.Entities
+	if ents == nil {
+		ents = entities.Extract(post.Text)
+	}
+	setEntities(content, ents)
+}
+
+func (s *Store) GetContentRevisions(ctx context.Context, contentID, userID int) ([]models.ContentRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.content[contentID]
+	if !ok || content.UserID != userID || content.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	revisions := []models.ContentRevision{}
+	for i := len(s.revisions) - 1; i >= 0; i-- {
+		if s.revisions[i].ContentID == contentID {
+			revisions = append(revisions, s.revisions[i])
+		}
+	}
+	return revisions, nil
+}
+
+func (s *Store) AddContentTags(ctx context.Context, contentID int, tags []string, actor models.Actor) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.content[contentID]
+	if !ok || content.DeletedAt != nil {
+		return false, sql.ErrNoRows
+	}
+
+	current := content.Tags
+	updated := append([]string{}, current...)
+	has := make(map[string]bool)
+	for _, tag := range current {
+		has[tag] = true
+	}
+	for _, tag := range s.resolveTags(tags) {
+		if !has[tag] {
+			has[tag] = true
+			updated = append(updated, tag)
+		}
+	}
+	if len(updated) == len(current) {
+		return false, nil
+	}
+	content.Tags, content.UpdatedAt = updated, s.now()
+
+	event := map[string]interface{}{"id": contentID, "tags": updated}
+	s.recordEvent("content", contentID, repository.AuditContentTagsUpdated, event)
+	s.audit(actor, repository.AuditContentTagsUpdated, "content", contentID,
+		map[string][]string{"tags": current}, map[string][]string{"tags": updated})
+	s.recordContentEvent(content, models.ContentEventUpdated)
+	return true, nil
+}
+
+func (s *Store) EnqueuePullJob(ctx context.Context, userID, accountID int, actor models.Actor) (*models.PullJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[accountID]; !ok {
+		return nil, sql.ErrNoRows
+	}
+	for _, job := range s.pullJobs {
+		if job.SocialAccountID == accountID && !job.Finished() {
+			copied := *job
+			return &copied, nil
+		}
+	}
+
+	now := s.now()
+	job := &models.PullJob{
+		ID:              s.id(),
+		UserID:          userID,
+		SocialAccountID: accountID,
+		Status:          models.PullJobQueued,
+		RunAt:           now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Actor:           actor,
+	}
+	s.pullJobs[job.ID] = job
+	copied := *job
+	return &copied, nil
+}
+
+func (s *Store) GetPullJob(ctx context.Context, jobID, userID int) (*models.PullJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.pullJobs[jobID]
+	if !ok || job.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (s *Store) ClaimPullJobs(ctx context.Context, limit int, lease time.Duration) ([]models.PullJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var due []*models.PullJob
+	for _, job := range s.pullJobs {
+		if (job.Status == models.PullJobQueued && !job.RunAt.After(now)) ||
+			(job.Status == models.PullJobRunning && !job.LeaseExpiresAt.After(now)) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].RunAt.Equal(due[j].RunAt) {
+			return due[i].RunAt.Before(due[j].RunAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var claimed []models.PullJob
+	for _, job := range due {
+		leaseExpiresAt := now.Add(lease)
+		job.Status = models.PullJobRunning
+		job.Attempts++
+		job.LeaseExpiresAt = &leaseExpiresAt
+		if job.StartedAt == nil {
+			job.StartedAt = &now
+		}
+		job.UpdatedAt = now
+		claimed = append(claimed, *job)
+	}
+	return claimed, nil
+}
+
+// claimedJob returns the stored job if claimed still holds it
+func (s *Store) claimedJob(claimed models.PullJob) (*models.PullJob, error) {
+	job, ok := s.pullJobs[claimed.ID]
+	if !ok || job.Status != models.PullJobRunning || job.LeaseExpiresAt == nil ||
+		claimed.LeaseExpiresAt == nil || !job.LeaseExpiresAt.Equal(*claimed.LeaseExpiresAt) {
+		return nil, repository.ErrPullJobLost
+	}
+	return job, nil
+}
+
+func (s *Store) UpdatePullJobProgress(ctx context.Context, claimed models.PullJob, progress models.PullJobProgress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.claimedJob(claimed)
+	if err != nil {
+		return err
+	}
+	job.Progress, job.UpdatedAt = progress, s.now()
+	return nil
+}
+
+func (s *Store) CompletePullJob(ctx context.Context, claimed models.PullJob, result models.SyncResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.claimedJob(claimed)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	job.Status, job.Result, job.Error = models.PullJobSucceeded, &result, nil
+	job.FinishedAt, job.UpdatedAt, job.LeaseExpiresAt = &now, now, nil
+	return nil
+}
+
+func (s *Store) FailPullJob(ctx context.Context, claimed models.PullJob, errMsg string, retryAfter time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.claimedJob(claimed)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	job.Error, job.UpdatedAt, job.LeaseExpiresAt = &errMsg, now, nil
+	if retryAfter > 0 {
+		job.Status, job.RunAt = models.PullJobQueued, now.Add(retryAfter)
+		job.Attempts = max(job.Attempts-1, 0)
+	} else {
+		job.Status, job.FinishedAt = models.PullJobFailed, &now
+	}
+	return nil
+}
+
+// AddOutboxEvent records an event as a mutation of an aggregate would, without
+// queueing webhook deliveries for it
+func (s *Store) AddOutboxEvent(aggregateType string, aggregateID int, eventType string, payload json.RawMessage) models.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addOutboxEvent(aggregateType, aggregateID, eventType, payload)
+}
+
+// recordEvent writes an outbox event for a content or account mutation and
+// queues deliveries for the webhooks subscribed to it, like the repository's
+func (s *Store) recordEvent(aggregateType string, aggregateID int, eventType string, payload interface{}) {
+	s.addOutboxEvent(aggregateType, aggregateID, eventType, mustMarshal(payload))
+	s.enqueueWebhookEvent(eventType, payload)
+}
+
+func (s *Store) addOutboxEvent(aggregateType string, aggregateID int, eventType string, payload json.RawMessage) models.OutboxEvent {
+	now := s.now()
+	event := &outboxEvent{
+		OutboxEvent: models.OutboxEvent{
+			ID:            int64(s.id()),
+			AggregateType: aggregateType,
+			AggregateID:   aggregateID,
+			EventType:     eventType,
+			Payload:       payload,
+			CreatedAt:     now,
+		},
+		nextAttemptAt: now,
+	}
+	s.outbox = append(s.outbox, event)
+	return event.OutboxEvent
+}
+
+func (s *Store) RelayOutbox(ctx context.Context, limit int, publish func(models.OutboxEvent) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only the oldest unpublished event of each aggregate is eligible
+	now := s.now()
+	heads := make(map[string]bool)
+	var due []*outboxEvent
+	for _, event := range s.outbox {
+		aggregate := event.AggregateType + "/" + strconv.Itoa(event.AggregateID)
+		if event.publishedAt != nil || heads[aggregate] {
+			continue
+		}
+		heads[aggregate] = true
+		if !event.nextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, event)
+		}
+	}
+
+	published := 0
+	for _, event := range due {
+		if err := publish(event.OutboxEvent); err != nil {
+			event.nextAttemptAt = now.Add(min(time.Hour, 5*time.Second<<min(event.Attempts, 20)))
+		} else {
+			event.publishedAt = &now
+			published++
+		}
+		event.Attempts++
+	}
+	return published, nil
+}
+
+func (s *Store) PruneOutbox(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []*outboxEvent
+	for _, event := range s.outbox {
+		if event.publishedAt == nil || !event.publishedAt.Before(cutoff) {
+			kept = append(kept, event)
+		}
+	}
+	pruned := int64(len(s.outbox) - len(kept))
+	s.outbox = kept
+	return pruned, nil
+}
+
+// findContent returns the user's live content with link, or content in the
+// trash as well when includeTrashed is set
+func (s *Store) findContent(userID int, link string, includeTrashed bool) *models.Content {
+	for _, content := range s.content {
+		if content.UserID == userID && content.Link == link && (content.DeletedAt == nil || includeTrashed) {
+			return content
+		}
+	}
+	return nil
+}
+
+// sortedContent returns all content, most recently posted or created first
+func (s *Store) sortedContent() []*models.Content {
+	contents := make([]*models.Content, 0, len(s.content))
+	for _, content := range s.content {
+		contents = append(contents, content)
+	}
+	sort.Slice(contents, func(i, j int) bool { return postedAfter(contents[i], contents[j]) })
+	return contents
+}
+
+// postedAfter orders content by COALESCE(posted_at, created_at), newest first
+func postedAfter(a, b *models.Content) bool {
+	at, bt := a.CreatedAt, b.CreatedAt
+	if a.PostedAt != nil {
+		at = *a.PostedAt
+	}
+	if b.PostedAt != nil {
+		bt = *b.PostedAt
+	}
+	if !at.Equal(bt) {
+		return at.After(bt)
+	}
+	return a.ID > b.ID
+}
+
+func copyContent(content *models.Content) models.Content {
+	copied := *content
+	copied.Tags = append([]string(nil), content.Tags...)
+	copied.EditHistoryIDs = append([]string(nil), content.EditHistoryIDs...)
+	copied.Media = append([]models.ContentMedia(nil), content.Media...)
+	return copied
+}
+
+func contentText(content *models.Content) string {
+	var parts []string
+	if content.OriginalText != nil {
+		parts = append(parts, *content.OriginalText)
+	}
+	if content.Description != nil {
+		parts = append(parts, *content.Description)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func setEntities(content *models.Content, list []entities.Entity) {
+	content.Hashtags = entities.Values(list, entities.KindHashtag)
+	content.Mentions = entities.Values(list, entities.KindMention)
+	content.Cashtags = entities.Values(list, entities.KindCashtag)
+	content.URLs = entities.Values(list, entities.KindURL)
+}
+
+// relationsMatch applies the relation filters
+func (s *Store) relationsMatch(content *models.Content, filters map[string]string) bool {
+	if relationExcluded(content, filters) {
+		return false
+	}
+	if filters[models.FilterCollapseThreads] == "true" && content.Relation != nil && *content.Relation == models.RelationThread {
+		// Left out when an earlier post of its thread is listed. Posts of a
+		// thread share the user, so only the other filters are repeated.
+		for _, other := range s.content {
+			if other.UserID == content.UserID && other.DeletedAt == nil && other.ConversationID != nil &&
+				*other.ConversationID == *content.ConversationID && other.Platform == content.Platform &&
+				postedAfter(content, other) && entitiesMatch(other, filters) && !relationExcluded(other, filters) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// relationExcluded applies the exclude_replies and exclude_retweets filters
+func relationExcluded(content *models.Content, filters map[string]string) bool {
+	relation := ""
+	if content.Relation != nil {
+		relation = *content.Relation
+	}
+	return filters[models.FilterExcludeReplies] == "true" && relation == models.RelationReply ||
+		filters[models.FilterExcludeRetweets] == "true" && relation == models.RelationRetweet
+}
+
+// entitiesMatch applies the hashtag, mention and cashtag filters
+func entitiesMatch(content *models.Content, filters map[string]string) bool {
+	values := map[string][]string{
+		entities.KindHashtag: content.Hashtags,
+		entities.KindMention: content.Mentions,
+		entities.KindCashtag: content.Cashtags,
+	}
+	for kind, list := range values {
+		raw := filters[kind]
+		if raw == "" {
+			continue
+		}
+		want := entities.Normalize(kind, raw)
+		found := false
+		for _, value := range list {
+			if value == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// usernameMatches is the case-insensitive substring match of the username filter
+func usernameMatches(user *models.User, filter string) bool {
+	return filter == "" || strings.Contains(strings.ToLower(user.Username), strings.ToLower(filter))
+}
+
+// mustMarshal encodes state the way the repository stores it as JSON, with
+// nil as no value. The stores only marshal models, which cannot fail.
+func mustMarshal(state interface{}) json.RawMessage {
+	if state == nil {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/notify"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+var _ notify.Store = (*Store)(nil)
+
+// notificationPreferences returns a user's preferences, or the defaults when
+// they have none: daily digests for admins and managers, weekly for creators
+func (s *Store) notificationPreferences(user *models.User) models.NotificationPreferences {
+	if prefs, ok := s.preferences[user.ID]; ok {
+		return prefs
+	}
+	prefs := models.NotificationPreferences{
+		UserID:          user.ID,
+		EmailEnabled:    true,
+		DigestFrequency: models.DigestWeekly,
+		AlertsEnabled:   true,
+	}
+	if user.Role == models.RoleAdmin || user.Role == models.RoleManager {
+		prefs.DigestFrequency = models.DigestDaily
+	}
+	return prefs
+}
+
+func (s *Store) UpdateNotificationPreferences(ctx context.Context, userID int, req models.UpdateNotificationPreferencesRequest, actor models.Actor) (*models.NotificationPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	before := s.notificationPreferences(user)
+	after := before
+	if req.EmailEnabled != nil {
+		after.EmailEnabled = *req.EmailEnabled
+	}
+	if req.SlackWebhookURL != nil {
+		after.SlackWebhookURL = req.SlackWebhookURL
+		if *req.SlackWebhookURL == "" {
+			after.SlackWebhookURL = nil
+		}
+		after.SlackEnabled = after.SlackWebhookURL != nil
+	}
+	if req.DigestFrequency != nil {
+		after.DigestFrequency = *req.DigestFrequency
+	}
+	if req.AlertsEnabled != nil {
+		after.AlertsEnabled = *req.AlertsEnabled
+	}
+	now := s.now()
+	after.UpdatedAt = &now
+	s.preferences[userID] = after
+
+	s.audit(actor, repository.AuditNotificationPreferencesUpdated, "user", userID, before, after)
+	return &after, nil
+}
+
+func (s *Store) ClaimNotification(ctx context.Context, userID int, kind, key, subject string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range s.notifications {
+		if n.UserID != userID || n.Kind != kind || n.Key != key {
+			continue
+		}
+		if n.Status != models.NotificationFailed {
+			return 0, false, nil
+		}
+		n.Status, n.Subject, n.Error, n.CreatedAt = models.NotificationPending, subject, nil, s.now()
+		return n.ID, true, nil
+	}
+
+	n := &models.Notification{
+		ID:        s.id(),
+		UserID:    userID,
+		Kind:      kind,
+		Key:       key,
+		Subject:   subject,
+		Status:    models.NotificationPending,
+		CreatedAt: s.now(),
+	}
+	s.notifications = append(s.notifications, n)
+	return n.ID, true, nil
+}
+
+func (s *Store) FinishNotification(ctx context.Context, id int, sent bool, sendErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range s.notifications {
+		if n.ID != id {
+			continue
+		}
+		n.Status, n.SentAt, n.Error = models.NotificationFailed, nil, nil
+		if sent {
+			now := s.now()
+			n.Status, n.SentAt = models.NotificationSent, &now
+		}
+		if sendErr != nil {
+			msg := sendErr.Error()
+			n.Error = &msg
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetNotifications(ctx context.Context, userID, limit int) ([]models.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var notifications []models.Notification
+	for _, n := range s.notifications {
+		if n.UserID == userID {
+			notifications = append(notifications, *n)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		if !notifications[i].CreatedAt.Equal(notifications[j].CreatedAt) {
+			return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+		}
+		return notifications[i].ID > notifications[j].ID
+	})
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+	return notifications, nil
+}
+
+func (s *Store) GetDigest(ctx context.Context, viewer *models.User, frequency string, from, to time.Time, postsPerCreator int) (*models.Digest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creators := make(map[int]*models.DigestCreator)
+	creator := func(userID int) *models.DigestCreator {
+		if c, ok := creators[userID]; ok {
+			return c
+		}
+		c := &models.DigestCreator{UserID: userID, Posts: []models.Content{}}
+		if user, ok := s.users[userID]; ok {
+			c.Username = user.Username
+		}
+		creators[userID] = c
+		return c
+	}
+	inPeriod := func(at time.Time) bool { return !at.Before(from) && at.Before(to) }
+
+	var posts []*models.Content
+	for _, content := range s.content {
+		if content.DeletedAt == nil && inPeriod(content.CreatedAt) && s.canSee(viewer, content.UserID) {
+			posts = append(posts, content)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.After(posts[j].CreatedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+	for _, content := range posts {
+		c := creator(content.UserID)
+		c.PostCount++
+		if len(c.Posts) < postsPerCreator {
+			c.Posts = append(c.Posts, copyContent(content))
+		}
+	}
+
+	// Sync runs are kept oldest first, so the last failure seen is the latest
+	for _, run := range s.syncRuns {
+		if !inPeriod(run.CreatedAt) || !s.canSee(viewer, run.UserID) {
+			continue
+		}
+		c := creator(run.UserID)
+		c.SyncCount++
+		if run.Status == models.SyncRunFailed {
+			c.FailedSyncs++
+			c.LastSyncError = run.Error
+		}
+	}
+
+	digest := &models.Digest{Frequency: frequency, From: from, To: to, Creators: []models.DigestCreator{}}
+	for _, c := range creators {
+		digest.Creators = append(digest.Creators, *c)
+	}
+	sort.Slice(digest.Creators, func(i, j int) bool {
+		return digest.Creators[i].Username < digest.Creators[j].Username
+	})
+	return digest, nil
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+// AddTagRule stores a tag rule as is, without resolving its tags or auditing it
+func (s *Store) AddTagRule(rule models.TagRule) models.TagRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule.ID = s.id()
+	rule.CreatedAt = s.now()
+	rule.UpdatedAt = rule.CreatedAt
+	s.tagRules = append(s.tagRules, rule)
+	return rule
+}
+
+func (s *Store) GetTagRules(ctx context.Context, userID int) ([]models.TagRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var global, own []models.TagRule
+	for _, rule := range s.tagRules {
+		switch {
+		case rule.OwnerID == nil:
+			global = append(global, rule)
+		case *rule.OwnerID == userID:
+			own = append(own, rule)
+		}
+	}
+	return append(global, own...), nil
+}
+
+// findTagRule returns the index of a rule, or -1
+func (s *Store) findTagRule(id int) int {
+	for i, rule := range s.tagRules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) GetTagRule(ctx context.Context, id int) (*models.TagRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findTagRule(id)
+	if i < 0 {
+		return nil, sql.ErrNoRows
+	}
+	rule := s.tagRules[i]
+	return &rule, nil
+}
+
+func (s *Store) CreateTagRule(ctx context.Context, rule models.TagRule, actor models.Actor) (*models.TagRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule.ID = s.id()
+	rule.Tags = s.resolveTags(rule.Tags)
+	rule.CreatedAt = s.now()
+	rule.UpdatedAt = rule.CreatedAt
+	s.tagRules = append(s.tagRules, rule)
+
+	s.audit(actor, repository.AuditTagRuleCreated, "tag_rule", rule.ID, nil, rule)
+	return &rule, nil
+}
+
+func (s *Store) UpdateTagRule(ctx context.Context, rule models.TagRule, actor models.Actor) (*models.TagRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findTagRule(rule.ID)
+	if i < 0 {
+		return nil, sql.ErrNoRows
+	}
+	before := s.tagRules[i]
+
+	after := rule
+	after.OwnerID, after.CreatedAt = before.OwnerID, before.CreatedAt
+	after.Tags = s.resolveTags(rule.Tags)
+	after.UpdatedAt = s.now()
+	s.tagRules[i] = after
+
+	s.audit(actor, repository.AuditTagRuleUpdated, "tag_rule", rule.ID, before, after)
+	return &after, nil
+}
+
+func (s *Store) DeleteTagRule(ctx context.Context, id int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findTagRule(id)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	rule := s.tagRules[i]
+	s.tagRules = append(s.tagRules[:i], s.tagRules[i+1:]...)
+
+	s.audit(actor, repository.AuditTagRuleDeleted, "tag_rule", id, rule, nil)
+	return nil
+}
+
+func (s *Store) GetContentForTagRules(ctx context.Context, userID *int) ([]models.Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var contents []models.Content
+	for _, content := range s.sortedContent() {
+		if content.DeletedAt == nil && (userID == nil || content.UserID == *userID) {
+			contents = append(contents, copyContent(content))
+		}
+	}
+	return contents, nil
+}
@@ -0,0 +1,332 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+type tag struct {
+	models.Tag
+	slug string
+	// aliases maps the slug of each alias to its spelling
+	aliases map[string]string
+}
+
+// tagView returns a tag with its aliases and usage count, as the repository lists it
+func (s *Store) tagView(t *tag) models.Tag {
+	view := t.Tag
+	view.Aliases = []string{}
+	for _, alias := range t.aliases {
+		view.Aliases = append(view.Aliases, alias)
+	}
+	sort.Strings(view.Aliases)
+	view.UsageCount = 0
+	for _, content := range s.content {
+		if content.DeletedAt == nil && hasTag(content, t.Name) {
+			view.UsageCount++
+		}
+	}
+	return view
+}
+
+func hasTag(content *models.Content, name string) bool {
+	for _, tag := range content.Tags {
+		if tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findTag returns the tag slug resolves to by name or alias
+func (s *Store) findTag(slug string) *tag {
+	for _, t := range s.tags {
+		if _, ok := t.aliases[slug]; ok || t.slug == slug {
+			return t
+		}
+	}
+	return nil
+}
+
+// slugInUse reports whether slug resolves to a tag other than exceptTagID
+func (s *Store) slugInUse(slug string, exceptTagID int) bool {
+	t := s.findTag(slug)
+	return t != nil && t.ID != exceptTagID
+}
+
+// resolveTags maps tag names to their canonical names, creating tags for
+// spellings that match nothing yet. Blank and duplicate names are dropped.
+func (s *Store) resolveTags(names []string) []string {
+	var resolved []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := repository.TagSlug(name)
+		if slug == "" {
+			continue
+		}
+
+		t := s.findTag(slug)
+		if t == nil {
+			t = s.insertTag(name, slug, nil, nil)
+		}
+		if !seen[t.Name] {
+			seen[t.Name] = true
+			resolved = append(resolved, t.Name)
+		}
+	}
+	return resolved
+}
+
+func (s *Store) insertTag(name, slug string, color, description *string) *tag {
+	now := s.now()
+	t := &tag{
+		Tag:     models.Tag{ID: s.id(), Name: name, Color: color, Description: description, CreatedAt: now, UpdatedAt: now},
+		slug:    slug,
+		aliases: make(map[string]string),
+	}
+	s.tags[t.ID] = t
+	return t
+}
+
+// insertTagAlias adds an alias to a tag. Aliases that already point at the tag
+// are ignored; ErrTagInUse is returned if it resolves to another tag.
+func (s *Store) insertTagAlias(t *tag, alias string) error {
+	alias = strings.TrimSpace(alias)
+	slug := repository.TagSlug(alias)
+	if slug == "" {
+		return nil
+	}
+	if s.slugInUse(slug, t.ID) {
+		return repository.ErrTagInUse
+	}
+	if _, ok := t.aliases[slug]; !ok && slug != t.slug {
+		t.aliases[slug] = alias
+	}
+	return nil
+}
+
+// rewriteContentTags replaces the tags named in from with to on all content,
+// including trashed content, keeping tag order and dropping duplicates. A nil
+// to removes the tags instead.
+func (s *Store) rewriteContentTags(from []string, to *string) {
+	replaced := make(map[string]bool)
+	for _, name := range from {
+		replaced[name] = true
+	}
+
+	for _, content := range s.sortedContent() {
+		var tags []string
+		seen := make(map[string]bool)
+		changed := false
+		for _, name := range content.Tags {
+			if replaced[name] {
+				changed = true
+				if to == nil {
+					continue
+				}
+				name = *to
+			}
+			if !seen[name] {
+				seen[name] = true
+				tags = append(tags, name)
+			}
+		}
+		if !changed {
+			continue
+		}
+		content.Tags, content.UpdatedAt = tags, s.now()
+		if content.DeletedAt == nil {
+			s.recordContentEvent(content, models.ContentEventUpdated)
+		}
+	}
+}
+
+func (s *Store) GetTags(ctx context.Context, search string) ([]models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := repository.TagSlug(search)
+	var tags []models.Tag
+	for _, t := range s.tags {
+		matches := strings.HasPrefix(t.slug, prefix)
+		for slug := range t.aliases {
+			matches = matches || strings.HasPrefix(slug, prefix)
+		}
+		if matches {
+			tags = append(tags, s.tagView(t))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].UsageCount != tags[j].UsageCount {
+			return tags[i].UsageCount > tags[j].UsageCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (s *Store) CreateTag(ctx context.Context, req models.TagRequest, actor models.Actor) (*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slug := repository.TagSlug(req.Name)
+	if s.slugInUse(slug, 0) {
+		return nil, repository.ErrTagInUse
+	}
+	// Aliases are checked first, so a taken one leaves no tag behind
+	for _, alias := range req.Aliases {
+		aliasSlug := repository.TagSlug(alias)
+		if aliasSlug != "" && aliasSlug != slug && s.slugInUse(aliasSlug, 0) {
+			return nil, repository.ErrTagInUse
+		}
+	}
+
+	t := s.insertTag(req.Name, slug, req.Color, req.Description)
+	for _, alias := range req.Aliases {
+		if err := s.insertTagAlias(t, alias); err != nil {
+			return nil, err
+		}
+	}
+
+	created := s.tagView(t)
+	s.audit(actor, repository.AuditTagCreated, "tag", t.ID, nil, created)
+	return &created, nil
+}
+
+func (s *Store) UpdateTag(ctx context.Context, id int, req models.TagRequest, actor models.Actor) (*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tags[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	before := s.tagView(t)
+
+	slug := repository.TagSlug(req.Name)
+	if s.slugInUse(slug, id) {
+		return nil, repository.ErrTagInUse
+	}
+
+	oldSlug := t.slug
+	t.Name, t.slug, t.Color, t.Description, t.UpdatedAt = req.Name, slug, req.Color, req.Description, s.now()
+	if req.Name != before.Name {
+		s.rewriteContentTags([]string{before.Name}, &req.Name)
+	}
+	if slug != oldSlug {
+		// The new name no longer needs an alias, the old one now does
+		delete(t.aliases, slug)
+		if err := s.insertTagAlias(t, before.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	after := s.tagView(t)
+	s.audit(actor, repository.AuditTagUpdated, "tag", id, before, after)
+	return &after, nil
+}
+
+func (s *Store) DeleteTag(ctx context.Context, id int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tags[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	deleted := s.tagView(t)
+
+	s.rewriteContentTags([]string{t.Name}, nil)
+	delete(s.tags, id)
+
+	s.audit(actor, repository.AuditTagDeleted, "tag", id, deleted, nil)
+	return nil
+}
+
+func (s *Store) AddTagAlias(ctx context.Context, id int, alias string, actor models.Actor) (*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tags[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	before := s.tagView(t)
+
+	if err := s.insertTagAlias(t, alias); err != nil {
+		return nil, err
+	}
+
+	after := s.tagView(t)
+	s.audit(actor, repository.AuditTagAliasAdded, "tag", id, before, after)
+	return &after, nil
+}
+
+func (s *Store) RemoveTagAlias(ctx context.Context, id int, alias string, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tags[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	slug := repository.TagSlug(alias)
+	removed, ok := t.aliases[slug]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delete(t.aliases, slug)
+
+	s.audit(actor, repository.AuditTagAliasRemoved, "tag", id, map[string]interface{}{"tag_id": id, "alias": removed}, nil)
+	return nil
+}
+
+func (s *Store) MergeTags(ctx context.Context, targetID int, sourceIDs []int, actor models.Actor) (*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, ok := s.tags[targetID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	targetBefore := s.tagView(target)
+
+	var sources []*tag
+	var sourceViews []models.Tag
+	for _, id := range sourceIDs {
+		if id == targetID {
+			continue
+		}
+		source, ok := s.tags[id]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		sources = append(sources, source)
+		sourceViews = append(sourceViews, s.tagView(source))
+	}
+
+	names := make([]string, len(sources))
+	for i, source := range sources {
+		names[i] = source.Name
+	}
+	s.rewriteContentTags(names, &target.Name)
+
+	for _, source := range sources {
+		for slug, alias := range source.aliases {
+			target.aliases[slug] = alias
+		}
+		target.aliases[source.slug] = source.Name
+		delete(s.tags, source.ID)
+	}
+
+	after := s.tagView(target)
+	before := map[string]interface{}{"target": targetBefore, "sources": sourceViews}
+	s.audit(actor, repository.AuditTagMerged, "tag", targetID, before, after)
+	return &after, nil
+}
@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+	"github.com/lib/pq"
+)
+
+type team struct {
+	models.Team
+	members map[int]bool
+}
+
+// view returns the team as the repository lists it
+func (t *team) view() models.Team {
+	view := t.Team
+	view.MemberCount = len(t.members)
+	return view
+}
+
+// foreignKeyViolation is what the repository returns when a referenced row is missing
+var foreignKeyViolation = &pq.Error{Code: "23503", Message: "foreign key violation"}
+
+// uniqueViolation is what the repository returns when a unique index is violated
+var uniqueViolation = &pq.Error{Code: "23505", Message: "unique violation"}
+
+func (s *Store) CreateTeam(ctx context.Context, req models.CreateTeamRequest, actor models.Actor) (*models.Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	t := &team{
+		Team:    models.Team{ID: s.id(), Name: req.Name, Description: req.Description, CreatedAt: now, UpdatedAt: now},
+		members: make(map[int]bool),
+	}
+	s.teams[t.ID] = t
+
+	created := t.view()
+	s.audit(actor, repository.AuditTeamCreated, "team", t.ID, nil, created)
+	return &created, nil
+}
+
+func (s *Store) GetTeams(ctx context.Context, memberID *int) ([]models.Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var teams []models.Team
+	for _, t := range s.teams {
+		if memberID == nil || t.members[*memberID] {
+			teams = append(teams, t.view())
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].Name != teams[j].Name {
+			return teams[i].Name < teams[j].Name
+		}
+		return teams[i].ID < teams[j].ID
+	})
+	return teams, nil
+}
+
+func (s *Store) DeleteTeam(ctx context.Context, teamID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.teams[teamID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.teams, teamID)
+
+	// The repository returns the deleted row, without the member count
+	deleted := t.Team
+	s.audit(actor, repository.AuditTeamDeleted, "team", teamID, deleted, nil)
+	return nil
+}
+
+func (s *Store) IsTeamMember(ctx context.Context, teamID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.teams[teamID]
+	return ok && t.members[userID], nil
+}
+
+func (s *Store) GetTeamMembers(ctx context.Context, teamID int) ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []models.User
+	if t, ok := s.teams[teamID]; ok {
+		for userID := range t.members {
+			if user, ok := s.users[userID]; ok {
+				users = append(users, *user)
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Username != users[j].Username {
+			return users[i].Username < users[j].Username
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+// AddTeamMember adds a user to a team; adding an existing member is a no-op.
+// Returns a foreign key violation if the team or user does not exist.
+func (s *Store) AddTeamMember(ctx context.Context, teamID, userID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.teams[teamID]
+	if _, userExists := s.users[userID]; !ok || !userExists {
+		return foreignKeyViolation
+	}
+	if t.members[userID] {
+		return nil
+	}
+	t.members[userID] = true
+
+	s.audit(actor, repository.AuditTeamMemberAdded, "team", teamID, nil, map[string]int{"user_id": userID})
+	return nil
+}
+
+func (s *Store) RemoveTeamMember(ctx context.Context, teamID, userID int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.teams[teamID]
+	if !ok || !t.members[userID] {
+		return sql.ErrNoRows
+	}
+	delete(t.members, userID)
+
+	s.audit(actor, repository.AuditTeamMemberRemoved, "team", teamID, map[string]int{"user_id": userID}, nil)
+	return nil
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+func (s *Store) GetTrashedContent(ctx context.Context, userID int) ([]models.Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var contents []models.Content
+	for _, content := range s.content {
+		if content.UserID == userID && content.DeletedAt != nil {
+			contents = append(contents, copyContent(content))
+		}
+	}
+	sort.Slice(contents, func(i, j int) bool { return contents[i].DeletedAt.After(*contents[j].DeletedAt) })
+	return contents, nil
+}
+
+func (s *Store) GetTrashedSocialAccounts(ctx context.Context, userID int) ([]models.SocialAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var accounts []models.SocialAccount
+	for _, account := range s.accounts {
+		if account.UserID == userID && account.DeletedAt != nil {
+			accounts = append(accounts, withoutTokens(*account))
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].DeletedAt.After(*accounts[j].DeletedAt) })
+	return accounts, nil
+}
+
+// RestoreContent takes content out of the trash. Returns sql.ErrNoRows if it is
+// not in the trash, or a unique violation if the same link was added again since.
+func (s *Store) RestoreContent(ctx context.Context, contentID, userID int, actor models.Actor) (*models.Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.content[contentID]
+	if !ok || content.UserID != userID || content.DeletedAt == nil {
+		return nil, sql.ErrNoRows
+	}
+	if s.findContent(userID, content.Link, false) != nil {
+		return nil, uniqueViolation
+	}
+	content.DeletedAt, content.UpdatedAt = nil, s.now()
+
+	restored := copyContent(content)
+	s.recordEvent("content", contentID, repository.AuditContentRestored, restored)
+	s.audit(actor, repository.AuditContentRestored, "content", contentID, nil, restored)
+	s.recordContentEvent(content, models.ContentEventCreated)
+	return &restored, nil
+}
+
+func (s *Store) RestoreSocialAccount(ctx context.Context, accountID, userID int, actor models.Actor) (*models.SocialAccount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[accountID]
+	if !ok || account.UserID != userID || account.DeletedAt == nil {
+		return nil, sql.ErrNoRows
+	}
+	account.DeletedAt, account.UpdatedAt = nil, s.now()
+
+	restored := withoutTokens(*account)
+	s.recordEvent("social_account", accountID, repository.AuditAccountRestored, restored)
+	s.audit(actor, repository.AuditAccountRestored, "social_account", accountID, nil, restored)
+	return &restored, nil
+}
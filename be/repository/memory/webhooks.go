@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/Armatorix/SocialTracker/be/repository"
+)
+
+// newEventID returns a random UUID, like the repository's gen_random_uuid()
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func subscribed(webhook *models.Webhook, eventType string) bool {
+	for _, event := range webhook.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueWebhookEvent queues an event for every enabled webhook subscribed to its type
+func (s *Store) enqueueWebhookEvent(eventType string, payload interface{}) {
+	var webhooks []*models.Webhook
+	for _, webhook := range s.webhooks {
+		if webhook.Enabled && subscribed(webhook, eventType) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	eventID, data := newEventID(), mustMarshal(payload)
+	for _, webhook := range webhooks {
+		s.insertDelivery(webhook.ID, eventID, eventType, data)
+	}
+}
+
+func (s *Store) insertDelivery(webhookID int, eventID, eventType string, payload []byte) *models.WebhookDelivery {
+	now := s.now()
+	delivery := &models.WebhookDelivery{
+		ID:            s.id(),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       append([]byte{}, payload...),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+	s.deliveries = append(s.deliveries, delivery)
+	return delivery
+}
+
+func copyWebhook(webhook *models.Webhook) models.Webhook {
+	copied := *webhook
+	copied.Events = append([]string{}, webhook.Events...)
+	return copied
+}
+
+func (s *Store) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var webhooks []models.Webhook
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id int) (*models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := copyWebhook(webhook)
+	return &copied, nil
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, webhook models.Webhook, actor models.Actor) (*models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook.ID = s.id()
+	webhook.Events = append([]string{}, webhook.Events...)
+	webhook.CreatedAt = s.now()
+	webhook.UpdatedAt = webhook.CreatedAt
+	stored := webhook
+	s.webhooks[webhook.ID] = &stored
+
+	s.audit(actor, repository.AuditWebhookCreated, "webhook", webhook.ID, nil, webhook)
+	return &webhook, nil
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, webhook models.Webhook, actor models.Actor) (*models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.webhooks[webhook.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	before := copyWebhook(stored)
+
+	stored.URL, stored.Enabled, stored.UpdatedAt = webhook.URL, webhook.Enabled, s.now()
+	stored.Events = append([]string{}, webhook.Events...)
+	if webhook.Secret != "" {
+		stored.Secret = webhook.Secret
+	}
+
+	after := copyWebhook(stored)
+	s.audit(actor, repository.AuditWebhookUpdated, "webhook", webhook.ID, before, after)
+	return &after, nil
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, id int, actor models.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.webhooks, id)
+	kept := s.deliveries[:0]
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID != id {
+			kept = append(kept, delivery)
+		}
+	}
+	s.deliveries = kept
+
+	s.audit(actor, repository.AuditWebhookDeleted, "webhook", id, *webhook, nil)
+	return nil
+}
+
+func (s *Store) GetWebhookDeliveries(ctx context.Context, webhookID int, status string, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range s.deliveries {
+		if delivery.WebhookID == webhookID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (s *Store) RedeliverWebhookDelivery(ctx context.Context, webhookID, deliveryID int, actor models.Actor) (*models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, original := range s.deliveries {
+		if original.ID != deliveryID || original.WebhookID != webhookID {
+			continue
+		}
+		delivery := s.insertDelivery(webhookID, original.EventID, original.EventType, original.Payload)
+
+		after := map[string]interface{}{"delivery_id": delivery.ID, "redelivery_of": deliveryID, "event_id": delivery.EventID}
+		s.audit(actor, repository.AuditWebhookRedelivered, "webhook", webhookID, nil, after)
+		copied := *delivery
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// Notification operations
+
+// digestFrequencyColumn is a user's digest frequency, defaulting to daily for
+// admins and managers and weekly for creators
+const digestFrequencyColumn = `COALESCE(p.digest_frequency, CASE WHEN u.role IN ('admin', 'manager') THEN 'daily' ELSE 'weekly' END)`
+
+// notificationRecipientQuery selects users with their preferences, or the
+// defaults when they have none
+const notificationRecipientQuery = `
+	SELECT u.id, u.user_id, u.email, u.username, u.role, u.created_at, u.updated_at,
+	       COALESCE(p.email_enabled, TRUE), p.slack_webhook_url, ` + digestFrequencyColumn + `,
+	       COALESCE(p.alerts_enabled, TRUE), p.updated_at
+	FROM users u
+	LEFT JOIN notification_preferences p ON p.user_id = u.id
+`
+
+func scanNotificationRecipient(row scanner) (*models.NotificationRecipient, error) {
+	var recipient models.NotificationRecipient
+	prefs := &recipient.Preferences
+	err := row.Scan(&recipient.ID, &recipient.UserID, &recipient.Email, &recipient.Username, &recipient.Role,
+		&recipient.CreatedAt, &recipient.UpdatedAt,
+		&prefs.EmailEnabled, &prefs.SlackWebhookURL, &prefs.DigestFrequency, &prefs.AlertsEnabled, &prefs.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	prefs.UserID = recipient.ID
+	prefs.SlackEnabled = prefs.SlackWebhookURL != nil
+	return &recipient, nil
+}
+
+// GetNotificationRecipient returns a user with their notification preferences
+func (r *Repository) GetNotificationRecipient(ctx context.Context, userID int) (*models.NotificationRecipient, error) {
+	return scanNotificationRecipient(r.db.QueryRowContext(ctx, notificationRecipientQuery+` WHERE u.id = $1`, userID))
+}
+
+// GetDigestRecipients returns the users who receive digests at the given
+// frequency and have not been sent the digest identified by key yet
+func (r *Repository) GetDigestRecipients(ctx context.Context, frequency, key string) ([]models.NotificationRecipient, error) {
+	rows, err := r.db.QueryContext(ctx, notificationRecipientQuery+`
+		WHERE `+digestFrequencyColumn+` = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.user_id = u.id AND n.kind = $2 AND n.dedupe_key = $3 AND n.status <> 'failed'
+		  )
+		ORDER BY u.id
+	`, frequency, models.NotificationDigest, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []models.NotificationRecipient
+	for rows.Next() {
+		recipient, err := scanNotificationRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *recipient)
+	}
+	return recipients, rows.Err()
+}
+
+// UpdateNotificationPreferences changes a user's notification preferences
+func (r *Repository) UpdateNotificationPreferences(ctx context.Context, userID int, req models.UpdateNotificationPreferencesRequest, actor models.Actor) (*models.NotificationPreferences, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	recipient, err := scanNotificationRecipient(tx.QueryRowContext(ctx, notificationRecipientQuery+` WHERE u.id = $1 FOR UPDATE OF u`, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	before := recipient.Preferences
+	after := before
+	if req.EmailEnabled != nil {
+		after.EmailEnabled = *req.EmailEnabled
+	}
+	if req.SlackWebhookURL != nil {
+		after.SlackWebhookURL = req.SlackWebhookURL
+		if *req.SlackWebhookURL == "" {
+			after.SlackWebhookURL = nil
+		}
+		after.SlackEnabled = after.SlackWebhookURL != nil
+	}
+	if req.DigestFrequency != nil {
+		after.DigestFrequency = *req.DigestFrequency
+	}
+	if req.AlertsEnabled != nil {
+		after.AlertsEnabled = *req.AlertsEnabled
+	}
+
+	var updatedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO notification_preferences (user_id, email_enabled, slack_webhook_url, digest_frequency, alerts_enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email_enabled = EXCLUDED.email_enabled, slack_webhook_url = EXCLUDED.slack_webhook_url,
+		    digest_frequency = EXCLUDED.digest_frequency, alerts_enabled = EXCLUDED.alerts_enabled,
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at
+	`, userID, after.EmailEnabled, after.SlackWebhookURL, after.DigestFrequency, after.AlertsEnabled).Scan(&updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	after.UpdatedAt = &updatedAt
+
+	err = insertAuditEvent(ctx, tx, actor, AuditNotificationPreferencesUpdated, "user", userID, before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// GetDigest summarises new content and syncs between from and to, per creator
+// viewer may see. At most postsPerCreator of each creator's newest posts are
+// included.
+func (r *Repository) GetDigest(ctx context.Context, viewer *models.User, frequency string, from, to time.Time, postsPerCreator int) (*models.Digest, error) {
+	creators := make(map[int]*models.DigestCreator)
+	creator := func(userID int, username string) *models.DigestCreator {
+		if c, ok := creators[userID]; ok {
+			return c
+		}
+		c := &models.DigestCreator{UserID: userID, Username: username, Posts: []models.Content{}}
+		creators[userID] = c
+		return c
+	}
+
+	scope, scopeArgs := visibleUsersCondition(viewer, "c.user_id", 3)
+	args := append([]interface{}{from, to}, scopeArgs...)
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, user_id, social_account_id, platform, link, original_text, description, tags,
+		       external_post_id, posted_at, paid_partnership, created_at, updated_at, username, post_count
+		FROM (
+			SELECT c.id, c.user_id, c.social_account_id, c.platform, c.link, c.original_text, c.description, c.tags,
+			       c.external_post_id, c.posted_at, c.paid_partnership, c.created_at, c.updated_at, u.username,
+			       COUNT(*) OVER (PARTITION BY c.user_id) AS post_count,
+			       ROW_NUMBER() OVER (PARTITION BY c.user_id ORDER BY c.created_at DESC, c.id DESC) AS recency
+			FROM content c
+			JOIN users u ON u.id = c.user_id
+			WHERE c.deleted_at IS NULL AND c.created_at >= $1 AND c.created_at < $2%s
+		) recent
+		WHERE recency <= $%d
+		ORDER BY user_id, recency
+	`, scope, len(args)+1), append(args, postsPerCreator)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var content models.Content
+		var username string
+		var postCount int
+		err := rows.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt,
+			&content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt, &username, &postCount)
+		if err != nil {
+			return nil, err
+		}
+		c := creator(content.UserID, username)
+		c.PostCount = postCount
+		c.Posts = append(c.Posts, content)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scope, scopeArgs = visibleUsersCondition(viewer, "s.user_id", 3)
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT s.user_id, u.username, COUNT(*), COUNT(*) FILTER (WHERE s.status = 'failed'),
+		       (ARRAY_AGG(s.error ORDER BY s.created_at DESC) FILTER (WHERE s.status = 'failed'))[1]
+		FROM sync_runs s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.created_at >= $1 AND s.created_at < $2`+scope+`
+		GROUP BY s.user_id, u.username
+	`, append([]interface{}{from, to}, scopeArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, syncs, failed int
+		var username string
+		var lastError *string
+		if err := rows.Scan(&userID, &username, &syncs, &failed, &lastError); err != nil {
+			return nil, err
+		}
+		c := creator(userID, username)
+		c.SyncCount, c.FailedSyncs, c.LastSyncError = syncs, failed, lastError
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	digest := &models.Digest{Frequency: frequency, From: from, To: to, Creators: []models.DigestCreator{}}
+	for _, c := range creators {
+		digest.Creators = append(digest.Creators, *c)
+	}
+	sort.Slice(digest.Creators, func(i, j int) bool {
+		return digest.Creators[i].Username < digest.Creators[j].Username
+	})
+	return digest, nil
+}
+
+// RecordSyncRun stores the outcome of an account sync. A failed sync also
+// queues a sync.failed webhook event.
+func (r *Repository) RecordSyncRun(ctx context.Context, run models.SyncRun) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	event := models.SyncFailedEvent{AccountID: run.SocialAccountID, UserID: run.UserID}
+	err = tx.QueryRowContext(ctx, `SELECT platform, account_name FROM social_accounts WHERE id = $1`, run.SocialAccountID).
+		Scan(&event.Platform, &event.AccountName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sync_runs (social_account_id, user_id, status, synced_count, skipped_count, error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, run.SocialAccountID, run.UserID, run.Status, run.SyncedCount, run.SkippedCount, run.Error)
+	if err != nil {
+		return err
+	}
+
+	if run.Status == models.SyncRunFailed {
+		if run.Error != nil {
+			event.Error = *run.Error
+		}
+		if err := enqueueWebhookEvent(ctx, tx, models.WebhookEventSyncFailed, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PruneSyncRuns deletes sync runs recorded before the cutoff
+func (r *Repository) PruneSyncRuns(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sync_runs WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetAccountsWithExpiringTokens returns accounts whose OAuth access token
+// expires before the given time and cannot be refreshed
+func (r *Repository) GetAccountsWithExpiringTokens(ctx context.Context, before time.Time) ([]models.SocialAccount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, platform, account_name, account_id, token_expires_at, last_pull_at, created_at, updated_at
+		FROM social_accounts
+		WHERE deleted_at IS NULL
+		  AND COALESCE(access_token, '') <> '' AND COALESCE(refresh_token, '') = ''
+		  AND token_expires_at < $1
+		ORDER BY id
+	`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.SocialAccount
+	for rows.Next() {
+		var account models.SocialAccount
+		err := rows.Scan(&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID,
+			&account.TokenExpiresAt, &account.LastPullAt, &account.CreatedAt, &account.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// ClaimNotification records that a notification is about to be sent. It
+// reports false when the same notification was already sent or is being
+// sent; one that failed before may be claimed again.
+func (r *Repository) ClaimNotification(ctx context.Context, userID int, kind, key, subject string) (int, bool, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO notifications (user_id, kind, dedupe_key, subject)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, kind, dedupe_key) DO UPDATE
+		SET status = 'pending', subject = EXCLUDED.subject, error = NULL, created_at = CURRENT_TIMESTAMP
+		WHERE notifications.status = 'failed'
+		RETURNING id
+	`, userID, kind, key, subject).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// FinishNotification records whether a claimed notification was sent. A sent
+// notification may still carry an error when some of its channels failed.
+func (r *Repository) FinishNotification(ctx context.Context, id int, sent bool, sendErr error) error {
+	status, sentAt := models.NotificationFailed, (*time.Time)(nil)
+	if sent {
+		now := time.Now()
+		status, sentAt = models.NotificationSent, &now
+	}
+	var errMsg *string
+	if sendErr != nil {
+		msg := sendErr.Error()
+		errMsg = &msg
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET status = $2, error = $3, sent_at = $4 WHERE id = $1`, id, status, errMsg, sentAt)
+	return err
+}
+
+// GetNotifications returns a user's most recent notifications, newest first
+func (r *Repository) GetNotifications(ctx context.Context, userID, limit int) ([]models.Notification, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, kind, dedupe_key, subject, status, error, created_at, sent_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Key, &n.Subject, &n.Status, &n.Error, &n.CreatedAt, &n.SentAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// outboxLockKey is the advisory lock that keeps a single relay publishing at a time
+const outboxLockKey = 0x6f7574626f78
+
+// recordEvent writes an outbox event for a content or account mutation and
+// queues deliveries for the webhooks subscribed to it. It is meant to run in the
+// same transaction as the mutation, so the event exists if and only if the
+// change was committed.
+func recordEvent(ctx context.Context, db execer, aggregateType string, aggregateID int, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+	`, aggregateType, aggregateID, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	return enqueueWebhookEvent(ctx, db, eventType, payload)
+}
+
+// RelayOutbox publishes up to limit due outbox events, oldest first. Only the
+// oldest unpublished event of each aggregate is eligible, so an aggregate's
+// events are published in order and a failing event holds back the ones after
+// it. Failed events are retried with backoff. Publishing happens inside the
+// transaction that marks events published, so a crash in between publishes
+// them again: delivery is at least once. Returns 0 if another relay holds the lock.
+func (r *Repository) RelayOutbox(ctx context.Context, limit int, publish func(models.OutboxEvent) error) (published int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxLockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, aggregate_type, aggregate_id, event_type, payload, attempts, created_at
+		FROM (
+			SELECT DISTINCT ON (aggregate_type, aggregate_id) *
+			FROM outbox
+			WHERE published_at IS NULL
+			ORDER BY aggregate_type, aggregate_id, id
+		) head
+		WHERE next_attempt_at <= CURRENT_TIMESTAMP
+		ORDER BY id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.AggregateType, &event.AggregateID, &event.EventType, &payload, &event.Attempts, &event.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if publishErr := publish(event); publishErr != nil {
+			// Back off 5s, 10s, 20s, ... up to an hour; events are never dropped
+			_, err = tx.ExecContext(ctx, `
+				UPDATE outbox
+				SET attempts = attempts + 1, last_error = $2,
+				    next_attempt_at = CURRENT_TIMESTAMP + LEAST(INTERVAL '1 hour', INTERVAL '5 seconds' * power(2, LEAST(attempts, 20)))
+				WHERE id = $1
+			`, event.ID, publishErr.Error())
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE outbox SET attempts = attempts + 1, last_error = NULL, published_at = CURRENT_TIMESTAMP
+				WHERE id = $1
+			`, event.ID)
+			published++
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, nil
+}
+
+// PruneOutbox deletes events that were published before the cutoff
+func (r *Repository) PruneOutbox(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
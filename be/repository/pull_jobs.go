@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// ErrPullJobLost is returned when recording the outcome of a job whose claim
+// ran out and that another worker has claimed since
+var ErrPullJobLost = errors.New("pull job was claimed by another worker")
+
+const pullJobColumns = `j.id, j.user_id, j.social_account_id, j.actor_id, j.impersonator_id, j.status, j.attempts, j.run_at,
+	j.fetched_count, j.processed_count, j.result, j.last_error, j.started_at, j.finished_at, j.created_at, j.updated_at, j.lease_expires_at`
+
+func scanPullJob(row scanner) (*models.PullJob, error) {
+	var job models.PullJob
+	var result []byte
+	err := row.Scan(&job.ID, &job.UserID, &job.SocialAccountID, &job.Actor.UserID, &job.Actor.ImpersonatorID, &job.Status,
+		&job.Attempts, &job.RunAt, &job.Progress.Fetched, &job.Progress.Processed, &result, &job.Error,
+		&job.StartedAt, &job.FinishedAt, &job.CreatedAt, &job.UpdatedAt, &job.LeaseExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		job.Result = &models.SyncResponse{}
+		if err := json.Unmarshal(result, job.Result); err != nil {
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+// EnqueuePullJob queues a pull of an account. If a pull of the account is
+// already queued or running, that job is returned instead.
+func (r *Repository) EnqueuePullJob(ctx context.Context, userID, accountID int, actor models.Actor) (*models.PullJob, error) {
+	job, err := scanPullJob(r.db.QueryRowContext(ctx, `
+		INSERT INTO pull_jobs AS j (user_id, social_account_id, actor_id, impersonator_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (social_account_id) WHERE status IN ('queued', 'running') DO NOTHING
+		RETURNING `+pullJobColumns, userID, accountID, actor.UserID, actor.ImpersonatorID))
+	if err != sql.ErrNoRows {
+		return job, err
+	}
+
+	return scanPullJob(r.db.QueryRowContext(ctx, `
+		SELECT `+pullJobColumns+`
+		FROM pull_jobs j
+		WHERE j.social_account_id = $1 AND j.status IN ('queued', 'running')
+	`, accountID))
+}
+
+// GetPullJob returns a job of the user. Returns sql.ErrNoRows if it does not
+// exist or belongs to someone else.
+func (r *Repository) GetPullJob(ctx context.Context, jobID, userID int) (*models.PullJob, error) {
+	return scanPullJob(r.db.QueryRowContext(ctx, `
+		SELECT `+pullJobColumns+`
+		FROM pull_jobs j
+		WHERE j.id = $1 AND j.user_id = $2
+	`, jobID, userID))
+}
+
+// ClaimPullJobs picks up to limit due jobs and marks them running. A claimed
+// job is due again once lease has passed, so jobs of a crashed worker are not
+// lost and concurrent workers do not share them.
+func (r *Repository) ClaimPullJobs(ctx context.Context, limit int, lease time.Duration) ([]models.PullJob, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE pull_jobs j
+		SET status = 'running', attempts = j.attempts + 1, lease_expires_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second',
+		    started_at = COALESCE(j.started_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+		WHERE j.id IN (
+			SELECT id FROM pull_jobs
+			WHERE (status = 'queued' AND run_at <= CURRENT_TIMESTAMP)
+			   OR (status = 'running' AND lease_expires_at <= CURRENT_TIMESTAMP)
+			ORDER BY run_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+pullJobColumns, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.PullJob
+	for rows.Next() {
+		job, err := scanPullJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// UpdatePullJobProgress records how far a running job has come. job is the
+// job as claimed; ErrPullJobLost is returned if it has been claimed again since.
+func (r *Repository) UpdatePullJobProgress(ctx context.Context, job models.PullJob, progress models.PullJobProgress) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pull_jobs
+		SET fetched_count = $3, processed_count = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND lease_expires_at = $2
+	`, job.ID, job.LeaseExpiresAt, progress.Fetched, progress.Processed)
+	return claimHeld(result, err, ErrPullJobLost)
+}
+
+// CompletePullJob records the result of a successful job. job is the job as
+// claimed; ErrPullJobLost is returned if it has been claimed again since.
+func (r *Repository) CompletePullJob(ctx context.Context, job models.PullJob, response models.SyncResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pull_jobs
+		SET status = 'succeeded', result = $3, last_error = NULL, lease_expires_at = NULL,
+		    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND lease_expires_at = $2
+	`, job.ID, job.LeaseExpiresAt, data)
+	return claimHeld(result, err, ErrPullJobLost)
+}
+
+// FailPullJob records a failed attempt. The job is queued again to run after
+// retryAfter, or marked failed when retryAfter is not positive. A job queued
+// again gets its claim back, so that deferring it does not count towards the
+// attempts that bound jobs lost by crashed workers. job is the job as claimed;
+// ErrPullJobLost is returned if it has been claimed again since.
+func (r *Repository) FailPullJob(ctx context.Context, job models.PullJob, errMsg string, retryAfter time.Duration) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE pull_jobs
+		SET status = CASE WHEN $4 > 0 THEN 'queued' ELSE 'failed' END,
+		    attempts = CASE WHEN $4 > 0 THEN GREATEST(attempts - 1, 0) ELSE attempts END,
+		    run_at = CASE WHEN $4 > 0 THEN CURRENT_TIMESTAMP + $4 * INTERVAL '1 second' ELSE run_at END,
+		    finished_at = CASE WHEN $4 > 0 THEN NULL ELSE CURRENT_TIMESTAMP END,
+		    last_error = $3, lease_expires_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running' AND lease_expires_at = $2
+	`, job.ID, job.LeaseExpiresAt, errMsg, retryAfter.Seconds())
+	return claimHeld(result, err, ErrPullJobLost)
+}
+
+// claimHeld turns an update of a claimed row that matched no row into lost
+func claimHeld(result sql.Result, err error, lost error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return lost
+	}
+	return nil
+}
+
+// PrunePullJobs deletes jobs that finished before the cutoff
+func (r *Repository) PrunePullJobs(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM pull_jobs WHERE finished_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/Armatorix/SocialTracker/be/entities"
 	"github.com/Armatorix/SocialTracker/be/models"
 	"github.com/lib/pq"
 )
@@ -18,9 +21,9 @@ func NewRepository(db *sql.DB) *Repository {
 }
 
 // User operations
-func (r *Repository) GetOrCreateUser(userID, email, username string) (*models.User, error) {
+func (r *Repository) GetOrCreateUser(ctx context.Context, userID, email, username string) (*models.User, error) {
 	var user models.User
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO users (user_id, email, username, role)
 		VALUES ($1, $2, $3, 'creator')
 		ON CONFLICT (user_id) DO UPDATE SET email = $2, username = $3, updated_at = CURRENT_TIMESTAMP
@@ -33,9 +36,9 @@ func (r *Repository) GetOrCreateUser(userID, email, username string) (*models.Us
 	return &user, nil
 }
 
-func (r *Repository) GetUserByUserID(userID string) (*models.User, error) {
+func (r *Repository) GetUserByUserID(ctx context.Context, userID string) (*models.User, error) {
 	var user models.User
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT id, user_id, email, username, role, created_at, updated_at
 		FROM users WHERE user_id = $1
 	`, userID).Scan(&user.ID, &user.UserID, &user.Email, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt)
@@ -46,10 +49,129 @@ func (r *Repository) GetUserByUserID(userID string) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUserByID retrieves a user by internal ID
+func (r *Repository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, email, username, role, created_at, updated_at
+		FROM users WHERE id = $1
+	`, id).Scan(&user.ID, &user.UserID, &user.Email, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns the users viewer may see, ordered by username
+func (r *Repository) ListUsers(ctx context.Context, viewer *models.User) ([]models.User, error) {
+	scope, args := visibleUsersCondition(viewer, "id", 1)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, email, username, role, created_at, updated_at
+		FROM users
+		WHERE 1=1`+scope+`
+		ORDER BY username, id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(&user.ID, &user.UserID, &user.Email, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateUserRole changes a user's role and records the change in role_changes
+// and the audit log. actor has no user when the change did not come from an
+// admin (e.g. group mapping). If the user already has the role nothing is written.
+func (r *Repository) UpdateUserRole(ctx context.Context, id int, role string, actor models.Actor, source string) (*models.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldRole string
+	err = tx.QueryRowContext(ctx, `SELECT role FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&oldRole)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users SET role = $1, updated_at = CASE WHEN role = $1 THEN updated_at ELSE CURRENT_TIMESTAMP END
+		WHERE id = $2
+		RETURNING id, user_id, email, username, role, created_at, updated_at
+	`, role, id).Scan(&user.ID, &user.UserID, &user.Email, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldRole != role {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO role_changes (user_id, old_role, new_role, changed_by, source)
+			VALUES ($1, $2, $3, $4, $5)
+		`, id, oldRole, role, actor.UserID, source)
+		if err != nil {
+			return nil, err
+		}
+
+		err = insertAuditEvent(ctx, tx, actor, AuditUserRoleChanged, "user", id,
+			map[string]string{"role": oldRole},
+			map[string]string{"role": role, "source": source})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetRoleChanges returns the role change history for a user, newest first
+func (r *Repository) GetRoleChanges(ctx context.Context, userID int) ([]models.RoleChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, old_role, new_role, changed_by, source, created_at
+		FROM role_changes WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.RoleChange
+	for rows.Next() {
+		var change models.RoleChange
+		err := rows.Scan(&change.ID, &change.UserID, &change.OldRole, &change.NewRole, &change.ChangedBy, &change.Source, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
 // Social Account operations
-func (r *Repository) CreateSocialAccount(userID int, req models.CreateSocialAccountRequest) (*models.SocialAccount, error) {
+func (r *Repository) CreateSocialAccount(ctx context.Context, userID int, req models.CreateSocialAccountRequest, actor models.Actor) (*models.SocialAccount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var account models.SocialAccount
-	err := r.db.QueryRow(`
+	err = tx.QueryRowContext(ctx, `
 		INSERT INTO social_accounts (user_id, platform, account_name, account_id, access_token, refresh_token)
 		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, user_id, platform, account_name, account_id, last_pull_at, created_at, updated_at
@@ -59,13 +181,25 @@ func (r *Repository) CreateSocialAccount(userID int, req models.CreateSocialAcco
 	if err != nil {
 		return nil, err
 	}
+
+	if err := recordEvent(ctx, tx, "social_account", account.ID, AuditAccountConnected, account); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditAccountConnected, "social_account", account.ID, nil, account); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &account, nil
 }
 
-func (r *Repository) GetSocialAccountsByUserID(userID int) ([]models.SocialAccount, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetSocialAccountsByUserID(ctx context.Context, userID int) ([]models.SocialAccount, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, user_id, platform, account_name, account_id, last_pull_at, created_at, updated_at
-		FROM social_accounts WHERE user_id = $1
+		FROM social_accounts WHERE user_id = $1 AND deleted_at IS NULL
 		ORDER BY created_at DESC
 	`, userID)
 	if err != nil {
@@ -85,43 +219,58 @@ func (r *Repository) GetSocialAccountsByUserID(userID int) ([]models.SocialAccou
 	return accounts, nil
 }
 
-func (r *Repository) DeleteSocialAccount(accountID, userID int) error {
-	result, err := r.db.Exec(`
-		DELETE FROM social_accounts WHERE id = $1 AND user_id = $2
-	`, accountID, userID)
+// DeleteSocialAccount moves a social account to the trash. Its content is kept.
+func (r *Repository) DeleteSocialAccount(ctx context.Context, accountID, userID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	
-	rows, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	var account models.SocialAccount
+	err = tx.QueryRowContext(ctx, `
+		UPDATE social_accounts SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		RETURNING id, user_id, platform, account_name, account_id, token_expires_at, last_pull_at, created_at, updated_at, deleted_at
+	`, accountID, userID).Scan(&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID,
+		&account.TokenExpiresAt, &account.LastPullAt, &account.CreatedAt, &account.UpdatedAt, &account.DeletedAt)
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return sql.ErrNoRows
+
+	if err := recordEvent(ctx, tx, "social_account", account.ID, AuditAccountDisconnected, account); err != nil {
+		return err
 	}
-	return nil
-}
 
-func (r *Repository) UpdateSocialAccountLastPull(accountID, userID int) error {
-	_, err := r.db.Exec(`
-		UPDATE social_accounts SET last_pull_at = $1, updated_at = $1
-		WHERE id = $2 AND user_id = $3
-	`, time.Now(), accountID, userID)
-	return err
+	if err := insertAuditEvent(ctx, tx, actor, AuditAccountDisconnected, "social_account", account.ID, account, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Content operations
-func (r *Repository) CreateContent(userID int, req models.CreateContentRequest) (*models.Content, error) {
+func (r *Repository) CreateContent(ctx context.Context, userID int, req models.CreateContentRequest, actor models.Actor) (*models.Content, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tags, err := resolveTags(ctx, tx, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
 	var content models.Content
-	err := r.db.QueryRow(`
-		INSERT INTO content (user_id, social_account_id, platform, link, original_text, description, tags)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (user_id, link) DO NOTHING
-		RETURNING id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, created_at, updated_at
-	`, userID, req.SocialAccountID, req.Platform, req.Link, req.OriginalText, req.Description, pq.Array(req.Tags)).
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO content (user_id, social_account_id, platform, link, original_text, description, tags, paid_partnership)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, link) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, paid_partnership, created_at, updated_at
+	`, userID, req.SocialAccountID, req.Platform, req.Link, req.OriginalText, req.Description, pq.Array(tags), req.PaidPartnership).
 		Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link, 
-			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.CreatedAt, &content.UpdatedAt)
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt)
 	
 	if err == sql.ErrNoRows {
 		// Duplicate content, return nil without error
@@ -130,15 +279,50 @@ func (r *Repository) CreateContent(userID int, req models.CreateContentRequest)
 	if err != nil {
 		return nil, err
 	}
+
+	ents := entities.Extract(contentText(content.OriginalText, content.Description))
+	if err := replaceContentEntities(ctx, tx, content.ID, ents); err != nil {
+		return nil, err
+	}
+	setContentEntities(&content, ents)
+
+	if err := autoAttachCampaigns(ctx, tx, nil, &content.ID); err != nil {
+		return nil, err
+	}
+
+	if _, err := evaluateCompliance(ctx, tx, content.ID); err != nil {
+		return nil, err
+	}
+
+	if err := recordEvent(ctx, tx, "content", content.ID, AuditContentCreated, content); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditContentCreated, "content", content.ID, nil, content); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &content, nil
 }
 
-func (r *Repository) GetContentByUserID(userID int) ([]models.Content, error) {
-	rows, err := r.db.Query(`
-		SELECT id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, created_at, updated_at
-		FROM content WHERE user_id = $1
-		ORDER BY COALESCE(posted_at, created_at) DESC
-	`, userID)
+// GetContentByUserID lists a user's content. Supported filters are hashtag, mention and cashtag,
+// and the relation filters.
+func (r *Repository) GetContentByUserID(ctx context.Context, userID int, filters map[string]string) ([]models.Content, error) {
+	query := `
+		SELECT c.id, c.user_id, c.social_account_id, c.platform, c.link, c.original_text, c.description, c.tags,
+		       c.external_post_id, c.posted_at, c.paid_partnership, c.created_at, c.updated_at,
+		       c.edit_history_ids, c.edited_at, c.removed_on_platform_at,
+		       c.conversation_id, c.relation, c.parent_external_id, c.parent_content_id,` + contentEntityColumns + `
+		FROM content c WHERE c.user_id = $1 AND c.deleted_at IS NULL
+	`
+
+	conditions, args := entityFilterConditions(filters, "c.id", 2)
+	query += conditions + relationFilterConditions(filters, 2) + " ORDER BY COALESCE(c.posted_at, c.created_at) DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{userID}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -148,26 +332,45 @@ func (r *Repository) GetContentByUserID(userID int) ([]models.Content, error) {
 	for rows.Next() {
 		var content models.Content
 		err := rows.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
-			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.CreatedAt, &content.UpdatedAt)
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt,
+			pq.Array(&content.EditHistoryIDs), &content.EditedAt, &content.RemovedOnPlatformAt,
+			&content.ConversationID, &content.Relation, &content.ParentExternalID, &content.ParentContentID,
+			pq.Array(&content.Hashtags), pq.Array(&content.Mentions), pq.Array(&content.Cashtags), pq.Array(&content.URLs))
 		if err != nil {
 			return nil, err
 		}
 		contents = append(contents, content)
 	}
+
+	ids := make([]int, len(contents))
+	for i := range contents {
+		ids[i] = contents[i].ID
+	}
+	media, err := r.loadContentMedia(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range contents {
+		contents[i].Media = media[contents[i].ID]
+	}
 	return contents, nil
 }
 
-func (r *Repository) GetAllContent(filters map[string]string) ([]models.ContentWithUser, error) {
+// GetAllContent returns content across users, limited to what viewer may see
+func (r *Repository) GetAllContent(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.ContentWithUser, error) {
 	query := `
 		SELECT c.id, c.user_id, c.social_account_id, c.platform, c.link, c.original_text, 
-		       c.description, c.tags, c.external_post_id, c.posted_at, c.created_at, c.updated_at, u.username, u.email
+		       c.description, c.tags, c.external_post_id, c.posted_at, c.paid_partnership, c.created_at, c.updated_at, u.username, u.email,
+		       c.edit_history_ids, c.edited_at, c.removed_on_platform_at,
+		       c.conversation_id, c.relation, c.parent_external_id, c.parent_content_id,` + contentEntityColumns + `
 		FROM content c
 		JOIN users u ON c.user_id = u.id
-		WHERE 1=1
+		WHERE c.deleted_at IS NULL
 	`
 	
-	var args []interface{}
-	argCount := 1
+	scope, args := visibleUsersCondition(viewer, "c.user_id", 1)
+	query += scope
+	argCount := len(args) + 1
 	
 	if platform, ok := filters["platform"]; ok && platform != "" {
 		query += fmt.Sprintf(" AND c.platform = $%d", argCount)
@@ -180,10 +383,14 @@ func (r *Repository) GetAllContent(filters map[string]string) ([]models.ContentW
 		args = append(args, "%"+username+"%")
 		argCount++
 	}
+
+	conditions, entityArgs := entityFilterConditions(filters, "c.id", argCount)
+	query += conditions + relationFilterConditions(filters, argCount)
+	args = append(args, entityArgs...)
 	
 	query += " ORDER BY COALESCE(c.posted_at, c.created_at) DESC"
 	
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -193,40 +400,148 @@ func (r *Repository) GetAllContent(filters map[string]string) ([]models.ContentW
 	for rows.Next() {
 		var content models.ContentWithUser
 		err := rows.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
-			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.CreatedAt, &content.UpdatedAt,
-			&content.Username, &content.Email)
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt,
+			&content.Username, &content.Email,
+			pq.Array(&content.EditHistoryIDs), &content.EditedAt, &content.RemovedOnPlatformAt,
+			&content.ConversationID, &content.Relation, &content.ParentExternalID, &content.ParentContentID,
+			pq.Array(&content.Hashtags), pq.Array(&content.Mentions), pq.Array(&content.Cashtags), pq.Array(&content.URLs))
 		if err != nil {
 			return nil, err
 		}
 		contents = append(contents, content)
 	}
+
+	ids := make([]int, len(contents))
+	for i := range contents {
+		ids[i] = contents[i].ID
+	}
+	media, err := r.loadContentMedia(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range contents {
+		contents[i].Media = media[contents[i].ID]
+	}
 	return contents, nil
 }
 
-func (r *Repository) DeleteContent(contentID, userID int) error {
-	result, err := r.db.Exec(`
-		DELETE FROM content WHERE id = $1 AND user_id = $2
-	`, contentID, userID)
+// DeleteContent moves content to the trash
+func (r *Repository) DeleteContent(ctx context.Context, contentID, userID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	
-	rows, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	var content models.Content
+	err = tx.QueryRowContext(ctx, `
+		UPDATE content SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		RETURNING id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, paid_partnership, created_at, updated_at, deleted_at
+	`, contentID, userID).Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+		&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt, &content.DeletedAt)
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return sql.ErrNoRows
+
+	if err := recordEvent(ctx, tx, "content", content.ID, AuditContentDeleted, content); err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditContentDeleted, "content", content.ID, content, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ErrSyncedContentText is returned when editing the text of content that was synced from a platform
+var ErrSyncedContentText = errors.New("original text of synced content cannot be edited")
+
+// UpdateContent edits a user's content, re-extracting its entities and
+// re-checking campaign attachment and disclosure compliance.
+// Returns sql.ErrNoRows if the content does not exist.
+func (r *Repository) UpdateContent(ctx context.Context, contentID, userID int, req models.UpdateContentRequest, actor models.Actor) (*models.Content, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var before models.Content
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, paid_partnership, created_at, updated_at
+		FROM content WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, contentID, userID).Scan(&before.ID, &before.UserID, &before.SocialAccountID, &before.Platform, &before.Link,
+		&before.OriginalText, &before.Description, pq.Array(&before.Tags), &before.ExternalPostID, &before.PostedAt, &before.PaidPartnership, &before.CreatedAt, &before.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	after := before
+	if req.OriginalText != nil {
+		if before.ExternalPostID != nil {
+			return nil, ErrSyncedContentText
+		}
+		after.OriginalText = req.OriginalText
+	}
+	if req.Description != nil {
+		after.Description = req.Description
+	}
+	if req.Tags != nil {
+		if after.Tags, err = resolveTags(ctx, tx, *req.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if req.PaidPartnership != nil {
+		after.PaidPartnership = *req.PaidPartnership
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE content
+		SET original_text = $2, description = $3, tags = $4, paid_partnership = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at
+	`, contentID, after.OriginalText, after.Description, pq.Array(after.Tags), after.PaidPartnership).Scan(&after.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	ents := entities.Extract(contentText(after.OriginalText, after.Description))
+	if err := replaceContentEntities(ctx, tx, contentID, ents); err != nil {
+		return nil, err
+	}
+	setContentEntities(&after, ents)
+
+	if err := autoAttachCampaigns(ctx, tx, nil, &contentID); err != nil {
+		return nil, err
+	}
+
+	if _, err := evaluateCompliance(ctx, tx, contentID); err != nil {
+		return nil, err
+	}
+
+	if err := recordEvent(ctx, tx, "content", contentID, AuditContentUpdated, after); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditContentUpdated, "content", contentID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
-	return nil
+	return &after, nil
 }
 
 // GetSocialAccountByID retrieves a social account by ID and user ID
-func (r *Repository) GetSocialAccountByID(accountID, userID int) (*models.SocialAccount, error) {
+func (r *Repository) GetSocialAccountByID(ctx context.Context, accountID, userID int) (*models.SocialAccount, error) {
 	var account models.SocialAccount
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT id, user_id, platform, account_name, account_id, access_token, refresh_token, token_expires_at, last_pull_at, created_at, updated_at
-		FROM social_accounts WHERE id = $1 AND user_id = $2
+		FROM social_accounts WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 	`, accountID, userID).Scan(
 		&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID,
 		&account.AccessToken, &account.RefreshToken, &account.TokenExpiresAt, &account.LastPullAt,
@@ -239,11 +554,11 @@ func (r *Repository) GetSocialAccountByID(accountID, userID int) (*models.Social
 }
 
 // GetSocialAccountByPlatformAndAccountID finds an account by platform and external account ID
-func (r *Repository) GetSocialAccountByPlatformAndAccountID(userID int, platform string, accountID string) (*models.SocialAccount, error) {
+func (r *Repository) GetSocialAccountByPlatformAndAccountID(ctx context.Context, userID int, platform string, accountID string) (*models.SocialAccount, error) {
 	var account models.SocialAccount
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT id, user_id, platform, account_name, account_id, access_token, refresh_token, token_expires_at, last_pull_at, created_at, updated_at
-		FROM social_accounts WHERE user_id = $1 AND platform = $2 AND account_id = $3
+		FROM social_accounts WHERE user_id = $1 AND platform = $2 AND account_id = $3 AND deleted_at IS NULL
 	`, userID, platform, accountID).Scan(
 		&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID,
 		&account.AccessToken, &account.RefreshToken, &account.TokenExpiresAt, &account.LastPullAt,
@@ -256,77 +571,81 @@ func (r *Repository) GetSocialAccountByPlatformAndAccountID(userID int, platform
 }
 
 // UpdateSocialAccountID updates the account_id field (e.g., Twitter user ID)
-func (r *Repository) UpdateSocialAccountID(accountID int, externalID string) error {
-	_, err := r.db.Exec(`
+func (r *Repository) UpdateSocialAccountID(ctx context.Context, accountID int, externalID string) error {
+	_, err := r.db.ExecContext(ctx, `
 		UPDATE social_accounts SET account_id = $1, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $2
 	`, externalID, accountID)
 	return err
 }
 
-// UpdateSocialAccountTokens updates the OAuth tokens for a social account
-func (r *Repository) UpdateSocialAccountTokens(accountID int, accessToken string, refreshToken string, expiresAt time.Time) error {
-	_, err := r.db.Exec(`
-		UPDATE social_accounts 
+// UpdateSocialAccountTokens updates the OAuth tokens for a social account.
+// The audit event only records the new expiry, never the tokens themselves.
+func (r *Repository) UpdateSocialAccountTokens(ctx context.Context, accountID int, accessToken string, refreshToken string, expiresAt time.Time, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previousExpiry *time.Time
+	err = tx.QueryRowContext(ctx, `
+		UPDATE social_accounts a
 		SET access_token = $1, refresh_token = $2, token_expires_at = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $4
-	`, accessToken, refreshToken, expiresAt, accountID)
-	return err
+		FROM social_accounts prev
+		WHERE a.id = $4 AND prev.id = a.id
+		RETURNING prev.token_expires_at
+	`, accessToken, refreshToken, expiresAt, accountID).Scan(&previousExpiry)
+	if err != nil {
+		return err
+	}
+
+	// Event consumers only learn the new expiry, never the tokens themselves
+	event := map[string]interface{}{"id": accountID, "token_expires_at": expiresAt}
+	if err := recordEvent(ctx, tx, "social_account", accountID, AuditAccountTokensUpdated, event); err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, AuditAccountTokensUpdated, "social_account", accountID,
+		map[string]*time.Time{"token_expires_at": previousExpiry},
+		map[string]time.Time{"token_expires_at": expiresAt})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // CreateSocialAccountWithTokens creates a social account with OAuth tokens
-func (r *Repository) CreateSocialAccountWithTokens(userID int, req models.CreateSocialAccountRequest, tokenExpiresAt time.Time) (*models.SocialAccount, error) {
+func (r *Repository) CreateSocialAccountWithTokens(ctx context.Context, userID int, req models.CreateSocialAccountRequest, tokenExpiresAt time.Time, actor models.Actor) (*models.SocialAccount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var account models.SocialAccount
-	err := r.db.QueryRow(`
+	err = tx.QueryRowContext(ctx, `
 		INSERT INTO social_accounts (user_id, platform, account_name, account_id, access_token, refresh_token, token_expires_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, user_id, platform, account_name, account_id, last_pull_at, created_at, updated_at
+		RETURNING id, user_id, platform, account_name, account_id, token_expires_at, last_pull_at, created_at, updated_at
 	`, userID, req.Platform, req.AccountName, req.AccountID, req.AccessToken, req.RefreshToken, tokenExpiresAt).
-		Scan(&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID, &account.LastPullAt, &account.CreatedAt, &account.UpdatedAt)
+		Scan(&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID, &account.TokenExpiresAt, &account.LastPullAt, &account.CreatedAt, &account.UpdatedAt)
 	
 	if err != nil {
 		return nil, err
 	}
-	return &account, nil
-}
 
-// CreateSyncedContent inserts synced content with external post ID, skipping duplicates by user_id + link
-func (r *Repository) CreateSyncedContent(userID int, socialAccountID int, platform string, link string, originalText string, externalPostID string, postedAt time.Time) (*models.Content, error) {
-	var content models.Content
-	err := r.db.QueryRow(`
-		INSERT INTO content (user_id, social_account_id, platform, link, original_text, external_post_id, posted_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (user_id, link) DO NOTHING
-		RETURNING id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, created_at, updated_at
-	`, userID, socialAccountID, platform, link, originalText, externalPostID, postedAt).
-		Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
-			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.CreatedAt, &content.UpdatedAt)
-	
-	if err == sql.ErrNoRows {
-		// Duplicate, return nil without error
-		return nil, nil
-	}
-	if err != nil {
+	if err := recordEvent(ctx, tx, "social_account", account.ID, AuditAccountConnected, account); err != nil {
 		return nil, err
 	}
-	return &content, nil
-}
 
-// GetLatestExternalPostID returns the most recent external post ID for an account
-func (r *Repository) GetLatestExternalPostID(socialAccountID int) (*string, error) {
-	var externalID *string
-	err := r.db.QueryRow(`
-		SELECT external_post_id FROM content 
-		WHERE social_account_id = $1 AND external_post_id IS NOT NULL
-		ORDER BY posted_at DESC NULLS LAST, created_at DESC
-		LIMIT 1
-	`, socialAccountID).Scan(&externalID)
-	
-	if err == sql.ErrNoRows {
-		return nil, nil
+	if err := insertAuditEvent(ctx, tx, actor, AuditAccountConnected, "social_account", account.ID, nil, account); err != nil {
+		return nil, err
 	}
-	if err != nil {
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	return externalID, nil
+	return &account, nil
 }
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// UserStore reads and manages users and how they authenticate.
+// Lookups of missing users return sql.ErrNoRows.
+type UserStore interface {
+	GetOrCreateUser(ctx context.Context, userID, email, username string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	ListUsers(ctx context.Context, viewer *models.User) ([]models.User, error)
+	UpdateUserRole(ctx context.Context, id int, role string, actor models.Actor, source string) (*models.User, error)
+	GetRoleChanges(ctx context.Context, userID int) ([]models.RoleChange, error)
+	AuthenticateAPIToken(ctx context.Context, tokenHash string) (*models.User, *models.APIToken, error)
+	GetNotificationRecipient(ctx context.Context, userID int) (*models.NotificationRecipient, error)
+}
+
+// AccountStore reads and manages connected social accounts and records their syncs.
+// Lookups of missing or trashed accounts return sql.ErrNoRows.
+type AccountStore interface {
+	GetSocialAccountsByUserID(ctx context.Context, userID int) ([]models.SocialAccount, error)
+	GetAllSocialAccounts(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.SocialAccountWithUser, error)
+	GetSocialAccountByID(ctx context.Context, accountID, userID int) (*models.SocialAccount, error)
+	GetSocialAccountByPlatformAndAccountID(ctx context.Context, userID int, platform string, accountID string) (*models.SocialAccount, error)
+	CreateSocialAccount(ctx context.Context, userID int, req models.CreateSocialAccountRequest, actor models.Actor) (*models.SocialAccount, error)
+	CreateSocialAccountWithTokens(ctx context.Context, userID int, req models.CreateSocialAccountRequest, tokenExpiresAt time.Time, actor models.Actor) (*models.SocialAccount, error)
+	UpdateSocialAccountTokens(ctx context.Context, accountID int, accessToken string, refreshToken string, expiresAt time.Time, actor models.Actor) error
+	UpdateSocialAccountID(ctx context.Context, accountID int, externalID string) error
+	DeleteSocialAccount(ctx context.Context, accountID, userID int, actor models.Actor) error
+	GetSyncCursor(ctx context.Context, accountID int) (*models.SyncCursor, error)
+	RewindSyncCursor(ctx context.Context, accountID int, newestID *string, actor models.Actor) (*models.SyncCursor, error)
+	RecordSyncRun(ctx context.Context, run models.SyncRun) error
+}
+
+// ContentStore reads and manages content, and the tag rules applied to content
+// as it is synced. Lookups of missing or trashed content return sql.ErrNoRows.
+type ContentStore interface {
+	GetContentByUserID(ctx context.Context, userID int, filters map[string]string) ([]models.Content, error)
+	GetAllContent(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.ContentWithUser, error)
+	CreateContent(ctx context.Context, userID int, req models.CreateContentRequest, actor models.Actor) (*models.Content, error)
+	UpdateContent(ctx context.Context, contentID, userID int, req models.UpdateContentRequest, actor models.Actor) (*models.Content, error)
+	DeleteContent(ctx context.Context, contentID, userID int, actor models.Actor) error
+	StoreSyncedContent(ctx context.Context, userID, socialAccountID int, platform string, posts []models.SyncedPost, cursor models.SyncCursor, actor models.Actor) ([]models.SyncedPostResult, error)
+	GetContentRevisions(ctx context.Context, contentID, userID int) ([]models.ContentRevision, error)
+	AddContentTags(ctx context.Context, contentID int, tags []string, actor models.Actor) (bool, error)
+	GetTagRules(ctx context.Context, userID int) ([]models.TagRule, error)
+}
+
+// JobStore queues pulls of social accounts for workers and tracks their
+// progress. Lookups of missing jobs return sql.ErrNoRows, and updates of jobs
+// that were claimed again after the caller's claim ran out ErrPullJobLost.
+type JobStore interface {
+	EnqueuePullJob(ctx context.Context, userID, accountID int, actor models.Actor) (*models.PullJob, error)
+	GetPullJob(ctx context.Context, jobID, userID int) (*models.PullJob, error)
+	ClaimPullJobs(ctx context.Context, limit int, lease time.Duration) ([]models.PullJob, error)
+	UpdatePullJobProgress(ctx context.Context, job models.PullJob, progress models.PullJobProgress) error
+	CompletePullJob(ctx context.Context, job models.PullJob, result models.SyncResponse) error
+	FailPullJob(ctx context.Context, job models.PullJob, errMsg string, retryAfter time.Duration) error
+}
+
+// OutboxStore holds the events recorded with content and account changes
+// until they are relayed
+type OutboxStore interface {
+	RelayOutbox(ctx context.Context, limit int, publish func(models.OutboxEvent) error) (int, error)
+	PruneOutbox(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// TagStore manages the tag vocabulary. Lookups of missing tags return
+// sql.ErrNoRows and names or aliases taken by another tag ErrTagInUse.
+type TagStore interface {
+	GetTags(ctx context.Context, search string) ([]models.Tag, error)
+	CreateTag(ctx context.Context, req models.TagRequest, actor models.Actor) (*models.Tag, error)
+	UpdateTag(ctx context.Context, id int, req models.TagRequest, actor models.Actor) (*models.Tag, error)
+	DeleteTag(ctx context.Context, id int, actor models.Actor) error
+	AddTagAlias(ctx context.Context, id int, alias string, actor models.Actor) (*models.Tag, error)
+	RemoveTagAlias(ctx context.Context, id int, alias string, actor models.Actor) error
+	MergeTags(ctx context.Context, targetID int, sourceIDs []int, actor models.Actor) (*models.Tag, error)
+}
+
+// TagRuleStore manages tag rules and reads the content they are applied to.
+// Lookups of missing rules return sql.ErrNoRows.
+type TagRuleStore interface {
+	GetTagRule(ctx context.Context, id int) (*models.TagRule, error)
+	CreateTagRule(ctx context.Context, rule models.TagRule, actor models.Actor) (*models.TagRule, error)
+	UpdateTagRule(ctx context.Context, rule models.TagRule, actor models.Actor) (*models.TagRule, error)
+	DeleteTagRule(ctx context.Context, id int, actor models.Actor) error
+	GetContentForTagRules(ctx context.Context, userID *int) ([]models.Content, error)
+}
+
+// CampaignStore manages campaigns, their creators and attached content.
+// Lookups of missing campaigns return sql.ErrNoRows.
+type CampaignStore interface {
+	GetCampaigns(ctx context.Context, creatorID *int) ([]models.Campaign, error)
+	CreateCampaign(ctx context.Context, name, brand string, brief *string, startDate, endDate time.Time, hashtags, jurisdictions []string, actor models.Actor) (*models.Campaign, error)
+	UpdateCampaign(ctx context.Context, id int, name, brand string, brief *string, startDate, endDate time.Time, hashtags, jurisdictions []string, actor models.Actor) (*models.Campaign, error)
+	DeleteCampaign(ctx context.Context, id int, actor models.Actor) error
+	AddCampaignCreator(ctx context.Context, campaignID, userID int, actor models.Actor) error
+	RemoveCampaignCreator(ctx context.Context, campaignID, userID int, actor models.Actor) error
+	AttachCampaignContent(ctx context.Context, campaignID, contentID int, actor models.Actor) error
+	DetachCampaignContent(ctx context.Context, campaignID, contentID int, actor models.Actor) error
+	GetCampaignReport(ctx context.Context, campaignID int, viewer *models.User) (*models.CampaignReport, error)
+}
+
+// ComplianceStore manages disclosure rules and the compliance results of
+// sponsored content. Lookups of missing rules return sql.ErrNoRows.
+type ComplianceStore interface {
+	GetDisclosureRules(ctx context.Context) ([]models.DisclosureRule, error)
+	CreateDisclosureRule(ctx context.Context, rule models.DisclosureRule, actor models.Actor) (*models.DisclosureRule, error)
+	UpdateDisclosureRule(ctx context.Context, rule models.DisclosureRule, actor models.Actor) (*models.DisclosureRule, error)
+	DeleteDisclosureRule(ctx context.Context, id int, actor models.Actor) error
+	ReevaluateCompliance(ctx context.Context) (evaluated, failed int, err error)
+	GetComplianceResults(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.ComplianceResult, error)
+}
+
+// WebhookStore manages webhook subscriptions and their delivery log.
+// Lookups of missing webhooks and deliveries return sql.ErrNoRows.
+type WebhookStore interface {
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*models.Webhook, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook, actor models.Actor) (*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook models.Webhook, actor models.Actor) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int, actor models.Actor) error
+	GetWebhookDeliveries(ctx context.Context, webhookID int, status string, limit int) ([]models.WebhookDelivery, error)
+	RedeliverWebhookDelivery(ctx context.Context, webhookID, deliveryID int, actor models.Actor) (*models.WebhookDelivery, error)
+}
+
+// TeamStore manages teams and their members. Lookups of missing teams and
+// memberships return sql.ErrNoRows.
+type TeamStore interface {
+	GetTeams(ctx context.Context, memberID *int) ([]models.Team, error)
+	CreateTeam(ctx context.Context, req models.CreateTeamRequest, actor models.Actor) (*models.Team, error)
+	DeleteTeam(ctx context.Context, teamID int, actor models.Actor) error
+	GetTeamMembers(ctx context.Context, teamID int) ([]models.User, error)
+	AddTeamMember(ctx context.Context, teamID, userID int, actor models.Actor) error
+	RemoveTeamMember(ctx context.Context, teamID, userID int, actor models.Actor) error
+	IsTeamMember(ctx context.Context, teamID, userID int) (bool, error)
+}
+
+// TrashStore lists and restores trashed content and social accounts.
+// Restoring something that is not in the trash returns sql.ErrNoRows.
+type TrashStore interface {
+	GetTrashedContent(ctx context.Context, userID int) ([]models.Content, error)
+	GetTrashedSocialAccounts(ctx context.Context, userID int) ([]models.SocialAccount, error)
+	RestoreContent(ctx context.Context, contentID, userID int, actor models.Actor) (*models.Content, error)
+	RestoreSocialAccount(ctx context.Context, accountID, userID int, actor models.Actor) (*models.SocialAccount, error)
+}
+
+// TokenStore manages users' API tokens. Revoking a missing or revoked token
+// returns sql.ErrNoRows.
+type TokenStore interface {
+	GetAPITokensByUserID(ctx context.Context, userID int) ([]models.APIToken, error)
+	CreateAPIToken(ctx context.Context, userID int, name, tokenHash, tokenPrefix, scope string, expiresAt time.Time, actor models.Actor) (*models.APIToken, error)
+	RevokeAPIToken(ctx context.Context, tokenID, userID int, actor models.Actor) error
+}
+
+// NotificationStore reads sent notifications and digests and manages
+// notification preferences
+type NotificationStore interface {
+	GetNotifications(ctx context.Context, userID, limit int) ([]models.Notification, error)
+	UpdateNotificationPreferences(ctx context.Context, userID int, req models.UpdateNotificationPreferencesRequest, actor models.Actor) (*models.NotificationPreferences, error)
+	GetDigest(ctx context.Context, viewer *models.User, frequency string, from, to time.Time, postsPerCreator int) (*models.Digest, error)
+}
+
+// AuditStore reads the audit log
+type AuditStore interface {
+	GetAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// ImpersonationStore tracks admins' impersonation sessions and the write
+// requests made during them. Missing sessions return sql.ErrNoRows.
+type ImpersonationStore interface {
+	StartImpersonationSession(ctx context.Context, adminID, targetUserID int, reason *string, expiresAt time.Time) (*models.ImpersonationSession, error)
+	GetActiveImpersonationSession(ctx context.Context, adminID int) (*models.ImpersonationSession, error)
+	EndImpersonationSession(ctx context.Context, adminID int) error
+	RecordImpersonationAction(ctx context.Context, adminID, targetUserID int, sessionID *int, method, path string, status int) error
+	GetImpersonationActions(ctx context.Context, filters map[string]int) ([]models.ImpersonationAction, error)
+}
+
+// ContentEventStore reads the content event feed that real-time streams are
+// served from. Positions of events that are no longer kept return sql.ErrNoRows.
+type ContentEventStore interface {
+	GetContentEvents(ctx context.Context, after models.ContentEventPosition, viewer *models.User, userID *int, limit int) (events []models.ContentEvent, held bool, err error)
+	GetContentEventPosition(ctx context.Context, id int64) (*models.ContentEventPosition, error)
+	GetLatestContentEventPosition(ctx context.Context) (models.ContentEventPosition, error)
+}
+
+var (
+	_ UserStore          = (*Repository)(nil)
+	_ AccountStore       = (*Repository)(nil)
+	_ ContentStore       = (*Repository)(nil)
+	_ JobStore           = (*Repository)(nil)
+	_ OutboxStore        = (*Repository)(nil)
+	_ TagStore           = (*Repository)(nil)
+	_ TagRuleStore       = (*Repository)(nil)
+	_ CampaignStore      = (*Repository)(nil)
+	_ ComplianceStore    = (*Repository)(nil)
+	_ WebhookStore       = (*Repository)(nil)
+	_ TeamStore          = (*Repository)(nil)
+	_ TrashStore         = (*Repository)(nil)
+	_ TokenStore         = (*Repository)(nil)
+	_ NotificationStore  = (*Repository)(nil)
+	_ AuditStore         = (*Repository)(nil)
+	_ ImpersonationStore = (*Repository)(nil)
+	_ ContentEventStore  = (*Repository)(nil)
+)
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+const syncCursorColumns = `c.newest_id, c.oldest_id, c.pagination_token, c.high_water_id, c.high_water_at, c.updated_at`
+
+// GetSyncCursor returns where the sync of an account has got to, which is
+// empty if it has never been synced. Returns sql.ErrNoRows if the account
+// does not exist.
+func (r *Repository) GetSyncCursor(ctx context.Context, accountID int) (*models.SyncCursor, error) {
+	return getSyncCursor(ctx, r.db, accountID, "")
+}
+
+// RewindSyncCursor moves the sync cursor of an account back to newestID, or
+// resets it when newestID is nil, so the next pull fetches those posts again.
+// Posts already stored are skipped as usual.
+func (r *Repository) RewindSyncCursor(ctx context.Context, accountID int, newestID *string, actor models.Actor) (*models.SyncCursor, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getSyncCursor(ctx, tx, accountID, "FOR UPDATE OF a")
+	if err != nil {
+		return nil, err
+	}
+
+	after := models.SyncCursor{SocialAccountID: accountID, NewestID: newestID}
+	if newestID != nil {
+		// What was fetched is still known; only the pages after newestID are fetched again
+		after.OldestID, after.HighWaterID, after.HighWaterAt = before.OldestID, before.HighWaterID, before.HighWaterAt
+	}
+	if err := saveSyncCursor(ctx, tx, &after); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditAccountSyncCursorRewound, "social_account", accountID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+func getSyncCursor(ctx context.Context, db queryRower, accountID int, lock string) (*models.SyncCursor, error) {
+	cursor := models.SyncCursor{SocialAccountID: accountID}
+	err := db.QueryRowContext(ctx, `
+		SELECT `+syncCursorColumns+`
+		FROM social_accounts a
+		LEFT JOIN sync_cursors c ON c.social_account_id = a.id
+		WHERE a.id = $1 AND a.deleted_at IS NULL
+		`+lock, accountID).
+		Scan(&cursor.NewestID, &cursor.OldestID, &cursor.PaginationToken, &cursor.HighWaterID, &cursor.HighWaterAt, &cursor.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// saveSyncCursor stores cursor, setting its update time
+func saveSyncCursor(ctx context.Context, tx *sql.Tx, cursor *models.SyncCursor) error {
+	return tx.QueryRowContext(ctx, `
+		INSERT INTO sync_cursors (social_account_id, newest_id, oldest_id, pagination_token, high_water_id, high_water_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (social_account_id) DO UPDATE
+		SET newest_id = EXCLUDED.newest_id, oldest_id = EXCLUDED.oldest_id, pagination_token = EXCLUDED.pagination_token,
+		    high_water_id = EXCLUDED.high_water_id, high_water_at = EXCLUDED.high_water_at, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at
+	`, cursor.SocialAccountID, cursor.NewestID, cursor.OldestID, cursor.PaginationToken, cursor.HighWaterID, cursor.HighWaterAt).
+		Scan(&cursor.UpdatedAt)
+}
@@ -0,0 +1,484 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+const syncedContentColumns = `c.id, c.user_id, c.social_account_id, c.platform, c.link, c.original_text, c.description, c.tags,
+	c.external_post_id, c.posted_at, c.paid_partnership, c.created_at, c.updated_at, c.deleted_at,
+	c.edit_history_ids, c.edited_at, c.removed_on_platform_at,
+	c.conversation_id, c.relation, c.parent_external_id, c.parent_content_id`
+
+func scanSyncedContent(row scanner) (*models.Content, error) {
+	var content models.Content
+	err := row.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+		&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt,
+		&content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt, &content.DeletedAt,
+		pq.Array(&content.EditHistoryIDs), &content.EditedAt, &content.RemovedOnPlatformAt,
+		&content.ConversationID, &content.Relation, &content.ParentExternalID, &content.ParentContentID)
+	if err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// StoreSyncedContent stores the posts of one pull of an account in a single
+// transaction with the pull time and the sync cursor the pull advanced to, so
+// the account's sync state never runs ahead of or behind the stored posts.
+//
+// New posts are inserted with their media and linked to their parents in
+// either direction where both are stored. A post the user already has, by
+// its link or by an earlier version in its edit history, takes the text and
+// media of the post; the text it replaces is kept as a revision. Posts the user has moved to the trash
+// are skipped, so a sync does not bring them back. Results are in the order
+// of posts.
+func (r *Repository) StoreSyncedContent(ctx context.Context, userID, socialAccountID int, platform string, posts []models.SyncedPost, cursor models.SyncCursor, actor models.Actor) ([]models.SyncedPostResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored, err := findSyncedContent(ctx, tx, userID, socialAccountID, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SyncedPostResult, len(posts))
+	var inserts []int
+	seen := make(map[string]bool)
+	for i, post := range posts {
+		results[i] = models.SyncedPostResult{ExternalID: post.ExternalID, Status: models.SyncedPostSkipped}
+
+		// A link repeated within the batch is skipped like any other duplicate
+		if seen[post.Link] {
+			continue
+		}
+		seen[post.Link] = true
+
+		content := stored.match(post)
+		switch {
+		case content == nil:
+			inserts = append(inserts, i)
+		case content.DeletedAt == nil:
+			updated, edited, err := updateSyncedContent(ctx, tx, content, post, actor)
+			if err != nil {
+				return nil, err
+			}
+			if edited {
+				results[i].Status, results[i].Content = models.SyncedPostUpdated, updated
+			}
+		}
+	}
+
+	created, err := insertSyncedContent(ctx, tx, userID, socialAccountID, platform, posts, inserts, actor)
+	if err != nil {
+		return nil, err
+	}
+	createdIDs := make([]int, 0, len(created))
+	for _, content := range created {
+		createdIDs = append(createdIDs, content.ID)
+	}
+	externalIDs := make([]string, len(posts))
+	for i, post := range posts {
+		externalIDs[i] = post.ExternalID
+	}
+	parents, err := linkContentParents(ctx, tx, userID, createdIDs, externalIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, content := range created {
+		if parentID, ok := parents[content.ID]; ok {
+			content.ParentContentID = &parentID
+		}
+		results[i].Status, results[i].Content = models.SyncedPostCreated, content
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE social_accounts SET last_pull_at = $1, updated_at = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+	`, time.Now(), socialAccountID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor.SocialAccountID = socialAccountID
+	if err := saveSyncCursor(ctx, tx, &cursor); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// storedPosts are the posts a user already has that a batch of synced posts
+// may match, live posts taking precedence over trashed ones
+type storedPosts struct {
+	byLink       map[string]*models.Content
+	byExternalID map[string]*models.Content
+}
+
+func (s storedPosts) add(index map[string]*models.Content, key string, content *models.Content) {
+	if existing, ok := index[key]; !ok || existing.DeletedAt != nil {
+		index[key] = content
+	}
+}
+
+// match returns the stored post for post, or nil if it is new
+func (s storedPosts) match(post models.SyncedPost) *models.Content {
+	if content, ok := s.byLink[post.Link]; ok {
+		return content
+	}
+	for i := len(post.EditHistoryIDs) - 1; i >= 0; i-- {
+		if content, ok := s.byExternalID[post.EditHistoryIDs[i]]; ok {
+			return content
+		}
+	}
+	return nil
+}
+
+// findSyncedContent locks the posts of the user that posts may match
+func findSyncedContent(ctx context.Context, tx *sql.Tx, userID, socialAccountID int, posts []models.SyncedPost) (storedPosts, error) {
+	stored := storedPosts{
+		byLink:       make(map[string]*models.Content),
+		byExternalID: make(map[string]*models.Content),
+	}
+
+	var links, versionIDs []string
+	for _, post := range posts {
+		links = append(links, post.Link)
+		versionIDs = append(versionIDs, post.EditHistoryIDs...)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+syncedContentColumns+`
+		FROM content c
+		WHERE c.user_id = $1
+		  AND (c.link = ANY($2) OR (c.social_account_id = $3 AND c.external_post_id = ANY($4)))
+		FOR UPDATE
+	`, userID, pq.Array(links), socialAccountID, pq.Array(versionIDs))
+	if err != nil {
+		return stored, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		content, err := scanSyncedContent(rows)
+		if err != nil {
+			return stored, err
+		}
+		stored.add(stored.byLink, content.Link, content)
+		if content.ExternalPostID != nil {
+			stored.add(stored.byExternalID, *content.ExternalPostID, content)
+		}
+	}
+	return stored, rows.Err()
+}
+
+// insertSyncedContent inserts the posts at indexes in one statement and
+// returns the created content by index
+func insertSyncedContent(ctx context.Context, tx *sql.Tx, userID, socialAccountID int, platform string, posts []models.SyncedPost, indexes []int, actor models.Actor) (map[int]*models.Content, error) {
+	created := make(map[int]*models.Content)
+	if len(indexes) == 0 {
+		return created, nil
+	}
+
+	links := make([]string, len(indexes))
+	texts := make([]string, len(indexes))
+	externalIDs := make([]string, len(indexes))
+	postedAts := make([]string, len(indexes))
+	histories := make([]string, len(indexes))
+	conversations := make([]string, len(indexes))
+	relations := make([]string, len(indexes))
+	parents := make([]string, len(indexes))
+	byLink := make(map[string]int)
+	for i, index := range indexes {
+		post := posts[index]
+		links[i], texts[i], externalIDs[i] = post.Link, post.Text, post.ExternalID
+		postedAts[i] = string(pq.FormatTimestamp(post.PostedAt))
+		histories[i] = strings.Join(post.EditHistoryIDs, ",")
+		conversations[i], relations[i], parents[i] = post.ConversationID, post.Relation, post.ParentExternalID
+		byLink[post.Link] = index
+	}
+
+	// Conflicts are left to posts stored since they were looked up
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO content AS c (user_id, social_account_id, platform, link, original_text, external_post_id, posted_at, edit_history_ids,
+		                          conversation_id, relation, parent_external_id)
+		SELECT $1, $2, $3, p.link, p.original_text, p.external_post_id, p.posted_at,
+		       NULLIF(string_to_array(p.edit_history_ids, ','), '{}'),
+		       NULLIF(p.conversation_id, ''), NULLIF(p.relation, ''), NULLIF(p.parent_external_id, '')
+		FROM unnest($4::text[], $5::text[], $6::text[], $7::timestamp[], $8::text[], $9::text[], $10::text[], $11::text[])
+			WITH ORDINALITY AS p(link, original_text, external_post_id, posted_at, edit_history_ids, conversation_id, relation, parent_external_id, ord)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM content t WHERE t.user_id = $1 AND t.link = p.link AND t.deleted_at IS NOT NULL
+		)
+		ORDER BY p.ord
+		ON CONFLICT (user_id, link) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING `+syncedContentColumns,
+		userID, socialAccountID, platform, pq.Array(links), pq.Array(texts), pq.Array(externalIDs), pq.Array(postedAts), pq.Array(histories),
+		pq.Array(conversations), pq.Array(relations), pq.Array(parents))
+	if err != nil {
+		return nil, err
+	}
+	var contents []*models.Content
+	for rows.Next() {
+		content, err := scanSyncedContent(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, content := range contents {
+		index := byLink[content.Link]
+		if err := syncContentText(ctx, tx, content, posts[index]); err != nil {
+			return nil, err
+		}
+
+		if len(posts[index].Media) > 0 {
+			if content.Media, err = replaceContentMedia(ctx, tx, content.ID, posts[index].Media); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := autoAttachCampaigns(ctx, tx, nil, &content.ID); err != nil {
+			return nil, err
+		}
+
+		if err := recordEvent(ctx, tx, "content", content.ID, AuditContentCreated, content); err != nil {
+			return nil, err
+		}
+
+		if err := insertAuditEvent(ctx, tx, actor, AuditContentCreated, "content", content.ID, nil, content); err != nil {
+			return nil, err
+		}
+
+		created[index] = content
+	}
+	return created, nil
+}
+
+// updateSyncedContent brings a stored post up to date with its synced
+// version. It reports whether the text was edited, in which case the old text
+// is kept as a revision.
+func updateSyncedContent(ctx context.Context, tx *sql.Tx, before *models.Content, post models.SyncedPost, actor models.Actor) (*models.Content, bool, error) {
+	edited := before.OriginalText == nil || *before.OriginalText != post.Text
+	history := len(post.EditHistoryIDs) > 0 && strings.Join(post.EditHistoryIDs, ",") != strings.Join(before.EditHistoryIDs, ",")
+	if !edited && !history && before.RemovedOnPlatformAt == nil {
+		return before, false, nil
+	}
+
+	editHistoryIDs := before.EditHistoryIDs
+	if len(post.EditHistoryIDs) > 0 {
+		editHistoryIDs = post.EditHistoryIDs
+	}
+	after, err := scanSyncedContent(tx.QueryRowContext(ctx, `
+		UPDATE content c
+		SET original_text = $2, edit_history_ids = $3,
+		    edited_at = CASE WHEN $4::boolean THEN CURRENT_TIMESTAMP ELSE c.edited_at END,
+		    removed_on_platform_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE c.id = $1
+		RETURNING `+syncedContentColumns,
+		before.ID, post.Text, pq.Array(editHistoryIDs), edited))
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Media can be changed by an edit too
+	if after.Media, err = replaceContentMedia(ctx, tx, after.ID, post.Media); err != nil {
+		return nil, false, err
+	}
+	if !edited {
+		return after, false, nil
+	}
+
+	// The revision is the version the text belonged to: the last one known
+	// before this edit
+	versionID := before.ExternalPostID
+	if len(before.EditHistoryIDs) > 0 {
+		versionID = &before.EditHistoryIDs[len(before.EditHistoryIDs)-1]
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO content_revisions (content_id, external_post_id, original_text)
+		VALUES ($1, $2, $3)
+	`, before.ID, versionID, before.OriginalText)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := syncContentText(ctx, tx, after, post); err != nil {
+		return nil, false, err
+	}
+
+	if err := recordEvent(ctx, tx, "content", after.ID, AuditContentUpdated, after); err != nil {
+		return nil, false, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditContentUpdated, "content", after.ID, before, after); err != nil {
+		return nil, false, err
+	}
+	return after, true, nil
+}
+
+// syncContentText stores the entities of the synced text of content and
+// evaluates its compliance
+func syncContentText(ctx context.Context, tx *sql.Tx, content *models.Content, post models.SyncedPost) error {
+	ents := post.Entities
+	if ents == nil {
+		ents = entities.Extract(post.Text)
+	}
+	if err := replaceContentEntities(ctx, tx, content.ID, ents); err != nil {
+		return err
+	}
+	setContentEntities(content, ents)
+
+	_, err := evaluateCompliance(ctx, tx, content.ID)
+	return err
+}
+
+// GetContentRevisions returns the earlier texts of a user's synced post,
+// newest first. Returns sql.ErrNoRows if the post does not exist.
+func (r *Repository) GetContentRevisions(ctx context.Context, contentID, userID int) ([]models.ContentRevision, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM content WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)
+	`, contentID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, content_id, external_post_id, original_text, revised_at
+		FROM content_revisions
+		WHERE content_id = $1
+		ORDER BY revised_at DESC, id DESC
+	`, contentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.ContentRevision{}
+	for rows.Next() {
+		var revision models.ContentRevision
+		if err := rows.Scan(&revision.ID, &revision.ContentID, &revision.ExternalPostID, &revision.OriginalText, &revision.RevisedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetContentToReconcile returns up to limit synced posts on platform that
+// have not been looked up on the platform since cutoff, least recently looked
+// up first
+func (r *Repository) GetContentToReconcile(ctx context.Context, platform string, cutoff time.Time, limit int) ([]models.Content, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+syncedContentColumns+`
+		FROM content c
+		WHERE c.platform = $1 AND c.deleted_at IS NULL AND c.external_post_id IS NOT NULL
+		  AND (c.reconciled_at IS NULL OR c.reconciled_at < $2)
+		ORDER BY c.reconciled_at NULLS FIRST, c.id
+		LIMIT $3
+	`, platform, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contents []models.Content
+	for rows.Next() {
+		content, err := scanSyncedContent(rows)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, *content)
+	}
+	return contents, rows.Err()
+}
+
+// RecordReconciliation records that posts were looked up on their platform:
+// found are still there and removed no longer are. It returns how many posts
+// were newly found removed.
+func (r *Repository) RecordReconciliation(ctx context.Context, found, removed []int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// A post found again, e.g. after its account was suspended, is no longer removed
+	_, err = tx.ExecContext(ctx, `
+		UPDATE content
+		SET reconciled_at = CURRENT_TIMESTAMP, removed_on_platform_at = NULL
+		WHERE id = ANY($1)
+	`, pq.Array(found))
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE content c
+		SET reconciled_at = CURRENT_TIMESTAMP, removed_on_platform_at = COALESCE(c.removed_on_platform_at, CURRENT_TIMESTAMP)
+		FROM content prev
+		WHERE c.id = ANY($1) AND prev.id = c.id AND prev.removed_on_platform_at IS NULL
+		RETURNING c.id, c.removed_on_platform_at
+	`, pq.Array(removed))
+	if err != nil {
+		return 0, err
+	}
+	newlyRemoved := make(map[int]time.Time)
+	for rows.Next() {
+		var id int
+		var removedAt time.Time
+		if err := rows.Scan(&id, &removedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		newlyRemoved[id] = removedAt
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE content SET reconciled_at = CURRENT_TIMESTAMP
+		WHERE id = ANY($1) AND removed_on_platform_at IS NOT NULL
+	`, pq.Array(removed))
+	if err != nil {
+		return 0, err
+	}
+
+	for id, removedAt := range newlyRemoved {
+		err := insertAuditEvent(ctx, tx, models.Actor{}, AuditContentRemovedOnPlatform, "content", id,
+			nil, map[string]time.Time{"removed_on_platform_at": removedAt})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(newlyRemoved), nil
+}
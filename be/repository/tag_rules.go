@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// Tag rule operations
+
+const tagRuleColumns = `id, owner_id, name, enabled, keyword, pattern, hashtag, platform, social_account_id, tags, created_at, updated_at`
+
+func scanTagRule(row scanner) (*models.TagRule, error) {
+	var rule models.TagRule
+	err := row.Scan(&rule.ID, &rule.OwnerID, &rule.Name, &rule.Enabled, &rule.Keyword, &rule.Pattern, &rule.Hashtag,
+		&rule.Platform, &rule.SocialAccountID, pq.Array(&rule.Tags), &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetTagRules returns the rules that apply to a user's content: their own and the global ones
+func (r *Repository) GetTagRules(ctx context.Context, userID int) ([]models.TagRule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+tagRuleColumns+`
+		FROM tag_rules
+		WHERE owner_id = $1 OR owner_id IS NULL
+		ORDER BY owner_id NULLS FIRST, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.TagRule
+	for rows.Next() {
+		rule, err := scanTagRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetTagRule returns a rule or sql.ErrNoRows
+func (r *Repository) GetTagRule(ctx context.Context, id int) (*models.TagRule, error) {
+	return scanTagRule(r.db.QueryRowContext(ctx, `SELECT `+tagRuleColumns+` FROM tag_rules WHERE id = $1`, id))
+}
+
+// CreateTagRule stores a rule. Its tags are resolved against the tag vocabulary.
+func (r *Repository) CreateTagRule(ctx context.Context, rule models.TagRule, actor models.Actor) (*models.TagRule, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tags, err := resolveTags(ctx, tx, rule.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := scanTagRule(tx.QueryRowContext(ctx, `
+		INSERT INTO tag_rules (owner_id, name, enabled, keyword, pattern, hashtag, platform, social_account_id, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+tagRuleColumns,
+		rule.OwnerID, rule.Name, rule.Enabled, rule.Keyword, rule.Pattern, rule.Hashtag, rule.Platform, rule.SocialAccountID, pq.Array(tags)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagRuleCreated, "tag_rule", created.ID, nil, created); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateTagRule replaces a rule's name, conditions and tags. The owner does
+// not change. Returns sql.ErrNoRows if the rule does not exist.
+func (r *Repository) UpdateTagRule(ctx context.Context, rule models.TagRule, actor models.Actor) (*models.TagRule, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanTagRule(tx.QueryRowContext(ctx, `SELECT `+tagRuleColumns+` FROM tag_rules WHERE id = $1 FOR UPDATE`, rule.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := resolveTags(ctx, tx, rule.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := scanTagRule(tx.QueryRowContext(ctx, `
+		UPDATE tag_rules
+		SET name = $2, enabled = $3, keyword = $4, pattern = $5, hashtag = $6, platform = $7,
+		    social_account_id = $8, tags = $9, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+tagRuleColumns,
+		rule.ID, rule.Name, rule.Enabled, rule.Keyword, rule.Pattern, rule.Hashtag, rule.Platform, rule.SocialAccountID, pq.Array(tags)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagRuleUpdated, "tag_rule", rule.ID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// DeleteTagRule deletes a rule. Tags it already applied stay on the content.
+func (r *Repository) DeleteTagRule(ctx context.Context, id int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rule, err := scanTagRule(tx.QueryRowContext(ctx, `DELETE FROM tag_rules WHERE id = $1 RETURNING `+tagRuleColumns, id))
+	if err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagRuleDeleted, "tag_rule", id, rule, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetContentForTagRules returns live content with its entities, for one user
+// or for everyone when userID is nil
+func (r *Repository) GetContentForTagRules(ctx context.Context, userID *int) ([]models.Content, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.id, c.user_id, c.social_account_id, c.platform, c.link, c.original_text, c.description, c.tags,
+		       c.external_post_id, c.posted_at, c.paid_partnership, c.created_at, c.updated_at,`+contentEntityColumns+`
+		FROM content c
+		WHERE c.deleted_at IS NULL AND ($1::INTEGER IS NULL OR c.user_id = $1)
+		ORDER BY COALESCE(c.posted_at, c.created_at) DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contents []models.Content
+	for rows.Next() {
+		var content models.Content
+		err := rows.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt,
+			pq.Array(&content.Hashtags), pq.Array(&content.Mentions), pq.Array(&content.Cashtags), pq.Array(&content.URLs))
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return contents, rows.Err()
+}
+
+// AddContentTags appends tags to content, resolving them against the tag
+// vocabulary. It reports whether any tag was new to the content.
+func (r *Repository) AddContentTags(ctx context.Context, contentID int, tags []string, actor models.Actor) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var current []string
+	err = tx.QueryRowContext(ctx, `SELECT tags FROM content WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, contentID).Scan(pq.Array(&current))
+	if err != nil {
+		return false, err
+	}
+
+	resolved, err := resolveTags(ctx, tx, tags)
+	if err != nil {
+		return false, err
+	}
+
+	updated := append([]string{}, current...)
+	has := make(map[string]bool)
+	for _, tag := range current {
+		has[tag] = true
+	}
+	for _, tag := range resolved {
+		if !has[tag] {
+			has[tag] = true
+			updated = append(updated, tag)
+		}
+	}
+	if len(updated) == len(current) {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE content SET tags = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, contentID, pq.Array(updated))
+	if err != nil {
+		return false, err
+	}
+
+	event := map[string]interface{}{"id": contentID, "tags": updated}
+	if err := recordEvent(ctx, tx, "content", contentID, AuditContentTagsUpdated, event); err != nil {
+		return false, err
+	}
+
+	before := map[string][]string{"tags": current}
+	after := map[string][]string{"tags": updated}
+	if err := insertAuditEvent(ctx, tx, actor, AuditContentTagsUpdated, "content", contentID, before, after); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,442 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// ErrTagInUse is returned when a tag name or alias already resolves to another tag
+var ErrTagInUse = errors.New("tag name or alias is already in use")
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// TagSlug returns the form tag spellings are matched on: lowercase letters and
+// digits only, so "NikeQ3", "nike-q3" and "#nike_q3" are the same tag
+func TagSlug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Tag operations
+
+const tagColumns = `
+	t.id, t.name, t.color, t.description,
+	ARRAY(SELECT alias FROM tag_aliases a WHERE a.tag_id = t.id ORDER BY alias),
+	(SELECT COUNT(*) FROM content c WHERE c.deleted_at IS NULL AND c.tags @> ARRAY[t.name]::TEXT[]),
+	t.created_at, t.updated_at`
+
+func scanTag(row scanner) (*models.Tag, error) {
+	var tag models.Tag
+	err := row.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.Description, pq.Array(&tag.Aliases), &tag.UsageCount, &tag.CreatedAt, &tag.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func getTag(ctx context.Context, db queryRower, id int) (*models.Tag, error) {
+	return scanTag(db.QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags t WHERE t.id = $1`, id))
+}
+
+// slugInUse reports whether slug resolves to a tag other than exceptTagID
+func slugInUse(ctx context.Context, db queryRower, slug string, exceptTagID int) (bool, error) {
+	var inUse bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM tags WHERE slug = $1 AND id <> $2)
+		    OR EXISTS (SELECT 1 FROM tag_aliases WHERE slug = $1 AND tag_id <> $2)
+	`, slug, exceptTagID).Scan(&inUse)
+	return inUse, err
+}
+
+// resolveTags maps tag names to their canonical names, creating tags for
+// spellings that match nothing yet. Blank and duplicate names are dropped.
+func resolveTags(ctx context.Context, db queryRower, names []string) ([]string, error) {
+	var resolved []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := TagSlug(name)
+		if slug == "" {
+			continue
+		}
+
+		var canonical string
+		err := db.QueryRowContext(ctx, `
+			SELECT name FROM tags WHERE slug = $1
+			UNION ALL
+			SELECT t.name FROM tag_aliases a JOIN tags t ON t.id = a.tag_id WHERE a.slug = $1
+			LIMIT 1
+		`, slug).Scan(&canonical)
+		if err == sql.ErrNoRows {
+			err = db.QueryRowContext(ctx, `
+				INSERT INTO tags (name, slug) VALUES ($1, $2)
+				ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+				RETURNING name
+			`, name, slug).Scan(&canonical)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[canonical] {
+			seen[canonical] = true
+			resolved = append(resolved, canonical)
+		}
+	}
+	return resolved, nil
+}
+
+// rewriteContentTags replaces the tags named in from with to on all content,
+// including trashed content, keeping tag order and dropping duplicates. A nil
+// to removes the tags instead.
+func rewriteContentTags(ctx context.Context, db execer, from []string, to *string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE content SET tags = ARRAY(
+			SELECT x FROM (
+				SELECT CASE WHEN u.t = ANY($1::TEXT[]) THEN $2::TEXT ELSE u.t END AS x, u.ord
+				FROM unnest(tags) WITH ORDINALITY AS u(t, ord)
+			) s
+			WHERE x IS NOT NULL
+			GROUP BY x
+			ORDER BY MIN(ord)
+		), updated_at = CURRENT_TIMESTAMP
+		WHERE tags && $1::TEXT[]
+	`, pq.Array(from), to)
+	return err
+}
+
+// GetTags lists tags with their aliases and usage counts, most used first.
+// search, when set, matches the start of a tag name or alias.
+func (r *Repository) GetTags(ctx context.Context, search string) ([]models.Tag, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+tagColumns+`
+		FROM tags t
+		WHERE $1 = ''
+		   OR t.slug LIKE $1 || '%'
+		   OR EXISTS (SELECT 1 FROM tag_aliases a WHERE a.tag_id = t.id AND a.slug LIKE $1 || '%')
+		ORDER BY 6 DESC, t.name
+	`, TagSlug(search))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+// CreateTag adds a tag to the vocabulary. Returns ErrTagInUse if the name or
+// one of the aliases already resolves to a tag.
+func (r *Repository) CreateTag(ctx context.Context, req models.TagRequest, actor models.Actor) (*models.Tag, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	slug := TagSlug(req.Name)
+	if inUse, err := slugInUse(ctx, tx, slug, 0); err != nil {
+		return nil, err
+	} else if inUse {
+		return nil, ErrTagInUse
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tags (name, slug, color, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, req.Name, slug, req.Color, req.Description).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alias := range req.Aliases {
+		if err := insertTagAlias(ctx, tx, id, alias); err != nil {
+			return nil, err
+		}
+	}
+
+	tag, err := getTag(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagCreated, "tag", id, nil, tag); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// insertTagAlias adds an alias to a tag. Aliases that already point at the tag
+// are ignored; ErrTagInUse is returned if it resolves to another tag.
+func insertTagAlias(ctx context.Context, tx *sql.Tx, tagID int, alias string) error {
+	alias = strings.TrimSpace(alias)
+	slug := TagSlug(alias)
+	if slug == "" {
+		return nil
+	}
+
+	if inUse, err := slugInUse(ctx, tx, slug, tagID); err != nil {
+		return err
+	} else if inUse {
+		return ErrTagInUse
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tag_aliases (slug, tag_id, alias)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM tags WHERE id = $2 AND slug = $1)
+		ON CONFLICT (slug) DO NOTHING
+	`, slug, tagID, alias)
+	return err
+}
+
+// UpdateTag renames a tag and updates its color and description. Renaming
+// rewrites the tag on existing content and keeps the old spelling as an alias.
+// Returns sql.ErrNoRows if the tag does not exist.
+func (r *Repository) UpdateTag(ctx context.Context, id int, req models.TagRequest, actor models.Actor) (*models.Tag, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var oldSlug string
+	if err := tx.QueryRowContext(ctx, `SELECT slug FROM tags WHERE id = $1 FOR UPDATE`, id).Scan(&oldSlug); err != nil {
+		return nil, err
+	}
+
+	before, err := getTag(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	slug := TagSlug(req.Name)
+	if inUse, err := slugInUse(ctx, tx, slug, id); err != nil {
+		return nil, err
+	} else if inUse {
+		return nil, ErrTagInUse
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tags SET name = $2, slug = $3, color = $4, description = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id, req.Name, slug, req.Color, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != before.Name {
+		if err := rewriteContentTags(ctx, tx, []string{before.Name}, &req.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if slug != oldSlug {
+		// The new name no longer needs an alias, the old one now does
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tag_aliases WHERE slug = $1`, slug); err != nil {
+			return nil, err
+		}
+		if err := insertTagAlias(ctx, tx, id, before.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := getTag(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagUpdated, "tag", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// DeleteTag removes a tag and strips it from all content. Returns sql.ErrNoRows if it does not exist.
+func (r *Repository) DeleteTag(ctx context.Context, id int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tag, err := getTag(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := rewriteContentTags(ctx, tx, []string{tag.Name}, nil); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagDeleted, "tag", id, tag, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddTagAlias adds an alternative spelling to a tag. Returns sql.ErrNoRows if
+// the tag does not exist and ErrTagInUse if the alias resolves to another tag.
+func (r *Repository) AddTagAlias(ctx context.Context, id int, alias string, actor models.Actor) (*models.Tag, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getTag(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertTagAlias(ctx, tx, id, alias); err != nil {
+		return nil, err
+	}
+
+	after, err := getTag(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagAliasAdded, "tag", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// RemoveTagAlias removes an alias from a tag. Returns sql.ErrNoRows if the tag has no such alias.
+func (r *Repository) RemoveTagAlias(ctx context.Context, id int, alias string, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var removed string
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM tag_aliases WHERE tag_id = $1 AND slug = $2
+		RETURNING alias
+	`, id, TagSlug(alias)).Scan(&removed)
+	if err != nil {
+		return err
+	}
+
+	before := map[string]interface{}{"tag_id": id, "alias": removed}
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagAliasRemoved, "tag", id, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MergeTags folds the source tags into the target: content is rewritten to the
+// target's name, and the sources' names and aliases become aliases of the
+// target. Returns sql.ErrNoRows if the target or any source does not exist.
+func (r *Repository) MergeTags(ctx context.Context, targetID int, sourceIDs []int, actor models.Actor) (*models.Tag, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	target, err := getTag(ctx, tx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []models.Tag
+	for _, id := range sourceIDs {
+		if id == targetID {
+			continue
+		}
+		source, err := getTag(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, *source)
+	}
+
+	names := make([]string, len(sources))
+	ids := make([]int64, len(sources))
+	for i, source := range sources {
+		names[i] = source.Name
+		ids[i] = int64(source.ID)
+	}
+
+	if err := rewriteContentTags(ctx, tx, names, &target.Name); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE tag_aliases SET tag_id = $1 WHERE tag_id = ANY($2)`, targetID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO tag_aliases (slug, tag_id, alias)
+		SELECT slug, $1, name FROM tags WHERE id = ANY($2)
+		ON CONFLICT (slug) DO UPDATE SET tag_id = EXCLUDED.tag_id
+	`, targetID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	after, err := getTag(ctx, tx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := map[string]interface{}{"target": target, "sources": sources}
+	if err := insertAuditEvent(ctx, tx, actor, AuditTagMerged, "tag", targetID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// visibleUsersCondition returns an SQL condition (starting with AND) restricting
+// column to the users viewer may see: admins see everyone, managers see the
+// members of their teams and everyone else only themselves. argN is the number
+// of the first placeholder the condition may use.
+func visibleUsersCondition(viewer *models.User, column string, argN int) (string, []interface{}) {
+	switch viewer.Role {
+	case models.RoleAdmin:
+		return "", nil
+	case models.RoleManager:
+		return fmt.Sprintf(` AND %s IN (
+			SELECT tm.user_id FROM team_members tm
+			JOIN team_members mine ON mine.team_id = tm.team_id
+			WHERE mine.user_id = $%d
+		)`, column, argN), []interface{}{viewer.ID}
+	default:
+		return fmt.Sprintf(" AND %s = $%d", column, argN), []interface{}{viewer.ID}
+	}
+}
+
+// GetAllSocialAccounts returns social accounts across users, limited to what viewer may see
+func (r *Repository) GetAllSocialAccounts(ctx context.Context, viewer *models.User, filters map[string]string) ([]models.SocialAccountWithUser, error) {
+	query := `
+		SELECT a.id, a.user_id, a.platform, a.account_name, a.account_id, a.token_expires_at, a.last_pull_at,
+		       a.created_at, a.updated_at, u.username, u.email
+		FROM social_accounts a
+		JOIN users u ON a.user_id = u.id
+		WHERE 1=1
+	`
+
+	scope, args := visibleUsersCondition(viewer, "a.user_id", 1)
+	query += scope
+	argCount := len(args) + 1
+
+	if platform, ok := filters["platform"]; ok && platform != "" {
+		query += fmt.Sprintf(" AND a.platform = $%d", argCount)
+		args = append(args, platform)
+		argCount++
+	}
+
+	if username, ok := filters["username"]; ok && username != "" {
+		query += fmt.Sprintf(" AND u.username ILIKE $%d", argCount)
+		args = append(args, "%"+username+"%")
+	}
+
+	query += " ORDER BY u.username, a.created_at DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.SocialAccountWithUser
+	for rows.Next() {
+		var account models.SocialAccountWithUser
+		err := rows.Scan(&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID,
+			&account.TokenExpiresAt, &account.LastPullAt, &account.CreatedAt, &account.UpdatedAt,
+			&account.Username, &account.Email)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// Team operations
+
+// CreateTeam creates a new, empty team
+func (r *Repository) CreateTeam(ctx context.Context, req models.CreateTeamRequest, actor models.Actor) (*models.Team, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var team models.Team
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO teams (name, description)
+		VALUES ($1, $2)
+		RETURNING id, name, description, 0, created_at, updated_at
+	`, req.Name, req.Description).Scan(&team.ID, &team.Name, &team.Description, &team.MemberCount, &team.CreatedAt, &team.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTeamCreated, "team", team.ID, nil, team); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// GetTeams returns all teams, or only the teams of memberID when it is not nil
+func (r *Repository) GetTeams(ctx context.Context, memberID *int) ([]models.Team, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.name, t.description, COUNT(tm.user_id), t.created_at, t.updated_at
+		FROM teams t
+		LEFT JOIN team_members tm ON tm.team_id = t.id
+		WHERE $1::INTEGER IS NULL OR t.id IN (SELECT team_id FROM team_members WHERE user_id = $1)
+		GROUP BY t.id
+		ORDER BY t.name
+	`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []models.Team
+	for rows.Next() {
+		var team models.Team
+		err := rows.Scan(&team.ID, &team.Name, &team.Description, &team.MemberCount, &team.CreatedAt, &team.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+// DeleteTeam deletes a team and its memberships
+func (r *Repository) DeleteTeam(ctx context.Context, teamID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var team models.Team
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM teams WHERE id = $1
+		RETURNING id, name, description, created_at, updated_at
+	`, teamID).Scan(&team.ID, &team.Name, &team.Description, &team.CreatedAt, &team.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTeamDeleted, "team", team.ID, team, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsTeamMember reports whether a user belongs to a team
+func (r *Repository) IsTeamMember(ctx context.Context, teamID, userID int) (bool, error) {
+	var member bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM team_members WHERE team_id = $1 AND user_id = $2)
+	`, teamID, userID).Scan(&member)
+	return member, err
+}
+
+// GetTeamMembers returns the users in a team
+func (r *Repository) GetTeamMembers(ctx context.Context, teamID int) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT u.id, u.user_id, u.email, u.username, u.role, u.created_at, u.updated_at
+		FROM team_members tm
+		JOIN users u ON tm.user_id = u.id
+		WHERE tm.team_id = $1
+		ORDER BY u.username
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(&user.ID, &user.UserID, &user.Email, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// AddTeamMember adds a user to a team; adding an existing member is a no-op
+func (r *Repository) AddTeamMember(ctx context.Context, teamID, userID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO team_members (team_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (team_id, user_id) DO NOTHING
+	`, teamID, userID)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTeamMemberAdded, "team", teamID, nil, map[string]int{"user_id": userID}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveTeamMember removes a user from a team
+func (r *Repository) RemoveTeamMember(ctx context.Context, teamID, userID int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM team_members WHERE team_id = $1 AND user_id = $2
+	`, teamID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditTeamMemberRemoved, "team", teamID, map[string]int{"user_id": userID}, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
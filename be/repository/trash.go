@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// Trash operations
+
+// GetTrashedContent returns a user's soft-deleted content, most recently deleted first
+func (r *Repository) GetTrashedContent(ctx context.Context, userID int) ([]models.Content, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, paid_partnership, created_at, updated_at, deleted_at
+		FROM content WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contents []models.Content
+	for rows.Next() {
+		var content models.Content
+		err := rows.Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+			&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt, &content.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return contents, rows.Err()
+}
+
+// GetTrashedSocialAccounts returns a user's soft-deleted social accounts, most recently deleted first
+func (r *Repository) GetTrashedSocialAccounts(ctx context.Context, userID int) ([]models.SocialAccount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, platform, account_name, account_id, last_pull_at, created_at, updated_at, deleted_at
+		FROM social_accounts WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.SocialAccount
+	for rows.Next() {
+		var account models.SocialAccount
+		err := rows.Scan(&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID, &account.LastPullAt, &account.CreatedAt, &account.UpdatedAt, &account.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// RestoreContent takes content out of the trash. Returns sql.ErrNoRows if it is
+// not in the trash, or a unique violation if the same link was added again since.
+func (r *Repository) RestoreContent(ctx context.Context, contentID, userID int, actor models.Actor) (*models.Content, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var content models.Content
+	err = tx.QueryRowContext(ctx, `
+		UPDATE content SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING id, user_id, social_account_id, platform, link, original_text, description, tags, external_post_id, posted_at, paid_partnership, created_at, updated_at
+	`, contentID, userID).Scan(&content.ID, &content.UserID, &content.SocialAccountID, &content.Platform, &content.Link,
+		&content.OriginalText, &content.Description, pq.Array(&content.Tags), &content.ExternalPostID, &content.PostedAt, &content.PaidPartnership, &content.CreatedAt, &content.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordEvent(ctx, tx, "content", content.ID, AuditContentRestored, content); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditContentRestored, "content", content.ID, nil, content); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// RestoreSocialAccount takes a social account out of the trash. Returns sql.ErrNoRows if it is not in the trash.
+func (r *Repository) RestoreSocialAccount(ctx context.Context, accountID, userID int, actor models.Actor) (*models.SocialAccount, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var account models.SocialAccount
+	err = tx.QueryRowContext(ctx, `
+		UPDATE social_accounts SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING id, user_id, platform, account_name, account_id, token_expires_at, last_pull_at, created_at, updated_at
+	`, accountID, userID).Scan(&account.ID, &account.UserID, &account.Platform, &account.AccountName, &account.AccountID,
+		&account.TokenExpiresAt, &account.LastPullAt, &account.CreatedAt, &account.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordEvent(ctx, tx, "social_account", account.ID, AuditAccountRestored, account); err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditAccountRestored, "social_account", account.ID, nil, account); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// PurgeDeleted permanently deletes content and social accounts that were moved
+// to the trash before the cutoff. Each purged row is recorded in the audit log
+// as a system action, written to the outbox and sent to webhooks subscribed to
+// content.purged or account.purged.
+func (r *Repository) PurgeDeleted(ctx context.Context, cutoff time.Time) (contentPurged, accountsPurged int64, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	contentPurged, err = purgeTrashed(ctx, tx, `
+		WITH purged AS (
+			DELETE FROM content WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			RETURNING id, jsonb_build_object(
+				'id', id, 'user_id', user_id, 'social_account_id', social_account_id, 'platform', platform,
+				'link', link, 'external_post_id', external_post_id, 'deleted_at', deleted_at
+			) AS state
+		), audited AS (
+			INSERT INTO audit_events (action, target_type, target_id, before)
+			SELECT $2, 'content', id, state FROM purged
+		)
+		SELECT id, state FROM purged
+	`, cutoff, "content", AuditContentPurged)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Tokens are never copied into the audit log, the outbox or webhooks
+	accountsPurged, err = purgeTrashed(ctx, tx, `
+		WITH purged AS (
+			DELETE FROM social_accounts WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			RETURNING id, jsonb_build_object(
+				'id', id, 'user_id', user_id, 'platform', platform,
+				'account_name', account_name, 'account_id', account_id, 'deleted_at', deleted_at
+			) AS state
+		), audited AS (
+			INSERT INTO audit_events (action, target_type, target_id, before)
+			SELECT $2, 'social_account', id, state FROM purged
+		)
+		SELECT id, state FROM purged
+	`, cutoff, "social_account", AuditAccountPurged)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return contentPurged, accountsPurged, nil
+}
+
+// purgeTrashed runs a purge query returning the id and last state of each
+// deleted row, and records an event for each row
+func purgeTrashed(ctx context.Context, tx *sql.Tx, query string, cutoff time.Time, aggregateType, eventType string) (int64, error) {
+	rows, err := tx.QueryContext(ctx, query, cutoff, eventType)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type purgedRow struct {
+		id    int
+		state json.RawMessage
+	}
+	var purged []purgedRow
+	for rows.Next() {
+		var row purgedRow
+		if err := rows.Scan(&row.id, &row.state); err != nil {
+			return 0, err
+		}
+		purged = append(purged, row)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, row := range purged {
+		if err := recordEvent(ctx, tx, aggregateType, row.id, eventType, row.state); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(purged)), nil
+}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+	"github.com/lib/pq"
+)
+
+// Webhook operations
+
+// ErrWebhookDeliveryLost is returned when recording an attempt at a delivery
+// whose claim ran out and that another sender has claimed since
+var ErrWebhookDeliveryLost = errors.New("webhook delivery was claimed by another sender")
+
+const webhookColumns = `id, url, secret, events, enabled, created_at, updated_at`
+
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, pq.Array(&webhook.Events), &webhook.Enabled, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.last_attempt_at, d.response_status, d.response_body, d.last_error, d.delivered_at, d.created_at`
+
+func scanWebhookDelivery(row scanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload []byte
+	dest := []interface{}{&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastAttemptAt, &delivery.ResponseStatus, &delivery.ResponseBody,
+		&delivery.LastError, &delivery.DeliveredAt, &delivery.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	return &delivery, nil
+}
+
+// enqueueWebhookEvent queues an event for every enabled webhook subscribed to
+// its type. It is meant to run in the same transaction as the change it reports.
+func enqueueWebhookEvent(ctx context.Context, db execer, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		WITH event AS MATERIALIZED (SELECT gen_random_uuid() AS id)
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT w.id, event.id, $1, $2
+		FROM webhooks w, event
+		WHERE w.enabled AND $1 = ANY(w.events)
+	`, eventType, data)
+	return err
+}
+
+// GetWebhooks lists all webhooks
+func (r *Repository) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook returns a webhook or sql.ErrNoRows
+func (r *Repository) GetWebhook(ctx context.Context, id int) (*models.Webhook, error) {
+	return scanWebhook(r.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+}
+
+// CreateWebhook stores a webhook subscription
+func (r *Repository) CreateWebhook(ctx context.Context, webhook models.Webhook, actor models.Actor) (*models.Webhook, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanWebhook(tx.QueryRowContext(ctx, `
+		INSERT INTO webhooks (url, secret, events, enabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+webhookColumns,
+		webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Enabled))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditWebhookCreated, "webhook", created.ID, nil, created); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateWebhook replaces a webhook's URL, events and enabled flag. An empty
+// secret keeps the current one. Returns sql.ErrNoRows if the webhook does not exist.
+func (r *Repository) UpdateWebhook(ctx context.Context, webhook models.Webhook, actor models.Actor) (*models.Webhook, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanWebhook(tx.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 FOR UPDATE`, webhook.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := scanWebhook(tx.QueryRowContext(ctx, `
+		UPDATE webhooks
+		SET url = $2, secret = COALESCE(NULLIF($3, ''), secret), events = $4, enabled = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+webhookColumns,
+		webhook.ID, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Enabled))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditWebhookUpdated, "webhook", webhook.ID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// DeleteWebhook deletes a webhook along with its queued and logged deliveries
+func (r *Repository) DeleteWebhook(ctx context.Context, id int, actor models.Actor) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	webhook, err := scanWebhook(tx.QueryRowContext(ctx, `DELETE FROM webhooks WHERE id = $1 RETURNING `+webhookColumns, id))
+	if err != nil {
+		return err
+	}
+
+	if err := insertAuditEvent(ctx, tx, actor, AuditWebhookDeleted, "webhook", id, webhook, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetWebhookDeliveries returns a webhook's most recent deliveries, optionally filtered by status
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, webhookID int, status string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $3
+	`, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// RedeliverWebhookDelivery queues a delivery again as a new delivery of the
+// same event. Returns sql.ErrNoRows if the delivery does not belong to the webhook.
+func (r *Repository) RedeliverWebhookDelivery(ctx context.Context, webhookID, deliveryID int, actor models.Actor) (*models.WebhookDelivery, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	delivery, err := scanWebhookDelivery(tx.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries AS d (webhook_id, event_id, event_type, payload)
+		SELECT webhook_id, event_id, event_type, payload
+		FROM webhook_deliveries
+		WHERE id = $1 AND webhook_id = $2
+		RETURNING `+webhookDeliveryColumns, deliveryID, webhookID))
+	if err != nil {
+		return nil, err
+	}
+
+	after := map[string]interface{}{"delivery_id": delivery.ID, "redelivery_of": deliveryID, "event_id": delivery.EventID}
+	if err := insertAuditEvent(ctx, tx, actor, AuditWebhookRedelivered, "webhook", webhookID, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ClaimWebhookDeliveries picks up to limit due deliveries for sending. Claimed
+// deliveries are not due again until lease has passed, so a crashed sender
+// does not lose them and concurrent senders do not share them. The
+// next_attempt_at of a claimed delivery identifies the claim.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingWebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id FROM webhook_deliveries dd
+			JOIN webhooks ww ON ww.id = dd.webhook_id
+			WHERE dd.status = 'pending' AND dd.next_attempt_at <= CURRENT_TIMESTAMP AND ww.enabled
+			ORDER BY dd.next_attempt_at, dd.id
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns+`, w.url, w.secret
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []models.PendingWebhookDelivery
+	for rows.Next() {
+		var p models.PendingWebhookDelivery
+		delivery, err := scanWebhookDelivery(rows, &p.URL, &p.Secret)
+		if err != nil {
+			return nil, err
+		}
+		p.WebhookDelivery = *delivery
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// CompleteWebhookDelivery records a successful attempt. delivery is the
+// delivery as claimed; ErrWebhookDeliveryLost is returned if it has been
+// claimed again since.
+func (r *Repository) CompleteWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery, statusCode int, body string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_attempt_at = CURRENT_TIMESTAMP, delivered_at = CURRENT_TIMESTAMP,
+		    next_attempt_at = NULL, response_status = $3, response_body = $4, last_error = NULL
+		WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2
+	`, delivery.ID, delivery.NextAttemptAt, statusCode, body)
+	return claimHeld(result, err, ErrWebhookDeliveryLost)
+}
+
+// FailWebhookDelivery records a failed attempt. The delivery is retried after
+// retryAfter, or marked failed when retryAfter is not positive. statusCode is 0
+// when no response was received. delivery is the delivery as claimed;
+// ErrWebhookDeliveryLost is returned if it has been claimed again since.
+func (r *Repository) FailWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery, statusCode int, body, errMsg string, retryAfter time.Duration) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = CASE WHEN $6 > 0 THEN 'pending' ELSE 'failed' END,
+		    next_attempt_at = CASE WHEN $6 > 0 THEN CURRENT_TIMESTAMP + $6 * INTERVAL '1 second' END,
+		    attempts = attempts + 1, last_attempt_at = CURRENT_TIMESTAMP,
+		    response_status = NULLIF($3, 0), response_body = NULLIF($4, ''), last_error = $5
+		WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2
+	`, delivery.ID, delivery.NextAttemptAt, statusCode, body, errMsg, retryAfter.Seconds())
+	return claimHeld(result, err, ErrWebhookDeliveryLost)
+}
@@ -0,0 +1,86 @@
+// Package stream wakes up in-process subscribers when Postgres notifies a channel.
+package stream
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Hub fans out notifications to subscribers. Subscribers only learn that
+// something changed and read the changes themselves, so a slow subscriber
+// misses nothing: wake-ups are coalesced, never queued.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+// NewHub creates a hub with no subscribers
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel that receives a value after each notification,
+// and a function that unsubscribes
+func (h *Hub) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Broadcast wakes up every subscriber
+func (h *Hub) Broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// Already has a pending wake-up
+		}
+	}
+}
+
+// Listen broadcasts every notification on a Postgres channel until ctx is
+// cancelled. Subscribers are also woken after a reconnect, since
+// notifications may have been missed while disconnected.
+func (h *Hub) Listen(ctx context.Context, dbURL, channel string) {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Listener for %s: %v", channel, err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		log.Printf("Failed to listen on %s: %v", channel, err)
+		return
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Notify:
+			// A nil notification means the connection was re-established
+			h.Broadcast()
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
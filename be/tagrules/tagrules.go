@@ -0,0 +1,126 @@
+// Package tagrules matches content against user- and admin-defined tag rules.
+package tagrules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// Matcher is a compiled tag rule
+type Matcher struct {
+	Rule    models.TagRule
+	keyword string
+	pattern *regexp.Regexp
+	hashtag string
+}
+
+// Compile validates a rule and prepares it for matching
+func Compile(rule models.TagRule) (*Matcher, error) {
+	m := &Matcher{Rule: rule}
+	if rule.Keyword != nil {
+		m.keyword = strings.ToLower(*rule.Keyword)
+	}
+	if rule.Pattern != nil {
+		pattern, err := regexp.Compile(*rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		m.pattern = pattern
+	}
+	if rule.Hashtag != nil {
+		m.hashtag = entities.Normalize(entities.KindHashtag, *rule.Hashtag)
+	}
+	return m, nil
+}
+
+// CompileAll compiles rules, skipping disabled rules and rules that fail to compile
+func CompileAll(rules []models.TagRule) []*Matcher {
+	var matchers []*Matcher
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if m, err := Compile(rule); err == nil {
+			matchers = append(matchers, m)
+		}
+	}
+	return matchers
+}
+
+// Match reports whether content satisfies every condition the rule sets and
+// falls in the rule's scope
+func (m *Matcher) Match(content models.Content) bool {
+	if m.Rule.OwnerID != nil && *m.Rule.OwnerID != content.UserID {
+		return false
+	}
+	if m.Rule.Platform != nil && *m.Rule.Platform != content.Platform {
+		return false
+	}
+	if m.Rule.SocialAccountID != nil && (content.SocialAccountID == nil || *content.SocialAccountID != *m.Rule.SocialAccountID) {
+		return false
+	}
+
+	text := contentText(content)
+	if m.keyword != "" && !strings.Contains(strings.ToLower(text), m.keyword) {
+		return false
+	}
+	if m.pattern != nil && !m.pattern.MatchString(text) {
+		return false
+	}
+	if m.hashtag != "" && !containsHashtag(content, text, m.hashtag) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the tags all matching rules add to content that it does not
+// carry yet, in rule order
+func Apply(matchers []*Matcher, content models.Content) []string {
+	has := make(map[string]bool)
+	for _, tag := range content.Tags {
+		has[tag] = true
+	}
+
+	var added []string
+	for _, m := range matchers {
+		if !m.Match(content) {
+			continue
+		}
+		for _, tag := range m.Rule.Tags {
+			if !has[tag] {
+				has[tag] = true
+				added = append(added, tag)
+			}
+		}
+	}
+	return added
+}
+
+func contentText(content models.Content) string {
+	var parts []string
+	if content.OriginalText != nil {
+		parts = append(parts, *content.OriginalText)
+	}
+	if content.Description != nil {
+		parts = append(parts, *content.Description)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// containsHashtag uses the stored hashtags when content has them and parses the text otherwise
+func containsHashtag(content models.Content, text, hashtag string) bool {
+	hashtags := content.Hashtags
+	if hashtags == nil {
+		hashtags = entities.Values(entities.Extract(text), entities.KindHashtag)
+	}
+	for _, h := range hashtags {
+		if h == hashtag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package tagrules
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func post(text string) models.Content {
+	return models.Content{UserID: 1, Platform: "twitter", SocialAccountID: ptr(10), OriginalText: ptr(text)}
+}
+
+func compile(t *testing.T, rule models.TagRule) *Matcher {
+	t.Helper()
+
+	rule.Enabled = true
+	m, err := Compile(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMatch(t *testing.T) {
+	described := post("Unboxing day")
+	described.Description = ptr("Sponsored by ACME")
+	withHashtags := post("no tags in this text")
+	withHashtags.Hashtags = []string{"golang"}
+
+	for _, tc := range []struct {
+		name    string
+		rule    models.TagRule
+		content models.Content
+		want    bool
+	}{
+		{"keyword", models.TagRule{Keyword: ptr("giveaway")}, post("Big giveaway today"), true},
+		{"keyword missing", models.TagRule{Keyword: ptr("giveaway")}, post("Nothing here"), false},
+		{"keyword folds case", models.TagRule{Keyword: ptr("GiveAway")}, post("BIG GIVEAWAY"), true},
+		{"keyword folds unicode case", models.TagRule{Keyword: ptr("zażółć")}, post("ZAŻÓŁĆ gęślą jaźń"), true},
+		{"keyword in description", models.TagRule{Keyword: ptr("acme")}, described, true},
+		{"pattern", models.TagRule{Pattern: ptr(`\bv\d+\.\d+\b`)}, post("Released v1.2 today"), true},
+		{"pattern is case sensitive", models.TagRule{Pattern: ptr(`Promo`)}, post("promo code"), false},
+		{"pattern with case folding flag", models.TagRule{Pattern: ptr(`(?i)Promo`)}, post("promo code"), true},
+		{"hashtag", models.TagRule{Hashtag: ptr("golang")}, post("Learning #golang"), true},
+		{"hashtag folds case", models.TagRule{Hashtag: ptr("#GoLang")}, post("Learning #GOLANG"), true},
+		{"hashtag is not a keyword", models.TagRule{Hashtag: ptr("golang")}, post("Learning golang"), false},
+		{"hashtag is not a prefix", models.TagRule{Hashtag: ptr("go")}, post("Learning #golang"), false},
+		{"stored hashtags", models.TagRule{Hashtag: ptr("golang")}, withHashtags, true},
+		{"platform", models.TagRule{Platform: ptr("twitter")}, post("anything"), true},
+		{"other platform", models.TagRule{Platform: ptr("instagram")}, post("anything"), false},
+		{"social account", models.TagRule{SocialAccountID: ptr(10)}, post("anything"), true},
+		{"other social account", models.TagRule{SocialAccountID: ptr(11)}, post("anything"), false},
+		{"social account of added content", models.TagRule{SocialAccountID: ptr(10)}, models.Content{UserID: 1, Platform: "twitter"}, false},
+		{"owner", models.TagRule{OwnerID: ptr(1), Keyword: ptr("day")}, post("Good day"), true},
+		{"other owner", models.TagRule{OwnerID: ptr(2), Keyword: ptr("day")}, post("Good day"), false},
+		{"every condition", models.TagRule{Keyword: ptr("launch"), Hashtag: ptr("golang")}, post("launch of #golang"), true},
+		{"one condition failing", models.TagRule{Keyword: ptr("launch"), Hashtag: ptr("golang")}, post("launch of #rust"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := compile(t, tc.rule).Match(tc.content); got != tc.want {
+				t.Errorf("Match = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsInvalidPattern(t *testing.T) {
+	if _, err := Compile(models.TagRule{Pattern: ptr(`(unclosed`)}); err == nil {
+		t.Error("compiled an invalid pattern")
+	}
+}
+
+func TestCompileAll(t *testing.T) {
+	rules := []models.TagRule{
+		{ID: 1, Enabled: true, Keyword: ptr("a")},
+		{ID: 2, Enabled: false, Keyword: ptr("b")},
+		{ID: 3, Enabled: true, Pattern: ptr(`(unclosed`)},
+		{ID: 4, Enabled: true, Hashtag: ptr("c")},
+	}
+
+	var ids []int
+	for _, m := range CompileAll(rules) {
+		ids = append(ids, m.Rule.ID)
+	}
+	if want := []int{1, 4}; !slices.Equal(ids, want) {
+		t.Errorf("compiled rules %v, want %v", ids, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	giveaway := models.TagRule{Keyword: ptr("giveaway"), Tags: []string{"promo", "giveaway"}}
+	sponsored := models.TagRule{Hashtag: ptr("ad"), Tags: []string{"sponsored", "promo"}}
+	instagram := models.TagRule{Platform: ptr("instagram"), Tags: []string{"instagram"}}
+	otherOwner := models.TagRule{OwnerID: ptr(2), Keyword: ptr("giveaway"), Tags: []string{"bobs"}}
+
+	tagged := post("Giveaway! #ad")
+	tagged.Tags = []string{"promo"}
+
+	for _, tc := range []struct {
+		name    string
+		rules   []models.TagRule
+		content models.Content
+		want    []string
+	}{
+		{"no rules", nil, post("Giveaway!"), nil},
+		{"one rule", []models.TagRule{giveaway}, post("Giveaway!"), []string{"promo", "giveaway"}},
+		{"no match", []models.TagRule{giveaway, instagram}, post("Hello"), nil},
+		{"overlapping rules add a tag once, in rule order", []models.TagRule{sponsored, giveaway}, post("Giveaway! #ad"), []string{"sponsored", "promo", "giveaway"}},
+		{"tags the content carries are not added", []models.TagRule{giveaway, sponsored}, tagged, []string{"giveaway", "sponsored"}},
+		{"rules out of scope add nothing", []models.TagRule{otherOwner, instagram, giveaway}, post("Giveaway!"), []string{"promo", "giveaway"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var matchers []*Matcher
+			for _, rule := range tc.rules {
+				matchers = append(matchers, compile(t, rule))
+			}
+			if got := Apply(matchers, tc.content); !slices.Equal(got, tc.want) {
+				t.Errorf("Apply = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package twitter
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotConfigured is returned for requests without credentials
+	ErrNotConfigured = errors.New("twitter client not configured: missing credentials")
+	// ErrTokenRefresh is wrapped by errors of user auth that could not renew
+	// an expired or revoked access token. The account has to be reconnected.
+	ErrTokenRefresh = errors.New("failed to refresh access token")
+)
+
+// Auth authorizes requests to the X API
+type Auth interface {
+	Authorize(req *http.Request) error
+}
+
+// renewer is implemented by auth that can get new credentials after the API
+// rejected the current ones
+type renewer interface {
+	renew() error
+}
+
+// credentialed is implemented by auth that knows which credential's rate
+// limit budget its requests count against
+type credentialed interface {
+	credential() string
+}
+
+// BearerAuth authorizes requests as the app with an app-only bearer token
+type BearerAuth struct {
+	Token string
+}
+
+// Authorize sets the bearer token
+func (a BearerAuth) Authorize(req *http.Request) error {
+	if a.Token == "" {
+		return ErrNotConfigured
+	}
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+func (a BearerAuth) credential() string { return credentialID("app", a.Token) }
+
+// Token is a user's OAuth 2.0 access token
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	// ExpiresAt is when the access token expires, or zero if unknown
+	ExpiresAt time.Time
+}
+
+// expired reports if the token expires within the next minute
+func (t Token) expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().Add(time.Minute).After(t.ExpiresAt)
+}
+
+// UserAuth authorizes requests as a user with an OAuth 2.0 access token. An
+// expired token is refreshed before it is used, and a rejected token once
+// before the request is retried.
+type UserAuth struct {
+	oauth     *OAuthHandler
+	onRefresh func(Token)
+
+	mu    sync.Mutex
+	token Token
+}
+
+// NewUserAuth creates user auth that refreshes token with the OAuth client
+// of oauth. onRefresh, if not nil, is called with every new token so it can
+// be stored; refresh tokens can only be used once.
+func NewUserAuth(token Token, oauth *OAuthHandler, onRefresh func(Token)) *UserAuth {
+	return &UserAuth{
+		oauth:     oauth,
+		onRefresh: onRefresh,
+		token:     token,
+	}
+}
+
+// Authorize sets the access token, refreshing it first if it has expired
+func (a *UserAuth) Authorize(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token.AccessToken == "" {
+		return ErrNotConfigured
+	}
+	if a.token.expired() && a.token.RefreshToken != "" {
+		if err := a.refresh(); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token.AccessToken)
+	return nil
+}
+
+func (a *UserAuth) renew() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.refresh()
+}
+
+// refresh exchanges the refresh token for a new token. a.mu must be held.
+func (a *UserAuth) refresh() error {
+	if a.token.RefreshToken == "" || a.oauth == nil {
+		return fmt.Errorf("%w: no refresh token", ErrTokenRefresh)
+	}
+
+	tokenResp, err := a.oauth.RefreshAccessToken(a.token.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTokenRefresh, err)
+	}
+
+	refreshToken := tokenResp.RefreshToken
+	if refreshToken == "" {
+		// Refresh tokens are not always rotated
+		refreshToken = a.token.RefreshToken
+	}
+	a.token = Token{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}
+	if a.onRefresh != nil {
+		a.onRefresh(a.token)
+	}
+	return nil
+}
+
+func (a *UserAuth) credential() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return credentialID("user", a.token.AccessToken)
+}
+
+// Token returns the current token, which may have been refreshed
+func (a *UserAuth) Token() Token {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.token
+}
+
+// OAuth1Auth authorizes requests in OAuth 1.0a user context, signing them
+// with HMAC-SHA1 as described in RFC 5849. Only query parameters are signed,
+// which covers every request the client makes.
+type OAuth1Auth struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	Token          string
+	TokenSecret    string
+
+	// nonce and now are replaced in tests
+	nonce func() string
+	now   func() time.Time
+}
+
+// Authorize signs the request and sets the OAuth authorization header
+func (a OAuth1Auth) Authorize(req *http.Request) error {
+	if a.ConsumerKey == "" || a.ConsumerSecret == "" || a.Token == "" || a.TokenSecret == "" {
+		return ErrNotConfigured
+	}
+
+	nonce, now := a.nonce, a.now
+	if nonce == nil {
+		nonce = func() string {
+			n, _ := generateRandomString(32)
+			return n
+		}
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     a.ConsumerKey,
+		"oauth_nonce":            nonce(),
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(now().Unix(), 10),
+		"oauth_token":            a.Token,
+		"oauth_version":          "1.0",
+	}
+
+	var params []string
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, percentEncode(key)+"="+percentEncode(value))
+		}
+	}
+	for key, value := range oauthParams {
+		params = append(params, percentEncode(key)+"="+percentEncode(value))
+	}
+	sort.Strings(params)
+
+	baseURL := strings.ToLower(req.URL.Scheme) + "://" + strings.ToLower(req.URL.Host) + req.URL.EscapedPath()
+	base := strings.ToUpper(req.Method) + "&" + percentEncode(baseURL) + "&" + percentEncode(strings.Join(params, "&"))
+
+	mac := hmac.New(sha1.New, []byte(percentEncode(a.ConsumerSecret)+"&"+percentEncode(a.TokenSecret)))
+	mac.Write([]byte(base))
+	oauthParams["oauth_signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	keys := make([]string, 0, len(oauthParams))
+	for key := range oauthParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	header := make([]string, 0, len(keys))
+	for _, key := range keys {
+		header = append(header, fmt.Sprintf("%s=%q", percentEncode(key), percentEncode(oauthParams[key])))
+	}
+	req.Header.Set("Authorization", "OAuth "+strings.Join(header, ", "))
+	return nil
+}
+
+func (a OAuth1Auth) credential() string { return credentialID("oauth1", a.Token) }
+
+// percentEncode encodes s as RFC 3986 requires for OAuth 1.0a signatures
+func percentEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
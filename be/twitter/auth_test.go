@@ -0,0 +1,57 @@
+package twitter
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOAuth1Signature signs the example request from X's "Creating a
+// signature" guide. Parameters go in the query instead of the form body, which
+// yields the same signature base string.
+func TestOAuth1Signature(t *testing.T) {
+	auth := OAuth1Auth{
+		ConsumerKey:    "xvz1evFS4wEEPTGEFPHBog",
+		ConsumerSecret: "kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw",
+		Token:          "370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb",
+		TokenSecret:    "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE",
+		nonce:          func() string { return "kYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg" },
+		now:            func() time.Time { return time.Unix(1318622958, 0) },
+	}
+	query := url.Values{
+		"include_entities": {"true"},
+		"status":           {"Hello Ladies + Gentlemen, a signed OAuth request!"},
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://api.twitter.com/1.1/statuses/update.json?"+query.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := auth.Authorize(req); err != nil {
+		t.Fatal(err)
+	}
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "OAuth ") {
+		t.Fatalf("authorization = %q, want OAuth parameters", header)
+	}
+	if want := `oauth_signature="hCtSmYh%2BiHYCEqBWrE7C7hYmtUk%3D"`; !strings.Contains(header, want) {
+		t.Errorf("authorization = %q, want %s", header, want)
+	}
+}
+
+func TestPercentEncode(t *testing.T) {
+	tests := map[string]string{
+		"Ladies + Gentlemen": "Ladies%20%2B%20Gentlemen",
+		"An encoded string!": "An%20encoded%20string%21",
+		"Dogs, Cats & Mice":  "Dogs%2C%20Cats%20%26%20Mice",
+		"a-b_c.d~e*":         "a-b_c.d~e%2A",
+		"☃":                  "%E2%98%83",
+	}
+	for in, want := range tests {
+		if got := percentEncode(in); got != want {
+			t.Errorf("percentEncode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,14 +1,15 @@
 package twitter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -36,7 +37,7 @@ func IsRateLimitError(err error) (*RateLimitError, bool) {
 // parseRateLimitError extracts retry-after information from response headers
 func parseRateLimitError(resp *http.Response) *RateLimitError {
 	retryAfter := 0
-	
+
 	// Try x-rate-limit-reset header (Unix timestamp)
 	if resetStr := resp.Header.Get("x-rate-limit-reset"); resetStr != "" {
 		if resetTime, err := strconv.ParseInt(resetStr, 10, 64); err == nil {
@@ -46,7 +47,7 @@ func parseRateLimitError(resp *http.Response) *RateLimitError {
 			}
 		}
 	}
-	
+
 	// Try Retry-After header (seconds)
 	if retryAfter == 0 {
 		if retryStr := resp.Header.Get("Retry-After"); retryStr != "" {
@@ -55,334 +56,360 @@ func parseRateLimitError(resp *http.Response) *RateLimitError {
 			}
 		}
 	}
-	
+
 	// Default to 15 minutes if no header found (Twitter's typical rate limit window)
 	if retryAfter == 0 {
 		retryAfter = 900
 	}
-	
-	return &RateLimitError{RetryAfter: retryAfter}
-}
-
-// Client handles Twitter/X API interactions
-type Client struct {
-	httpClient  *http.Client
-	bearerToken string
-	baseURL     string
-}
-
-// UserClient handles Twitter/X API interactions with user OAuth tokens
-type UserClient struct {
-	httpClient  *http.Client
-	accessToken string
-	baseURL     string
-}
 
-// Tweet represents a tweet from the X API
-type Tweet struct {
-	ID        string    `json:"id"`
-	Text      string    `json:"text"`
-	CreatedAt time.Time `json:"created_at"`
-	AuthorID  string    `json:"author_id"`
-}
-
-// TweetsResponse represents the API response for tweets
-type TweetsResponse struct {
-	Data []Tweet `json:"data"`
-	Meta struct {
-		ResultCount   int    `json:"result_count"`
-		NextToken     string `json:"next_token"`
-		PreviousToken string `json:"previous_token"`
-	} `json:"meta"`
-	Errors []APIError `json:"errors,omitempty"`
-}
-
-// UserResponse represents the API response for user lookup
-type UserResponse struct {
-	Data struct {
-		ID       string `json:"id"`
-		Name     string `json:"name"`
-		Username string `json:"username"`
-	} `json:"data"`
-	Errors []APIError `json:"errors,omitempty"`
+	return &RateLimitError{RetryAfter: retryAfter}
 }
 
-// APIError represents an error from the X API
+// APIError is an error reported by the X API or its OAuth endpoints. Type is
+// the problem type URI, e.g. https://api.twitter.com/2/problems/resource-not-found.
+// Status is the HTTP status, or 0 for errors reported in a successful response.
 type APIError struct {
 	Title  string `json:"title"`
 	Detail string `json:"detail"`
 	Type   string `json:"type"`
+	Status int    `json:"status"`
+	// ResourceID is the ID of the resource a partial error is about
+	ResourceID string `json:"resource_id,omitempty"`
 }
 
-// NewClient creates a new Twitter API client
-func NewClient() *Client {
-	bearerToken := os.Getenv("TWITTER_BEARER_TOKEN")
-
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		bearerToken: bearerToken,
-		baseURL:     "https://api.x.com/2",
+func (e *APIError) Error() string {
+	msg := e.Title
+	if e.Detail != "" && e.Detail != e.Title {
+		msg += ": " + e.Detail
 	}
+	if e.Status != 0 {
+		return fmt.Sprintf("X API error (status %d): %s", e.Status, msg)
+	}
+	return "X API error: " + msg
 }
 
-// NewClientWithToken creates a new Twitter API client with specific token
-func NewClientWithToken(bearerToken string) *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		bearerToken: bearerToken,
-		baseURL:     "https://api.x.com/2",
+// IsAPIError checks if an error is an error reported by the X API (including wrapped errors)
+func IsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
 	}
+	return nil, false
 }
 
-// IsConfigured returns true if the client has necessary credentials
-func (c *Client) IsConfigured() bool {
-	return c.bearerToken != ""
+// parseAPIError reads the error of a failed response. The v2 API answers
+// with a problem object, or a list of errors for invalid requests, and the
+// OAuth endpoints with an error code and description.
+func parseAPIError(status int, body []byte) *APIError {
+	var problem struct {
+		APIError
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		OAuthError       string `json:"error"`
+		OAuthDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &problem)
+
+	apiErr := problem.APIError
+	apiErr.Status = status
+	if apiErr.Title == "" && problem.OAuthError != "" {
+		apiErr.Title, apiErr.Detail = problem.OAuthError, problem.OAuthDescription
+	}
+	if len(problem.Errors) > 0 && problem.Errors[0].Message != "" {
+		apiErr.Detail = problem.Errors[0].Message
+	}
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(status)
+		apiErr.Detail = string(body)
+	}
+	return &apiErr
 }
 
-// GetUserByUsername fetches a user's profile by username
-func (c *Client) GetUserByUsername(username string) (*UserResponse, error) {
-	if !c.IsConfigured() {
-		return nil, fmt.Errorf("twitter client not configured: missing bearer token")
-	}
-
-	endpoint := fmt.Sprintf("%s/users/by/username/%s", c.baseURL, url.PathEscape(username))
-
-	req, err := http.NewRequest("GET", endpoint, nil)
+// do sends a request and decodes the JSON response into v. Failed requests
+// return a *RateLimitError or an *APIError. observe, if not nil, sees every
+// response before it is handled.
+func (o Options) do(req *http.Request, v interface{}, observe func(*http.Response)) error {
+	resp, err := o.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to make request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("Authorization", "Bearer "+c.bearerToken)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+	if observe != nil {
+		observe(resp)
 	}
-	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, parseRateLimitError(resp)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return parseRateLimitError(resp)
 	}
 
-	var userResp UserResponse
-	if err := json.Unmarshal(body, &userResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseAPIError(resp.StatusCode, body)
 	}
 
-	if len(userResp.Errors) > 0 {
-		return nil, fmt.Errorf("API error: %s - %s", userResp.Errors[0].Title, userResp.Errors[0].Detail)
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
-
-	return &userResp, nil
+	return nil
 }
 
-// GetUserTweets fetches recent tweets for a user by their ID
-func (c *Client) GetUserTweets(userID string, maxResults int, sinceID string) (*TweetsResponse, error) {
-	if !c.IsConfigured() {
-		return nil, fmt.Errorf("twitter client not configured: missing bearer token")
-	}
+// tweetFields are the fields requested for tweets
+const tweetFields = "created_at,author_id,text,entities,edit_history_tweet_ids,conversation_id,referenced_tweets,in_reply_to_user_id"
 
-	if maxResults <= 0 || maxResults > 100 {
-		maxResults = 10
-	}
-
-	endpoint := fmt.Sprintf("%s/users/%s/tweets", c.baseURL, url.PathEscape(userID))
-
-	params := url.Values{}
-	params.Set("max_results", fmt.Sprintf("%d", maxResults))
-	params.Set("tweet.fields", "created_at,author_id,text")
-
-	if sinceID != "" {
-		params.Set("since_id", sinceID)
-	}
-
-	fullURL := endpoint + "?" + params.Encode()
-
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+c.bearerToken)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
+// mediaFields are the fields requested for attached media, which are
+// expanded into the includes of a response
+const (
+	mediaExpansions = "attachments.media_keys"
+	mediaFields     = "type,url,preview_image_url,width,height,duration_ms,alt_text,variants"
+)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+// maxLookupIDs is how many tweets can be looked up at once
+const maxLookupIDs = 100
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, parseRateLimitError(resp)
-	}
+// Client handles Twitter/X API interactions. How requests are authorized,
+// as the app or as a user, is up to its Auth. Calls are paced by the
+// Governor of its options.
+type Client struct {
+	opts Options
+	auth Auth
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
+// User is an X account
+type User struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
 
-	var tweetsResp TweetsResponse
-	if err := json.Unmarshal(body, &tweetsResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+// Tweet represents a tweet from the X API
+type Tweet struct {
+	ID        string         `json:"id"`
+	Text      string         `json:"text"`
+	CreatedAt time.Time      `json:"created_at"`
+	AuthorID  string         `json:"author_id"`
+	Entities  *TweetEntities `json:"entities,omitempty"`
+	// EditHistoryTweetIDs are the IDs of all versions of the tweet, oldest first
+	EditHistoryTweetIDs []string `json:"edit_history_tweet_ids,omitempty"`
+	Attachments         *struct {
+		MediaKeys []string `json:"media_keys"`
+	} `json:"attachments,omitempty"`
+	// ConversationID is the ID of the tweet that started the thread the tweet is in
+	ConversationID   string            `json:"conversation_id,omitempty"`
+	InReplyToUserID  string            `json:"in_reply_to_user_id,omitempty"`
+	ReferencedTweets []ReferencedTweet `json:"referenced_tweets,omitempty"`
+}
 
-	if len(tweetsResp.Errors) > 0 {
-		return nil, fmt.Errorf("API error: %s - %s", tweetsResp.Errors[0].Title, tweetsResp.Errors[0].Detail)
-	}
+// Types of tweets a tweet refers to
+const (
+	ReferenceRepliedTo = "replied_to"
+	ReferenceQuoted    = "quoted"
+	ReferenceRetweeted = "retweeted"
+)
 
-	return &tweetsResp, nil
+// ReferencedTweet is a tweet that a tweet replies to, quotes or retweets
+type ReferencedTweet struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
 }
 
-// TweetToLink converts a tweet ID and username to a full URL
-func TweetToLink(username, tweetID string) string {
-	return fmt.Sprintf("https://x.com/%s/status/%s", username, tweetID)
+// Media is a photo, video or animated GIF attached to a tweet. Only photos
+// have a URL; videos and GIFs come as variants in different formats.
+type Media struct {
+	MediaKey        string `json:"media_key"`
+	Type            string `json:"type"`
+	URL             string `json:"url,omitempty"`
+	PreviewImageURL string `json:"preview_image_url,omitempty"`
+	Width           int    `json:"width,omitempty"`
+	Height          int    `json:"height,omitempty"`
+	DurationMs      int    `json:"duration_ms,omitempty"`
+	AltText         string `json:"alt_text,omitempty"`
+	Variants        []struct {
+		BitRate     int    `json:"bit_rate,omitempty"`
+		ContentType string `json:"content_type"`
+		URL         string `json:"url"`
+	} `json:"variants,omitempty"`
 }
 
-// NewUserClient creates a new Twitter API client with user OAuth access token
-func NewUserClient(accessToken string) *UserClient {
-	return &UserClient{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		accessToken: accessToken,
-		baseURL:     "https://api.x.com/2",
-	}
+// TweetEntities are the hashtags, mentions, cashtags and URLs the X API parsed out of a tweet
+type TweetEntities struct {
+	Hashtags []struct {
+		Tag string `json:"tag"`
+	} `json:"hashtags"`
+	Mentions []struct {
+		Username string `json:"username"`
+	} `json:"mentions"`
+	Cashtags []struct {
+		Tag string `json:"tag"`
+	} `json:"cashtags"`
+	URLs []struct {
+		URL         string `json:"url"`
+		ExpandedURL string `json:"expanded_url"`
+		MediaKey    string `json:"media_key"`
+	} `json:"urls"`
 }
 
-// IsConfigured returns true if the user client has an access token
-func (c *UserClient) IsConfigured() bool {
-	return c.accessToken != ""
+// TweetsResponse represents the API response for tweets
+type TweetsResponse struct {
+	Data []Tweet `json:"data"`
+	Meta struct {
+		ResultCount   int    `json:"result_count"`
+		NextToken     string `json:"next_token"`
+		PreviousToken string `json:"previous_token"`
+	} `json:"meta"`
+	// Includes has the media the tweets' attachments refer to by key
+	Includes struct {
+		Media []Media `json:"media"`
+	} `json:"includes"`
+	Errors []APIError `json:"errors,omitempty"`
 }
 
-// GetAuthenticatedUser fetches the authenticated user's profile
-func (c *UserClient) GetAuthenticatedUser() (*UserResponse, error) {
-	if !c.IsConfigured() {
-		return nil, fmt.Errorf("user client not configured: missing access token")
-	}
-
-	endpoint := fmt.Sprintf("%s/users/me", c.baseURL)
-
-	req, err := http.NewRequest("GET", endpoint, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+// UserResponse represents the API response for user lookup
+type UserResponse struct {
+	Data   User       `json:"data"`
+	Errors []APIError `json:"errors,omitempty"`
+}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, parseRateLimitError(resp)
+// NewClient creates a new Twitter API client that authorizes its requests with auth
+func NewClient(auth Auth, opts Options) *Client {
+	return &Client{
+		opts: opts.withDefaults(),
+		auth: auth,
 	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
+// IsConfigured returns true if the client has necessary credentials
+func (c *Client) IsConfigured() bool {
+	return c.auth != nil
+}
 
+// GetUserByUsername fetches a user's profile by username
+func (c *Client) GetUserByUsername(ctx context.Context, username string) (*UserResponse, error) {
 	var userResp UserResponse
-	if err := json.Unmarshal(body, &userResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := c.get(ctx, "/users/by/username/:username", "/users/by/username/"+url.PathEscape(username), nil, &userResp); err != nil {
+		return nil, err
+	}
+	if len(userResp.Errors) > 0 {
+		return nil, &userResp.Errors[0]
 	}
-
 	return &userResp, nil
 }
 
-// GetMyTweets fetches the authenticated user's recent tweets
-func (c *UserClient) GetMyTweets(maxResults int, sinceID string) (*TweetsResponse, error) {
-	if !c.IsConfigured() {
-		return nil, fmt.Errorf("user client not configured: missing access token")
-	}
-
-	// First get the user ID
-	userResp, err := c.GetAuthenticatedUser()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get authenticated user: %w", err)
+// GetAuthenticatedUser fetches the profile of the user the client acts for.
+// It needs user authentication.
+func (c *Client) GetAuthenticatedUser(ctx context.Context) (*UserResponse, error) {
+	var userResp UserResponse
+	if err := c.get(ctx, "/users/me", "/users/me", nil, &userResp); err != nil {
+		return nil, err
 	}
-
-	return c.GetUserTweets(userResp.Data.ID, maxResults, sinceID)
+	return &userResp, nil
 }
 
-// GetUserTweets fetches recent tweets for a user by their ID
-func (c *UserClient) GetUserTweets(userID string, maxResults int, sinceID string) (*TweetsResponse, error) {
-	if !c.IsConfigured() {
-		return nil, fmt.Errorf("user client not configured: missing access token")
-	}
-
+// GetUserTweets fetches recent tweets for a user by their ID, newest first.
+// paginationToken is the next token of the previous page, if any.
+func (c *Client) GetUserTweets(ctx context.Context, userID string, maxResults int, sinceID, paginationToken string) (*TweetsResponse, error) {
 	if maxResults <= 0 || maxResults > 100 {
 		maxResults = 10
 	}
 
-	endpoint := fmt.Sprintf("%s/users/%s/tweets", c.baseURL, url.PathEscape(userID))
-
 	params := url.Values{}
 	params.Set("max_results", fmt.Sprintf("%d", maxResults))
-	params.Set("tweet.fields", "created_at,author_id,text")
+	params.Set("tweet.fields", tweetFields)
+	params.Set("expansions", mediaExpansions)
+	params.Set("media.fields", mediaFields)
 
 	if sinceID != "" {
 		params.Set("since_id", sinceID)
 	}
+	if paginationToken != "" {
+		params.Set("pagination_token", paginationToken)
+	}
 
-	fullURL := endpoint + "?" + params.Encode()
+	var tweetsResp TweetsResponse
+	if err := c.get(ctx, "/users/:id/tweets", "/users/"+url.PathEscape(userID)+"/tweets", params, &tweetsResp); err != nil {
+		return nil, err
+	}
+	if len(tweetsResp.Errors) > 0 && len(tweetsResp.Data) == 0 {
+		return nil, &tweetsResp.Errors[0]
+	}
+	return &tweetsResp, nil
+}
 
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// GetTweets looks up tweets by ID. Tweets that could not be returned, e.g.
+// because they were deleted, are reported in the response's errors.
+func (c *Client) GetTweets(ctx context.Context, ids []string) (*TweetsResponse, error) {
+	if len(ids) == 0 || len(ids) > maxLookupIDs {
+		return nil, fmt.Errorf("can look up 1 to %d tweets at once, not %d", maxLookupIDs, len(ids))
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	params := url.Values{}
+	params.Set("ids", strings.Join(ids, ","))
+	params.Set("tweet.fields", tweetFields)
+	params.Set("expansions", mediaExpansions)
+	params.Set("media.fields", mediaFields)
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+	var tweetsResp TweetsResponse
+	if err := c.get(ctx, "/tweets", "/tweets", params, &tweetsResp); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return &tweetsResp, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+// get requests an API path of endpoint, the path pattern its rate limit
+// applies to. A request rejected as unauthorized is retried once if the auth
+// could renew its credentials.
+func (c *Client) get(ctx context.Context, endpoint, path string, params url.Values, v interface{}) error {
+	if !c.IsConfigured() {
+		return ErrNotConfigured
 	}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, parseRateLimitError(resp)
+	reqURL := c.opts.APIBaseURL + path
+	if len(params) > 0 {
+		reqURL += "?" + params.Encode()
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
+	for attempt := 0; ; attempt++ {
+		req, err := c.opts.newRequest("GET", reqURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req = req.WithContext(ctx)
+		if err := c.auth.Authorize(req); err != nil {
+			return err
+		}
 
-	var tweetsResp TweetsResponse
-	if err := json.Unmarshal(body, &tweetsResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+		// The credential is read after authorizing, which may have refreshed it
+		credential := fmt.Sprintf("%T", c.auth)
+		if cred, ok := c.auth.(credentialed); ok {
+			credential = cred.credential()
+		}
+		if err := c.opts.Governor.acquire(ctx, endpoint, credential); err != nil {
+			return err
+		}
 
-	if len(tweetsResp.Errors) > 0 {
-		return nil, fmt.Errorf("API error: %s - %s", tweetsResp.Errors[0].Title, tweetsResp.Errors[0].Detail)
+		err = c.opts.do(req, v, func(resp *http.Response) {
+			c.opts.Governor.update(endpoint, credential, resp)
+		})
+		apiErr, ok := IsAPIError(err)
+		if !ok || apiErr.Status != http.StatusUnauthorized || attempt > 0 {
+			return err
+		}
+		renewer, ok := c.auth.(renewer)
+		if !ok {
+			return err
+		}
+		if renewErr := renewer.renew(); renewErr != nil {
+			return renewErr
+		}
 	}
+}
 
-	return &tweetsResp, nil
+// TweetToLink converts a tweet ID and username to a full URL
+func TweetToLink(username, tweetID string) string {
+	return fmt.Sprintf("https://x.com/%s/status/%s", username, tweetID)
 }
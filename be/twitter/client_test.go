@@ -0,0 +1,448 @@
+package twitter
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
+	"github.com/Armatorix/SocialTracker/be/twitter/xfake"
+)
+
+const testAppToken = "app-token"
+
+// newFakeX starts a fake X API with one user and returns options pointing at it
+func newFakeX(t *testing.T) (*xfake.Server, Options) {
+	t.Helper()
+
+	x := xfake.New(t)
+	x.AddAppToken(testAppToken)
+	x.SetClient("client-id", "client-secret")
+	x.AddUser("42", "alice", "Alice")
+
+	return x, Options{
+		BearerToken: testAppToken,
+		OAuth: OAuthConfig{
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			RedirectURI:  "https://tracker.example.com/api/auth/twitter/callback",
+		},
+		APIBaseURL:   x.APIBaseURL(),
+		AuthorizeURL: x.AuthorizeURL(),
+		TokenURL:     x.TokenURL(),
+	}
+}
+
+func postedAt(minute int) time.Time {
+	return time.Date(2026, 3, 1, 12, minute, 0, 0, time.UTC)
+}
+
+func TestClientGetUserByUsername(t *testing.T) {
+	_, opts := newFakeX(t)
+	client := NewClient(BearerAuth{Token: testAppToken}, opts)
+
+	user, err := client.GetUserByUsername(t.Context(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Data.ID != "42" || user.Data.Name != "Alice" {
+		t.Errorf("user = %+v, want alice with id 42", user.Data)
+	}
+
+	_, err = client.GetUserByUsername(t.Context(), "nobody")
+	apiErr, ok := IsAPIError(err)
+	if !ok || apiErr.Title != "Not Found Error" || apiErr.Type != "https://api.twitter.com/2/problems/resource-not-found" {
+		t.Errorf("looking up a missing user returned %v, want a not found error", err)
+	}
+}
+
+func TestClientGetUserTweets(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddTweets("42",
+		xfake.Tweet{ID: "101", Text: "first", CreatedAt: postedAt(1)},
+		xfake.Tweet{ID: "102", Text: "second #Go", CreatedAt: postedAt(2), Hashtags: []string{"Go"}},
+		xfake.Tweet{ID: "103", Text: "third", CreatedAt: postedAt(3)},
+	)
+	client := NewClient(BearerAuth{Token: testAppToken}, opts)
+
+	tweets, err := client.GetUserTweets(t.Context(), "42", 10, "101", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tweets.Meta.ResultCount != 2 || tweets.Data[0].ID != "103" || tweets.Data[1].ID != "102" {
+		t.Fatalf("tweets since 101 = %+v, want 103 and 102", tweets.Data)
+	}
+	if !tweets.Data[1].CreatedAt.Equal(postedAt(2)) {
+		t.Errorf("created at = %v, want %v", tweets.Data[1].CreatedAt, postedAt(2))
+	}
+	if got := tweetEntities(tweets.Data[1]); len(got) != 1 || got[0] != (entities.Entity{Kind: entities.KindHashtag, Value: "go"}) {
+		t.Errorf("entities = %v, want the #go hashtag", got)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	x, opts := newFakeX(t)
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := NewClient(BearerAuth{Token: "wrong"}, opts).GetUserByUsername(t.Context(), "alice")
+		if apiErr, ok := IsAPIError(err); !ok || apiErr.Status != http.StatusUnauthorized {
+			t.Errorf("got %v, want a 401 error", err)
+		}
+	})
+
+	t.Run("user context required", func(t *testing.T) {
+		_, err := NewClient(BearerAuth{Token: testAppToken}, opts).GetAuthenticatedUser(t.Context())
+		apiErr, ok := IsAPIError(err)
+		if !ok || apiErr.Status != http.StatusForbidden || apiErr.Type != "https://api.twitter.com/2/problems/unsupported-authentication" {
+			t.Errorf("got %v, want app-only authentication to be refused", err)
+		}
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		opts := opts
+		opts.APIBaseURL = x.URL
+		_, err := NewClient(BearerAuth{Token: testAppToken}, opts).GetUserByUsername(t.Context(), "alice")
+		if apiErr, ok := IsAPIError(err); !ok || apiErr.Status != http.StatusNotFound {
+			t.Errorf("got %v, want a 404 error", err)
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		for _, auth := range []Auth{nil, BearerAuth{}} {
+			if _, err := NewClient(auth, opts).GetUserTweets(t.Context(), "42", 10, "", ""); !errors.Is(err, ErrNotConfigured) {
+				t.Errorf("auth %#v made a request: %v", auth, err)
+			}
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		x.SetRateLimit(1, time.Minute)
+		defer x.SetRateLimit(0, 0)
+
+		client := NewClient(BearerAuth{Token: testAppToken}, opts)
+		if _, err := client.GetUserTweets(t.Context(), "42", 10, "", ""); err != nil {
+			t.Fatalf("first request failed: %v", err)
+		}
+		_, err := client.GetUserTweets(t.Context(), "42", 10, "", "")
+		rle, ok := IsRateLimitError(err)
+		if !ok {
+			t.Fatalf("got %v, want a rate limit error", err)
+		}
+		if rle.RetryAfter <= 0 || rle.RetryAfter > 60 {
+			t.Errorf("retry after %d seconds, want the rest of the minute window", rle.RetryAfter)
+		}
+	})
+}
+
+type recordingTransport struct {
+	requests int
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestOptionsHTTPClient(t *testing.T) {
+	x, opts := newFakeX(t)
+	transport := &recordingTransport{}
+	opts.Transport = transport
+	opts.UserAgent = "tracker-test/1"
+
+	syncer := NewSyncer(opts)
+	if _, err := syncer.GetTwitterUserID(t.Context(), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncer.SyncAccount(t.Context(), "alice", nil, Cursor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if transport.requests != 3 {
+		t.Errorf("transport made %d requests, want all 3", transport.requests)
+	}
+	for _, req := range x.Requests() {
+		if req.UserAgent != "tracker-test/1" {
+			t.Errorf("%s %s sent user agent %q", req.Method, req.Path, req.UserAgent)
+		}
+	}
+}
+
+func TestSyncAccount(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddTweets("42",
+		xfake.Tweet{ID: "101", Text: "hello @bob", CreatedAt: postedAt(1), Mentions: []string{"bob"}},
+		xfake.Tweet{ID: "102", Text: "see https://t.co/x", CreatedAt: postedAt(2), URLs: []string{"https://example.com/post"}},
+	)
+	syncer := NewSyncer(opts)
+
+	page, err := syncer.SyncAccount(t.Context(), "alice", nil, Cursor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != 2 {
+		t.Fatalf("synced %d tweets, want 2", len(page.Tweets))
+	}
+	if page.Tweets[0].Link != "https://x.com/alice/status/102" || !page.Tweets[0].PostedAt.Equal(postedAt(2)) {
+		t.Errorf("tweet = %+v, want 102 linked on x.com", page.Tweets[0])
+	}
+	if got := page.Tweets[0].Entities; len(got) != 1 || got[0].Value != "https://example.com/post" {
+		t.Errorf("entities = %v, want the expanded URL", got)
+	}
+
+	// With the account ID known the user is not looked up again
+	before := len(x.Requests())
+	accountID := "42"
+	page, err = syncer.SyncAccount(t.Context(), "alice", &accountID, Cursor{SinceID: "102"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != 0 {
+		t.Errorf("synced %d tweets since the latest, want none", len(page.Tweets))
+	}
+	if requests := x.Requests()[before:]; len(requests) != 1 || requests[0].Query.Get("since_id") != "102" {
+		t.Errorf("requests = %+v, want a single timeline request since 102", requests)
+	}
+}
+
+func TestSyncAccountPages(t *testing.T) {
+	x, opts := newFakeX(t)
+	for i := 0; i < maxSyncPages*syncPageSize+10; i++ {
+		x.AddTweets("42", xfake.Tweet{ID: strconv.Itoa(1000 + i), Text: "post", CreatedAt: postedAt(i % 60)})
+	}
+	syncer := NewSyncer(opts)
+	accountID := "42"
+
+	page, err := syncer.SyncAccount(t.Context(), "alice", &accountID, Cursor{SinceID: "999"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != maxSyncPages*syncPageSize || page.NextToken == "" {
+		t.Fatalf("synced %d tweets with next token %q, want %d and pages left", len(page.Tweets), page.NextToken, maxSyncPages*syncPageSize)
+	}
+
+	page, err = syncer.SyncAccount(t.Context(), "alice", &accountID, Cursor{SinceID: "999", PaginationToken: page.NextToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != 10 || page.NextToken != "" || page.Tweets[9].ExternalID != "1000" {
+		t.Errorf("resumed sync = %d tweets with next token %q, want the 10 oldest and no pages left", len(page.Tweets), page.NextToken)
+	}
+}
+
+func TestSyncAccountEditsAndDeletions(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddTweets("42",
+		xfake.Tweet{ID: "101", Text: "first", CreatedAt: postedAt(1)},
+		xfake.Tweet{ID: "102", Text: "secnod", CreatedAt: postedAt(2)},
+		xfake.Tweet{ID: "103", Text: "third", CreatedAt: postedAt(3)},
+	)
+	x.EditTweet("42", "102", "104", "second")
+	x.DeleteTweet("42", "103")
+	syncer := NewSyncer(opts)
+	accountID := "42"
+
+	page, err := syncer.SyncAccount(t.Context(), "alice", &accountID, Cursor{SinceID: "101"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != 1 || page.Tweets[0].Text != "second" || strings.Join(page.Tweets[0].EditHistoryIDs, ",") != "102,104" {
+		t.Fatalf("tweets = %+v, want the edit with its history", page.Tweets)
+	}
+
+	removed, err := syncer.FindRemovedTweets(t.Context(), []string{"101", "104", "103"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != "103" {
+		t.Errorf("removed = %v, want the deleted tweet", removed)
+	}
+}
+
+func TestSyncAccountMedia(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddTweets("42",
+		xfake.Tweet{ID: "101", Text: "look", CreatedAt: postedAt(1), Media: []xfake.Media{
+			{Key: "3_1", Type: "photo", URL: "https://pbs.twimg.com/media/1.jpg", Width: 1200, Height: 800, AltText: "a cat"},
+			{Key: "7_2", Type: "video", URL: "https://video.twimg.com/2.mp4", PreviewURL: "https://pbs.twimg.com/2.jpg", DurationMs: 15000},
+		}},
+		xfake.Tweet{ID: "102", Text: "no media", CreatedAt: postedAt(2)},
+	)
+	syncer := NewSyncer(opts)
+	accountID := "42"
+
+	page, err := syncer.SyncAccount(t.Context(), "alice", &accountID, Cursor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != 2 || len(page.Tweets[0].Media) != 0 {
+		t.Fatalf("tweets = %+v, want 102 without media first", page.Tweets)
+	}
+	media := page.Tweets[1].Media
+	if len(media) != 2 {
+		t.Fatalf("media = %+v, want a photo and a video", media)
+	}
+	if media[0] != (SyncedMedia{Key: "3_1", Type: "photo", URL: "https://pbs.twimg.com/media/1.jpg", Width: 1200, Height: 800, AltText: "a cat"}) {
+		t.Errorf("photo = %+v", media[0])
+	}
+	if media[1].URL != "https://video.twimg.com/2.mp4" || media[1].PreviewURL != "https://pbs.twimg.com/2.jpg" || media[1].DurationMs != 15000 {
+		t.Errorf("video = %+v, want its MP4 variant and preview", media[1])
+	}
+}
+
+func TestSyncAccountRelations(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddUser("7", "bob", "Bob")
+	x.AddTweets("7", xfake.Tweet{ID: "100", Text: "question", CreatedAt: postedAt(0)})
+	x.AddTweets("42",
+		xfake.Tweet{ID: "101", Text: "thread 1/2", CreatedAt: postedAt(1)},
+		xfake.Tweet{ID: "102", Text: "thread 2/2", CreatedAt: postedAt(2), InReplyTo: "101"},
+		xfake.Tweet{ID: "103", Text: "@bob answer", CreatedAt: postedAt(3), InReplyTo: "100"},
+		xfake.Tweet{ID: "104", Text: "look at this", CreatedAt: postedAt(4), Quotes: "100"},
+		xfake.Tweet{ID: "105", Text: "RT @bob: question", CreatedAt: postedAt(5), RetweetOf: "100"},
+	)
+	syncer := NewSyncer(opts)
+	accountID := "42"
+
+	page, err := syncer.SyncAccount(t.Context(), "alice", &accountID, Cursor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][3]string{
+		"101": {"101", "", ""},
+		"102": {"101", RelationThread, "101"},
+		"103": {"100", RelationReply, "100"},
+		"104": {"104", RelationQuote, "100"},
+		"105": {"105", RelationRetweet, "100"},
+	}
+	for _, tweet := range page.Tweets {
+		if got := [3]string{tweet.ConversationID, tweet.Relation, tweet.ParentID}; got != want[tweet.ExternalID] {
+			t.Errorf("tweet %s: conversation, relation, parent = %v, want %v", tweet.ExternalID, got, want[tweet.ExternalID])
+		}
+	}
+}
+
+func TestIDLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"99", "100", true},
+		{"100", "99", false},
+		{"1800000000000000001", "1800000000000000002", true},
+		{"1800000000000000002", "1800000000000000002", false},
+	}
+	for _, tt := range tests {
+		if got := IDLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("IDLess(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSyncAccountWithOAuth(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddTweets("42", xfake.Tweet{ID: "101", Text: "mine", CreatedAt: postedAt(1)})
+	access, _ := x.IssueToken("42")
+	syncer := NewSyncer(opts)
+
+	// Without an account ID the user is the owner of the token
+	page, err := syncer.SyncAccountWithOAuth(t.Context(), Token{AccessToken: access}, "old_name", nil, Cursor{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != 1 || page.Tweets[0].Link != "https://x.com/alice/status/101" {
+		t.Errorf("tweets = %+v, want 101 linked under the current username", page.Tweets)
+	}
+	for _, req := range x.Requests() {
+		if req.Authorization != "Bearer "+access {
+			t.Errorf("%s %s was not made with the user token", req.Method, req.Path)
+		}
+	}
+
+	x.ExpireToken(access)
+	if _, err := syncer.SyncAccountWithOAuth(t.Context(), Token{AccessToken: access}, "alice", nil, Cursor{}, nil); !errors.Is(err, ErrTokenRefresh) {
+		t.Errorf("syncing with an expired token and no refresh token returned %v, want a refresh error", err)
+	}
+}
+
+func TestSyncAccountRefreshesToken(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddTweets("42", xfake.Tweet{ID: "101", Text: "mine", CreatedAt: postedAt(1)})
+	syncer := NewSyncer(opts)
+
+	tests := []struct {
+		name string
+		// expire makes the token invalid on the server; otherwise it is known to have expired
+		expire bool
+	}{
+		{"expired", false},
+		{"revoked", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, refresh := x.IssueToken("42")
+			token := Token{AccessToken: access, RefreshToken: refresh, ExpiresAt: time.Now().Add(time.Hour)}
+			if tt.expire {
+				x.ExpireToken(access)
+			} else {
+				token.ExpiresAt = time.Now().Add(-time.Minute)
+			}
+
+			var stored []Token
+			page, err := syncer.SyncAccountWithOAuth(t.Context(), token, "alice", nil, Cursor{}, func(t Token) { stored = append(stored, t) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Tweets) != 1 {
+				t.Errorf("synced %d tweets, want 1", len(page.Tweets))
+			}
+			if len(stored) != 1 || stored[0].AccessToken == access || stored[0].RefreshToken == refresh || time.Until(stored[0].ExpiresAt) < time.Hour {
+				t.Errorf("refreshed tokens = %+v, want one new pair", stored)
+			}
+		})
+	}
+
+	t.Run("refresh token used", func(t *testing.T) {
+		access, refresh := x.IssueToken("42")
+		x.ExpireToken(access)
+		if _, err := NewOAuthHandler(opts).RefreshAccessToken(refresh); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := syncer.SyncAccountWithOAuth(t.Context(), Token{AccessToken: access, RefreshToken: refresh}, "alice", nil, Cursor{}, nil)
+		apiErr, ok := IsAPIError(err)
+		if !errors.Is(err, ErrTokenRefresh) || !ok || apiErr.Status != http.StatusBadRequest {
+			t.Errorf("got %v, want the token endpoint's error wrapped as a refresh error", err)
+		}
+	})
+}
+
+func TestSyncAccountWithOAuth1(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.AddOAuth1Token("consumer-key", "consumer secret", "42-token", "token/secret", "42")
+	x.AddTweets("42", xfake.Tweet{ID: "101", Text: "mine", CreatedAt: postedAt(1)})
+	opts.BearerToken = ""
+	opts.OAuth1 = OAuth1Auth{ConsumerKey: "consumer-key", ConsumerSecret: "consumer secret", Token: "42-token", TokenSecret: "token/secret"}
+
+	page, err := NewSyncer(opts).SyncAccount(t.Context(), "alice", nil, Cursor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tweets) != 1 {
+		t.Errorf("synced %d tweets, want 1", len(page.Tweets))
+	}
+	for _, req := range x.Requests() {
+		if !strings.HasPrefix(req.Authorization, "OAuth ") {
+			t.Errorf("%s %s was not signed", req.Method, req.Path)
+		}
+	}
+
+	opts.OAuth1.TokenSecret = "wrong"
+	_, err = NewSyncer(opts).SyncAccount(t.Context(), "alice", nil, Cursor{})
+	if apiErr, ok := IsAPIError(err); !ok || apiErr.Status != http.StatusUnauthorized {
+		t.Errorf("got %v, want a request signed with the wrong secret to be rejected", err)
+	}
+}
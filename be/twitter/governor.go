@@ -0,0 +1,170 @@
+package twitter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitMaxWait is how long a call waits for its rate limit window
+// to reset before it is deferred with a RateLimitError
+const defaultRateLimitMaxWait = 30 * time.Second
+
+// resetMargin is waited past a reported reset, which only has second
+// precision, so the window has surely reset on X's side
+const resetMargin = time.Second
+
+// Budget is what is left of the rate limit of one endpoint for one credential
+type Budget struct {
+	Endpoint string `json:"endpoint"`
+	// Credential identifies the token the limit applies to without revealing it
+	Credential string    `json:"credential"`
+	Limit      int       `json:"limit"`
+	Remaining  int       `json:"remaining"`
+	ResetAt    time.Time `json:"reset_at"`
+	// Waiting is the number of calls blocked until the window resets
+	Waiting int `json:"waiting"`
+}
+
+type budgetKey struct {
+	endpoint   string
+	credential string
+}
+
+// Governor keeps calls to the X API within its rate limits. It learns each
+// endpoint's budget per credential from the x-rate-limit headers of the
+// responses and counts calls in flight against it, so calls that would be
+// refused wait for the window to reset, or fail early if that takes too long.
+type Governor struct {
+	maxWait time.Duration
+
+	mu      sync.Mutex
+	budgets map[budgetKey]*Budget
+}
+
+// NewGovernor creates a governor that lets calls wait up to maxWait for a
+// rate limit window to reset
+func NewGovernor(maxWait time.Duration) *Governor {
+	return &Governor{
+		maxWait: maxWait,
+		budgets: make(map[budgetKey]*Budget),
+	}
+}
+
+// acquire reserves a call to endpoint with credential, waiting for the
+// window to reset if the budget is used up. It returns the context's error if
+// ctx is done before then.
+func (g *Governor) acquire(ctx context.Context, endpoint, credential string) error {
+	key := budgetKey{endpoint: endpoint, credential: credential}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for {
+		b, ok := g.budgets[key]
+		if !ok || b.Remaining > 0 {
+			if ok {
+				b.Remaining--
+			}
+			return nil
+		}
+
+		wait := time.Until(b.ResetAt.Add(resetMargin))
+		if wait <= 0 {
+			// A new window: the response will tell the budget
+			return nil
+		}
+		if wait > g.maxWait {
+			retryAfter := int((time.Until(b.ResetAt) + time.Second - 1) / time.Second)
+			return &RateLimitError{RetryAfter: max(retryAfter, 1)}
+		}
+
+		b.Waiting++
+		g.mu.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			g.mu.Lock()
+			b.Waiting--
+			return ctx.Err()
+		case <-timer.C:
+		}
+		g.mu.Lock()
+		b.Waiting--
+	}
+}
+
+// update records the budget reported by a response to a call to endpoint
+func (g *Governor) update(endpoint, credential string, resp *http.Response) {
+	limit, errLimit := strconv.Atoi(resp.Header.Get("x-rate-limit-limit"))
+	remaining, errRemaining := strconv.Atoi(resp.Header.Get("x-rate-limit-remaining"))
+	reset, errReset := strconv.ParseInt(resp.Header.Get("x-rate-limit-reset"), 10, 64)
+
+	var resetAt time.Time
+	if errReset == nil {
+		resetAt = time.Unix(reset, 0)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		remaining, errRemaining = 0, nil
+		if resetAt.IsZero() {
+			resetAt = time.Now().Add(time.Duration(parseRateLimitError(resp).RetryAfter) * time.Second)
+		}
+	}
+	if errRemaining != nil || resetAt.IsZero() {
+		return
+	}
+
+	key := budgetKey{endpoint: endpoint, credential: credential}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b, ok := g.budgets[key]
+	if !ok {
+		b = &Budget{Endpoint: endpoint, Credential: credential}
+		g.budgets[key] = b
+	}
+	if errLimit == nil {
+		b.Limit = limit
+	}
+	// Responses to calls made in parallel arrive in any order; within a
+	// window the lowest remaining count is the most recent
+	if resetAt.Equal(b.ResetAt) && b.Remaining < remaining {
+		return
+	}
+	b.Remaining, b.ResetAt = remaining, resetAt
+}
+
+// Budgets returns the known budgets, with windows that have already reset
+// left out
+func (g *Governor) Budgets() []Budget {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	budgets := []Budget{}
+	for _, b := range g.budgets {
+		if now.Before(b.ResetAt) {
+			budgets = append(budgets, *b)
+		}
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		if budgets[i].Endpoint != budgets[j].Endpoint {
+			return budgets[i].Endpoint < budgets[j].Endpoint
+		}
+		return budgets[i].Credential < budgets[j].Credential
+	})
+	return budgets
+}
+
+// credentialID identifies a credential in budgets by a short hash
+func credentialID(kind, secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return fmt.Sprintf("%s:%s", kind, hex.EncodeToString(sum[:4]))
+}
@@ -0,0 +1,122 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGovernorDefersCalls(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.SetRateLimit(2, time.Hour)
+	opts.RateLimitMaxWait = time.Second
+	client := NewClient(BearerAuth{Token: testAppToken}, opts)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetUserTweets(t.Context(), "42", 10, "", ""); err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+	}
+
+	_, err := client.GetUserTweets(t.Context(), "42", 10, "", "")
+	rle, ok := IsRateLimitError(err)
+	if !ok || rle.RetryAfter < 3500 {
+		t.Fatalf("got %v, want the call deferred until the end of the hour window", err)
+	}
+	if requests := x.Requests(); len(requests) != 2 {
+		t.Errorf("made %d requests, want the deferred call not to reach the API", len(requests))
+	}
+
+	// Other endpoints have budgets of their own
+	if _, err := client.GetUserByUsername(t.Context(), "alice"); err == nil {
+		t.Error("looking up a user succeeded, want the fake's shared limit to refuse it")
+	} else if _, ok := IsRateLimitError(err); !ok {
+		t.Errorf("got %v, want a rate limit error from the API", err)
+	}
+	if requests := x.Requests(); len(requests) != 3 {
+		t.Errorf("made %d requests, want the lookup to reach the API", len(requests))
+	}
+}
+
+func TestGovernorWaitsForReset(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.SetRateLimit(1, time.Second)
+	opts.RateLimitMaxWait = 5 * time.Second
+	client := NewClient(BearerAuth{Token: testAppToken}, opts)
+
+	if _, err := client.GetUserTweets(t.Context(), "42", 10, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := client.GetUserTweets(t.Context(), "42", 10, "", ""); err != nil {
+		t.Fatalf("the call over the limit failed instead of waiting: %v", err)
+	}
+	if waited := time.Since(start); waited < 500*time.Millisecond {
+		t.Errorf("waited %v, want the call held until the window reset", waited)
+	}
+}
+
+func TestGovernorWaitIsCancellable(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.SetRateLimit(1, 3*time.Second)
+	opts.RateLimitMaxWait = 10 * time.Second
+	client := NewClient(BearerAuth{Token: testAppToken}, opts)
+
+	if _, err := client.GetUserTweets(t.Context(), "42", 10, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetUserTweets(ctx, "42", 10, "", "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the wait ended by the context", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %v, want the call to return once the context was done", waited)
+	}
+	if requests := x.Requests(); len(requests) != 1 {
+		t.Errorf("made %d requests, want the cancelled call not to reach the API", len(requests))
+	}
+	if budgets := client.opts.Governor.Budgets(); len(budgets) != 1 || budgets[0].Waiting != 0 {
+		t.Errorf("budgets = %+v, want no call left waiting", budgets)
+	}
+}
+
+func TestGovernorBudgets(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.SetRateLimit(5, time.Hour)
+	access, _ := x.IssueToken("42")
+	syncer := NewSyncer(opts)
+
+	if _, err := syncer.SyncAccount(t.Context(), "alice", nil, Cursor{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncer.SyncAccountWithOAuth(t.Context(), Token{AccessToken: access}, "alice", nil, Cursor{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	budgets := syncer.GetGovernor().Budgets()
+	var got []string
+	for _, b := range budgets {
+		if strings.Contains(b.Credential, testAppToken) || strings.Contains(b.Credential, access) {
+			t.Errorf("budget %+v reveals its token", b)
+		}
+		kind, _, _ := strings.Cut(b.Credential, ":")
+		got = append(got, b.Endpoint+" "+kind)
+		if b.Limit != 5 || time.Until(b.ResetAt) < 59*time.Minute {
+			t.Errorf("budget %+v, want a limit of 5 per hour", b)
+		}
+	}
+	want := []string{"/users/:id/tweets app", "/users/:id/tweets user", "/users/by/username/:username app", "/users/me user"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("budgets = %v, want %v", got, want)
+	}
+	// The fake's limit is shared by all endpoints and tokens; the user's
+	// timeline was read last
+	if last := budgets[1]; last.Remaining != 1 {
+		t.Errorf("remaining after 4 calls = %d, want 1", last.Remaining)
+	}
+}
@@ -1,15 +1,12 @@
 package twitter
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -39,34 +36,21 @@ type TokenResponse struct {
 	Scope        string `json:"scope"`
 }
 
-// OAuthUserResponse represents the authenticated user from Twitter
-type OAuthUserResponse struct {
-	Data struct {
-		ID       string `json:"id"`
-		Name     string `json:"name"`
-		Username string `json:"username"`
-	} `json:"data"`
-}
-
 // OAuthHandler manages Twitter OAuth 2.0 flows
 type OAuthHandler struct {
-	config     OAuthConfig
-	states     map[string]*OAuthState
-	statesMu   sync.RWMutex
-	httpClient *http.Client
+	config   OAuthConfig
+	opts     Options
+	states   map[string]*OAuthState
+	statesMu sync.RWMutex
 }
 
-// NewOAuthHandler creates a new OAuth handler
-func NewOAuthHandler() *OAuthHandler {
+// NewOAuthHandler creates a new OAuth handler for the OAuth client of opts
+func NewOAuthHandler(opts Options) *OAuthHandler {
+	opts = opts.withDefaults()
 	return &OAuthHandler{
-		config: OAuthConfig{
-			ClientID:     os.Getenv("TWITTER_CLIENT_ID"),
-			ClientSecret: os.Getenv("TWITTER_CLIENT_SECRET"),
-			RedirectURI:  os.Getenv("TWITTER_REDIRECT_URI"),
-			Scopes:       []string{"tweet.read", "users.read", "offline.access"},
-		},
-		states:     make(map[string]*OAuthState),
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		config: opts.OAuth,
+		opts:   opts,
+		states: make(map[string]*OAuthState),
 	}
 }
 
@@ -117,7 +101,7 @@ func (h *OAuthHandler) GetAuthorizationURL(userID int) (string, error) {
 	params.Set("code_challenge", codeChallenge)
 	params.Set("code_challenge_method", "S256")
 
-	return "https://twitter.com/i/oauth2/authorize?" + params.Encode(), nil
+	return h.opts.AuthorizeURL + "?" + params.Encode(), nil
 }
 
 // ExchangeCode exchanges the authorization code for tokens
@@ -146,37 +130,12 @@ func (h *OAuthHandler) ExchangeCode(code, state string) (*TokenResponse, int, er
 	data.Set("redirect_uri", h.config.RedirectURI)
 	data.Set("code_verifier", oauthState.CodeVerifier)
 
-	req, err := http.NewRequest("POST", "https://api.x.com/2/oauth2/token", strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	// Use Basic Auth with client credentials
-	auth := base64.StdEncoding.EncodeToString([]byte(h.config.ClientID + ":" + h.config.ClientSecret))
-	req.Header.Set("Authorization", "Basic "+auth)
-
-	resp, err := h.httpClient.Do(req)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to exchange code: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	tokenResp, err := h.requestToken(data)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, 0, fmt.Errorf("token exchange failed (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	var tokenResp TokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, 0, fmt.Errorf("failed to parse token response: %w", err)
+		return nil, 0, fmt.Errorf("token exchange failed: %w", err)
 	}
 
-	return &tokenResp, oauthState.UserID, nil
+	return tokenResp, oauthState.UserID, nil
 }
 
 // RefreshAccessToken refreshes an expired access token
@@ -185,68 +144,35 @@ func (h *OAuthHandler) RefreshAccessToken(refreshToken string) (*TokenResponse,
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", refreshToken)
 
-	req, err := http.NewRequest("POST", "https://api.x.com/2/oauth2/token", strings.NewReader(data.Encode()))
+	tokenResp, err := h.requestToken(data)
+	if err != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", err)
+	}
+	return tokenResp, nil
+}
+
+// requestToken posts a grant to the token endpoint with the client credentials
+func (h *OAuthHandler) requestToken(data url.Values) (*TokenResponse, error) {
+	req, err := h.opts.newRequest("POST", h.opts.TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Use Basic Auth with client credentials
 	auth := base64.StdEncoding.EncodeToString([]byte(h.config.ClientID + ":" + h.config.ClientSecret))
 	req.Header.Set("Authorization", "Basic "+auth)
 
-	resp, err := h.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to refresh token: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token refresh failed (status %d): %s", resp.StatusCode, string(body))
-	}
-
 	var tokenResp TokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	if err := h.opts.do(req, &tokenResp, nil); err != nil {
+		return nil, err
 	}
-
 	return &tokenResp, nil
 }
 
 // GetAuthenticatedUser fetches the authenticated user's profile using the access token
-func (h *OAuthHandler) GetAuthenticatedUser(accessToken string) (*OAuthUserResponse, error) {
-	req, err := http.NewRequest("GET", "https://api.x.com/2/users/me", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	resp, err := h.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch user: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get user (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	var userResp OAuthUserResponse
-	if err := json.Unmarshal(body, &userResp); err != nil {
-		return nil, fmt.Errorf("failed to parse user response: %w", err)
-	}
-
-	return &userResp, nil
+func (h *OAuthHandler) GetAuthenticatedUser(ctx context.Context, accessToken string) (*UserResponse, error) {
+	return NewClient(BearerAuth{Token: accessToken}, h.opts).GetAuthenticatedUser(ctx)
 }
 
 // cleanupOldStates removes states older than 15 minutes
@@ -0,0 +1,132 @@
+package twitter
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// authorize follows an authorization URL on the fake server and returns the
+// query of the redirect back to the app
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d, want a redirect", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location.String(), "https://tracker.example.com/api/auth/twitter/callback?") {
+		t.Fatalf("redirected to %s, want the callback", location)
+	}
+	return location.Query()
+}
+
+func TestOAuthFlow(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.SetAuthorizingUser("42")
+	handler := NewOAuthHandler(opts)
+
+	authURL, err := handler.GetAuthorizationURL(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, x.AuthorizeURL()+"?") {
+		t.Fatalf("authorization URL %s is not on the configured server", authURL)
+	}
+	callback := authorize(t, authURL)
+
+	tokens, userID, err := handler.ExchangeCode(callback.Get("code"), callback.Get("state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 7 {
+		t.Errorf("code was exchanged for user %d, want 7", userID)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.ExpiresIn != 7200 {
+		t.Errorf("tokens = %+v, want access and refresh tokens valid for two hours", tokens)
+	}
+
+	user, err := handler.GetAuthenticatedUser(t.Context(), tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Data.ID != "42" || user.Data.Username != "alice" {
+		t.Errorf("authenticated user = %+v, want alice", user.Data)
+	}
+
+	refreshed, err := handler.RefreshAccessToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.AccessToken == tokens.AccessToken {
+		t.Error("refreshing returned the same access token")
+	}
+	if _, err := handler.RefreshAccessToken(tokens.RefreshToken); err == nil {
+		t.Error("a refresh token was accepted twice")
+	}
+
+	if _, _, err := handler.ExchangeCode(callback.Get("code"), callback.Get("state")); err == nil {
+		t.Error("a state was accepted twice")
+	}
+}
+
+func TestOAuthDenied(t *testing.T) {
+	_, opts := newFakeX(t)
+	handler := NewOAuthHandler(opts)
+
+	authURL, err := handler.GetAuthorizationURL(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := authorize(t, authURL)
+	if callback.Get("error") != "access_denied" || callback.Get("code") != "" {
+		t.Errorf("callback = %v, want access_denied", callback)
+	}
+}
+
+func TestOAuthExchangeFailures(t *testing.T) {
+	x, opts := newFakeX(t)
+	x.SetAuthorizingUser("42")
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		opts := opts
+		opts.OAuth.ClientSecret = "wrong"
+		handler := NewOAuthHandler(opts)
+
+		authURL, err := handler.GetAuthorizationURL(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		callback := authorize(t, authURL)
+		if _, _, err := handler.ExchangeCode(callback.Get("code"), callback.Get("state")); err == nil || !strings.Contains(err.Error(), "status 401") {
+			t.Errorf("got %v, want the exchange to be rejected", err)
+		}
+	})
+
+	t.Run("unknown state", func(t *testing.T) {
+		handler := NewOAuthHandler(opts)
+		if _, _, err := handler.ExchangeCode("code-1", "forged"); err == nil {
+			t.Error("a forged state was accepted")
+		}
+	})
+
+	t.Run("app token on users/me", func(t *testing.T) {
+		handler := NewOAuthHandler(opts)
+		if _, err := handler.GetAuthenticatedUser(t.Context(), testAppToken); err == nil || !strings.Contains(err.Error(), "status 403") {
+			t.Errorf("got %v, want app-only authentication to be refused", err)
+		}
+	})
+}
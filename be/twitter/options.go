@@ -0,0 +1,178 @@
+package twitter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Endpoints of the X API used when Options leave them empty
+const (
+	DefaultAPIBaseURL   = "https://api.x.com/2"
+	DefaultAuthorizeURL = "https://twitter.com/i/oauth2/authorize"
+	DefaultTokenURL     = "https://api.x.com/2/oauth2/token"
+)
+
+const (
+	defaultTimeout   = 30 * time.Second
+	defaultUserAgent = "SocialTracker/1.0"
+)
+
+// defaultScopes are requested when connecting an account: reading posts and
+// profiles, and a refresh token so syncs keep working after the access token expires
+var defaultScopes = []string{"tweet.read", "users.read", "offline.access"}
+
+// Options configure how the X API is reached. Empty fields fall back to the
+// public endpoints and defaults.
+type Options struct {
+	// BearerToken is the app-only token used to read public accounts
+	BearerToken string
+	// OAuth1 are OAuth 1.0a user context credentials used to read public
+	// accounts instead when there is no bearer token
+	OAuth1 OAuth1Auth
+	// OAuth is the app's OAuth 2.0 client, used to connect accounts
+	OAuth OAuthConfig
+
+	APIBaseURL   string
+	AuthorizeURL string
+	TokenURL     string
+
+	// HTTPClient is used for every request when set. Otherwise one client is
+	// built from Transport and Timeout and shared by everything created from
+	// the same options.
+	HTTPClient *http.Client
+	Transport  http.RoundTripper
+	Timeout    time.Duration
+	UserAgent  string
+
+	// Governor paces API calls by their rate limits. One is created from
+	// RateLimitMaxWait when it is nil and shared like the HTTP client.
+	Governor         *Governor
+	RateLimitMaxWait time.Duration
+}
+
+// OptionsFromEnv reads the X API settings:
+//   - TWITTER_BEARER_TOKEN, the app-only token
+//   - TWITTER_CONSUMER_KEY, TWITTER_CONSUMER_SECRET, TWITTER_ACCESS_TOKEN and
+//     TWITTER_ACCESS_TOKEN_SECRET, OAuth 1.0a credentials to use without a bearer token
+//   - TWITTER_CLIENT_ID, TWITTER_CLIENT_SECRET and TWITTER_REDIRECT_URI, the OAuth 2.0 client
+//   - TWITTER_API_BASE_URL, TWITTER_AUTHORIZE_URL and TWITTER_TOKEN_URL, to use another server
+//   - TWITTER_PROXY_URL, an HTTP proxy for X API traffic only
+//   - TWITTER_HTTP_TIMEOUT, a duration such as 30s
+//   - TWITTER_USER_AGENT
+//   - TWITTER_RATE_LIMIT_MAX_WAIT, how long a call may wait for its rate limit to reset
+//
+// Invalid values are returned as an error alongside options without them.
+func OptionsFromEnv() (Options, error) {
+	opts := Options{
+		BearerToken: os.Getenv("TWITTER_BEARER_TOKEN"),
+		OAuth1: OAuth1Auth{
+			ConsumerKey:    os.Getenv("TWITTER_CONSUMER_KEY"),
+			ConsumerSecret: os.Getenv("TWITTER_CONSUMER_SECRET"),
+			Token:          os.Getenv("TWITTER_ACCESS_TOKEN"),
+			TokenSecret:    os.Getenv("TWITTER_ACCESS_TOKEN_SECRET"),
+		},
+		OAuth: OAuthConfig{
+			ClientID:     os.Getenv("TWITTER_CLIENT_ID"),
+			ClientSecret: os.Getenv("TWITTER_CLIENT_SECRET"),
+			RedirectURI:  os.Getenv("TWITTER_REDIRECT_URI"),
+		},
+		APIBaseURL:   os.Getenv("TWITTER_API_BASE_URL"),
+		AuthorizeURL: os.Getenv("TWITTER_AUTHORIZE_URL"),
+		TokenURL:     os.Getenv("TWITTER_TOKEN_URL"),
+		UserAgent:    os.Getenv("TWITTER_USER_AGENT"),
+	}
+
+	var errs []string
+	if raw := os.Getenv("TWITTER_HTTP_TIMEOUT"); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			errs = append(errs, fmt.Sprintf("TWITTER_HTTP_TIMEOUT %q is not a positive duration", raw))
+		} else {
+			opts.Timeout = timeout
+		}
+	}
+	if raw := os.Getenv("TWITTER_RATE_LIMIT_MAX_WAIT"); raw != "" {
+		maxWait, err := time.ParseDuration(raw)
+		if err != nil || maxWait <= 0 {
+			errs = append(errs, fmt.Sprintf("TWITTER_RATE_LIMIT_MAX_WAIT %q is not a positive duration", raw))
+		} else {
+			opts.RateLimitMaxWait = maxWait
+		}
+	}
+	if raw := os.Getenv("TWITTER_PROXY_URL"); raw != "" {
+		proxy, err := url.Parse(raw)
+		if err != nil || proxy.Host == "" {
+			errs = append(errs, fmt.Sprintf("TWITTER_PROXY_URL %q is not a valid URL", raw))
+		} else {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.Proxy = http.ProxyURL(proxy)
+			opts.Transport = transport
+		}
+	}
+
+	if len(errs) > 0 {
+		return opts, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return opts, nil
+}
+
+// appAuth is how the app reads public accounts, or nil without credentials
+func (o Options) appAuth() Auth {
+	if o.BearerToken != "" {
+		return BearerAuth{Token: o.BearerToken}
+	}
+	if o.OAuth1.ConsumerKey != "" {
+		return o.OAuth1
+	}
+	return nil
+}
+
+// withDefaults fills in empty options and builds the shared HTTP client
+func (o Options) withDefaults() Options {
+	if o.APIBaseURL == "" {
+		o.APIBaseURL = DefaultAPIBaseURL
+	}
+	o.APIBaseURL = strings.TrimRight(o.APIBaseURL, "/")
+	if o.AuthorizeURL == "" {
+		o.AuthorizeURL = DefaultAuthorizeURL
+	}
+	if o.TokenURL == "" {
+		o.TokenURL = DefaultTokenURL
+	}
+	if len(o.OAuth.Scopes) == 0 {
+		o.OAuth.Scopes = defaultScopes
+	}
+	if o.UserAgent == "" {
+		o.UserAgent = defaultUserAgent
+	}
+	if o.HTTPClient == nil {
+		timeout := o.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		o.HTTPClient = &http.Client{Transport: o.Transport, Timeout: timeout}
+	}
+	if o.Governor == nil {
+		maxWait := o.RateLimitMaxWait
+		if maxWait <= 0 {
+			maxWait = defaultRateLimitMaxWait
+		}
+		o.Governor = NewGovernor(maxWait)
+	}
+	return o
+}
+
+// newRequest creates a request identified by the configured user agent
+func (o Options) newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", o.UserAgent)
+	return req, nil
+}
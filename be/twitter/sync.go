@@ -1,9 +1,12 @@
 package twitter
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/Armatorix/SocialTracker/be/entities"
 )
 
 // SyncResult contains the results of a sync operation
@@ -21,19 +24,92 @@ type SyncedTweet struct {
 	Text       string
 	Link       string
 	PostedAt   time.Time
+	// Entities from the X API, or nil if it did not return any and the text should be parsed instead
+	Entities []entities.Entity
+	// EditHistoryIDs are the IDs of all versions of the tweet, oldest first;
+	// an edited tweet has more than one
+	EditHistoryIDs []string
+	// Media attached to the tweet, in order
+	Media []SyncedMedia
+	// ConversationID is the ID of the tweet that started the thread the tweet is in
+	ConversationID string
+	// Relation is how the tweet relates to ParentID, the tweet it replies to,
+	// quotes or retweets; empty for standalone tweets
+	Relation string
+	ParentID string
+}
+
+// Relations of a synced tweet to its parent. A thread continues the
+// account's own tweet, while a reply answers someone else's.
+const (
+	RelationThread  = "thread"
+	RelationReply   = "reply"
+	RelationQuote   = "quote"
+	RelationRetweet = "retweet"
+)
+
+// SyncedMedia is a photo, video or animated GIF attached to a synced tweet.
+// URL is the file itself: the photo, or the best MP4 variant of a video.
+type SyncedMedia struct {
+	Key        string
+	Type       string
+	URL        string
+	PreviewURL string
+	Width      int
+	Height     int
+	DurationMs int
+	AltText    string
+}
+
+// Cursor is where a sync starts: tweets after SinceID, continuing from
+// PaginationToken if a previous sync stopped with pages left
+type Cursor struct {
+	SinceID         string
+	PaginationToken string
+}
+
+// SyncPage is what a sync fetched, newest first. NextToken is set when the
+// sync stopped with pages left; a later sync with the same SinceID resumes
+// from it.
+type SyncPage struct {
+	Tweets    []SyncedTweet
+	NextToken string
+}
+
+const (
+	syncPageSize = 50
+	// maxSyncPages bounds how many pages one sync fetches, so syncs of busy
+	// accounts return in reasonable time and spend little of the rate limit
+	maxSyncPages = 4
+)
+
+// notFoundProblem is the problem type of resources that do not exist
+const notFoundProblem = "https://api.twitter.com/2/problems/resource-not-found"
+
+// IDLess reports whether tweet ID a is older than b. IDs are decimal numbers
+// that grow over time, too large to compare as floats.
+func IDLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
 }
 
 // Syncer handles synchronization of Twitter content
 type Syncer struct {
+	opts         Options
 	client       *Client
 	oauthHandler *OAuthHandler
 }
 
-// NewSyncer creates a new Twitter syncer
-func NewSyncer(client *Client) *Syncer {
+// NewSyncer creates a new Twitter syncer. The app client, the OAuth handler
+// and the clients of connected accounts all share the same options.
+func NewSyncer(opts Options) *Syncer {
+	opts = opts.withDefaults()
 	return &Syncer{
-		client:       client,
-		oauthHandler: NewOAuthHandler(),
+		opts:         opts,
+		client:       NewClient(opts.appAuth(), opts),
+		oauthHandler: NewOAuthHandler(opts),
 	}
 }
 
@@ -42,95 +118,217 @@ func (s *Syncer) GetOAuthHandler() *OAuthHandler {
 	return s.oauthHandler
 }
 
-// SyncAccount fetches new tweets for an account (using app-level bearer token)
-func (s *Syncer) SyncAccount(accountName string, accountID *string, sinceID string) ([]SyncedTweet, error) {
+// GetGovernor returns the governor pacing all calls made by the syncer
+func (s *Syncer) GetGovernor() *Governor {
+	return s.opts.Governor
+}
+
+// SyncAccount fetches new tweets for an account (using app-level credentials)
+func (s *Syncer) SyncAccount(ctx context.Context, accountName string, accountID *string, cursor Cursor) (*SyncPage, error) {
+	return s.sync(ctx, s.client, false, accountName, accountID, cursor)
+}
+
+// SyncAccountWithOAuth fetches new tweets using the user's OAuth token. The
+// token is refreshed when needed and onRefresh, if not nil, is called with
+// the new one. Errors wrap ErrTokenRefresh if that failed.
+func (s *Syncer) SyncAccountWithOAuth(ctx context.Context, token Token, accountName string, accountID *string, cursor Cursor, onRefresh func(Token)) (*SyncPage, error) {
+	userClient := NewClient(NewUserAuth(token, s.oauthHandler, onRefresh), s.opts)
+	return s.sync(ctx, userClient, true, accountName, accountID, cursor)
+}
+
+// sync fetches new tweets with client. Without a stored account ID the
+// account is looked up by username, or is the authenticated user for user
+// clients, whose current username is then used for links.
+func (s *Syncer) sync(ctx context.Context, client *Client, userContext bool, accountName string, accountID *string, cursor Cursor) (*SyncPage, error) {
 	var twitterUserID string
+	username := accountName
+
+	logSuffix := ""
+	if userContext {
+		logSuffix = " (OAuth)"
+	}
 
 	// If we have a stored account ID, use it; otherwise look it up
 	if accountID != nil && *accountID != "" {
 		twitterUserID = *accountID
+	} else if userContext {
+		userResp, err := client.GetAuthenticatedUser(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get authenticated user: %w", err)
+		}
+		twitterUserID = userResp.Data.ID
+		username = userResp.Data.Username
 	} else {
-		// Look up the user by username
-		userResp, err := s.client.GetUserByUsername(accountName)
+		userResp, err := client.GetUserByUsername(ctx, accountName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to lookup user %s: %w", accountName, err)
 		}
 		twitterUserID = userResp.Data.ID
 	}
 
-	// Fetch recent tweets
-	tweetsResp, err := s.client.GetUserTweets(twitterUserID, 50, sinceID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tweets: %w", err)
-	}
+	// Fetch recent tweets, page by page
+	page := &SyncPage{Tweets: []SyncedTweet{}, NextToken: cursor.PaginationToken}
+	for i := 0; i < maxSyncPages; i++ {
+		tweetsResp, err := client.GetUserTweets(ctx, twitterUserID, syncPageSize, cursor.SinceID, page.NextToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch tweets: %w", err)
+		}
 
-	if tweetsResp.Meta.ResultCount == 0 {
-		log.Printf("No new tweets found for @%s", accountName)
-		return []SyncedTweet{}, nil
+		media := make(map[string]Media, len(tweetsResp.Includes.Media))
+		for _, m := range tweetsResp.Includes.Media {
+			media[m.MediaKey] = m
+		}
+
+		for _, tweet := range tweetsResp.Data {
+			page.Tweets = append(page.Tweets, SyncedTweet{
+				ExternalID:     tweet.ID,
+				Text:           tweet.Text,
+				Link:           TweetToLink(username, tweet.ID),
+				PostedAt:       tweet.CreatedAt,
+				Entities:       tweetEntities(tweet),
+				EditHistoryIDs: tweet.EditHistoryTweetIDs,
+				Media:          tweetMedia(tweet, media),
+				ConversationID: tweet.ConversationID,
+			})
+			last := &page.Tweets[len(page.Tweets)-1]
+			last.Relation, last.ParentID = tweetRelation(tweet, twitterUserID)
+		}
+
+		page.NextToken = tweetsResp.Meta.NextToken
+		if page.NextToken == "" {
+			break
+		}
 	}
 
-	var synced []SyncedTweet
-	for _, tweet := range tweetsResp.Data {
-		synced = append(synced, SyncedTweet{
-			ExternalID: tweet.ID,
-			Text:       tweet.Text,
-			Link:       TweetToLink(accountName, tweet.ID),
-			PostedAt:   tweet.CreatedAt,
-		})
+	if len(page.Tweets) == 0 {
+		log.Printf("No new tweets found for @%s%s", username, logSuffix)
+		return page, nil
 	}
 
-	log.Printf("Fetched %d tweets for @%s", len(synced), accountName)
-	return synced, nil
+	log.Printf("Fetched %d tweets for @%s%s", len(page.Tweets), username, logSuffix)
+	return page, nil
 }
 
-// SyncAccountWithOAuth fetches new tweets using user's OAuth access token
-func (s *Syncer) SyncAccountWithOAuth(accessToken string, accountName string, accountID *string, sinceID string) ([]SyncedTweet, error) {
-	userClient := NewUserClient(accessToken)
+// tweetRelation returns how a tweet of authorID relates to the tweet it
+// refers to. A reply that also quotes a tweet counts as a reply.
+func tweetRelation(tweet Tweet, authorID string) (relation, parentID string) {
+	var quoted string
+	for _, ref := range tweet.ReferencedTweets {
+		switch ref.Type {
+		case ReferenceRepliedTo:
+			if tweet.InReplyToUserID == authorID {
+				return RelationThread, ref.ID
+			}
+			return RelationReply, ref.ID
+		case ReferenceRetweeted:
+			return RelationRetweet, ref.ID
+		case ReferenceQuoted:
+			quoted = ref.ID
+		}
+	}
+	if quoted != "" {
+		return RelationQuote, quoted
+	}
+	return "", ""
+}
 
-	var twitterUserID string
-	var username string = accountName
+// tweetMedia returns the media attached to a tweet from the media included
+// in its response, by key
+func tweetMedia(tweet Tweet, included map[string]Media) []SyncedMedia {
+	if tweet.Attachments == nil {
+		return nil
+	}
 
-	// If we have a stored account ID, use it; otherwise get it from the authenticated user
-	if accountID != nil && *accountID != "" {
-		twitterUserID = *accountID
-	} else {
-		// Get the authenticated user's ID
-		userResp, err := userClient.GetAuthenticatedUser()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get authenticated user: %w", err)
+	var list []SyncedMedia
+	for _, key := range tweet.Attachments.MediaKeys {
+		m, ok := included[key]
+		if !ok {
+			continue
 		}
-		twitterUserID = userResp.Data.ID
-		username = userResp.Data.Username
+		synced := SyncedMedia{
+			Key:        m.MediaKey,
+			Type:       m.Type,
+			URL:        m.URL,
+			PreviewURL: m.PreviewImageURL,
+			Width:      m.Width,
+			Height:     m.Height,
+			DurationMs: m.DurationMs,
+			AltText:    m.AltText,
+		}
+		// Videos and GIFs are archived as their highest quality MP4
+		bitRate := -1
+		for _, variant := range m.Variants {
+			if variant.ContentType == "video/mp4" && variant.BitRate > bitRate {
+				synced.URL, bitRate = variant.URL, variant.BitRate
+			}
+		}
+		list = append(list, synced)
 	}
+	return list
+}
 
-	// Fetch recent tweets
-	tweetsResp, err := userClient.GetUserTweets(twitterUserID, 50, sinceID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tweets: %w", err)
+// tweetEntities converts the entities the X API returned for a tweet. URLs of
+// attached media are skipped since they point back at the tweet itself.
+func tweetEntities(tweet Tweet) []entities.Entity {
+	if tweet.Entities == nil {
+		return nil
 	}
 
-	if tweetsResp.Meta.ResultCount == 0 {
-		log.Printf("No new tweets found for @%s (OAuth)", username)
-		return []SyncedTweet{}, nil
+	list := []entities.Entity{}
+	seen := make(map[entities.Entity]bool)
+	add := func(kind, raw string) {
+		entity := entities.Entity{Kind: kind, Value: entities.Normalize(kind, raw)}
+		if entity.Value != "" && !seen[entity] {
+			seen[entity] = true
+			list = append(list, entity)
+		}
 	}
 
-	var synced []SyncedTweet
-	for _, tweet := range tweetsResp.Data {
-		synced = append(synced, SyncedTweet{
-			ExternalID: tweet.ID,
-			Text:       tweet.Text,
-			Link:       TweetToLink(username, tweet.ID),
-			PostedAt:   tweet.CreatedAt,
-		})
+	for _, hashtag := range tweet.Entities.Hashtags {
+		add(entities.KindHashtag, hashtag.Tag)
+	}
+	for _, mention := range tweet.Entities.Mentions {
+		add(entities.KindMention, mention.Username)
+	}
+	for _, cashtag := range tweet.Entities.Cashtags {
+		add(entities.KindCashtag, cashtag.Tag)
 	}
+	for _, u := range tweet.Entities.URLs {
+		if u.MediaKey != "" {
+			continue
+		}
+		if u.ExpandedURL != "" {
+			add(entities.KindURL, u.ExpandedURL)
+		} else {
+			add(entities.KindURL, u.URL)
+		}
+	}
+	return list
+}
 
-	log.Printf("Fetched %d tweets for @%s (OAuth)", len(synced), username)
-	return synced, nil
+// FindRemovedTweets looks up tweets with app-level credentials and returns
+// the IDs of those X reports as not found, which have been deleted. Tweets
+// that exist but cannot be read, e.g. of suspended accounts, are not removed.
+func (s *Syncer) FindRemovedTweets(ctx context.Context, ids []string) ([]string, error) {
+	removed := []string{}
+	for start := 0; start < len(ids); start += maxLookupIDs {
+		batch := ids[start:min(start+maxLookupIDs, len(ids))]
+		resp, err := s.client.GetTweets(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up tweets: %w", err)
+		}
+		for _, apiErr := range resp.Errors {
+			if apiErr.Type == notFoundProblem && apiErr.ResourceID != "" {
+				removed = append(removed, apiErr.ResourceID)
+			}
+		}
+	}
+	return removed, nil
 }
 
 // GetTwitterUserID fetches the Twitter user ID for a username
-func (s *Syncer) GetTwitterUserID(username string) (string, error) {
-	userResp, err := s.client.GetUserByUsername(username)
+func (s *Syncer) GetTwitterUserID(ctx context.Context, username string) (string, error) {
+	userResp, err := s.client.GetUserByUsername(ctx, username)
 	if err != nil {
 		return "", err
 	}
@@ -138,9 +336,9 @@ func (s *Syncer) GetTwitterUserID(username string) (string, error) {
 }
 
 // GetTwitterUserIDWithOAuth fetches the Twitter user ID using OAuth token
-func (s *Syncer) GetTwitterUserIDWithOAuth(accessToken string) (string, string, error) {
-	userClient := NewUserClient(accessToken)
-	userResp, err := userClient.GetAuthenticatedUser()
+func (s *Syncer) GetTwitterUserIDWithOAuth(ctx context.Context, accessToken string) (string, string, error) {
+	userClient := NewClient(NewUserAuth(Token{AccessToken: accessToken}, nil, nil), s.opts)
+	userResp, err := userClient.GetAuthenticatedUser(ctx)
 	if err != nil {
 		return "", "", err
 	}
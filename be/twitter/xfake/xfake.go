@@ -0,0 +1,746 @@
+// Package xfake is a fake X API server for tests. It emulates the parts of the
+// API the twitter package uses: user lookups, user timelines with since_id and
+// pagination, tweet lookups, edits and deletions, attached media, replies,
+// quotes and retweets, the OAuth 2.0 authorization and token endpoints, OAuth
+// 1.0a signatures, and rate limits.
+package xfake
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TokenTTL is how long issued access tokens are valid, like on X
+const TokenTTL = 2 * time.Hour
+
+// Tweet is a post on the fake server
+type Tweet struct {
+	ID        string
+	Text      string
+	CreatedAt time.Time
+	Hashtags  []string
+	Mentions  []string
+	URLs      []string
+	// EditOf lists the IDs of the earlier versions of an edited tweet, oldest first
+	EditOf []string
+	Media  []Media
+	// InReplyTo, Quotes and RetweetOf are the IDs of the tweets this one
+	// replies to, quotes or retweets
+	InReplyTo string
+	Quotes    string
+	RetweetOf string
+	// ConversationID is set when the tweet is added: its own ID, or that of
+	// the thread it replies to
+	ConversationID  string
+	inReplyToUserID string
+}
+
+// Media is a photo, video or GIF attached to a tweet. Videos and GIFs are
+// served as variants, with URL as their only MP4 variant.
+type Media struct {
+	Key        string
+	Type       string
+	URL        string
+	PreviewURL string
+	Width      int
+	Height     int
+	DurationMs int
+	AltText    string
+}
+
+// Request is a request the server received
+type Request struct {
+	Method        string
+	Path          string
+	Query         url.Values
+	Authorization string
+	UserAgent     string
+}
+
+type user struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+type accessToken struct {
+	userID    string
+	expiresAt time.Time
+}
+
+type oauth1Token struct {
+	consumerSecret string
+	tokenSecret    string
+	userID         string
+}
+
+type authCode struct {
+	userID        string
+	redirectURI   string
+	codeChallenge string
+}
+
+// Server is a fake X API. Create it with New.
+type Server struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	clientID        string
+	clientSecret    string
+	appTokens       map[string]bool
+	users           map[string]user
+	tweets          map[string][]Tweet
+	accessTokens    map[string]accessToken
+	refreshTokens   map[string]string
+	oauth1Tokens    map[string]oauth1Token
+	codes           map[string]authCode
+	authorizingUser string
+	issued          int
+
+	rateLimit   int
+	rateWindow  time.Duration
+	windowStart time.Time
+	used        int
+
+	requests []Request
+}
+
+// New starts a fake X API that is closed when the test ends
+func New(t testing.TB) *Server {
+	s := &Server{
+		appTokens:     make(map[string]bool),
+		users:         make(map[string]user),
+		tweets:        make(map[string][]Tweet),
+		accessTokens:  make(map[string]accessToken),
+		refreshTokens: make(map[string]string),
+		oauth1Tokens:  make(map[string]oauth1Token),
+		codes:         make(map[string]authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /2/users/me", s.api(s.handleMe))
+	mux.HandleFunc("GET /2/users/by/username/{username}", s.api(s.handleUserByUsername))
+	mux.HandleFunc("GET /2/users/{id}/tweets", s.api(s.handleUserTweets))
+	mux.HandleFunc("GET /2/tweets", s.api(s.handleTweets))
+	mux.HandleFunc("POST /2/oauth2/token", s.handleToken)
+	mux.HandleFunc("GET /i/oauth2/authorize", s.handleAuthorize)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Query:         r.URL.Query(),
+			Authorization: r.Header.Get("Authorization"),
+			UserAgent:     r.Header.Get("User-Agent"),
+		})
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// APIBaseURL is the base URL of the v2 API
+func (s *Server) APIBaseURL() string { return s.URL + "/2" }
+
+// AuthorizeURL is where users are sent to connect their account
+func (s *Server) AuthorizeURL() string { return s.URL + "/i/oauth2/authorize" }
+
+// TokenURL is the OAuth 2.0 token endpoint
+func (s *Server) TokenURL() string { return s.URL + "/2/oauth2/token" }
+
+// SetClient registers the OAuth 2.0 client allowed to authorize and exchange codes
+func (s *Server) SetClient(id, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientID, s.clientSecret = id, secret
+}
+
+// AddAppToken accepts token as an app-only bearer token
+func (s *Server) AddAppToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appTokens[token] = true
+}
+
+// AddUser creates a user
+func (s *Server) AddUser(id, username, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[id] = user{ID: id, Name: name, Username: username}
+}
+
+// AddTweets posts tweets as a user. Timelines are served newest first by ID.
+// Replies must come after the tweets they reply to.
+func (s *Server) AddTweets(userID string, tweets ...Tweet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tweet := range tweets {
+		tweet.ConversationID = tweet.ID
+		if tweet.InReplyTo != "" {
+			if authorID, parent, ok := s.findTweet(tweet.InReplyTo); ok {
+				tweet.ConversationID, tweet.inReplyToUserID = parent.ConversationID, authorID
+			}
+		}
+		s.tweets[userID] = append(s.tweets[userID], tweet)
+	}
+
+	timeline := s.tweets[userID]
+	sort.Slice(timeline, func(i, j int) bool { return idLess(timeline[j].ID, timeline[i].ID) })
+}
+
+// EditTweet edits a user's tweet. Like on X the edit is a new tweet with ID
+// newID that replaces the original in the timeline.
+func (s *Server) EditTweet(userID, id, newID, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timeline := s.tweets[userID]
+	for i, tweet := range timeline {
+		if tweet.ID == id {
+			edited := tweet
+			edited.ID, edited.Text = newID, text
+			edited.EditOf = append(append([]string{}, tweet.EditOf...), tweet.ID)
+			timeline[i] = edited
+		}
+	}
+	sort.Slice(timeline, func(i, j int) bool { return idLess(timeline[j].ID, timeline[i].ID) })
+}
+
+// DeleteTweet deletes a user's tweet
+func (s *Server) DeleteTweet(userID, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timeline := s.tweets[userID]
+	for i, tweet := range timeline {
+		if tweet.ID == id {
+			s.tweets[userID] = append(timeline[:i:i], timeline[i+1:]...)
+			return
+		}
+	}
+}
+
+// IssueToken creates user tokens without going through the authorization flow
+func (s *Server) IssueToken(userID string) (access, refresh string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issueToken(userID)
+}
+
+// ExpireToken makes an access token invalid, as if it had expired
+func (s *Server) ExpireToken(access string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accessTokens, access)
+}
+
+// AddOAuth1Token accepts requests signed with OAuth 1.0a credentials as the user
+func (s *Server) AddOAuth1Token(consumerKey, consumerSecret, token, tokenSecret, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.oauth1Tokens[consumerKey+"&"+token] = oauth1Token{consumerSecret: consumerSecret, tokenSecret: tokenSecret, userID: userID}
+}
+
+// SetAuthorizingUser sets who approves authorization requests. Until it is
+// set, users deny them.
+func (s *Server) SetAuthorizingUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizingUser = userID
+}
+
+// SetRateLimit allows limit API requests per window, across all endpoints
+// except the token endpoint. A limit of 0 removes it.
+func (s *Server) SetRateLimit(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit, s.rateWindow, s.windowStart, s.used = limit, window, time.Now(), 0
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) issueToken(userID string) (string, string) {
+	s.issued++
+	access := fmt.Sprintf("access-%s-%d", userID, s.issued)
+	refresh := fmt.Sprintf("refresh-%s-%d", userID, s.issued)
+	s.accessTokens[access] = accessToken{userID: userID, expiresAt: time.Now().Add(TokenTTL)}
+	s.refreshTokens[refresh] = userID
+	return access, refresh
+}
+
+// caller is who made an API request: an app, or a user when userID is set
+type caller struct {
+	userID string
+}
+
+// api authenticates a v2 API request and applies the rate limit
+func (s *Server) api(handler func(w http.ResponseWriter, r *http.Request, c caller)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		authorization := r.Header.Get("Authorization")
+		token, _ := strings.CutPrefix(authorization, "Bearer ")
+		var c caller
+		if signed, ok := strings.CutPrefix(authorization, "OAuth "); ok {
+			if c.userID, ok = s.verifyOAuth1(r, signed); !ok {
+				writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Unauthorized", "about:blank")
+				return
+			}
+		} else if at, ok := s.accessTokens[token]; ok && time.Now().Before(at.expiresAt) {
+			c.userID = at.userID
+		} else if !s.appTokens[token] {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Unauthorized", "about:blank")
+			return
+		}
+
+		if s.rateLimit > 0 {
+			now := time.Now()
+			if now.Sub(s.windowStart) >= s.rateWindow {
+				s.windowStart, s.used = now, 0
+			}
+			reset := s.windowStart.Add(s.rateWindow)
+			remaining := s.rateLimit - s.used
+			w.Header().Set("x-rate-limit-limit", strconv.Itoa(s.rateLimit))
+			w.Header().Set("x-rate-limit-reset", strconv.FormatInt(reset.Unix(), 10))
+			if remaining <= 0 {
+				w.Header().Set("x-rate-limit-remaining", "0")
+				writeProblem(w, http.StatusTooManyRequests, "Too Many Requests", "Too Many Requests", "about:blank")
+				return
+			}
+			s.used++
+			w.Header().Set("x-rate-limit-remaining", strconv.Itoa(remaining-1))
+		}
+
+		handler(w, r, c)
+	}
+}
+
+// verifyOAuth1 checks the HMAC-SHA1 signature of an OAuth 1.0a request and
+// returns the user it was signed for
+func (s *Server) verifyOAuth1(r *http.Request, header string) (string, bool) {
+	oauthParams := make(map[string]string)
+	for _, param := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return "", false
+		}
+		key, _ = url.QueryUnescape(key)
+		value, _ = url.QueryUnescape(strings.Trim(value, `"`))
+		oauthParams[key] = value
+	}
+	if oauthParams["oauth_signature_method"] != "HMAC-SHA1" {
+		return "", false
+	}
+	creds, ok := s.oauth1Tokens[oauthParams["oauth_consumer_key"]+"&"+oauthParams["oauth_token"]]
+	if !ok {
+		return "", false
+	}
+
+	var params []string
+	for key, values := range r.URL.Query() {
+		for _, value := range values {
+			params = append(params, percentEncode(key)+"="+percentEncode(value))
+		}
+	}
+	for key, value := range oauthParams {
+		if key != "oauth_signature" {
+			params = append(params, percentEncode(key)+"="+percentEncode(value))
+		}
+	}
+	sort.Strings(params)
+
+	base := r.Method + "&" + percentEncode("http://"+r.Host+r.URL.EscapedPath()) + "&" + percentEncode(strings.Join(params, "&"))
+	mac := hmac.New(sha1.New, []byte(percentEncode(creds.consumerSecret)+"&"+percentEncode(creds.tokenSecret)))
+	mac.Write([]byte(base))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return creds.userID, hmac.Equal([]byte(want), []byte(oauthParams["oauth_signature"]))
+}
+
+func percentEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request, c caller) {
+	if c.userID == "" {
+		writeProblem(w, http.StatusForbidden, "Unsupported Authentication",
+			"Authenticating with OAuth 2.0 Application-Only is forbidden for this endpoint.  Supported authentication types are [OAuth 1.0a User Context, OAuth 2.0 User Context].",
+			"https://api.twitter.com/2/problems/unsupported-authentication")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": s.users[c.userID]})
+}
+
+func (s *Server) handleUserByUsername(w http.ResponseWriter, r *http.Request, c caller) {
+	username := r.PathValue("username")
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, username) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": u})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errors": []map[string]string{{
+			"value":         username,
+			"detail":        fmt.Sprintf("Could not find user with username: [%s].", username),
+			"title":         "Not Found Error",
+			"resource_type": "user",
+			"parameter":     "username",
+			"resource_id":   username,
+			"type":          "https://api.twitter.com/2/problems/resource-not-found",
+		}},
+	})
+}
+
+func (s *Server) handleUserTweets(w http.ResponseWriter, r *http.Request, c caller) {
+	userID := r.PathValue("id")
+	query := r.URL.Query()
+
+	maxResults := 10
+	if raw := query.Get("max_results"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 5 || n > 100 {
+			writeInvalidRequest(w, "max_results", raw, fmt.Sprintf("The `max_results` query parameter value [%s] is not between 5 and 100", raw))
+			return
+		}
+		maxResults = n
+	}
+
+	before := ""
+	if raw := query.Get("pagination_token"); raw != "" {
+		id, ok := strings.CutPrefix(raw, "next_")
+		if !ok {
+			writeInvalidRequest(w, "pagination_token", raw, fmt.Sprintf("The `pagination_token` query parameter value [%s] is not valid", raw))
+			return
+		}
+		before = id
+	}
+	sinceID := query.Get("since_id")
+
+	var page []Tweet
+	more := false
+	for _, tweet := range s.tweets[userID] {
+		if before != "" && !idLess(tweet.ID, before) {
+			continue
+		}
+		if sinceID != "" && !idLess(sinceID, tweet.ID) {
+			break
+		}
+		if len(page) == maxResults {
+			more = true
+			break
+		}
+		page = append(page, tweet)
+	}
+
+	meta := map[string]interface{}{"result_count": len(page)}
+	if len(page) == 0 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"meta": meta})
+		return
+	}
+	meta["newest_id"] = page[0].ID
+	meta["oldest_id"] = page[len(page)-1].ID
+	if more {
+		meta["next_token"] = "next_" + page[len(page)-1].ID
+	}
+
+	data := make([]map[string]interface{}, len(page))
+	for i, tweet := range page {
+		data[i] = tweetJSON(userID, tweet)
+	}
+	body := map[string]interface{}{"data": data, "meta": meta}
+	addIncludes(body, r.URL.Query(), page)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleTweets looks up tweets by ID. Tweets that do not exist are reported
+// as errors next to the ones that do.
+func (s *Server) handleTweets(w http.ResponseWriter, r *http.Request, c caller) {
+	raw := r.URL.Query().Get("ids")
+	ids := strings.Split(raw, ",")
+	if raw == "" || len(ids) > 100 {
+		writeInvalidRequest(w, "ids", raw, fmt.Sprintf("The `ids` query parameter value [%s] is not between 1 and 100 IDs", raw))
+		return
+	}
+
+	data := []map[string]interface{}{}
+	var found []Tweet
+	var errs []map[string]interface{}
+	for _, id := range ids {
+		if authorID, tweet, ok := s.findTweet(id); ok {
+			data = append(data, tweetJSON(authorID, tweet))
+			found = append(found, tweet)
+			continue
+		}
+		errs = append(errs, map[string]interface{}{
+			"value":         id,
+			"detail":        fmt.Sprintf("Could not find tweet with ids: [%s].", id),
+			"title":         "Not Found Error",
+			"resource_type": "tweet",
+			"parameter":     "ids",
+			"resource_id":   id,
+			"type":          "https://api.twitter.com/2/problems/resource-not-found",
+		})
+	}
+
+	body := map[string]interface{}{}
+	if len(data) > 0 {
+		body["data"] = data
+	}
+	if len(errs) > 0 {
+		body["errors"] = errs
+	}
+	addIncludes(body, r.URL.Query(), found)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// addIncludes adds the media of tweets to a response when the request asked
+// for them to be expanded. Only the requested media fields are returned.
+func addIncludes(body map[string]interface{}, query url.Values, tweets []Tweet) {
+	if !slices.Contains(strings.Split(query.Get("expansions"), ","), "attachments.media_keys") {
+		return
+	}
+	fields := strings.Split(query.Get("media.fields"), ",")
+
+	var media []map[string]interface{}
+	for _, tweet := range tweets {
+		for _, m := range tweet.Media {
+			v := map[string]interface{}{"media_key": m.Key, "type": m.Type}
+			set := func(field string, value interface{}, ok bool) {
+				if ok && slices.Contains(fields, field) {
+					v[field] = value
+				}
+			}
+			set("url", m.URL, m.Type == "photo")
+			set("preview_image_url", m.PreviewURL, m.PreviewURL != "")
+			set("width", m.Width, m.Width > 0)
+			set("height", m.Height, m.Height > 0)
+			set("duration_ms", m.DurationMs, m.DurationMs > 0)
+			set("alt_text", m.AltText, m.AltText != "")
+			set("variants", []map[string]interface{}{{"bit_rate": 832000, "content_type": "video/mp4", "url": m.URL}}, m.Type != "photo")
+			media = append(media, v)
+		}
+	}
+	if len(media) > 0 {
+		body["includes"] = map[string]interface{}{"media": media}
+	}
+}
+
+// findTweet finds a tweet and its author by ID
+func (s *Server) findTweet(id string) (string, Tweet, bool) {
+	for userID, timeline := range s.tweets {
+		for _, tweet := range timeline {
+			if tweet.ID == id {
+				return userID, tweet, true
+			}
+		}
+	}
+	return "", Tweet{}, false
+}
+
+func tweetJSON(authorID string, tweet Tweet) map[string]interface{} {
+	v := map[string]interface{}{
+		"id":                     tweet.ID,
+		"text":                   tweet.Text,
+		"author_id":              authorID,
+		"created_at":             tweet.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"edit_history_tweet_ids": append(append([]string{}, tweet.EditOf...), tweet.ID),
+	}
+
+	ents := map[string]interface{}{}
+	if len(tweet.Hashtags) > 0 {
+		var list []map[string]string
+		for _, tag := range tweet.Hashtags {
+			list = append(list, map[string]string{"tag": tag})
+		}
+		ents["hashtags"] = list
+	}
+	if len(tweet.Mentions) > 0 {
+		var list []map[string]string
+		for _, username := range tweet.Mentions {
+			list = append(list, map[string]string{"username": username})
+		}
+		ents["mentions"] = list
+	}
+	if len(tweet.URLs) > 0 {
+		var list []map[string]string
+		for i, u := range tweet.URLs {
+			list = append(list, map[string]string{"url": fmt.Sprintf("https://t.co/%s%d", tweet.ID, i), "expanded_url": u})
+		}
+		ents["urls"] = list
+	}
+	if len(ents) > 0 {
+		v["entities"] = ents
+	}
+
+	v["conversation_id"] = tweet.ConversationID
+	var refs []map[string]string
+	for _, ref := range []struct{ kind, id string }{
+		{"replied_to", tweet.InReplyTo}, {"quoted", tweet.Quotes}, {"retweeted", tweet.RetweetOf},
+	} {
+		if ref.id != "" {
+			refs = append(refs, map[string]string{"type": ref.kind, "id": ref.id})
+		}
+	}
+	if len(refs) > 0 {
+		v["referenced_tweets"] = refs
+	}
+	if tweet.inReplyToUserID != "" {
+		v["in_reply_to_user_id"] = tweet.inReplyToUserID
+	}
+
+	if len(tweet.Media) > 0 {
+		keys := make([]string, len(tweet.Media))
+		for i, m := range tweet.Media {
+			keys[i] = m.Key
+		}
+		v["attachments"] = map[string]interface{}{"media_keys": keys}
+	}
+	return v
+}
+
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Host == "" || query.Get("client_id") != s.clientID {
+		http.Error(w, "invalid client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	params := redirectURI.Query()
+	params.Set("state", query.Get("state"))
+	switch {
+	case query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		params.Set("error", "invalid_request")
+	case s.authorizingUser == "":
+		params.Set("error", "access_denied")
+	default:
+		s.issued++
+		code := fmt.Sprintf("code-%d", s.issued)
+		s.codes[code] = authCode{
+			userID:        s.authorizingUser,
+			redirectURI:   query.Get("redirect_uri"),
+			codeChallenge: query.Get("code_challenge"),
+		}
+		params.Set("code", code)
+	}
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != s.clientID || secret != s.clientSecret {
+		writeOAuthError(w, http.StatusUnauthorized, "unauthorized_client", "Missing valid authorization header")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	var userID string
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, ok := s.codes[r.PostForm.Get("code")]
+		if !ok || code.redirectURI != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Value passed for the authorization code was invalid.")
+			return
+		}
+		delete(s.codes, r.PostForm.Get("code"))
+		hash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(hash[:]) != code.codeChallenge {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Value passed for the code verifier did not match the code challenge.")
+			return
+		}
+		userID = code.userID
+	case "refresh_token":
+		refresh := r.PostForm.Get("refresh_token")
+		if userID, ok = s.refreshTokens[refresh]; !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Value passed for the token was invalid.")
+			return
+		}
+		// Refresh tokens can only be used once
+		delete(s.refreshTokens, refresh)
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Grant type is not supported.")
+		return
+	}
+
+	access, refresh := s.issueToken(userID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token_type":    "bearer",
+		"expires_in":    int(TokenTTL.Seconds()),
+		"access_token":  access,
+		"refresh_token": refresh,
+		"scope":         "tweet.read users.read offline.access",
+	})
+}
+
+// idLess compares post IDs, which are decimal numbers too large for some clients
+func idLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeProblem writes an error the way the v2 API does for failed requests
+func writeProblem(w http.ResponseWriter, status int, title, detail, problemType string) {
+	writeJSON(w, status, map[string]interface{}{
+		"title":  title,
+		"detail": detail,
+		"type":   problemType,
+		"status": status,
+	})
+}
+
+func writeInvalidRequest(w http.ResponseWriter, parameter, value, message string) {
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"parameters": map[string][]string{parameter: {value}},
+			"message":    message,
+		}},
+		"title":  "Invalid Request",
+		"detail": "One or more parameters to your request was invalid.",
+		"type":   "https://api.twitter.com/2/problems/invalid-request",
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
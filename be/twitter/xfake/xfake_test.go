@@ -0,0 +1,109 @@
+package xfake_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/twitter/xfake"
+)
+
+type timeline struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+	Meta struct {
+		ResultCount int    `json:"result_count"`
+		NextToken   string `json:"next_token"`
+	} `json:"meta"`
+}
+
+func get(t *testing.T, x *xfake.Server, path string, query url.Values) (*http.Response, timeline) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, x.APIBaseURL()+path+"?"+query.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer app")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body timeline
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, body
+}
+
+func TestTimelinePagination(t *testing.T) {
+	x := xfake.New(t)
+	x.AddAppToken("app")
+	for i := 1; i <= 12; i++ {
+		x.AddTweets("42", xfake.Tweet{ID: strconv.Itoa(100 + i), Text: fmt.Sprint("post ", i), CreatedAt: time.Now()})
+	}
+
+	var ids []string
+	query := url.Values{"max_results": {"5"}, "since_id": {"102"}}
+	for page := 0; ; page++ {
+		resp, body := get(t, x, "/users/42/tweets", query)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("page %d returned %d", page, resp.StatusCode)
+		}
+		for _, tweet := range body.Data {
+			ids = append(ids, tweet.ID)
+		}
+		if body.Meta.NextToken == "" {
+			break
+		}
+		query.Set("pagination_token", body.Meta.NextToken)
+	}
+
+	want := []string{"112", "111", "110", "109", "108", "107", "106", "105", "104", "103"}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("paged through %v, want %v", ids, want)
+	}
+}
+
+func TestTimelineValidation(t *testing.T) {
+	x := xfake.New(t)
+	x.AddAppToken("app")
+
+	if resp, _ := get(t, x, "/users/42/tweets", url.Values{"max_results": {"3"}}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("max_results=3 returned %d, want 400", resp.StatusCode)
+	}
+	if resp, body := get(t, x, "/users/42/tweets", nil); resp.StatusCode != http.StatusOK || body.Meta.ResultCount != 0 {
+		t.Errorf("empty timeline returned %d with %d results", resp.StatusCode, body.Meta.ResultCount)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	x := xfake.New(t)
+	x.AddAppToken("app")
+	x.SetRateLimit(2, time.Hour)
+
+	for i, want := range []string{"1", "0"} {
+		resp, _ := get(t, x, "/users/42/tweets", nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("x-rate-limit-remaining") != want {
+			t.Errorf("request %d returned %d with %s remaining, want 200 with %s", i, resp.StatusCode, resp.Header.Get("x-rate-limit-remaining"), want)
+		}
+	}
+
+	resp, _ := get(t, x, "/users/42/tweets", nil)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit returned %d, want 429", resp.StatusCode)
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("x-rate-limit-reset"), 10, 64)
+	if err != nil || time.Until(time.Unix(reset, 0)) < 59*time.Minute {
+		t.Errorf("reset = %q, want the end of the hour window", resp.Header.Get("x-rate-limit-reset"))
+	}
+}
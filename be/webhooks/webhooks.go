@@ -0,0 +1,181 @@
+// Package webhooks signs and sends outbound webhook deliveries.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-SocialTracker-Event"
+	HeaderEventID   = "X-SocialTracker-Event-ID"
+	HeaderDelivery  = "X-SocialTracker-Delivery"
+	HeaderSignature = "X-SocialTracker-Signature"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is marked failed
+	MaxAttempts = 10
+
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = 12 * time.Hour
+	maxResponseBody = 1024
+)
+
+// Envelope is the JSON body of a delivery
+type Envelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Result is the outcome of one delivery attempt
+type Result struct {
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+// OK reports whether the receiver accepted the delivery with a 2xx response
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Error describes why the attempt failed, or "" if it succeeded
+func (r Result) Error() string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	if !r.OK() {
+		return fmt.Sprintf("receiver responded with status %d", r.StatusCode)
+	}
+	return ""
+}
+
+// Backoff returns how long to wait before retrying after the given number of
+// failed attempts: 30s, 1m, 2m, ... capped at 12h
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Sign returns the signature header value for a body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">"
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + signature(secret, t, body)
+}
+
+func signature(secret, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header against the body. Signatures older than
+// tolerance are rejected to prevent replays; zero disables the check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+	if t == "" || v1 == "" {
+		return errors.New("malformed signature header")
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return errors.New("signature timestamp too old")
+	}
+
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, t, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// Sender posts deliveries to their webhook URLs
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender creates a sender. A nil client uses one with a 10 second timeout.
+func NewSender(client *http.Client) *Sender {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Sender{client: client}
+}
+
+// Send makes one delivery attempt
+func (s *Sender) Send(ctx context.Context, delivery models.PendingWebhookDelivery) Result {
+	body, err := json.Marshal(Envelope{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SocialTracker-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderEventID, delivery.EventID)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, time.Now(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return Result{StatusCode: resp.StatusCode, Err: err}
+	}
+	// Stored in a TEXT column, which rejects NUL bytes and invalid UTF-8
+	text := strings.ToValidUTF8(strings.ReplaceAll(string(respBody), "\x00", ""), "")
+	return Result{StatusCode: resp.StatusCode, Body: text}
+}
@@ -0,0 +1,172 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Armatorix/SocialTracker/be/models"
+)
+
+const testSecret = "whsec_test-secret-value"
+
+// receivedRequest is what the local receiver saw
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver starts a local HTTP receiver that records requests and answers with status
+func newReceiver(t *testing.T, status int) (*httptest.Server, <-chan receivedRequest) {
+	t.Helper()
+	received := make(chan receivedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+		w.Write([]byte("ack"))
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func testDelivery(url string) models.PendingWebhookDelivery {
+	return models.PendingWebhookDelivery{
+		WebhookDelivery: models.WebhookDelivery{
+			ID:        42,
+			WebhookID: 7,
+			EventID:   "7f1c2b0e-8a6d-4c3e-9b1a-2d5e6f708192",
+			EventType: models.WebhookEventContentCreated,
+			Payload:   json.RawMessage(`{"id":1,"link":"https://x.com/creator/status/1"}`),
+			CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		URL:    url,
+		Secret: testSecret,
+	}
+}
+
+func TestSendDeliversSignedEnvelope(t *testing.T) {
+	server, received := newReceiver(t, http.StatusNoContent)
+
+	result := NewSender(nil).Send(context.Background(), testDelivery(server.URL))
+	if !result.OK() {
+		t.Fatalf("expected success, got status %d: %s", result.StatusCode, result.Error())
+	}
+
+	req := <-received
+	if got := req.header.Get(HeaderEvent); got != models.WebhookEventContentCreated {
+		t.Errorf("event header = %q", got)
+	}
+	if got := req.header.Get(HeaderEventID); got != "7f1c2b0e-8a6d-4c3e-9b1a-2d5e6f708192" {
+		t.Errorf("event id header = %q", got)
+	}
+	if got := req.header.Get(HeaderDelivery); got != "42" {
+		t.Errorf("delivery header = %q", got)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("content type = %q", got)
+	}
+	if err := Verify(testSecret, req.header.Get(HeaderSignature), req.body, time.Minute); err != nil {
+		t.Errorf("signature did not verify: %v", err)
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal(req.body, &envelope); err != nil {
+		t.Fatalf("body is not an envelope: %v", err)
+	}
+	if envelope.Type != models.WebhookEventContentCreated || envelope.ID == "" {
+		t.Errorf("unexpected envelope %+v", envelope)
+	}
+	if string(envelope.Data) != `{"id":1,"link":"https://x.com/creator/status/1"}` {
+		t.Errorf("data = %s", envelope.Data)
+	}
+}
+
+func TestSendReportsReceiverErrors(t *testing.T) {
+	server, received := newReceiver(t, http.StatusInternalServerError)
+
+	result := NewSender(nil).Send(context.Background(), testDelivery(server.URL))
+	<-received
+	if result.OK() {
+		t.Fatal("expected a 500 response to fail")
+	}
+	if result.StatusCode != http.StatusInternalServerError || result.Body != "ack" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !strings.Contains(result.Error(), "500") {
+		t.Errorf("error = %q", result.Error())
+	}
+}
+
+func TestSendReportsConnectionErrors(t *testing.T) {
+	server, _ := newReceiver(t, http.StatusOK)
+	url := server.URL
+	server.Close()
+
+	result := NewSender(nil).Send(context.Background(), testDelivery(url))
+	if result.OK() || result.Err == nil || result.StatusCode != 0 {
+		t.Errorf("expected a connection error, got %+v", result)
+	}
+}
+
+func TestSendTimesOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	sender := NewSender(&http.Client{Timeout: 20 * time.Millisecond})
+	if result := sender.Send(context.Background(), testDelivery(server.URL)); result.Err == nil {
+		t.Errorf("expected a timeout, got %+v", result)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	body := []byte(`{"type":"content.created"}`)
+	header := Sign(testSecret, time.Now(), body)
+
+	if err := Verify(testSecret, header, body, time.Minute); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := Verify(testSecret, header, []byte(`{"type":"content.deleted"}`), time.Minute); err == nil {
+		t.Error("tampered body accepted")
+	}
+	if err := Verify("another-secret-value", header, body, time.Minute); err == nil {
+		t.Error("wrong secret accepted")
+	}
+	if err := Verify(testSecret, "v1=abc", body, time.Minute); err == nil {
+		t.Error("header without timestamp accepted")
+	}
+
+	old := Sign(testSecret, time.Now().Add(-time.Hour), body)
+	if err := Verify(testSecret, old, body, 5*time.Minute); err == nil {
+		t.Error("stale signature accepted")
+	}
+	if err := Verify(testSecret, old, body, 0); err != nil {
+		t.Errorf("stale signature rejected without tolerance: %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{9, 128 * time.Minute},
+		{20, 12 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
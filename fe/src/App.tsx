@@ -110,7 +110,7 @@ export function App() {
       
       {/* Main */}
       <main className="flex-1 py-8">
-        {user.role === 'admin' ? <AdminDashboard /> : <CreatorDashboard />}
+        {user.role === 'admin' || user.role === 'manager' ? <AdminDashboard /> : <CreatorDashboard />}
       </main>
       
       {/* Footer */}
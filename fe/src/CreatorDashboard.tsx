@@ -154,8 +154,11 @@ export function CreatorDashboard() {
       setError('');
       const result = await api.pullContent(accountId);
       let message = result.message;
-      if (result.synced_count > 0 || result.skipped_count > 0) {
+      if (result.synced_count > 0 || result.skipped_count > 0 || result.updated_count > 0) {
         message = `Synced ${result.synced_count} new posts from @${result.account_name}`;
+        if (result.updated_count > 0) {
+          message += `, updated ${result.updated_count} edited posts`;
+        }
         if (result.skipped_count > 0) {
           message += ` (${result.skipped_count} duplicates skipped)`;
         }
@@ -1,7 +1,19 @@
-import type { User, SocialAccount, Content, ContentWithUser, CreateSocialAccountRequest, CreateContentRequest, SyncResponse } from './types';
+import type { User, SocialAccount, Content, ContentWithUser, CreateSocialAccountRequest, CreateContentRequest, SyncResponse, PullJob, ContentRevision, RelationFilters } from './types';
 
 const API_BASE_URL = '/api';
 
+// How often a pull job is checked while it runs, in milliseconds
+const PULL_JOB_POLL_INTERVAL = 1000;
+
+// Query parameters of the relation filters of content listings
+const relationParams = (filters?: RelationFilters): URLSearchParams => {
+  const params = new URLSearchParams();
+  if (filters?.collapse_threads) params.append('collapse_threads', 'true');
+  if (filters?.exclude_replies) params.append('exclude_replies', 'true');
+  if (filters?.exclude_retweets) params.append('exclude_retweets', 'true');
+  return params;
+};
+
 // Helper to format retry time in a human-readable way
 const formatRetryTime = (seconds: number): string => {
   if (seconds < 60) {
@@ -78,43 +90,52 @@ export const api = {
     if (!res.ok) throw new Error('Failed to delete social account');
   },
 
+  // Queues a pull of the account and waits for the job to finish
   pullContent: async (accountId: number): Promise<SyncResponse> => {
     const res = await fetchWithCredentials(`${API_BASE_URL}/social-accounts/${accountId}/pull`, {
       method: 'POST',
     });
-    if (res.status === 429) {
-      const data = await res.json();
-      const retryAfter = data.retry_after || 900;
-      throw new RateLimitError(
-        `X/Twitter rate limit exceeded. Please try again in ${formatRetryTime(retryAfter)}.`,
-        retryAfter
-      );
-    }
     if (!res.ok) {
-      // Try to get error details from response
       try {
         const data = await res.json();
-        const errorMsg = data.error || 'Failed to pull content';
-        // Check if error message indicates rate limiting
-        if (errorMsg.toLowerCase().includes('rate limit') || errorMsg.includes('429') || errorMsg.includes('too many')) {
-          throw new RateLimitError(
-            `X/Twitter rate limit exceeded. Please try again later.`,
-            900
-          );
-        }
-        throw new Error(errorMsg);
+        throw new Error(data.error || 'Failed to pull content');
       } catch (parseErr) {
-        if (parseErr instanceof RateLimitError) throw parseErr;
-        if (isRateLimitError(parseErr)) throw parseErr;
+        if (parseErr instanceof Error && parseErr.message) throw parseErr;
         throw new Error('Failed to pull content');
       }
     }
+
+    let job: PullJob = await res.json();
+    while (job.status === 'queued' || job.status === 'running') {
+      // A queued job that has already failed was deferred by the rate limit
+      if (job.status === 'queued' && job.error) {
+        const retryAfter = Math.max(Math.ceil((new Date(job.run_at).getTime() - Date.now()) / 1000), 1);
+        throw new RateLimitError(
+          `X/Twitter rate limit exceeded. The pull is queued and will run in ${formatRetryTime(retryAfter)}.`,
+          retryAfter
+        );
+      }
+      await new Promise((resolve) => setTimeout(resolve, PULL_JOB_POLL_INTERVAL));
+      job = await api.getJob(job.id);
+    }
+
+    if (job.status === 'failed' || !job.result) {
+      throw new Error(job.error || 'Failed to pull content');
+    }
+    return job.result;
+  },
+
+  // Jobs
+  getJob: async (jobId: number): Promise<PullJob> => {
+    const res = await fetchWithCredentials(`${API_BASE_URL}/jobs/${jobId}`);
+    if (!res.ok) throw new Error('Failed to fetch job');
     return res.json();
   },
 
   // Content
-  getContent: async (): Promise<Content[]> => {
-    const res = await fetchWithCredentials(`${API_BASE_URL}/content`);
+  getContent: async (filters?: RelationFilters): Promise<Content[]> => {
+    const params = relationParams(filters);
+    const res = await fetchWithCredentials(`${API_BASE_URL}/content${params.toString() ? '?' + params.toString() : ''}`);
     if (!res.ok) throw new Error('Failed to fetch content');
     return res.json();
   },
@@ -136,9 +157,15 @@ export const api = {
     if (!res.ok) throw new Error('Failed to delete content');
   },
 
+  getContentRevisions: async (id: number): Promise<ContentRevision[]> => {
+    const res = await fetchWithCredentials(`${API_BASE_URL}/content/${id}/revisions`);
+    if (!res.ok) throw new Error('Failed to fetch content revisions');
+    return res.json();
+  },
+
   // Admin
-  getAllContent: async (filters?: { platform?: string; username?: string }): Promise<ContentWithUser[]> => {
-    const params = new URLSearchParams();
+  getAllContent: async (filters?: { platform?: string; username?: string } & RelationFilters): Promise<ContentWithUser[]> => {
+    const params = relationParams(filters);
     if (filters?.platform) params.append('platform', filters.platform);
     if (filters?.username) params.append('username', filters.username);
     
@@ -3,7 +3,7 @@ export interface User {
   user_id: string;
   email: string;
   username: string;
-  role: 'admin' | 'creator';
+  role: 'admin' | 'manager' | 'creator';
   created_at: string;
   updated_at: string;
 }
@@ -31,10 +31,48 @@ export interface Content {
   tags?: string[];
   external_post_id?: string;
   posted_at?: string;
+  edit_history_ids?: string[];
+  edited_at?: string;
+  removed_on_platform_at?: string;
+  media?: ContentMedia[];
+  conversation_id?: string;
+  relation?: 'thread' | 'reply' | 'quote' | 'retweet';
+  parent_external_id?: string;
+  parent_content_id?: number;
   created_at: string;
   updated_at: string;
 }
 
+export interface RelationFilters {
+  collapse_threads?: boolean;
+  exclude_replies?: boolean;
+  exclude_retweets?: boolean;
+}
+
+export interface ContentMedia {
+  id: number;
+  content_id: number;
+  media_key: string;
+  type: 'photo' | 'video' | 'animated_gif';
+  url?: string;
+  preview_url?: string;
+  width?: number;
+  height?: number;
+  duration_ms?: number;
+  alt_text?: string;
+  archive_key?: string;
+  archived_at?: string;
+  created_at: string;
+}
+
+export interface ContentRevision {
+  id: number;
+  content_id: number;
+  external_post_id?: string;
+  original_text?: string;
+  revised_at: string;
+}
+
 export interface ContentWithUser extends Content {
   username: string;
   email: string;
@@ -63,6 +101,26 @@ export interface SyncResponse {
   account_name: string;
   synced_count: number;
   skipped_count: number;
+  updated_count: number;
   errors?: string[];
   message: string;
 }
+
+export interface PullJob {
+  id: number;
+  user_id: number;
+  social_account_id: number;
+  status: 'queued' | 'running' | 'succeeded' | 'failed';
+  attempts: number;
+  run_at: string;
+  progress: {
+    fetched: number;
+    processed: number;
+  };
+  result?: SyncResponse;
+  error?: string;
+  started_at?: string;
+  finished_at?: string;
+  created_at: string;
+  updated_at: string;
+}